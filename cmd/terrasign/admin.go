@@ -1,13 +1,16 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
+	"text/tabwriter"
 	"time"
 
+	"github.com/sulakshanakarunarathne/terrasign/pkg/planfile"
+	"github.com/sulakshanakarunarathne/terrasign/pkg/policy"
 	"github.com/sulakshanakarunarathne/terrasign/pkg/remote"
 	"github.com/sulakshanakarunarathne/terrasign/pkg/signer"
 )
@@ -20,7 +23,7 @@ type AdminCommands struct {
 // NewAdminCommands creates admin command handler
 func NewAdminCommands(serviceURL string) *AdminCommands {
 	return &AdminCommands{
-		client: remote.NewClient(serviceURL),
+		client: newClient(serviceURL),
 	}
 }
 
@@ -40,16 +43,74 @@ func (a *AdminCommands) ListPending() error {
 	for _, sub := range submissions {
 		fmt.Printf("ID: %s\n", sub.ID)
 		fmt.Printf("  Submitter: %s\n", sub.Submitter)
+		if sub.PlanHash != "" {
+			fmt.Printf("  Plan hash: sha256:%s\n", sub.PlanHash)
+		}
 		fmt.Printf("  Created:   %s\n", sub.CreatedAt.Format(time.RFC3339))
 		fmt.Printf("  Status:    %s\n", sub.Status)
+		if sub.Risk != nil {
+			fmt.Printf("  Risk:      %s - %s\n", sub.Risk, sub.Risk.Summary())
+		}
+		if sub.RequiredApprovals > 0 {
+			fmt.Printf("  Approvals: %d/%d\n", len(sub.Approvals), sub.RequiredApprovals)
+		}
+		switch {
+		case sub.Environment != "":
+			fmt.Printf("  Env:       %s (from %s)\n", sub.Environment, sub.EnvironmentSource)
+		case sub.EnvironmentSource == remote.EnvironmentUnverified:
+			fmt.Println("  Env:       unverified (strictest quorum)")
+		}
+		if sub.ExpiresAt != nil {
+			fmt.Printf("  Expires:   %s\n", sub.ExpiresAt.Format(time.RFC3339))
+		}
 		fmt.Println()
 	}
 
 	return nil
 }
 
-// Inspect shows what changes are in a plan
-func (a *AdminCommands) Inspect(id string) error {
+// List prints submissions matching a query, one per line. With all it
+// follows the cursor through every page; otherwise it prints the cursor
+// for the next page.
+func (a *AdminCommands) List(q remote.SubmissionQuery, all bool) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSTATUS\tRISK\tSUBMITTER\tENVIRONMENT\tAPPROVALS\tCREATED")
+
+	shown := 0
+	for {
+		page, err := a.client.ListSubmissions(q)
+		if err != nil {
+			return fmt.Errorf("failed to list submissions: %w", err)
+		}
+
+		for _, sub := range page.Submissions {
+			approvals := "-"
+			if sub.RequiredApprovals > 0 {
+				approvals = fmt.Sprintf("%d/%d", len(sub.Approvals), sub.RequiredApprovals)
+			}
+			environment := sub.Environment
+			if environment == "" {
+				environment = "-"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", sub.ID, sub.Status, sub.Risk, sub.Submitter, environment, approvals, sub.CreatedAt.Format(time.RFC3339))
+		}
+		shown += len(page.Submissions)
+
+		if page.NextCursor == "" || !all {
+			w.Flush()
+			fmt.Printf("\nShowing %d of %d submission(s)\n", shown, page.Total)
+			if page.NextCursor != "" {
+				fmt.Printf("Next page: --cursor %s\n", page.NextCursor)
+			}
+			return nil
+		}
+		q.Cursor = page.NextCursor
+	}
+}
+
+// Inspect shows what changes are in a plan, optionally only those of some
+// modules or resource types
+func (a *AdminCommands) Inspect(id string, filter diffFilter) error {
 	fmt.Printf("Inspecting plan %s...\n\n", id)
 
 	// Download the plan to a temp location
@@ -64,53 +125,113 @@ func (a *AdminCommands) Inspect(id string) error {
 		return fmt.Errorf("failed to download plan: %w", err)
 	}
 
-	// Run terraform show to display the changes
-	// We need to run this from an initialized terraform directory
-	// Get absolute path to examples/simple-app
-	cwd, err := os.Getwd()
+	// Plans submitted as JSON alone are read from the JSON
+	in, err := planfile.NewInput(planPath, "")
 	if err != nil {
-		return fmt.Errorf("failed to get working directory: %w", err)
+		return err
 	}
-	
-	// Try to find the project root by looking for go.mod
-	projectRoot := cwd
-	for {
-		if _, err := os.Stat(filepath.Join(projectRoot, "go.mod")); err == nil {
-			break
+	plan, _, err := in.Load()
+	if err != nil {
+		return err
+	}
+	printPlanSummary(plan, filter)
+
+	// The risk the service scored at submission
+	if submission, err := a.client.GetStatus(id); err == nil && submission.Risk != nil {
+		printRisk(submission.Risk)
+	}
+	return nil
+}
+
+// printPlanSummary prints the changes, prior state and configuration of a
+// plan read natively, without terraform. The filter narrows the resource
+// changes shown.
+func printPlanSummary(plan *planfile.Plan, filter diffFilter) {
+	status := "applyable"
+	switch {
+	case plan.Errored:
+		status = "errored"
+	case !plan.Applyable:
+		status = "no changes to apply"
+	}
+	fmt.Printf("Terraform %s plan (%s)\n", plan.TerraformVersion, status)
+
+	fmt.Println()
+	renderPlanDiff(os.Stdout, plan, filter, useColor(os.Stdout))
+
+	// Outputs belong to the root module and have no resource type
+	if len(plan.OutputChanges) > 0 && filter.empty() {
+		fmt.Println("\nOutput changes:")
+		for _, oc := range plan.OutputChanges {
+			line := fmt.Sprintf("  %-4s %s", actionSymbol(oc.Change.Actions), oc.Name)
+			if oc.Sensitive {
+				line += " (sensitive)"
+			}
+			fmt.Println(line)
 		}
-		parent := filepath.Dir(projectRoot)
-		if parent == projectRoot {
-			// Reached filesystem root, use cwd
-			projectRoot = cwd
-			break
+	}
+
+	if plan.PriorState != nil {
+		fmt.Printf("\nPrior state: %d resources (serial %d)\n", len(plan.PriorState.Resources), plan.PriorState.Serial)
+	}
+
+	if plan.Config != nil && len(plan.Config.Modules) > 0 {
+		fmt.Println("\nConfiguration:")
+		for _, m := range plan.Config.Modules {
+			name := "root"
+			if m.Key != "" {
+				name = "module." + strings.ReplaceAll(m.Key, ".", ".module.")
+			}
+			source := m.Dir
+			if m.Source != "" {
+				source = m.Source
+				if m.Version != "" {
+					source += " " + m.Version
+				}
+			}
+			fmt.Printf("  %s (%s): %s\n", name, source, strings.Join(m.FileNames(), ", "))
 		}
-		projectRoot = parent
-	}
-	
-	// Use a dummy directory for terraform show if a specific one isn't needed for the command itself
-	// The original code used `terraformDir` for `cmd.Dir`.
-	// The new code snippet implies `cmd.Dir = tfDir` but `tfDir` is not defined.
-	// To maintain functionality and syntactic correctness, we'll keep the `terraformDir` calculation
-	// and use it for `cmd.Dir`.
-	terraformDir := filepath.Join(projectRoot, "examples", "simple-app")
-	
-	// Run terraform show
-	cmd := exec.Command("terraform", "show", planPath)
-	cmd.Dir = terraformDir // Keep the original working directory for terraform command
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		// Check if it's a version mismatch error
-		if strings.Contains(string(output), "plan file was created by Terraform") {
-			fmt.Println("\n[WARNING] Terraform version mismatch - cannot inspect plan details")
-			fmt.Println("This plan was created with a different Terraform version.")
-			fmt.Println("You can still sign it, but detailed inspection is unavailable.")
-			return nil
+	}
+}
+
+// printRisk prints a submission's risk score and what contributed to it
+func printRisk(risk *remote.RiskAssessment) {
+	fmt.Printf("\nRisk: %s\n", risk)
+	for _, f := range risk.Factors {
+		line := fmt.Sprintf("  %-10s +%-3d", f.Factor, f.Points)
+		if f.Detail != "" {
+			line += " " + f.Detail
+		} else if len(f.Resources) > 0 {
+			line += " " + strings.Join(f.Resources, ", ")
+			if more := f.Count - len(f.Resources); more > 0 {
+				line += fmt.Sprintf(" (and %d more)", more)
+			}
+		} else {
+			line += fmt.Sprintf(" %d resources", f.Count)
 		}
-		return fmt.Errorf("failed to show plan: %w\nOutput: %s", err, output)
+		fmt.Println(line)
 	}
-	
-	fmt.Println(string(output))
-	return nil
+}
+
+// actionSymbol returns the symbol terraform shows for a change's actions
+func actionSymbol(actions []string) string {
+	switch strings.Join(actions, ",") {
+	case "create":
+		return "+"
+	case "delete":
+		return "-"
+	case "update":
+		return "~"
+	case "delete,create":
+		return "-/+"
+	case "create,delete":
+		return "+/-"
+	case "read":
+		return "<="
+	case "forget":
+		return "."
+	}
+	return " "
 }
 
 // Download downloads a plan for review
@@ -128,8 +249,19 @@ func (a *AdminCommands) Download(id, outputDir string) error {
 	}
 
 	fmt.Printf("Plan downloaded to: %s\n", planPath)
+
+	// The plan JSON submitted with the plan, when there is one
+	jsonPath := planPath + ".json"
+	err := a.client.DownloadPlanJSON(id, jsonPath)
+	switch {
+	case errors.Is(err, remote.ErrNotFound):
+	case err != nil:
+		return fmt.Errorf("failed to download plan JSON: %w", err)
+	default:
+		fmt.Printf("Plan JSON downloaded to: %s\n", jsonPath)
+	}
 	fmt.Println("\nReview the plan with:")
-	fmt.Printf("  terraform show %s\n", planPath)
+	fmt.Printf("  terrasign admin inspect %s\n", id)
 	fmt.Println("\nIf approved, sign with:")
 	fmt.Printf("  terrasign admin sign %s --key <admin-key>\n", id)
 
@@ -137,7 +269,7 @@ func (a *AdminCommands) Download(id, outputDir string) error {
 }
 
 // Sign signs an approved plan
-func (a *AdminCommands) Sign(id, keyPath, reviewer string) error {
+func (a *AdminCommands) Sign(id, keyPath, reviewer string, acknowledge []string) error {
 	fmt.Printf("Signing plan %s...\n", id)
 
 	// Download the plan first
@@ -151,11 +283,36 @@ func (a *AdminCommands) Sign(id, keyPath, reviewer string) error {
 		return fmt.Errorf("failed to download plan: %w", err)
 	}
 
-	// Sign the plan (skip policy check since it was done during submission)
-	if err := signer.SignWithOptions(planPath, keyPath, true); err != nil {
+	// A plan submitted with its JSON is signed as the binding of both
+	opts := signer.Options{KeyPath: keyPath, SkipPolicy: true} // Policy check was done during submission
+	if len(acknowledge) > 0 {
+		opts.Acknowledgement = policy.NewAcknowledgement(acknowledge, reviewer)
+	}
+	jsonPath := planPath + ".json"
+	err := a.client.DownloadPlanJSON(id, jsonPath)
+	switch {
+	case errors.Is(err, remote.ErrNotFound):
+	case err != nil:
+		return fmt.Errorf("failed to download plan JSON: %w", err)
+	default:
+		opts.PlanJSON = jsonPath
+	}
+
+	if err := signer.SignPlan(planPath, opts); err != nil {
 		return fmt.Errorf("failed to sign plan: %w", err)
 	}
 
+	// Upload the attestations first; the signature may complete the quorum
+	for _, kind := range remote.AttestationKinds {
+		attestationPath := planPath + "." + kind
+		if _, err := os.Stat(attestationPath); err != nil {
+			continue
+		}
+		if err := a.client.UploadAttestation(id, kind, attestationPath); err != nil {
+			return fmt.Errorf("failed to upload %s attestation: %w", kind, err)
+		}
+	}
+
 	// Upload the signature
 	sigPath := planPath + ".sig"
 	if err := a.client.UploadSignature(id, sigPath); err != nil {
@@ -163,17 +320,23 @@ func (a *AdminCommands) Sign(id, keyPath, reviewer string) error {
 	}
 
 	fmt.Printf("Plan %s signed successfully by %s\n", id, reviewer)
+
+	// Report quorum progress; the plan stays pending until enough reviewers sign
+	if submission, err := a.client.GetStatus(id); err == nil && submission.Status == "pending" {
+		fmt.Printf("Approvals: %d/%d (waiting for more reviewers)\n", len(submission.Approvals), submission.RequiredApprovals)
+	}
 	return nil
 }
 
 // Reject rejects a plan submission
-func (a *AdminCommands) Reject(id, reason string) error {
+func (a *AdminCommands) Reject(id, reviewer, reason string) error {
 	fmt.Printf("Rejecting plan %s...\n", id)
+
+	if err := a.client.RejectPlan(id, reviewer, reason); err != nil {
+		return fmt.Errorf("failed to reject plan: %w", err)
+	}
+
+	fmt.Printf("Plan %s rejected by %s\n", id, reviewer)
 	fmt.Printf("Reason: %s\n", reason)
-	
-	// In a full implementation, this would call an API endpoint
-	// For now, we just print the message
-	fmt.Println("Note: Rejection functionality requires server-side implementation")
-	
 	return nil
 }
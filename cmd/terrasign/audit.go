@@ -0,0 +1,243 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/sulakshanakarunarathne/terrasign/pkg/remote"
+	"github.com/sulakshanakarunarathne/terrasign/pkg/verifier"
+)
+
+func handleAudit() {
+	if len(os.Args) < 3 {
+		fmt.Println("Usage: terrasign audit <command> [args]")
+		fmt.Println("\nCommands:")
+		fmt.Println("  list      Show audit log entries")
+		fmt.Println("  verify    Check the audit log hash chain (and signatures with --key)")
+		os.Exit(1)
+	}
+
+	switch os.Args[2] {
+	case "list":
+		handleAuditList()
+	case "verify":
+		handleAuditVerify()
+	default:
+		fmt.Printf("Unknown audit command: %s\n", os.Args[2])
+		os.Exit(1)
+	}
+}
+
+func handleAuditList() {
+	fs := flag.NewFlagSet("audit list", flag.ExitOnError)
+	srv := fs.String("service", defaultServiceURL, "Service URL")
+	action := fs.String("action", "", "Only entries for this action (e.g. submit, download, sign, reject, lockdown.on)")
+	actor := fs.String("actor", "", "Only entries by this actor")
+	submission := fs.String("submission", "", "Only entries for this submission ID")
+	after := fs.Uint64("after", 0, "Only entries after this sequence number")
+	limit := fs.Int("limit", 50, "Entries per page")
+	all := fs.Bool("all", false, "Fetch every page")
+	fs.Parse(os.Args[3:])
+
+	client := newClient(*srv)
+	q := remote.AuditQuery{After: *after, Action: *action, Actor: *actor, Submission: *submission, Limit: *limit}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SEQ\tTIME\tACTION\tACTOR\tSUBMISSION\tDETAILS")
+	for {
+		page, err := client.ListAudit(q)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		for _, e := range page.Entries {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n", e.Seq, e.Time.Format("2006-01-02 15:04:05"), e.Action, e.Actor, e.Submission, formatDetails(e.Details))
+		}
+		if page.NextAfter == 0 {
+			break
+		}
+		if !*all {
+			w.Flush()
+			fmt.Printf("\nMore entries: terrasign audit list --after %d\n", page.NextAfter)
+			return
+		}
+		q.After = page.NextAfter
+	}
+	w.Flush()
+}
+
+// formatDetails renders entry details as sorted key=value pairs
+func formatDetails(details map[string]string) string {
+	keys := make([]string, 0, len(details))
+	for k := range details {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		if details[k] != "" {
+			parts = append(parts, fmt.Sprintf("%s=%q", k, details[k]))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func handleAuditVerify() {
+	fs := flag.NewFlagSet("audit verify", flag.ExitOnError)
+	srv := fs.String("service", defaultServiceURL, "Service URL")
+	storage := fs.String("storage", "", "Read the log straight from the service storage (as given to server --storage) instead of the service")
+	keys := fs.String("key", "", "Audit public key(s), comma-separated; every entry must be signed by one of them")
+	checkpoint := fs.String("checkpoint", "", "File holding the head seen by the last verification; detects truncation and rewrites since then, and is updated on success")
+	fs.Parse(os.Args[3:])
+
+	var trusted []verifier.TrustedKey
+	if *keys != "" {
+		var err error
+		if trusted, err = verifier.LoadTrustedKeys(splitList(*keys)); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	var previous *remote.AuditHead
+	if *checkpoint != "" {
+		data, err := os.ReadFile(*checkpoint)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			// First run; the checkpoint is written below
+		case err != nil:
+			fmt.Printf("Error reading checkpoint: %v\n", err)
+			os.Exit(1)
+		default:
+			previous = &remote.AuditHead{}
+			if err := json.Unmarshal(data, previous); err != nil {
+				fmt.Printf("Error parsing checkpoint %s: %v\n", *checkpoint, err)
+				os.Exit(1)
+			}
+		}
+	}
+
+	var source auditSource = newClient(*srv)
+	if *storage != "" {
+		store, err := remote.OpenStore(*storage)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		defer store.Close()
+		source = storeAuditSource{store}
+	}
+
+	head, err := verifyAuditLog(source, trusted, previous)
+	if err != nil {
+		fmt.Printf("[ERROR] Audit log verification failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("[OK] Audit log verified: %d entries, head %s\n", head.Seq, head.Hash)
+	if len(trusted) > 0 {
+		fmt.Println("[OK] Every entry is signed by the audit key")
+	} else {
+		fmt.Println("[WARN] Signatures not checked (use --key with the service's audit public key)")
+	}
+	if previous != nil {
+		fmt.Printf("[OK] Log still contains checkpoint entry %d\n", previous.Seq)
+	}
+
+	if *checkpoint != "" {
+		data, _ := json.MarshalIndent(head, "", "  ")
+		if err := os.WriteFile(*checkpoint, data, 0644); err != nil {
+			fmt.Printf("Error writing checkpoint: %v\n", err)
+			os.Exit(1)
+		}
+	}
+}
+
+// auditSource is where audit verify reads the log: the service, or its
+// storage when the service is down
+type auditSource interface {
+	ListAudit(q remote.AuditQuery) (*remote.AuditPage, error)
+}
+
+// storeAuditSource pages through the audit log in a storage backend
+type storeAuditSource struct {
+	store remote.Store
+}
+
+// ListAudit implements auditSource; the head is reported as unknown
+func (s storeAuditSource) ListAudit(q remote.AuditQuery) (*remote.AuditPage, error) {
+	records, err := s.store.ReadRecords("audit", q.After, q.Limit)
+	if err != nil {
+		return nil, err
+	}
+	page := &remote.AuditPage{}
+	for _, record := range records {
+		var e remote.AuditEntry
+		if err := json.Unmarshal(record, &e); err != nil {
+			return nil, fmt.Errorf("failed to parse audit entry after %d: %w", q.After, err)
+		}
+		page.Entries = append(page.Entries, e)
+	}
+	if len(records) == q.Limit {
+		page.NextAfter = page.Entries[len(page.Entries)-1].Seq
+	}
+	return page, nil
+}
+
+// verifyAuditLog fetches the whole log and checks its chain against the
+// server's head and, when given, a previously verified head
+func verifyAuditLog(client auditSource, keys []verifier.TrustedKey, previous *remote.AuditHead) (remote.AuditHead, error) {
+	var head remote.AuditHead
+	var serverHead remote.AuditHead
+	checkpointSeen := false
+
+	q := remote.AuditQuery{Limit: 1000}
+	for {
+		page, err := client.ListAudit(q)
+		if err != nil {
+			return head, err
+		}
+		if q.After == 0 {
+			serverHead = page.Head
+		}
+
+		head, err = remote.VerifyAuditChain(head, page.Entries, keys)
+		if err != nil {
+			return head, err
+		}
+		if previous != nil {
+			for _, e := range page.Entries {
+				if e.Seq == previous.Seq {
+					if e.Hash != previous.Hash {
+						return head, fmt.Errorf("entry %d differs from the checkpoint: the log was rewritten", e.Seq)
+					}
+					checkpointSeen = true
+				}
+			}
+		}
+
+		if page.NextAfter == 0 {
+			break
+		}
+		q.After = page.NextAfter
+	}
+
+	if head.Seq < serverHead.Seq {
+		return head, fmt.Errorf("server reports %d entries but only %d were returned", serverHead.Seq, head.Seq)
+	}
+	if serverHead.Seq > 0 && head.Seq == serverHead.Seq && head.Hash != serverHead.Hash {
+		return head, fmt.Errorf("last entry does not match the head reported by the server")
+	}
+	if previous != nil && previous.Seq > 0 && !checkpointSeen {
+		return head, fmt.Errorf("log ends at entry %d but the checkpoint saw entry %d: the log was truncated", head.Seq, previous.Seq)
+	}
+
+	return head, nil
+}
@@ -1,83 +1,220 @@
 package main
 
 import (
+	"bytes"
+	"crypto/ed25519"
+	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
+	"time"
 
 	"github.com/sulakshanakarunarathne/terrasign/pkg/remote"
+	"github.com/sulakshanakarunarathne/terrasign/pkg/signer"
 )
 
 func handleLockdown() {
 	if len(os.Args) < 3 {
-		fmt.Println("Usage: terrasign lockdown [on|off] [--service <url>] [--key <path>] [--recovery-code <code>]")
+		fmt.Println("Usage: terrasign lockdown [on|off|status] [--service <url>] [--scope global|workspace|submitter] [--target <name>]")
+		fmt.Println("                          [--reason <text>] [--duration <e.g. 4h>] [--key <admin-private-key> | --share <file>...]")
+		fmt.Println("       terrasign lockdown init-break-glass [--shares 5] [--threshold 3] [--out <dir>]")
 		os.Exit(1)
 	}
 
 	mode := os.Args[2]
-	if mode != "on" && mode != "off" {
-		fmt.Println("Error: mode must be 'on' or 'off'")
+	if mode == "init-break-glass" {
+		handleInitBreakGlass()
+		return
+	}
+	if mode != "on" && mode != "off" && mode != "status" {
+		fmt.Println("Error: mode must be 'on', 'off' or 'status'")
 		os.Exit(1)
 	}
 
 	serviceURL := defaultServiceURL
 	keyPath := ""
 	recoveryCode := ""
+	var shares []string
+	req := remote.LockdownRequest{Mode: mode, By: os.Getenv("USER")}
 	
 	for i, arg := range os.Args {
+		if i+1 >= len(os.Args) {
+			break
+		}
+		switch arg {
+		case "--scope":
+			req.Scope = os.Args[i+1]
+		case "--target":
+			req.Target = os.Args[i+1]
+		case "--reason":
+			req.Reason = os.Args[i+1]
+		case "--duration":
+			d, err := time.ParseDuration(os.Args[i+1])
+			if err != nil {
+				fmt.Printf("Error: invalid --duration: %v\n", err)
+				os.Exit(1)
+			}
+			req.Duration = d
+		}
 		if arg == "--service" && i+1 < len(os.Args) {
 			serviceURL = os.Args[i+1]
 		}
 		if arg == "--key" && i+1 < len(os.Args) {
 			keyPath = os.Args[i+1]
 		}
+		if arg == "--share" && i+1 < len(os.Args) {
+			shares = append(shares, os.Args[i+1])
+		}
 		if arg == "--recovery-code" && i+1 < len(os.Args) {
 			recoveryCode = os.Args[i+1]
 		}
 	}
 
-	// Lockdown OFF requires authentication
+	if mode == "status" {
+		lockdowns, err := newClient(serviceURL).ListLockdowns()
+		if err != nil {
+			fmt.Printf("Error getting lockdown status: %v\n", err)
+			os.Exit(1)
+		}
+		if len(lockdowns) == 0 {
+			fmt.Println("[OK] No active lockdowns")
+			return
+		}
+		fmt.Printf("%d active lockdown(s):\n", len(lockdowns))
+		for _, l := range lockdowns {
+			fmt.Printf("  [LOCKDOWN] %s\n", l)
+		}
+		return
+	}
+
+	client := newClient(serviceURL)
+
+	// Lifting a lockdown needs a signature the server verifies: either an
+	// admin key or the key rebuilt from enough break-glass shares
 	if mode == "off" {
-		if keyPath == "" && recoveryCode == "" {
-			fmt.Println("\n[ERROR] Lockdown deactivation requires authentication!")
+		if recoveryCode != "" {
+			fmt.Println("[ERROR] Recovery codes are no longer accepted.")
+			fmt.Println("Use an admin key (--key) or break-glass shares (--share, repeated).")
+			os.Exit(1)
+		}
+		method, sign, err := releaseSigner(keyPath, shares)
+		if err != nil {
+			fmt.Printf("[ERROR] %v\n", err)
 			fmt.Println("\nOptions:")
-			fmt.Println("  1. Use admin key:       terrasign lockdown off --key <path>")
-			fmt.Println("  2. Use recovery code:   terrasign lockdown off --recovery-code <code>")
-			fmt.Println("\n(Recovery code is documented in security documentation)")
+			fmt.Println("  1. Use admin key:          terrasign lockdown off --key <admin-private-key>")
+			fmt.Println("  2. Use break-glass shares: terrasign lockdown off --share <file> --share <file> ...")
 			os.Exit(1)
 		}
-		
-		// Verify authentication
-		if recoveryCode != "" {
-			// Check recovery code
-			if recoveryCode != "TERRASIGN-EMERGENCY" {
-				fmt.Println("\n[ERROR] Invalid recovery code!")
-				os.Exit(1)
-			}
-			fmt.Println("\n[OK] Recovery code verified")
-		} else {
-			// Verify key exists
-			if _, err := os.Stat(keyPath); os.IsNotExist(err) {
-				fmt.Printf("\n[ERROR] Key file not found: %s\n", keyPath)
-				fmt.Println("\nAlternatively, use recovery code (see security documentation)")
-				os.Exit(1)
-			}
-			fmt.Printf("\n[OK] Admin key verified: %s\n", keyPath)
+		if err := client.ReleaseLockdown(req, method, sign); err != nil {
+			fmt.Printf("Error lifting lockdown: %v\n", err)
+			os.Exit(1)
 		}
+		fmt.Println("\n[OK] Lockdown lifted. System resumes normal operation.")
+		return
 	}
 
-	client := remote.NewClient(serviceURL)
-	if err := client.SetLockdown(mode == "on"); err != nil {
+	if err := client.SetLockdown(req); err != nil {
 		fmt.Printf("Error setting lockdown: %v\n", err)
 		os.Exit(1)
 	}
 
+	scopeArgs := ""
+	if req.Scope != "" && req.Scope != remote.ScopeGlobal {
+		scopeArgs = fmt.Sprintf(" --scope %s --target %s", req.Scope, req.Target)
+	}
+
 	if mode == "on" {
 		fmt.Println("\n[EMERGENCY LOCKDOWN ACTIVATED]")
-		fmt.Println("System is now rejecting ALL plan submissions and signatures.")
+		if scopeArgs == "" {
+			fmt.Println("System is now rejecting ALL plan submissions and signatures.")
+		} else {
+			fmt.Printf("System is now rejecting submissions and signatures for %s %s.\n", req.Scope, req.Target)
+		}
+		if req.Duration > 0 {
+			fmt.Printf("Lockdown expires automatically in %s.\n", req.Duration)
+		}
 		fmt.Println("\nTo deactivate:")
-		fmt.Println("  terrasign lockdown off --key <admin-key-path>")
-		fmt.Println("  OR use emergency recovery code (see security documentation)")
-	} else {
-		fmt.Println("\n[OK] Lockdown lifted. System resumes normal operation.")
+		fmt.Printf("  terrasign lockdown off%s --key <admin-private-key>\n", scopeArgs)
+		fmt.Printf("  OR terrasign lockdown off%s --share <file> --share <file> ...\n", scopeArgs)
 	}
 }
+
+// releaseSigner returns the method and signing function for answering a
+// lockdown release challenge
+func releaseSigner(keyPath string, shares []string) (string, func([]byte) ([]byte, error), error) {
+	switch {
+	case keyPath != "" && len(shares) > 0:
+		return "", nil, fmt.Errorf("use either --key or --share, not both")
+	case keyPath != "":
+		sv, err := signer.LoadSigner(keyPath, nil)
+		if err != nil {
+			return "", nil, err
+		}
+		return remote.ReleaseAdminKey, func(msg []byte) ([]byte, error) {
+			return sv.SignMessage(bytes.NewReader(msg))
+		}, nil
+	case len(shares) > 0:
+		var parsed []remote.BreakGlassShare
+		for _, s := range shares {
+			// A share is given inline or as a file holding one
+			share, err := remote.ParseBreakGlassShare(s)
+			if err != nil {
+				data, readErr := os.ReadFile(s)
+				if readErr != nil {
+					return "", nil, fmt.Errorf("failed to read share: %w", readErr)
+				}
+				if share, err = remote.ParseBreakGlassShare(string(data)); err != nil {
+					return "", nil, fmt.Errorf("%s: %w", s, err)
+				}
+			}
+			parsed = append(parsed, share)
+		}
+		key, err := remote.RebuildBreakGlassKey(parsed)
+		if err != nil {
+			return "", nil, err
+		}
+		return remote.ReleaseBreakGlass, func(msg []byte) ([]byte, error) {
+			return ed25519.Sign(key, msg), nil
+		}, nil
+	}
+	return "", nil, fmt.Errorf("lifting a lockdown requires an admin key or break-glass shares")
+}
+
+// handleInitBreakGlass generates the break-glass key and writes its public
+// half and the shares
+func handleInitBreakGlass() {
+	cmd := flag.NewFlagSet("lockdown init-break-glass", flag.ExitOnError)
+	n := cmd.Int("shares", 5, "Number of shares to generate")
+	k := cmd.Int("threshold", 3, "Shares needed to lift a lockdown")
+	outDir := cmd.String("out", "break-glass", "Directory for break-glass.pub and the share files")
+	cmd.Parse(os.Args[3:])
+
+	pub, shares, err := remote.GenerateBreakGlass(*n, *k)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := os.MkdirAll(*outDir, 0700); err != nil {
+		fmt.Printf("Error creating %s: %v\n", *outDir, err)
+		os.Exit(1)
+	}
+	pubPath := filepath.Join(*outDir, "break-glass.pub")
+	if err := os.WriteFile(pubPath, pub, 0644); err != nil {
+		fmt.Printf("Error writing public key: %v\n", err)
+		os.Exit(1)
+	}
+	for i, share := range shares {
+		path := filepath.Join(*outDir, fmt.Sprintf("share-%d.txt", i+1))
+		if err := os.WriteFile(path, []byte(share.String()+"\n"), 0600); err != nil {
+			fmt.Printf("Error writing share: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	fmt.Printf("[OK] Break-glass key generated: %d shares, %d needed to lift a lockdown\n", *n, *k)
+	fmt.Printf("  Public key: %s (start the server with --break-glass-key %s)\n", pubPath, pubPath)
+	fmt.Printf("  Shares:     %s/share-1.txt .. share-%d.txt\n", *outDir, *n)
+	fmt.Println("\n[WARN] Hand each share to a different custodian and delete it from this machine.")
+	fmt.Println("       The private key is never written; only the shares can rebuild it.")
+}
@@ -1,20 +1,53 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
 	"strings"
 	"time"
 
+	"github.com/sulakshanakarunarathne/terrasign/pkg/planfile"
+	"github.com/sulakshanakarunarathne/terrasign/pkg/policy"
 	"github.com/sulakshanakarunarathne/terrasign/pkg/remote"
 	"github.com/sulakshanakarunarathne/terrasign/pkg/signer"
 	"github.com/sulakshanakarunarathne/terrasign/pkg/terraform"
+	"github.com/sulakshanakarunarathne/terrasign/pkg/tsa"
 	"github.com/sulakshanakarunarathne/terrasign/pkg/verifier"
 )
 
 const defaultServiceURL = "http://localhost:8081"
 
+// splitList splits a comma-separated flag value, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// newClient creates a signing service client with credentials from the
+// environment: TERRASIGN_TOKEN (static token or OIDC JWT), TERRASIGN_CLIENT_CERT
+// and TERRASIGN_CLIENT_KEY (mTLS), and TERRASIGN_CA_CERT (server CA bundle)
+func newClient(serviceURL string) *remote.Client {
+	client, err := remote.NewClientWithOptions(serviceURL, remote.ClientOptions{
+		Token:    os.Getenv("TERRASIGN_TOKEN"),
+		CertFile: os.Getenv("TERRASIGN_CLIENT_CERT"),
+		KeyFile:  os.Getenv("TERRASIGN_CLIENT_KEY"),
+		CAFile:   os.Getenv("TERRASIGN_CA_CERT"),
+	})
+	if err != nil {
+		fmt.Printf("Error configuring service client: %v\n", err)
+		os.Exit(1)
+	}
+	return client
+}
+
 func main() {
 	if len(os.Args) < 2 {
 		printUsage()
@@ -37,6 +70,10 @@ func main() {
 		handleLockdown()
 	case "monitor":
 		handleMonitor()
+	case "audit":
+		handleAudit()
+	case "tsa":
+		handleTSA()
 	case "server":
 		handleServer()
 	default:
@@ -57,6 +94,8 @@ func printUsage() {
 	fmt.Println("  admin                 Admin commands (list, download, sign)")
 	fmt.Println("  monitor               Live security dashboard")
 	fmt.Println("  lockdown              Emergency lockdown control (on/off)")
+	fmt.Println("  audit                 Query and verify the service audit log")
+	fmt.Println("  tsa                   Set up the built-in timestamp authority")
 	fmt.Println("  server                Start the signing service")
 	fmt.Println("\nUse 'terrasign <command> --help' for more information")
 }
@@ -64,16 +103,52 @@ func printUsage() {
 func handleSign() {
 	signCmd := flag.NewFlagSet("sign", flag.ExitOnError)
 	keyPath := signCmd.String("key", "", "Path to private key (for key-based signing)")
+	threshold := signCmd.String("policy-threshold", "", "Lowest policy severity that blocks signing: info, warn, error or critical (default from policies/config.yaml, else error)")
+	tlogService := signCmd.String("tlog-service", "", "Signing service URL whose transparency log records the signature (requires --key)")
+	tsaURL := signCmd.String("tsa-url", "", "RFC 3161 timestamp authority URL that counter-signs the signature (requires --key), e.g. http://signing-service:8080/tsa")
+	planJSON := signCmd.String("plan-json", "", "Path to the plan's terraform show -json output; signed together with the plan")
+	acknowledge := signCmd.String("acknowledge-destroy", "", "Comma-separated protected resource addresses the plan may destroy or replace; recorded in the policy attestation")
+	reviewer := signCmd.String("reviewer", os.Getenv("USER"), "Name recorded with --acknowledge-destroy")
+	fulcioURL := signCmd.String("fulcio-url", signer.DefaultFulcioURL, "Fulcio URL that certifies keyless signatures")
+	rekorURL := signCmd.String("rekor-url", signer.DefaultRekorURL, "Rekor URL that logs keyless signatures")
+	idToken := signCmd.String("identity-token", os.Getenv("SIGSTORE_ID_TOKEN"), "OIDC token for keyless signing (default from SIGSTORE_ID_TOKEN, else the browser flow)")
+	oidcIssuer := signCmd.String("oidc-issuer", signer.DefaultOIDCIssuer, "OIDC issuer of the browser flow for keyless signing")
 	
 	signCmd.Parse(os.Args[2:])
 	
 	if signCmd.NArg() < 1 {
-		fmt.Println("Usage: terrasign sign [flags] <plan-file>")
+		fmt.Println("Usage: terrasign sign [flags] <plan-file>\n\n<plan-file> is a saved plan or its `terraform show -json` output")
 		signCmd.PrintDefaults()
 		os.Exit(1)
 	}
+
+	opts := signer.Options{
+		KeyPath:    *keyPath,
+		PlanJSON:   *planJSON,
+		FulcioURL:  *fulcioURL,
+		RekorURL:   *rekorURL,
+		IDToken:    *idToken,
+		OIDCIssuer: *oidcIssuer,
+	}
+	if *acknowledge != "" {
+		opts.Acknowledgement = policy.NewAcknowledgement(splitList(*acknowledge), *reviewer)
+	}
+	if *threshold != "" {
+		severity, err := policy.ParseSeverity(*threshold)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		opts.PolicyThreshold = severity
+	}
+	if *tlogService != "" {
+		opts.TlogUpload = newClient(*tlogService).AddTlogEntry
+	}
+	if *tsaURL != "" {
+		opts.Timestamper = tsa.NewClient(*tsaURL)
+	}
 	
-	err := signer.Sign(signCmd.Arg(0), *keyPath)
+	err := signer.SignPlan(signCmd.Arg(0), opts)
 	if err != nil {
 		fmt.Printf("Error signing plan: %v\n", err)
 		os.Exit(1)
@@ -85,22 +160,62 @@ func handleVerify() {
 	identity := verifyCmd.String("identity", "", "The expected identity (email) in the certificate")
 	issuer := verifyCmd.String("issuer", "https://github.com/login/oauth", "The expected OIDC issuer (default: GitHub)")
 	keyPath := verifyCmd.String("key", "", "Path to public key (for key-based verification)")
+	jsonOutput := verifyCmd.Bool("json", false, "Print the verification report as JSON")
+	strict := verifyCmd.Bool("strict", false, "Fail if any attestation is missing, unsigned or bound to another plan")
+	trustedKeys := verifyCmd.String("trusted-keys", "", "Comma-separated reviewer public keys or key directories (multi-party verification)")
+	threshold := verifyCmd.Int("threshold", 1, "Number of distinct trusted keys that must have signed the plan")
+	tlogKey := verifyCmd.String("tlog-key", "", "Transparency log public key; requires an inclusion proof for the signature in <plan>.bundle")
+	tsaCerts := verifyCmd.String("tsa-cert", "", "Comma-separated trusted timestamp authority certificates; requires an RFC 3161 timestamp in <plan>.bundle and measures plan age from it")
+	maxAge := verifyCmd.String("max-age", "", "Maximum plan age: a duration, or per environment like default=24h,prod=2h (default 24h)")
+	environment := verifyCmd.String("environment", os.Getenv("TF_WORKSPACE"), "Environment or workspace that selects the --max-age window")
+	requireFresh := verifyCmd.Bool("require-fresh", false, "Fail when no trusted timestamp or provenance establishes the signing time")
+	planJSON := verifyCmd.String("plan-json", "", "Path to the terraform show -json output the plan was signed with")
+	trustedRoot := verifyCmd.String("trusted-root", "", "Sigstore trusted root JSON for keyless verification (default: fetch the public instance's through TUF)")
+	policyDir := verifyCmd.String("policy-dir", verifier.DefaultPolicyDir, "Policy directory whose config.yaml lists protected resources; destroying them needs a signed acknowledgement")
 	
 	verifyCmd.Parse(os.Args[2:])
 	
 	if verifyCmd.NArg() < 1 {
-		fmt.Println("Usage: terrasign verify [flags] <plan-file>")
+		fmt.Println("Usage: terrasign verify [flags] <plan-file>\n\n<plan-file> is a saved plan or its `terraform show -json` output")
 		verifyCmd.PrintDefaults()
 		os.Exit(1)
 	}
 	
-	if *keyPath == "" && *identity == "" {
-		fmt.Println("Error: either --key or --identity flag is required for verification")
+	if *keyPath == "" && *identity == "" && *trustedKeys == "" {
+		fmt.Println("Error: one of --key, --trusted-keys or --identity is required for verification")
 		verifyCmd.PrintDefaults()
 		os.Exit(1)
 	}
 
-	err := verifier.Verify(verifyCmd.Arg(0), *keyPath, *identity, *issuer)
+	window, err := verifier.ParseMaxAge(*maxAge)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	report, err := verifier.VerifyWithOptions(verifyCmd.Arg(0), verifier.Options{
+		KeyPath:     *keyPath,
+		Identity:    *identity,
+		Issuer:      *issuer,
+		Strict:      *strict,
+		TrustedKeys: splitList(*trustedKeys),
+		Threshold:   *threshold,
+		TlogKey:     *tlogKey,
+		TSACerts:    splitList(*tsaCerts),
+		PlanJSON:    *planJSON,
+		TrustedRoot: *trustedRoot,
+		PolicyDir:   *policyDir,
+
+		MaxAge:             window.For(*environment),
+		RequireSigningTime: *requireFresh,
+	})
+	if *jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(report)
+	} else {
+		report.Print(os.Stdout)
+	}
 	if err != nil {
 		fmt.Printf("Error verifying plan: %v\n", err)
 		os.Exit(1)
@@ -112,6 +227,17 @@ func handleWrap() {
 	identity := wrapCmd.String("identity", "", "Identity to verify against")
 	issuer := wrapCmd.String("issuer", "https://github.com/login/oauth", "OIDC Issuer")
 	keyPath := wrapCmd.String("key", "", "Path to public key (for key-based verification)")
+	strict := wrapCmd.Bool("strict", false, "Fail if any attestation is missing, unsigned or bound to another plan")
+	trustedKeys := wrapCmd.String("trusted-keys", "", "Comma-separated reviewer public keys or key directories (multi-party verification)")
+	threshold := wrapCmd.Int("threshold", 1, "Number of distinct trusted keys that must have signed the plan")
+	tlogKey := wrapCmd.String("tlog-key", "", "Transparency log public key; requires an inclusion proof for the signature in <plan>.bundle")
+	tsaCerts := wrapCmd.String("tsa-cert", "", "Comma-separated trusted timestamp authority certificates; requires an RFC 3161 timestamp in <plan>.bundle and measures plan age from it")
+	maxAge := wrapCmd.String("max-age", "", "Maximum plan age: a duration, or per environment like default=24h,prod=2h (default 24h)")
+	environment := wrapCmd.String("environment", os.Getenv("TF_WORKSPACE"), "Environment or workspace that selects the --max-age window")
+	requireFresh := wrapCmd.Bool("require-fresh", false, "Fail when no trusted timestamp or provenance establishes the signing time")
+	planJSON := wrapCmd.String("plan-json", "", "Path to the terraform show -json output the plan was signed with")
+	trustedRoot := wrapCmd.String("trusted-root", "", "Sigstore trusted root JSON for keyless verification (default: fetch the public instance's through TUF)")
+	policyDir := wrapCmd.String("policy-dir", verifier.DefaultPolicyDir, "Policy directory whose config.yaml lists protected resources; destroying them needs a signed acknowledgement")
 
 	wrapCmd.Parse(os.Args[2:])
 
@@ -120,8 +246,29 @@ func handleWrap() {
 		fmt.Println("Usage: terrasign wrap [flags] -- <terraform args>")
 		os.Exit(1)
 	}
+
+	window, err := verifier.ParseMaxAge(*maxAge)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
 	
-	err := terraform.Execute(terraformArgs, *keyPath, *identity, *issuer)
+	err = terraform.Execute(terraformArgs, verifier.Options{
+		KeyPath:     *keyPath,
+		Identity:    *identity,
+		Issuer:      *issuer,
+		Strict:      *strict,
+		TrustedKeys: splitList(*trustedKeys),
+		Threshold:   *threshold,
+		TlogKey:     *tlogKey,
+		TSACerts:    splitList(*tsaCerts),
+		PlanJSON:    *planJSON,
+		TrustedRoot: *trustedRoot,
+		PolicyDir:   *policyDir,
+
+		MaxAge:             window.For(*environment),
+		RequireSigningTime: *requireFresh,
+	})
 	if err != nil {
 		fmt.Printf("Error executing terraform: %v\n", err)
 		os.Exit(1)
@@ -132,22 +279,29 @@ func handleSubmitForReview() {
 	submitCmd := flag.NewFlagSet("submit-for-review", flag.ExitOnError)
 	serviceURL := submitCmd.String("service", defaultServiceURL, "Signing service URL")
 	submitter := submitCmd.String("submitter", "ci-pipeline", "Submitter identifier")
+	environment := submitCmd.String("environment", os.Getenv("TF_WORKSPACE"), "Target environment or workspace (selects the approval quorum)")
 	wait := submitCmd.Bool("wait", false, "Wait for signature before returning")
 	timeout := submitCmd.Duration("timeout", 30*time.Minute, "Timeout for waiting")
+	planJSON := submitCmd.String("plan-json", "", "Path to the plan's terraform show -json output; reviewers sign both together")
 
 	submitCmd.Parse(os.Args[2:])
 
 	if submitCmd.NArg() < 1 {
-		fmt.Println("Usage: terrasign submit-for-review [flags] <plan-file>")
+		fmt.Println("Usage: terrasign submit-for-review [flags] <plan-file>\n\n<plan-file> is a saved plan or its `terraform show -json` output")
 		submitCmd.PrintDefaults()
 		os.Exit(1)
 	}
 
-	planPath := submitCmd.Arg(0)
-	client := remote.NewClient(*serviceURL)
+	in, err := planfile.NewInput(submitCmd.Arg(0), *planJSON)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	planPath := in.Path()
+	client := newClient(*serviceURL)
 
 	fmt.Printf("Submitting plan for review...\n")
-	id, err := client.SubmitPlan(planPath, *submitter)
+	id, err := client.SubmitPlan(in, *submitter, *environment)
 	if err != nil {
 		fmt.Printf("Error submitting plan: %v\n", err)
 		os.Exit(1)
@@ -166,6 +320,12 @@ func handleSubmitForReview() {
 			os.Exit(1)
 		}
 
+		// The signature must be for the plan this pipeline submitted
+		if err := client.CheckPlanHash(id, in); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+
 		// Download signature
 		sigPath := planPath + ".sig"
 		if err := client.DownloadSignature(id, sigPath); err != nil {
@@ -174,6 +334,42 @@ func handleSubmitForReview() {
 		}
 
 		fmt.Printf("Signature downloaded to: %s\n", sigPath)
+
+		// All approval signatures, for threshold verification
+		sigsPath := planPath + ".sigs"
+		if err := client.DownloadSignatures(id, sigsPath); err != nil {
+			fmt.Printf("Error downloading signatures: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Approval signatures downloaded to: %s\n", sigsPath)
+
+		// Bundle with the transparency log inclusion proof and timestamp, for
+		// `verify --tlog-key` and `verify --tsa-cert`
+		bundlePath := planPath + ".bundle"
+		err = client.DownloadBundle(id, bundlePath)
+		switch {
+		case errors.Is(err, remote.ErrNotFound):
+			// The service runs without a transparency log or timestamp authority
+		case err != nil:
+			fmt.Printf("Error downloading bundle: %v\n", err)
+			os.Exit(1)
+		default:
+			fmt.Printf("Sigstore bundle downloaded to: %s\n", bundlePath)
+		}
+
+		// Attestations uploaded by the reviewers, for `verify --strict`
+		for _, kind := range remote.AttestationKinds {
+			attestationPath := planPath + "." + kind
+			err := client.DownloadAttestation(id, kind, attestationPath)
+			if errors.Is(err, remote.ErrNotFound) {
+				continue
+			}
+			if err != nil {
+				fmt.Printf("Error downloading %s attestation: %v\n", kind, err)
+				os.Exit(1)
+			}
+			fmt.Printf("Attestation (%s) downloaded to: %s\n", kind, attestationPath)
+		}
 	}
 }
 
@@ -182,9 +378,10 @@ func handleAdmin() {
 		fmt.Println("Usage: terrasign admin <subcommand> [args]")
 		fmt.Println("\nSubcommands:")
 		fmt.Println("  list-pending          List all pending submissions")
+		fmt.Println("  list                  Query submissions (--status, --submitter, --environment, --since, --until, --sort, --limit)")
 		fmt.Println("  download <id>         Download a plan for review")
 		fmt.Println("  sign <id>             Sign an approved plan")
-		fmt.Println("  reject <id>           Reject a plan submission")
+		fmt.Println("  reject <id> --reason  Reject a plan submission")
 		fmt.Println("\nFlags:")
 		fmt.Println("  --service <url>       Signing service URL (default: http://localhost:8080)")
 		os.Exit(1)
@@ -212,6 +409,50 @@ func handleAdmin() {
 		return
 	}
 
+	if args[0] == "list" {
+		fs := flag.NewFlagSet("list", flag.ExitOnError)
+		srv := fs.String("service", defaultServiceURL, "Service URL")
+		status := fs.String("status", "", "Only submissions with this status (pending, approved, rejected, expired)")
+		submitter := fs.String("submitter", "", "Only submissions from this submitter")
+		environment := fs.String("environment", "", "Only submissions for this environment or workspace")
+		since := fs.String("since", "", "Only submissions created at or after this date (YYYY-MM-DD or RFC3339)")
+		until := fs.String("until", "", "Only submissions created up to this date (YYYY-MM-DD includes the day, or RFC3339)")
+		sortBy := fs.String("sort", "-created_at", "Sort by created_at, submitter, status, environment or risk; prefix - for descending (-risk: riskiest first, oldest first among equals)")
+		limit := fs.Int("limit", 50, "Submissions per page")
+		cursor := fs.String("cursor", "", "Cursor printed by the previous page")
+		all := fs.Bool("all", false, "Fetch every page")
+		fs.Parse(args[1:])
+
+		q := remote.SubmissionQuery{
+			Status:      *status,
+			Submitter:   *submitter,
+			Environment: *environment,
+			Sort:        *sortBy,
+			Limit:       *limit,
+			Cursor:      *cursor,
+		}
+		var err error
+		if *since != "" {
+			if q.Since, err = remote.ParseQueryTime(*since, false); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		if *until != "" {
+			if q.Until, err = remote.ParseQueryTime(*until, true); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+		}
+
+		admin := NewAdminCommands(*srv)
+		if err := admin.List(q, *all); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Check inspect
 	if args[0] == "inspect" {
 		fs := flag.NewFlagSet("inspect", flag.ExitOnError)
@@ -224,22 +465,33 @@ func handleAdmin() {
 			}
 		}
 		
-		// Get ID (first non-flag argument)
+		// Get ID (first non-flag argument) and the module and type filters
 		var id string
-		for _, arg := range args[1:] {
-			if !strings.HasPrefix(arg, "--") && arg != *srv {
+		var filter diffFilter
+		for i := 1; i < len(args); i++ {
+			arg := args[i]
+			switch {
+			case (arg == "--module" || arg == "--type") && i+1 < len(args):
+				i++
+				if arg == "--module" {
+					filter.Modules = append(filter.Modules, splitList(args[i])...)
+				} else {
+					filter.Types = append(filter.Types, splitList(args[i])...)
+				}
+			case id == "" && !strings.HasPrefix(arg, "--") && arg != *srv:
 				id = arg
-				break
 			}
 		}
 		
 		if id == "" {
-			fmt.Println("Usage: terrasign admin inspect <submission-id> [--service <url>]")
+			fmt.Println("Usage: terrasign admin inspect <submission-id> [--service <url>] [--module <address>] [--type <glob>]")
+			fmt.Println("  --module  Only show changes in these modules (comma-separated, e.g. module.db; root for the root module)")
+			fmt.Println("  --type    Only show changes to these resource types (comma-separated globs, e.g. aws_iam_*)")
 			os.Exit(1)
 		}
 		
 		admin := NewAdminCommands(serviceURL)
-		if err := admin.Inspect(id); err != nil {
+		if err := admin.Inspect(id, filter); err != nil {
 			fmt.Printf("Error: %v\n", err)
 			os.Exit(1)
 		}
@@ -298,6 +550,7 @@ func handleAdmin() {
 		_ = fs.String("service", defaultServiceURL, "Service URL")
 		keyPath := fs.String("key", "", "Path to admin private key (required)")
 		reviewer := fs.String("reviewer", "admin", "Reviewer name")
+		acknowledge := fs.String("acknowledge-destroy", "", "Comma-separated protected resource addresses the plan may destroy or replace")
 		
 		// terrasign admin sign <id> --key ... --service ...
 		// flags must be parsed. Since <id> is a positional arg, `flag` stops there.
@@ -319,6 +572,7 @@ func handleAdmin() {
 		var id string
 		var key string
 		var rev = "admin"
+		var ack []string
 		
 		skipNext := false
 		for i, arg := range args[1:] {
@@ -340,6 +594,11 @@ func handleAdmin() {
 				skipNext = true
 				continue
 			}
+			if arg == "--acknowledge-destroy" && i+2 < len(args) {
+				ack = append(ack, splitList(args[1:][i+1])...)
+				skipNext = true
+				continue
+			}
 			// If it looks like a flag but we didn't handle it
 			if strings.HasPrefix(arg, "-") {
 				continue 
@@ -363,18 +622,72 @@ func handleAdmin() {
 			if *reviewer != "admin" {
 				rev = *reviewer
 			}
+			if *acknowledge != "" {
+				ack = splitList(*acknowledge)
+			}
 			if fs.NArg() > 0 && id == "" {
 				id = fs.Arg(0)
 			}
 		}
 
 		if id == "" || key == "" {
-			fmt.Println("Usage: terrasign admin sign [flags] <submission-id>\nFlags:\n  --key <path> (required)\n  --service <url>\n  --reviewer <name>")
+			fmt.Println("Usage: terrasign admin sign [flags] <submission-id>\nFlags:\n  --key <path> (required)\n  --service <url>\n  --reviewer <name>\n  --acknowledge-destroy <address,...> (protected resources the plan may destroy or replace)")
 			os.Exit(1)
 		}
 
 		admin := NewAdminCommands(serviceURL)
-		if err := admin.Sign(id, key, rev); err != nil {
+		if err := admin.Sign(id, key, rev, ack); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Check reject
+	if args[0] == "reject" {
+		var id string
+		var reason string
+		var rev = "admin"
+
+		skipNext := false
+		for i, arg := range args[1:] {
+			if skipNext {
+				skipNext = false
+				continue
+			}
+			value := ""
+			if i+2 < len(args) {
+				value = args[1:][i+1]
+			}
+			switch arg {
+			case "--service":
+				serviceURL = value
+				skipNext = true
+				continue
+			case "--reason":
+				reason = value
+				skipNext = true
+				continue
+			case "--reviewer":
+				rev = value
+				skipNext = true
+				continue
+			}
+			if strings.HasPrefix(arg, "-") {
+				continue
+			}
+			if id == "" {
+				id = arg
+			}
+		}
+
+		if id == "" || reason == "" {
+			fmt.Println("Usage: terrasign admin reject <submission-id> --reason <text>\nFlags:\n  --reason <text> (required)\n  --service <url>\n  --reviewer <name>")
+			os.Exit(1)
+		}
+
+		admin := NewAdminCommands(serviceURL)
+		if err := admin.Reject(id, rev, reason); err != nil {
 			fmt.Printf("Error: %v\n", err)
 			os.Exit(1)
 		}
@@ -388,13 +701,69 @@ func handleAdmin() {
 func handleServer() {
 	serverCmd := flag.NewFlagSet("server", flag.ExitOnError)
 	port := serverCmd.Int("port", 8080, "Port to listen on")
-	storageDir := serverCmd.String("storage", "./terrasign-storage", "Storage directory for plans")
+	storageDir := serverCmd.String("storage", "./terrasign-storage", "Storage: a directory, bolt:///path/terrasign.db or s3://bucket/prefix?endpoint=URL&region=R")
+	authConfig := serverCmd.String("auth-config", "", "Authentication config (tokens, mTLS, OIDC and roles); empty disables authentication")
+	adminKey := serverCmd.String("admin-key", "", "Public key trusted to sign plans and lift lockdowns")
+	auditKey := serverCmd.String("audit-key", "", "Private key that signs audit log entries (verify with terrasign audit verify --key)")
+	tlogKey := serverCmd.String("tlog-key", "", "Private key that signs transparency log checkpoints; enables the log of approval signatures (verify with terrasign verify --tlog-key)")
+	tsaURL := serverCmd.String("tsa-url", "", "RFC 3161 timestamp authority that counter-signs approval signatures")
+	tsaCert := serverCmd.String("tsa-cert", "", "Certificate chain of the built-in timestamp authority served at /tsa (terrasign tsa init)")
+	tsaKey := serverCmd.String("tsa-key", "", "Private key of the built-in timestamp authority")
+	breakGlassKey := serverCmd.String("break-glass-key", "", "Break-glass public key (terrasign lockdown init-break-glass) whose shares can lift lockdowns")
+	trustedKeys := serverCmd.String("trusted-keys", "", "Comma-separated reviewer public keys or directories of *.pub/*.pem keys")
+	quorum := serverCmd.String("quorum", "", "Reviewer signatures required: a number, or per environment like default=1,prod=2")
+	riskQuorum := serverCmd.String("risk-quorum", "", "Reviewer signatures required at least for risky plans, per risk level like high=2,critical=3")
+	maxAge := serverCmd.String("max-age", "", "How long submissions stay pending before they expire: a duration, or per environment like default=24h,prod=2h,dev=72h (default 24h)")
+	policyDir := serverCmd.String("policy-dir", "./policies", "Policy directory whose config.yaml lists protected resources; approvals that destroy them need a signed acknowledgement")
+	maxPlanSize := serverCmd.Int64("max-plan-size", remote.DefaultMaxPlanSize>>20, "Largest plan accepted for review, in MiB")
 
 	serverCmd.Parse(os.Args[2:])
 
 	config := remote.SigningServiceConfig{
 		StorageDir: *storageDir,
 		Port:       *port,
+		AdminKey:   *adminKey,
+	}
+	config.BreakGlassKey = *breakGlassKey
+	config.AuditKey = *auditKey
+	config.TlogKey = *tlogKey
+	config.TSAURL = *tsaURL
+	config.TSACert = *tsaCert
+	config.TSAKey = *tsaKey
+	config.TrustedKeys = splitList(*trustedKeys)
+	config.PolicyDir = *policyDir
+	if *maxPlanSize < 1 {
+		fmt.Println("Error: --max-plan-size must be at least 1 MiB")
+		os.Exit(1)
+	}
+	config.MaxPlanSize = *maxPlanSize << 20
+
+	q, err := remote.ParseQuorum(*quorum)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	config.Quorum = q
+
+	config.RiskQuorum, err = remote.ParseRiskQuorum(*riskQuorum)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	config.MaxAge, err = verifier.ParseMaxAge(*maxAge)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *authConfig != "" {
+		auth, err := remote.LoadAuthConfig(*authConfig)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		config.Auth = auth
 	}
 
 	service, err := remote.NewSigningService(config)
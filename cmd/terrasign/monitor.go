@@ -12,6 +12,9 @@ import (
 	"github.com/sulakshanakarunarathne/terrasign/pkg/remote"
 )
 
+// monitorPageSize is how many pending plans the dashboard shows
+const monitorPageSize = 25
+
 func handleMonitor() {
 	serviceURL := defaultServiceURL
 	for i, arg := range os.Args {
@@ -20,7 +23,7 @@ func handleMonitor() {
 		}
 	}
 
-	client := remote.NewClient(serviceURL)
+	client := newClient(serviceURL)
 	admin := NewAdminCommands(serviceURL)
 	
 	// Interactive mode
@@ -36,25 +39,31 @@ func handleMonitor() {
 		fmt.Printf("Service: %s   |   Time: %s\n", serviceURL, time.Now().Format("15:04:05"))
 		fmt.Println("---------------------------------------------------------------------------------")
 		
-		pending, err := client.ListPending()
+		page, err := client.ListSubmissions(remote.SubmissionQuery{Status: "pending", Sort: "-risk", Limit: monitorPageSize})
 		if err != nil {
 			fmt.Printf("Error fetching data: %v\n", err)
 		} else {
+			pending := page.Submissions
 			if len(pending) == 0 {
 				fmt.Println("\n  No pending plans. System secure.")
 			} else {
 				w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
-				fmt.Fprintln(w, "\nID\tSUBMITTER\tCREATED AT\tSTATUS")
-				fmt.Fprintln(w, "--\t---------\t----------\t------")
+				fmt.Fprintln(w, "\nID\tRISK\tSUBMITTER\tCREATED AT\tSTATUS\tFACTORS")
+				fmt.Fprintln(w, "--\t----\t---------\t----------\t------\t-------")
 				
 				for _, p := range pending {
-					fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", 
+					fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", 
 						p.ID, 
+						p.Risk,
 						p.Submitter, 
 						p.CreatedAt.Format("15:04:05"), 
-						p.Status)
+						p.Status,
+						p.Risk.Summary())
 				}
 				w.Flush()
+				if page.Total > len(pending) {
+					fmt.Printf("\n  Showing the riskiest %d of %d pending plans (terrasign admin list --status pending --sort -risk)\n", len(pending), page.Total)
+				}
 			}
 		}
 		
@@ -76,9 +85,18 @@ func handleMonitor() {
 			}
 			id := strings.TrimSpace(scanner.Text())
 			if id != "" {
-				fmt.Println("\n--- Plan Changes ---")
-				if err := admin.Inspect(id); err != nil {
+				fmt.Print("Filter (e.g. module=module.db type=aws_iam_*; Enter for all changes): ")
+				if !scanner.Scan() {
+					continue
+				}
+				filter, err := parseDiffFilter(scanner.Text())
+				if err != nil {
 					fmt.Printf("Error: %v\n", err)
+				} else {
+					fmt.Println("\n--- Plan Changes ---")
+					if err := admin.Inspect(id, filter); err != nil {
+						fmt.Printf("Error: %v\n", err)
+					}
 				}
 				fmt.Print("\nPress Enter to continue...")
 				scanner.Scan()
@@ -136,8 +154,14 @@ func handleMonitor() {
 				keyPath = defaultKeyPath
 			}
 			
+			fmt.Print("Acknowledge destroying protected resources (comma-separated addresses, Enter for none): ")
+			if !scanner.Scan() {
+				continue
+			}
+			ack := splitList(strings.TrimSpace(scanner.Text()))
+			
 			if id != "" {
-				if err := admin.Sign(id, keyPath, "admin"); err != nil {
+				if err := admin.Sign(id, keyPath, "admin", ack); err != nil {
 					fmt.Printf("Error: %v\n", err)
 				} else {
 					fmt.Println("[OK] Plan signed successfully")
@@ -0,0 +1,411 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/sulakshanakarunarathne/terrasign/pkg/planfile"
+)
+
+// maxDiffValueLen is how much of an attribute value the diff shows
+const maxDiffValueLen = 60
+
+// ANSI colors for the diff; only used when writing to a terminal
+const (
+	colorRed    = "\033[31m"
+	colorGreen  = "\033[32m"
+	colorYellow = "\033[33m"
+	colorBold   = "\033[1m"
+	colorReset  = "\033[0m"
+)
+
+// diffFilter selects the resource changes a plan diff shows
+type diffFilter struct {
+	Modules []string // Module addresses (module.net, or root); a module includes its instances and children
+	Types   []string // Resource type globs, e.g. aws_iam_*
+}
+
+// diffGroups are the action groups of a plan diff, in display order
+var diffGroups = []struct {
+	action, title string
+}{
+	{"create", "Create"},
+	{"update", "Update in-place"},
+	{"replace", "Replace"},
+	{"delete", "Destroy"},
+	{"read", "Read data sources"},
+	{"forget", "Forget"},
+}
+
+// parseDiffFilter reads a filter typed as "module=module.db type=aws_*,google_*"
+func parseDiffFilter(s string) (diffFilter, error) {
+	var f diffFilter
+	for _, field := range strings.Fields(s) {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			return f, fmt.Errorf("invalid filter %q (use module=<address> or type=<glob>)", field)
+		}
+		switch key {
+		case "module":
+			f.Modules = append(f.Modules, splitList(value)...)
+		case "type":
+			f.Types = append(f.Types, splitList(value)...)
+		default:
+			return f, fmt.Errorf("unknown filter %q (use module or type)", key)
+		}
+	}
+	return f, nil
+}
+
+// empty reports whether the filter shows every change
+func (f diffFilter) empty() bool {
+	return len(f.Modules) == 0 && len(f.Types) == 0
+}
+
+// match reports whether a resource change passes the filter
+func (f diffFilter) match(rc planfile.ResourceChange) bool {
+	if len(f.Modules) > 0 {
+		found := false
+		for _, m := range f.Modules {
+			if inModule(rc.ModuleAddress, m) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if len(f.Types) > 0 {
+		for _, glob := range f.Types {
+			if ok, _ := path.Match(glob, rc.Type); ok {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// String describes the filter for the diff header
+func (f diffFilter) String() string {
+	var parts []string
+	if len(f.Modules) > 0 {
+		parts = append(parts, "module="+strings.Join(f.Modules, ","))
+	}
+	if len(f.Types) > 0 {
+		parts = append(parts, "type="+strings.Join(f.Types, ","))
+	}
+	return strings.Join(parts, " ")
+}
+
+// inModule reports whether a module address is module or inside it. "root"
+// selects the root module only.
+func inModule(address, module string) bool {
+	if module == "root" {
+		return address == ""
+	}
+	return address == module ||
+		strings.HasPrefix(address, module+".") ||
+		strings.HasPrefix(address, module+"[")
+}
+
+// changeAction names the group a change's actions belong to
+func changeAction(actions []string) string {
+	switch strings.Join(actions, ",") {
+	case "delete,create", "create,delete":
+		return "replace"
+	case "":
+		return "no-op"
+	}
+	return strings.Join(actions, ",")
+}
+
+// destructive reports whether an action destroys existing objects
+func destructive(action string) bool {
+	return action == "delete" || action == "replace"
+}
+
+// attrDiff is one changed top-level attribute of a resource
+type attrDiff struct {
+	Name      string
+	Before    string
+	After     string
+	Sensitive bool
+	Forces    bool // Changing it forces replacement
+}
+
+// renderPlanDiff writes the plan's resource changes grouped by action, with
+// a summary header, destructive changes and sensitive attributes flagged and
+// the changed attributes of updates and replacements. The header always
+// counts the whole plan, so a filter cannot hide destructive changes.
+func renderPlanDiff(w io.Writer, plan *planfile.Plan, filter diffFilter, color bool) {
+	paint := func(c, s string) string {
+		if !color {
+			return s
+		}
+		return c + s + colorReset
+	}
+
+	groups := make(map[string][]planfile.ResourceChange)
+	counts := make(map[string]int)
+	var add, change, destroy, unchanged, total, shown, destroying, sensitive int
+	for _, rc := range plan.ResourceChanges {
+		action := changeAction(rc.Change.Actions)
+		if action == "no-op" {
+			unchanged++
+			continue
+		}
+		total++
+		counts[action]++
+		if destructive(action) {
+			destroying++
+		}
+		if hasSensitiveChange(rc) {
+			sensitive++
+		}
+		switch action {
+		case "create":
+			add++
+		case "update":
+			change++
+		case "replace":
+			add++
+			destroy++
+		case "delete":
+			destroy++
+		}
+
+		if !filter.match(rc) {
+			continue
+		}
+		shown++
+		groups[action] = append(groups[action], rc)
+	}
+
+	fmt.Fprintf(w, "Plan: %d to add, %d to change, %d to destroy.\n", add, change, destroy)
+	var breakdown []string
+	for _, g := range diffGroups {
+		if n := counts[g.action]; n > 0 {
+			breakdown = append(breakdown, fmt.Sprintf("%d %s", n, g.action))
+		}
+	}
+	if unchanged > 0 {
+		breakdown = append(breakdown, fmt.Sprintf("%d unchanged", unchanged))
+	}
+	if len(breakdown) > 0 {
+		fmt.Fprintf(w, "  %s\n", strings.Join(breakdown, ", "))
+	}
+	if destroying > 0 {
+		fmt.Fprintln(w, paint(colorRed+colorBold, fmt.Sprintf("[WARN] %d resource(s) will be destroyed or replaced", destroying)))
+	}
+	if sensitive > 0 {
+		fmt.Fprintln(w, paint(colorYellow, fmt.Sprintf("[WARN] %d change(s) touch sensitive attributes", sensitive)))
+	}
+	if !filter.empty() {
+		fmt.Fprintf(w, "Filter: %s (showing %d of %d changes)\n", filter, shown, total)
+	}
+
+	if shown == 0 {
+		fmt.Fprintln(w, "\nNo resource changes to show.")
+		return
+	}
+
+	for _, g := range diffGroups {
+		changes := groups[g.action]
+		if len(changes) == 0 {
+			continue
+		}
+		title := fmt.Sprintf("%s (%d):", g.title, len(changes))
+		if destructive(g.action) {
+			title = paint(colorRed+colorBold, fmt.Sprintf("%s (%d) [destructive]:", g.title, len(changes)))
+		}
+		fmt.Fprintf(w, "\n%s\n", title)
+
+		for _, rc := range changes {
+			attrs := attributeDiffs(rc)
+			line := fmt.Sprintf("  %-4s %s", actionSymbol(rc.Change.Actions), rc.Address)
+			if rc.Deposed != "" {
+				line += fmt.Sprintf(" (deposed object %s)", rc.Deposed)
+			}
+			if rc.PreviousAddress != "" {
+				line += fmt.Sprintf(" (moved from %s)", rc.PreviousAddress)
+			}
+			if rc.ActionReason != "" {
+				line += fmt.Sprintf(" [%s]", rc.ActionReason)
+			}
+			if rc.Change.Importing != nil {
+				line += fmt.Sprintf(" (import %s)", rc.Change.Importing.ID)
+			}
+			switch {
+			case destructive(g.action):
+				line = paint(colorRed, line)
+			case g.action == "create":
+				line = paint(colorGreen, line)
+			case g.action == "update":
+				line = paint(colorYellow, line)
+			}
+			if hasSensitiveChange(rc) {
+				line += " " + paint(colorYellow+colorBold, "[sensitive]")
+			}
+			fmt.Fprintln(w, line)
+
+			for _, a := range attrs {
+				attr := fmt.Sprintf("         ~ %s: %s -> %s", a.Name, a.Before, a.After)
+				if a.Forces {
+					attr += paint(colorRed, " # forces replacement")
+				}
+				if a.Sensitive {
+					attr += " " + paint(colorYellow+colorBold, "[sensitive]")
+				}
+				fmt.Fprintln(w, attr)
+			}
+		}
+	}
+}
+
+// attributeDiffs lists the top-level attributes an update or replacement
+// changes. Sensitive values are never shown.
+func attributeDiffs(rc planfile.ResourceChange) []attrDiff {
+	switch changeAction(rc.Change.Actions) {
+	case "update", "replace":
+	default:
+		return nil
+	}
+	before, _ := rc.Change.Before.(map[string]interface{})
+	after, _ := rc.Change.After.(map[string]interface{})
+
+	forces := make(map[string]bool)
+	for _, p := range rc.Change.ReplacePaths {
+		if len(p) > 0 {
+			if name, ok := p[0].(string); ok {
+				forces[name] = true
+			}
+		}
+	}
+
+	names := make(map[string]bool)
+	for name := range before {
+		names[name] = true
+	}
+	for name := range after {
+		names[name] = true
+	}
+	for name := range attrShape(rc.Change.AfterUnknown) {
+		names[name] = true
+	}
+
+	var diffs []attrDiff
+	for name := range names {
+		unknown := markedAt(rc.Change.AfterUnknown, name)
+		if !unknown && reflect.DeepEqual(before[name], after[name]) {
+			continue
+		}
+
+		a := attrDiff{
+			Name:      name,
+			Before:    formatValue(before[name]),
+			After:     formatValue(after[name]),
+			Sensitive: markedAt(rc.Change.BeforeSensitive, name) || markedAt(rc.Change.AfterSensitive, name),
+			Forces:    forces[name],
+		}
+		if a.Sensitive {
+			a.Before, a.After = "(sensitive value)", "(sensitive value)"
+		}
+		if unknown {
+			a.After = "(known after apply)"
+		}
+		diffs = append(diffs, a)
+	}
+
+	sort.Slice(diffs, func(i, j int) bool {
+		return diffs[i].Name < diffs[j].Name
+	})
+	return diffs
+}
+
+// hasSensitiveChange reports whether a change reads or writes a sensitive
+// attribute: any sensitive value of a create or destroy, or a changed
+// sensitive attribute of an update or replacement
+func hasSensitiveChange(rc planfile.ResourceChange) bool {
+	switch changeAction(rc.Change.Actions) {
+	case "update", "replace":
+		for _, a := range attributeDiffs(rc) {
+			if a.Sensitive {
+				return true
+			}
+		}
+		return false
+	}
+	return marked(rc.Change.BeforeSensitive) || marked(rc.Change.AfterSensitive)
+}
+
+// attrShape returns a sensitive or unknown shape as an object
+func attrShape(shape interface{}) map[string]interface{} {
+	m, _ := shape.(map[string]interface{})
+	return m
+}
+
+// markedAt reports whether a sensitive or unknown shape marks any part of
+// an attribute
+func markedAt(shape interface{}, name string) bool {
+	if b, ok := shape.(bool); ok {
+		return b
+	}
+	return marked(attrShape(shape)[name])
+}
+
+// marked reports whether a sensitive or unknown shape contains a mark
+func marked(shape interface{}) bool {
+	switch v := shape.(type) {
+	case bool:
+		return v
+	case map[string]interface{}:
+		for _, e := range v {
+			if marked(e) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, e := range v {
+			if marked(e) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// formatValue renders an attribute value as compact JSON, shortened for
+// the diff
+func formatValue(v interface{}) string {
+	if v == nil {
+		return "null"
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	s := string(data)
+	if r := []rune(s); len(r) > maxDiffValueLen {
+		s = string(r[:maxDiffValueLen-3]) + "..."
+	}
+	return s
+}
+
+// useColor reports whether the diff is written to a terminal that accepts
+// ANSI colors (NO_COLOR turns them off)
+func useColor(f *os.File) bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sulakshanakarunarathne/terrasign/pkg/tsa"
+)
+
+func handleTSA() {
+	if len(os.Args) < 3 {
+		fmt.Println("Usage: terrasign tsa <command> [args]")
+		fmt.Println("\nCommands:")
+		fmt.Println("  init      Create a certificate and key for the built-in timestamp authority")
+		os.Exit(1)
+	}
+
+	switch os.Args[2] {
+	case "init":
+		handleTSAInit()
+	default:
+		fmt.Printf("Unknown tsa command: %s\n", os.Args[2])
+		os.Exit(1)
+	}
+}
+
+// handleTSAInit generates a self-signed certificate and key for the
+// signing service's built-in timestamp authority
+func handleTSAInit() {
+	cmd := flag.NewFlagSet("tsa init", flag.ExitOnError)
+	outDir := cmd.String("out", ".", "Directory for tsa.pem and tsa.key")
+	name := cmd.String("name", "TerraSign Timestamp Authority", "Certificate common name")
+	validity := cmd.Duration("validity", 5*365*24*time.Hour, "Certificate lifetime")
+	cmd.Parse(os.Args[3:])
+
+	certPEM, keyPEM, err := tsa.GenerateAuthority(*name, *validity)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := os.MkdirAll(*outDir, 0700); err != nil {
+		fmt.Printf("Error creating %s: %v\n", *outDir, err)
+		os.Exit(1)
+	}
+	certPath := filepath.Join(*outDir, "tsa.pem")
+	keyPath := filepath.Join(*outDir, "tsa.key")
+	if err := os.WriteFile(certPath, certPEM, 0644); err != nil {
+		fmt.Printf("Error writing certificate: %v\n", err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile(keyPath, keyPEM, 0600); err != nil {
+		fmt.Printf("Error writing key: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("[OK] Timestamp authority created: %s\n", *name)
+	fmt.Printf("  Certificate: %s (trust it with terrasign verify --tsa-cert %s)\n", certPath, certPath)
+	fmt.Printf("  Key:         %s (start the server with --tsa-cert %s --tsa-key %s)\n", keyPath, certPath, keyPath)
+}
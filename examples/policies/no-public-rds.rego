@@ -0,0 +1,28 @@
+# Example TerraSign policy. Copy .rego files like this one into ./policies
+# (the directory terrasign evaluates when signing).
+#
+# Rules named `deny` default to error severity and block signing; rules named
+# `warn` default to warn severity and are recorded only. Results can be plain
+# strings or objects with `msg` and optional `policy`, `severity`
+# (info/warn/error/critical), `address` and `remediation` fields.
+package terrasign.rds
+
+deny contains violation if {
+	some rc in input.resource_changes
+	rc.type == "aws_db_instance"
+	rc.change.after.publicly_accessible == true
+	violation := {
+		"policy": "no-public-rds",
+		"severity": "critical",
+		"address": rc.address,
+		"msg": sprintf("RDS instance '%s' is publicly accessible", [rc.address]),
+		"remediation": "Set publicly_accessible = false and reach the database through a private subnet",
+	}
+}
+
+warn contains msg if {
+	some rc in input.resource_changes
+	rc.type == "aws_db_instance"
+	not rc.change.after.storage_encrypted
+	msg := sprintf("RDS instance '%s' does not enable storage encryption", [rc.address])
+}
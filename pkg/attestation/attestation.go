@@ -0,0 +1,233 @@
+package attestation
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/secure-systems-lab/go-securesystemslib/dsse"
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+	"github.com/sigstore/sigstore/pkg/signature"
+)
+
+const (
+	// PayloadType is the DSSE payload type for in-toto statements
+	PayloadType = "application/vnd.in-toto+json"
+	// StatementType is the in-toto statement type used for new attestations
+	StatementType = "https://in-toto.io/Statement/v1"
+)
+
+var (
+	// ErrMissing is returned when an attestation file does not exist
+	ErrMissing = errors.New("attestation not found")
+	// ErrUnsigned is returned when an attestation carries no signature
+	ErrUnsigned = errors.New("attestation is not signed")
+	// ErrBadSignature is returned when no envelope signature verifies
+	ErrBadSignature = errors.New("attestation signature is invalid")
+	// ErrDigestMismatch is returned when the subject digests differ from the plan
+	ErrDigestMismatch = errors.New("attestation is bound to a different plan digest")
+)
+
+// Subject identifies the artifact an attestation is about
+type Subject struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+// Statement is an in-toto statement with an arbitrary predicate
+type Statement struct {
+	Type          string          `json:"_type"`
+	Subject       []Subject       `json:"subject"`
+	PredicateType string          `json:"predicateType"`
+	Predicate     json.RawMessage `json:"predicate"`
+}
+
+// NewStatement builds an in-toto statement about a plan with the given digest
+func NewStatement(name, sha256Hex, predicateType string, predicate interface{}) (*Statement, error) {
+	data, err := json.Marshal(predicate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal predicate: %w", err)
+	}
+
+	return &Statement{
+		Type: StatementType,
+		Subject: []Subject{
+			{Name: name, Digest: map[string]string{"sha256": sha256Hex}},
+		},
+		PredicateType: predicateType,
+		Predicate:     data,
+	}, nil
+}
+
+// AddSubject adds an artifact the statement is about, such as the JSON
+// rendering of the plan
+func (s *Statement) AddSubject(name, sha256Hex string) {
+	s.Subject = append(s.Subject, Subject{Name: name, Digest: map[string]string{"sha256": sha256Hex}})
+}
+
+// Seal wraps a statement payload in a DSSE envelope signed by signer.
+// A nil signer produces an unsigned envelope (keyless signing, where no
+// local key is available to sign attestations).
+func Seal(payload interface{}, signer signature.Signer) (*dsse.Envelope, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal statement: %w", err)
+	}
+
+	env := &dsse.Envelope{
+		PayloadType: PayloadType,
+		Payload:     base64.StdEncoding.EncodeToString(data),
+		Signatures:  []dsse.Signature{},
+	}
+
+	if signer == nil {
+		return env, nil
+	}
+
+	sig, err := signer.SignMessage(bytes.NewReader(dsse.PAE(PayloadType, data)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign attestation: %w", err)
+	}
+
+	keyID := ""
+	if pub, err := signer.PublicKey(); err == nil {
+		keyID, _ = KeyID(pub)
+	}
+
+	env.Signatures = append(env.Signatures, dsse.Signature{
+		KeyID: keyID,
+		Sig:   base64.StdEncoding.EncodeToString(sig),
+	})
+	return env, nil
+}
+
+// Open verifies a DSSE envelope and checks that its subjects are bound to
+// every digest in wantDigests (the plan, and its JSON when paired).
+// It returns the raw statement payload for the caller to decode.
+// With a nil verifier the signature check is skipped and ErrUnsigned is returned
+// alongside the payload, so callers can decide how strict to be.
+func Open(env *dsse.Envelope, verifier signature.Verifier, wantDigests ...string) ([]byte, error) {
+	if env.PayloadType != PayloadType {
+		return nil, fmt.Errorf("%w: unexpected payload type %q", ErrUnsigned, env.PayloadType)
+	}
+
+	payload, err := env.DecodeB64Payload()
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode attestation payload: %w", err)
+	}
+
+	var stmt Statement
+	if err := json.Unmarshal(payload, &stmt); err != nil {
+		return nil, fmt.Errorf("failed to parse attestation statement: %w", err)
+	}
+	for _, want := range wantDigests {
+		if !subjectMatches(stmt.Subject, want) {
+			return nil, ErrDigestMismatch
+		}
+	}
+
+	if len(env.Signatures) == 0 {
+		return payload, ErrUnsigned
+	}
+	if verifier == nil {
+		return payload, fmt.Errorf("%w: no key available to check it", ErrUnsigned)
+	}
+
+	pae := dsse.PAE(env.PayloadType, payload)
+	for _, s := range env.Signatures {
+		sig, err := base64.StdEncoding.DecodeString(s.Sig)
+		if err != nil {
+			continue
+		}
+		if err := verifier.VerifySignature(bytes.NewReader(sig), bytes.NewReader(pae)); err == nil {
+			return payload, nil
+		}
+	}
+
+	return nil, ErrBadSignature
+}
+
+// subjectMatches reports whether any subject carries the wanted sha256 digest
+func subjectMatches(subjects []Subject, wantDigest string) bool {
+	for _, s := range subjects {
+		if s.Digest["sha256"] == wantDigest {
+			return true
+		}
+	}
+	return false
+}
+
+// Write writes a DSSE envelope to disk
+func Write(path string, env *dsse.Envelope) error {
+	data, err := json.MarshalIndent(env, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal envelope: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write envelope: %w", err)
+	}
+
+	return nil
+}
+
+// Read reads a DSSE envelope from disk.
+// Files that are not envelopes (e.g. legacy plain JSON) are reported as unsigned.
+func Read(path string) (*dsse.Envelope, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrMissing
+		}
+		return nil, fmt.Errorf("failed to read attestation: %w", err)
+	}
+
+	env, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s is not a DSSE envelope", ErrUnsigned, path)
+	}
+	return env, nil
+}
+
+// Parse decodes a DSSE envelope, e.g. one stored by the signing service.
+// Data that is not an envelope is reported as unsigned.
+func Parse(data []byte) (*dsse.Envelope, error) {
+	var env dsse.Envelope
+	if err := json.Unmarshal(data, &env); err != nil || env.PayloadType == "" {
+		return nil, fmt.Errorf("%w: not a DSSE envelope", ErrUnsigned)
+	}
+	return &env, nil
+}
+
+// KeyID returns the identifier used for a public key: base64(sha256(DER)).
+// This matches the public key hint cosign puts in Sigstore bundles.
+func KeyID(pub crypto.PublicKey) (string, error) {
+	der, err := cryptoutils.MarshalPublicKeyToDER(pub)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal public key: %w", err)
+	}
+	sum := sha256.Sum256(der)
+	return base64.StdEncoding.EncodeToString(sum[:]), nil
+}
+
+// DigestFile returns the hex-encoded SHA-256 digest of a file
+func DigestFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
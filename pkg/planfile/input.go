@@ -0,0 +1,181 @@
+package planfile
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Input is a plan as given to sign, verify, submit-for-review and policy
+// evaluation: a saved binary plan, its `terraform show -json` rendering, or
+// both. Signatures and attestations of a pair cover both digests.
+type Input struct {
+	Plan string // saved binary plan; empty when only JSON is given
+	JSON string // plan JSON; empty when only the binary plan is given
+}
+
+// NewInput pairs a plan with an optional JSON rendering. Plan JSON given in
+// place of the plan is recognized by its content and used on its own.
+func NewInput(planPath, jsonPath string) (Input, error) {
+	isJSON, err := IsJSON(planPath)
+	if err != nil {
+		return Input{}, err
+	}
+	if !isJSON {
+		return Input{Plan: planPath, JSON: jsonPath}, nil
+	}
+	if jsonPath != "" {
+		return Input{}, fmt.Errorf("%s is plan JSON; give the binary plan with its JSON", planPath)
+	}
+	return Input{JSON: planPath}, nil
+}
+
+// IsJSON reports whether a file holds JSON rather than a plan archive
+func IsJSON(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, fmt.Errorf("failed to open plan: %w", err)
+	}
+	defer f.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return false, fmt.Errorf("failed to read plan: %w", err)
+	}
+	return bytes.HasPrefix(bytes.TrimSpace(head[:n]), []byte("{")), nil
+}
+
+// Path returns the file that names the signature and attestation files
+// (<path>.sig, <path>.policy, ...): the binary plan when there is one
+func (in Input) Path() string {
+	if in.Plan != "" {
+		return in.Plan
+	}
+	return in.JSON
+}
+
+// Paired reports whether a binary plan and its JSON are given together
+func (in Input) Paired() bool {
+	return in.Plan != "" && in.JSON != ""
+}
+
+// Digests returns the hex SHA-256 of the binary plan and of the JSON; the
+// one not given is empty
+func (in Input) Digests() (planDigest, jsonDigest string, err error) {
+	if in.Plan != "" {
+		if planDigest, err = digestFile(in.Plan); err != nil {
+			return "", "", err
+		}
+	}
+	if in.JSON != "" {
+		if jsonDigest, err = digestFile(in.JSON); err != nil {
+			return "", "", err
+		}
+	}
+	return planDigest, jsonDigest, nil
+}
+
+// Subject is a file of an input with its hex SHA-256
+type Subject struct {
+	Path   string
+	Digest string
+}
+
+// Subjects returns the binary plan and the JSON, whichever are given, for
+// the subjects of attestations about the input
+func (in Input) Subjects() ([]Subject, error) {
+	planDigest, jsonDigest, err := in.Digests()
+	if err != nil {
+		return nil, err
+	}
+	var subjects []Subject
+	if in.Plan != "" {
+		subjects = append(subjects, Subject{Path: in.Plan, Digest: planDigest})
+	}
+	if in.JSON != "" {
+		subjects = append(subjects, Subject{Path: in.JSON, Digest: jsonDigest})
+	}
+	return subjects, nil
+}
+
+// Message returns the bytes a signature covers: the file itself, or the
+// Binding of both digests for a pair
+func (in Input) Message() ([]byte, error) {
+	if !in.Paired() {
+		data, err := os.ReadFile(in.Path())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read plan: %w", err)
+		}
+		return data, nil
+	}
+
+	planDigest, jsonDigest, err := in.Digests()
+	if err != nil {
+		return nil, err
+	}
+	return Binding(planDigest, jsonDigest), nil
+}
+
+// Load reads the plan and its `terraform show -json` document. A binary
+// plan is rendered natively (see Plan.JSON). Paired JSON must be the
+// rendering of the binary plan (see Plan.CheckJSON), but the document is
+// still the native rendering: sections only the JSON has, such as
+// configuration, cannot be checked against the plan Terraform applies.
+func (in Input) Load() (*Plan, map[string]interface{}, error) {
+	var plan *Plan
+	if in.Plan != "" {
+		var err error
+		if plan, err = Open(in.Plan); err != nil {
+			return nil, nil, err
+		}
+		if in.JSON == "" {
+			doc, err := plan.JSON()
+			return plan, doc, err
+		}
+	}
+
+	data, err := os.ReadFile(in.JSON)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read plan JSON: %w", err)
+	}
+	rendered, doc, err := ReadJSON(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	if plan == nil {
+		return rendered, doc, nil
+	}
+	if err := plan.CheckJSON(rendered); err != nil {
+		return nil, nil, err
+	}
+	doc, err = plan.JSON()
+	return plan, doc, err
+}
+
+// Binding returns the message signed for a binary plan paired with its JSON
+// rendering. It names both SHA-256 digests, so one signature approves
+// exactly that pair and neither file can be swapped.
+func Binding(planDigest, jsonDigest string) []byte {
+	return []byte(fmt.Sprintf("terrasign-plan-binding/v1\nplan sha256:%s\njson sha256:%s\n",
+		strings.ToLower(planDigest), strings.ToLower(jsonDigest)))
+}
+
+// digestFile returns the hex SHA-256 of a file
+func digestFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("failed to hash %s: %w", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
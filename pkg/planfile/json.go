@@ -0,0 +1,231 @@
+package planfile
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// Parse reads a plan archive held in memory
+func Parse(data []byte) (*Plan, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open plan file (is it a saved terraform plan?): %w", err)
+	}
+	return Read(zr)
+}
+
+// ReadJSON parses `terraform show -json` output for a saved plan. It returns
+// the typed plan, which has no prior state or configuration snapshot, and
+// the document itself for policy evaluation.
+func ReadJSON(data []byte) (*Plan, map[string]interface{}, error) {
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse plan JSON: %w", err)
+	}
+	_, hasVersion := doc["format_version"]
+	_, hasPlanned := doc["planned_values"]
+	if !hasVersion || !hasPlanned {
+		return nil, nil, fmt.Errorf("plan JSON is not `terraform show -json` output for a saved plan")
+	}
+
+	var rendered struct {
+		Plan
+		Variables map[string]struct {
+			Value interface{} `json:"value"`
+		} `json:"variables"`
+		OutputChanges map[string]Change `json:"output_changes"`
+	}
+	if err := json.Unmarshal(data, &rendered); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse plan JSON: %w", err)
+	}
+
+	plan := rendered.Plan
+	if plan.ResourceChanges == nil {
+		plan.ResourceChanges = []ResourceChange{}
+	}
+	plan.Variables = make(map[string]interface{}, len(rendered.Variables))
+	for name, v := range rendered.Variables {
+		plan.Variables[name] = v.Value
+	}
+	for name, change := range rendered.OutputChanges {
+		sensitive, _ := change.AfterSensitive.(bool)
+		plan.OutputChanges = append(plan.OutputChanges, OutputChange{Name: name, Sensitive: sensitive, Change: change})
+	}
+	sort.Slice(plan.OutputChanges, func(i, j int) bool {
+		return plan.OutputChanges[i].Name < plan.OutputChanges[j].Name
+	})
+
+	return &plan, doc, nil
+}
+
+// CheckJSON checks that rendered, read from plan JSON, is the rendering of
+// this plan: the same resource instances and drift, each with the same
+// address parts, provider, actions and reasons, planned values and
+// unknown and sensitive values, the same output changes and the same
+// variables the plan records. A JSON file edited after `terraform show
+// -json`, or rendered from another plan, fails the check.
+func (p *Plan) CheckJSON(rendered *Plan) error {
+	if err := checkChanges("", p.ResourceChanges, rendered.ResourceChanges); err != nil {
+		return err
+	}
+	if err := checkChanges("drift of ", p.ResourceDrift, rendered.ResourceDrift); err != nil {
+		return err
+	}
+
+	outputs := make(map[string]OutputChange, len(p.OutputChanges))
+	for _, oc := range p.OutputChanges {
+		outputs[oc.Name] = oc
+	}
+	for _, oc := range rendered.OutputChanges {
+		orig, ok := outputs[oc.Name]
+		if !ok || orig.Sensitive != oc.Sensitive || checkChange(orig.Change, oc.Change) != "" {
+			return fmt.Errorf("plan JSON does not match the binary plan: output %s differs", oc.Name)
+		}
+		delete(outputs, oc.Name)
+	}
+	for name := range outputs {
+		return fmt.Errorf("plan JSON does not match the binary plan: output %s is missing from the JSON", name)
+	}
+
+	// Variables left at their default are null in the plan; their value
+	// comes from the configuration and cannot be checked
+	for name, value := range p.Variables {
+		if value != nil && !sameValue(value, rendered.Variables[name]) {
+			return fmt.Errorf("plan JSON does not match the binary plan: variable %s differs", name)
+		}
+	}
+
+	return nil
+}
+
+// checkChanges checks rendered resource changes against the plan's
+func checkChanges(kind string, changes, rendered []ResourceChange) error {
+	want := make(map[string]ResourceChange, len(changes))
+	for _, rc := range changes {
+		want[instanceKey(rc)] = rc
+	}
+
+	for _, rc := range rendered {
+		key := instanceKey(rc)
+		orig, ok := want[key]
+		if !ok {
+			return fmt.Errorf("plan JSON does not match the binary plan: %s%s is not in the binary plan", kind, key)
+		}
+		delete(want, key)
+
+		if !reflect.DeepEqual(orig.Change.Actions, rc.Change.Actions) {
+			return fmt.Errorf("plan JSON does not match the binary plan: %s%s is planned as %v, not %v", kind, key, orig.Change.Actions, rc.Change.Actions)
+		}
+		parts := []struct {
+			name       string
+			orig, json interface{}
+		}{
+			{"mode", orig.Mode, rc.Mode},
+			{"type", orig.Type, rc.Type},
+			{"name", orig.Name, rc.Name},
+			{"index", orig.Index, rc.Index},
+			{"module_address", orig.ModuleAddress, rc.ModuleAddress},
+			{"previous_address", orig.PreviousAddress, rc.PreviousAddress},
+			{"provider_name", orig.ProviderName, rc.ProviderName},
+			{"action_reason", orig.ActionReason, rc.ActionReason},
+		}
+		for _, part := range parts {
+			if !sameValue(part.orig, part.json) {
+				return fmt.Errorf("plan JSON does not match the binary plan: %s of %s%s differs", part.name, kind, key)
+			}
+		}
+		if field := checkChange(orig.Change, rc.Change); field != "" {
+			return fmt.Errorf("plan JSON does not match the binary plan: %s of %s%s differs", field, kind, key)
+		}
+	}
+	for key := range want {
+		return fmt.Errorf("plan JSON does not match the binary plan: %s%s is missing from the JSON", kind, key)
+	}
+	return nil
+}
+
+// checkChange compares a rendered change with the plan's and returns the
+// first field that differs, or "". Unknown and sensitive values compare by
+// the paths marked true: `terraform show -json` also lists collections
+// holding none, which only provider schemas tell apart.
+func checkChange(orig, rendered Change) string {
+	switch {
+	case !reflect.DeepEqual(orig.Actions, rendered.Actions):
+		return "actions"
+	case !sameValue(orig.Before, rendered.Before):
+		return "before"
+	case !sameValue(orig.After, rendered.After):
+		return "after"
+	case !sameMarks(orig.AfterUnknown, rendered.AfterUnknown):
+		return "after_unknown"
+	case !sameMarks(orig.BeforeSensitive, rendered.BeforeSensitive):
+		return "before_sensitive"
+	case !sameMarks(orig.AfterSensitive, rendered.AfterSensitive):
+		return "after_sensitive"
+	case !sameValue(orig.ReplacePaths, rendered.ReplacePaths):
+		return "replace_paths"
+	case !sameValue(orig.Importing, rendered.Importing):
+		return "importing"
+	}
+	return ""
+}
+
+// sameMarks compares after_unknown or sensitivity values by the paths they
+// mark true
+func sameMarks(a, b interface{}) bool {
+	return reflect.DeepEqual(markedPaths(normalize(a), "", nil), markedPaths(normalize(b), "", nil))
+}
+
+// markedPaths collects the paths at which a decoded value is true
+func markedPaths(v interface{}, path string, paths map[string]bool) map[string]bool {
+	if paths == nil {
+		paths = map[string]bool{}
+	}
+	switch val := v.(type) {
+	case bool:
+		if val {
+			paths[path] = true
+		}
+	case map[string]interface{}:
+		for k, item := range val {
+			markedPaths(item, fmt.Sprintf("%s[%q]", path, k), paths)
+		}
+	case []interface{}:
+		for i, item := range val {
+			markedPaths(item, fmt.Sprintf("%s[%d]", path, i), paths)
+		}
+	}
+	return paths
+}
+
+// instanceKey identifies a resource change: its address, plus the deposed
+// key for deposed objects
+func instanceKey(rc ResourceChange) string {
+	if rc.Deposed != "" {
+		return rc.Address + " (deposed " + rc.Deposed + ")"
+	}
+	return rc.Address
+}
+
+// sameValue compares planned values after a JSON round trip, so numbers
+// decoded from msgpack and from JSON compare equal
+func sameValue(a, b interface{}) bool {
+	return reflect.DeepEqual(normalize(a), normalize(b))
+}
+
+// normalize converts a value to its generic JSON form
+func normalize(v interface{}) interface{} {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var out interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return v
+	}
+	return out
+}
@@ -0,0 +1,149 @@
+package planfile
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// findChange returns the resource change at address in a plan document
+func findChange(t *testing.T, doc map[string]interface{}, address string) map[string]interface{} {
+	t.Helper()
+	for _, rc := range doc["resource_changes"].([]interface{}) {
+		if change := rc.(map[string]interface{}); change["address"] == address {
+			return change
+		}
+	}
+	t.Fatalf("no resource change for %s", address)
+	return nil
+}
+
+func TestCheckJSON(t *testing.T) {
+	plan, _ := loadFixture(t)
+	data, err := os.ReadFile("testdata/changes.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rendered, _, err := ReadJSON(data)
+	if err != nil {
+		t.Fatalf("ReadJSON: %v", err)
+	}
+	if err := plan.CheckJSON(rendered); err != nil {
+		t.Fatalf("CheckJSON of terraform's own rendering: %v", err)
+	}
+
+	edits := []struct {
+		name string
+		edit func(doc map[string]interface{})
+		want string
+	}{
+		{"mode", func(doc map[string]interface{}) {
+			findChange(t, doc, "terraform_data.created")["mode"] = "data"
+		}, "mode of terraform_data.created"},
+		{"type", func(doc map[string]interface{}) {
+			findChange(t, doc, "terraform_data.deleted")["type"] = "null_resource"
+		}, "type of terraform_data.deleted"},
+		{"provider", func(doc map[string]interface{}) {
+			findChange(t, doc, "terraform_data.updated")["provider_name"] = "registry.terraform.io/hashicorp/null"
+		}, "provider_name of terraform_data.updated"},
+		{"index", func(doc map[string]interface{}) {
+			findChange(t, doc, "terraform_data.counted[1]")["index"] = 0
+		}, "index of terraform_data.counted[1]"},
+		{"action reason", func(doc map[string]interface{}) {
+			delete(findChange(t, doc, "terraform_data.deleted"), "action_reason")
+		}, "action_reason of terraform_data.deleted"},
+		{"actions", func(doc map[string]interface{}) {
+			findChange(t, doc, "terraform_data.replaced")["change"].(map[string]interface{})["actions"] = []interface{}{"update"}
+		}, "terraform_data.replaced is planned as"},
+		{"after", func(doc map[string]interface{}) {
+			change := findChange(t, doc, "terraform_data.updated")["change"].(map[string]interface{})
+			change["after"].(map[string]interface{})["input"] = "v3"
+		}, "after of terraform_data.updated"},
+		{"unknown value", func(doc map[string]interface{}) {
+			change := findChange(t, doc, "terraform_data.unknown")["change"].(map[string]interface{})
+			change["after_unknown"] = map[string]interface{}{}
+		}, "after_unknown of terraform_data.unknown"},
+		{"sensitive value", func(doc map[string]interface{}) {
+			change := findChange(t, doc, "terraform_data.created")["change"].(map[string]interface{})
+			change["after_sensitive"] = map[string]interface{}{}
+		}, "after_sensitive of terraform_data.created"},
+		{"replace paths", func(doc map[string]interface{}) {
+			delete(findChange(t, doc, "terraform_data.replaced")["change"].(map[string]interface{}), "replace_paths")
+		}, "replace_paths of terraform_data.replaced"},
+		{"missing resource", func(doc map[string]interface{}) {
+			doc["resource_changes"] = doc["resource_changes"].([]interface{})[1:]
+		}, "missing from the JSON"},
+		{"output sensitivity", func(doc map[string]interface{}) {
+			output := doc["output_changes"].(map[string]interface{})["secret"].(map[string]interface{})
+			output["before_sensitive"], output["after_sensitive"] = false, false
+		}, "output secret differs"},
+		{"variable", func(doc map[string]interface{}) {
+			doc["variables"].(map[string]interface{})["region"] = map[string]interface{}{"value": "us-east-1"}
+		}, "variable region differs"},
+	}
+	for _, e := range edits {
+		t.Run(e.name, func(t *testing.T) {
+			var doc map[string]interface{}
+			if err := json.Unmarshal(data, &doc); err != nil {
+				t.Fatal(err)
+			}
+			e.edit(doc)
+			edited, _ := json.Marshal(doc)
+			rendered, _, err := ReadJSON(edited)
+			if err != nil {
+				t.Fatalf("ReadJSON: %v", err)
+			}
+			if err := plan.CheckJSON(rendered); err == nil || !strings.Contains(err.Error(), e.want) {
+				t.Errorf("CheckJSON = %v, want an error naming %q", err, e.want)
+			}
+		})
+	}
+}
+
+func TestLoadPairEvaluatesBinaryPlan(t *testing.T) {
+	data, err := os.ReadFile("testdata/changes.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	// Sections the check cannot cover, edited, do not reach policies
+	doc["configuration"] = map[string]interface{}{"provider_config": "edited"}
+	edited, _ := json.Marshal(doc)
+	jsonPath := filepath.Join(t.TempDir(), "changes.json")
+	if err := os.WriteFile(jsonPath, edited, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	in, err := NewInput("testdata/changes.tfplan", jsonPath)
+	if err != nil {
+		t.Fatalf("NewInput: %v", err)
+	}
+	plan, loaded, err := in.Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if plan.Backend == nil {
+		t.Error("Load returned the plan read from JSON, not the binary plan")
+	}
+	if _, ok := loaded["configuration"]; ok {
+		t.Error("Load returned the JSON's configuration section")
+	}
+	if got := findChange(t, loaded, "terraform_data.deleted")["change"].(map[string]interface{})["actions"]; len(got.([]interface{})) != 1 {
+		t.Errorf("deleted actions = %v", got)
+	}
+
+	// A pair that does not match is refused
+	findChange(t, doc, "terraform_data.deleted")["mode"] = "data"
+	edited, _ = json.Marshal(doc)
+	if err := os.WriteFile(jsonPath, edited, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := in.Load(); err == nil {
+		t.Error("Load accepted plan JSON that does not match the binary plan")
+	}
+}
@@ -0,0 +1,291 @@
+package planfile
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// unknownValue stands in for values Terraform only knows after apply. cty
+// encodes them as a msgpack extension. Its type is only known for values
+// of dynamic type, which carry it; see unknownKind.
+type unknownValue struct {
+	kind string // "object" for objects and maps, "list" for lists, sets and tuples, else ""
+}
+
+// typeInfo is a msgpack bin value. cty only writes bin for the JSON type
+// that precedes a value of dynamic type.
+type typeInfo []byte
+
+// errShortMsgpack is returned for truncated msgpack values
+var errShortMsgpack = errors.New("truncated msgpack value")
+
+// Limits on a decoded value, so a crafted plan cannot exhaust the stack with
+// deeply nested arrays or memory with a huge number of small elements
+const (
+	maxMsgpackDepth    = 256
+	maxMsgpackElements = 4 << 20
+)
+
+// decodeMsgpack decodes a cty msgpack value without its schema. Numbers
+// decode to float64 and objects to map[string]interface{}, as encoding/json
+// does for `terraform show -json`; unknown values decode to unknownValue.
+func decodeMsgpack(data []byte) (interface{}, error) {
+	d := &msgpackDecoder{data: data}
+	v, err := d.value()
+	if err != nil {
+		return nil, err
+	}
+	if d.pos != len(data) {
+		return nil, fmt.Errorf("%d trailing bytes after msgpack value", len(data)-d.pos)
+	}
+	return v, nil
+}
+
+// msgpackDecoder reads msgpack values from a buffer
+type msgpackDecoder struct {
+	data []byte
+	pos  int
+
+	depth    int // Arrays and maps being decoded
+	elements int // Values decoded so far
+}
+
+// next consumes n bytes
+func (d *msgpackDecoder) next(n int) ([]byte, error) {
+	if n < 0 || d.pos+n > len(d.data) {
+		return nil, errShortMsgpack
+	}
+	b := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+// uint consumes a big-endian unsigned integer of n bytes
+func (d *msgpackDecoder) uint(n int) (uint64, error) {
+	b, err := d.next(n)
+	if err != nil {
+		return 0, err
+	}
+	switch n {
+	case 1:
+		return uint64(b[0]), nil
+	case 2:
+		return uint64(binary.BigEndian.Uint16(b)), nil
+	case 4:
+		return uint64(binary.BigEndian.Uint32(b)), nil
+	default:
+		return binary.BigEndian.Uint64(b), nil
+	}
+}
+
+// length consumes a length prefix of n bytes
+func (d *msgpackDecoder) length(n int) (int, error) {
+	l, err := d.uint(n)
+	if err != nil {
+		return 0, err
+	}
+	if l > uint64(len(d.data)) {
+		return 0, errShortMsgpack
+	}
+	return int(l), nil
+}
+
+// value decodes the next value
+func (d *msgpackDecoder) value() (interface{}, error) {
+	d.elements++
+	if d.elements > maxMsgpackElements {
+		return nil, fmt.Errorf("msgpack value has more than %d elements", maxMsgpackElements)
+	}
+
+	b, err := d.next(1)
+	if err != nil {
+		return nil, err
+	}
+	c := b[0]
+
+	switch {
+	case c <= 0x7f:
+		return float64(c), nil
+	case c >= 0xe0:
+		return float64(int8(c)), nil
+	case c <= 0x8f:
+		return d.mapOf(int(c & 0x0f))
+	case c <= 0x9f:
+		return d.arrayOf(int(c & 0x0f))
+	case c <= 0xbf:
+		return d.str(int(c & 0x1f))
+	}
+
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, err := d.length(1 << (c - 0xc4))
+		if err != nil {
+			return nil, err
+		}
+		raw, err := d.next(n)
+		if err != nil {
+			return nil, err
+		}
+		return typeInfo(raw), nil
+	case 0xc7, 0xc8, 0xc9:
+		n, err := d.length(1 << (c - 0xc7))
+		if err != nil {
+			return nil, err
+		}
+		return d.ext(n)
+	case 0xca:
+		n, err := d.uint(4)
+		if err != nil {
+			return nil, err
+		}
+		return float64(math.Float32frombits(uint32(n))), nil
+	case 0xcb:
+		n, err := d.uint(8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(n), nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		n, err := d.uint(1 << (c - 0xcc))
+		if err != nil {
+			return nil, err
+		}
+		return float64(n), nil
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (c - 0xd0)
+		n, err := d.uint(size)
+		if err != nil {
+			return nil, err
+		}
+		shift := uint(64 - 8*size)
+		return float64(int64(n<<shift) >> shift), nil
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return d.ext(1 << (c - 0xd4))
+	case 0xd9, 0xda, 0xdb:
+		n, err := d.length(1 << (c - 0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return d.str(n)
+	case 0xdc, 0xdd:
+		n, err := d.length(2 << (c - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return d.arrayOf(n)
+	case 0xde, 0xdf:
+		n, err := d.length(2 << (c - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return d.mapOf(n)
+	}
+
+	return nil, fmt.Errorf("unsupported msgpack type 0x%02x", c)
+}
+
+// str decodes a string of n bytes
+func (d *msgpackDecoder) str(n int) (interface{}, error) {
+	b, err := d.next(n)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// ext skips an extension of n bytes. cty only uses extensions for unknown
+// values (with optional refinements, which are not needed here).
+func (d *msgpackDecoder) ext(n int) (interface{}, error) {
+	if _, err := d.next(1 + n); err != nil {
+		return nil, err
+	}
+	return unknownValue{}, nil
+}
+
+// enter starts decoding a nested array or map
+func (d *msgpackDecoder) enter() error {
+	if d.depth >= maxMsgpackDepth {
+		return fmt.Errorf("msgpack value nested deeper than %d levels", maxMsgpackDepth)
+	}
+	d.depth++
+	return nil
+}
+
+// leave finishes decoding a nested array or map
+func (d *msgpackDecoder) leave() {
+	d.depth--
+}
+
+// arrayOf decodes an array of n values. cty writes a value of dynamic type
+// as [type JSON, value]; that pair decodes to the value alone.
+func (d *msgpackDecoder) arrayOf(n int) (interface{}, error) {
+	if err := d.enter(); err != nil {
+		return nil, err
+	}
+	defer d.leave()
+
+	out := make([]interface{}, 0, min(n, len(d.data)-d.pos))
+	for i := 0; i < n; i++ {
+		v, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	if len(out) == 2 {
+		if ty, dynamic := out[0].(typeInfo); dynamic {
+			if _, unknown := out[1].(unknownValue); unknown {
+				return unknownValue{kind: unknownKind(ty)}, nil
+			}
+			return out[1], nil
+		}
+	}
+	return out, nil
+}
+
+// unknownKind classifies a cty type in JSON form, e.g. "string",
+// ["map","string"] or ["object",{...}], as a collection kind
+func unknownKind(ty typeInfo) string {
+	var t []interface{}
+	if json.Unmarshal(ty, &t) != nil || len(t) == 0 {
+		return "" // A primitive type is a plain string
+	}
+	switch t[0] {
+	case "object", "map":
+		return "object"
+	case "list", "set", "tuple":
+		return "list"
+	}
+	return ""
+}
+
+// mapOf decodes a map (or object) of n entries
+func (d *msgpackDecoder) mapOf(n int) (interface{}, error) {
+	if err := d.enter(); err != nil {
+		return nil, err
+	}
+	defer d.leave()
+
+	out := make(map[string]interface{}, min(n, len(d.data)-d.pos))
+	for i := 0; i < n; i++ {
+		k, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		v, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		out[fmt.Sprint(k)] = v
+	}
+	return out, nil
+}
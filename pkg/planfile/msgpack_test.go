@@ -0,0 +1,54 @@
+package planfile
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+// nestedArrays returns depth single-element arrays (fixarray 0x91) around nil
+func nestedArrays(depth int) []byte {
+	return append(bytes.Repeat([]byte{0x91}, depth), 0xc0)
+}
+
+func TestDecodeMsgpackDeeplyNested(t *testing.T) {
+	// Far deeper than the stack would survive without a limit
+	_, err := decodeMsgpack(nestedArrays(10_000_000))
+	if err == nil || !strings.Contains(err.Error(), "nested deeper") {
+		t.Fatalf("decodeMsgpack of deeply nested arrays = %v, want a depth error", err)
+	}
+
+	// Maps count towards the same depth: {"a": {"a": ...}}
+	nestedMaps := append(bytes.Repeat([]byte{0x81, 0xa1, 'a'}, maxMsgpackDepth+1), 0xc0)
+	if _, err := decodeMsgpack(nestedMaps); err == nil {
+		t.Fatal("decodeMsgpack accepted maps nested beyond the limit")
+	}
+
+	v, err := decodeMsgpack(nestedArrays(maxMsgpackDepth))
+	if err != nil {
+		t.Fatalf("decodeMsgpack at the depth limit: %v", err)
+	}
+	for i := 0; i < maxMsgpackDepth; i++ {
+		array, ok := v.([]interface{})
+		if !ok || len(array) != 1 {
+			t.Fatalf("level %d = %#v, want a one-element array", i, v)
+		}
+		v = array[0]
+	}
+	if v != nil {
+		t.Errorf("innermost value = %#v, want nil", v)
+	}
+}
+
+func TestDecodeMsgpackTooManyElements(t *testing.T) {
+	// array32 of nils, one more than the limit allows with the array itself
+	data := []byte{0xdd, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(data[1:], maxMsgpackElements)
+	data = append(data, bytes.Repeat([]byte{0xc0}, maxMsgpackElements)...)
+
+	_, err := decodeMsgpack(data)
+	if err == nil || !strings.Contains(err.Error(), "elements") {
+		t.Fatalf("decodeMsgpack of %d elements = %v, want an element limit error", maxMsgpackElements+1, err)
+	}
+}
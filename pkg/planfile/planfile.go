@@ -0,0 +1,358 @@
+// Package planfile reads saved Terraform plan files (the zip archives
+// written by `terraform plan -out`) without running Terraform: the resource
+// changes, prior state and configuration snapshot are decoded into typed
+// structs, so plans can be checked on machines without the matching
+// Terraform version or provider plugins.
+package planfile
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Entry names inside a plan archive
+const (
+	planEntry       = "tfplan"
+	priorStateEntry = "tfstate"
+	configPrefix    = "tfconfig/"
+	configManifest  = configPrefix + "modules.json"
+	configModule    = configPrefix + "m-"
+)
+
+// planFormatVersion is the tfplan format written since Terraform 0.12
+const planFormatVersion = 3
+
+// maxEntrySize bounds each decompressed archive entry
+const maxEntrySize = 256 << 20
+
+// Plan is the content of a saved Terraform plan. JSON field names follow
+// `terraform show -json` where the two overlap.
+type Plan struct {
+	FormatVersion    uint64                 `json:"-"`
+	TerraformVersion string                 `json:"terraform_version"`
+	Errored          bool                   `json:"errored"`
+	Applyable        bool                   `json:"applyable"`
+	Complete         bool                   `json:"complete"`
+	Variables        map[string]interface{} `json:"-"`
+	ResourceChanges  []ResourceChange       `json:"resource_changes"`
+	ResourceDrift    []ResourceChange       `json:"resource_drift,omitempty"`
+	OutputChanges    []OutputChange         `json:"-"`
+	PriorState       *State                 `json:"-"`
+	Config           *ConfigSnapshot        `json:"-"`
+
+	// Backend is the backend and workspace the plan applies to; only saved
+	// plans record it
+	Backend *Backend `json:"-"`
+}
+
+// Backend is the state backend a saved plan was created with. Terraform
+// applies the plan to this workspace.
+type Backend struct {
+	Type      string
+	Workspace string
+}
+
+// ResourceChange is a planned change to one resource instance
+type ResourceChange struct {
+	Address         string      `json:"address"`
+	PreviousAddress string      `json:"previous_address,omitempty"`
+	ModuleAddress   string      `json:"module_address,omitempty"`
+	Mode            string      `json:"mode"`
+	Type            string      `json:"type"`
+	Name            string      `json:"name"`
+	Index           interface{} `json:"index,omitempty"` // number for count, string for for_each
+	Deposed         string      `json:"deposed,omitempty"`
+	ProviderName    string      `json:"provider_name"`
+	Change          Change      `json:"change"`
+	ActionReason    string      `json:"action_reason,omitempty"`
+}
+
+// Change holds the actions and before/after values of a change. Unknown
+// values are nil in After and marked in AfterUnknown; sensitive values are
+// marked in BeforeSensitive and AfterSensitive.
+type Change struct {
+	Actions         []string        `json:"actions"`
+	Before          interface{}     `json:"before"`
+	After           interface{}     `json:"after"`
+	AfterUnknown    interface{}     `json:"after_unknown"`
+	BeforeSensitive interface{}     `json:"before_sensitive"`
+	AfterSensitive  interface{}     `json:"after_sensitive"`
+	ReplacePaths    [][]interface{} `json:"replace_paths,omitempty"`
+	Importing       *Importing      `json:"importing,omitempty"`
+}
+
+// Importing marks a change that imports an existing object
+type Importing struct {
+	ID string `json:"id"`
+}
+
+// OutputChange is a planned change to a root module output
+type OutputChange struct {
+	Name      string
+	Sensitive bool
+	Change    Change
+}
+
+// State is the prior state stored in the plan (state format version 4)
+type State struct {
+	Version          int             `json:"version"`
+	TerraformVersion string          `json:"terraform_version"`
+	Serial           uint64          `json:"serial"`
+	Lineage          string          `json:"lineage"`
+	Resources        []StateResource `json:"resources"`
+}
+
+// StateResource is a resource recorded in state
+type StateResource struct {
+	Module    string          `json:"module,omitempty"`
+	Mode      string          `json:"mode"`
+	Type      string          `json:"type"`
+	Name      string          `json:"name"`
+	Provider  string          `json:"provider"`
+	Instances []StateInstance `json:"instances"`
+}
+
+// StateInstance is one instance of a resource in state
+type StateInstance struct {
+	IndexKey     interface{}            `json:"index_key,omitempty"`
+	Deposed      string                 `json:"deposed,omitempty"`
+	Attributes   map[string]interface{} `json:"attributes"`
+	Dependencies []string               `json:"dependencies,omitempty"`
+}
+
+// ConfigSnapshot is the configuration captured when the plan was created
+type ConfigSnapshot struct {
+	Modules []ConfigModule
+}
+
+// ConfigModule is one module of the configuration snapshot. The root
+// module has an empty Key.
+type ConfigModule struct {
+	Key     string            `json:"Key"`
+	Source  string            `json:"Source,omitempty"`
+	Version string            `json:"Version,omitempty"`
+	Dir     string            `json:"Dir"`
+	Files   map[string]string `json:"-"` // file name -> source
+}
+
+// Open reads a saved plan file
+func Open(path string) (*Plan, error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open plan file (is it a saved terraform plan?): %w", err)
+	}
+	defer zr.Close()
+
+	return Read(&zr.Reader)
+}
+
+// Read reads a plan from an opened plan archive
+func Read(zr *zip.Reader) (*Plan, error) {
+	var plan *Plan
+	var state *State
+	var manifest []ConfigModule
+	files := map[string]map[string]string{}
+
+	for _, f := range zr.File {
+		switch {
+		case f.Name == planEntry:
+			data, err := readEntry(f)
+			if err != nil {
+				return nil, err
+			}
+			if plan, err = parsePlanProto(data); err != nil {
+				return nil, fmt.Errorf("failed to decode plan: %w", err)
+			}
+		case f.Name == priorStateEntry:
+			data, err := readEntry(f)
+			if err != nil {
+				return nil, err
+			}
+			state = &State{}
+			if err := json.Unmarshal(data, state); err != nil {
+				return nil, fmt.Errorf("failed to parse prior state: %w", err)
+			}
+		case f.Name == configManifest:
+			data, err := readEntry(f)
+			if err != nil {
+				return nil, err
+			}
+			if err := json.Unmarshal(data, &manifest); err != nil {
+				return nil, fmt.Errorf("failed to parse configuration manifest: %w", err)
+			}
+		case strings.HasPrefix(f.Name, configModule) && !f.FileInfo().IsDir():
+			key, name, ok := strings.Cut(strings.TrimPrefix(f.Name, configModule), "/")
+			if !ok {
+				continue
+			}
+			data, err := readEntry(f)
+			if err != nil {
+				return nil, err
+			}
+			if files[key] == nil {
+				files[key] = map[string]string{}
+			}
+			files[key][name] = string(data)
+		}
+	}
+
+	if plan == nil {
+		return nil, fmt.Errorf("plan archive has no %s entry (is it a saved terraform plan?)", planEntry)
+	}
+	if plan.FormatVersion != planFormatVersion {
+		return nil, fmt.Errorf("unsupported plan file format version %d; only version %d is supported", plan.FormatVersion, planFormatVersion)
+	}
+	plan.PriorState = state
+
+	plan.Config = &ConfigSnapshot{Modules: manifest}
+	for i := range plan.Config.Modules {
+		module := &plan.Config.Modules[i]
+		module.Files = files[module.Key]
+		delete(files, module.Key)
+	}
+	// Files of modules missing from the manifest are still worth showing
+	for key, moduleFiles := range files {
+		plan.Config.Modules = append(plan.Config.Modules, ConfigModule{Key: key, Files: moduleFiles})
+	}
+	sort.Slice(plan.Config.Modules, func(i, j int) bool {
+		return plan.Config.Modules[i].Key < plan.Config.Modules[j].Key
+	})
+
+	return plan, nil
+}
+
+// readEntry reads one file from the archive
+func readEntry(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", f.Name, err)
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(io.LimitReader(rc, maxEntrySize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", f.Name, err)
+	}
+	if len(data) > maxEntrySize {
+		return nil, fmt.Errorf("%s is larger than %d bytes", f.Name, maxEntrySize)
+	}
+	return data, nil
+}
+
+// fillAddress derives the module address, mode, type, name and index from
+// the instance address, e.g. module.net.aws_subnet.private["a"]
+func (rc *ResourceChange) fillAddress() {
+	var rest string
+	rc.ModuleAddress, rest = splitModule(rc.Address)
+
+	rc.Mode = "managed"
+	if strings.HasPrefix(rest, "data.") {
+		rc.Mode = "data"
+		rest = strings.TrimPrefix(rest, "data.")
+	}
+
+	if i := strings.Index(rest, "["); i >= 0 && strings.HasSuffix(rest, "]") {
+		rc.Index = parseIndex(rest[i+1 : len(rest)-1])
+		rest = rest[:i]
+	}
+	rc.Type, rc.Name, _ = strings.Cut(rest, ".")
+}
+
+// splitModule splits an instance address into its module path (e.g.
+// module.net["a"].module.subnets) and the resource part
+func splitModule(addr string) (module, rest string) {
+	end := 0
+	for strings.HasPrefix(addr[end:], "module.") {
+		i := end + len("module.")
+		for i < len(addr) && addr[i] != '.' && addr[i] != '[' {
+			i++
+		}
+		if i < len(addr) && addr[i] == '[' {
+			i = keyEnd(addr, i)
+		}
+		if i >= len(addr) || addr[i] != '.' {
+			break
+		}
+		end = i + 1
+	}
+	if end == 0 {
+		return "", addr
+	}
+	return addr[:end-1], addr[end:]
+}
+
+// keyEnd returns the index just past the instance key that opens at
+// addr[open], skipping brackets inside quoted keys
+func keyEnd(addr string, open int) int {
+	quoted := false
+	for i := open + 1; i < len(addr); i++ {
+		switch {
+		case quoted && addr[i] == '\\':
+			i++
+		case addr[i] == '"':
+			quoted = !quoted
+		case !quoted && addr[i] == ']':
+			return i + 1
+		}
+	}
+	return len(addr)
+}
+
+// parseIndex converts an instance key to the index of `terraform show
+// -json`: a number for count, a string for for_each
+func parseIndex(key string) interface{} {
+	if s, err := strconv.Unquote(key); err == nil {
+		return s
+	}
+	if n, err := strconv.Atoi(key); err == nil {
+		return n
+	}
+	return key
+}
+
+// JSON converts the plan into the parts of the `terraform show -json`
+// document that policies read: resource_changes, resource_drift,
+// output_changes, variables and terraform_version. The configuration
+// section is missing, since it needs provider schemas, and variables left
+// at their default are null, since the default is in the configuration.
+func (p *Plan) JSON() (map[string]interface{}, error) {
+	outputs := make(map[string]interface{}, len(p.OutputChanges))
+	for _, oc := range p.OutputChanges {
+		outputs[oc.Name] = oc.Change
+	}
+	variables := make(map[string]interface{}, len(p.Variables))
+	for name, value := range p.Variables {
+		variables[name] = map[string]interface{}{"value": value}
+	}
+
+	data, err := json.Marshal(struct {
+		FormatVersion string                 `json:"format_version"`
+		Variables     map[string]interface{} `json:"variables,omitempty"`
+		OutputChanges map[string]interface{} `json:"output_changes,omitempty"`
+		*Plan
+	}{"1.2", variables, outputs, p})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode plan: %w", err)
+	}
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to decode plan: %w", err)
+	}
+	return doc, nil
+}
+
+// FileNames returns the module's file names in order
+func (m ConfigModule) FileNames() []string {
+	names := make([]string, 0, len(m.Files))
+	for name := range m.Files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
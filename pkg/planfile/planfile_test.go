@@ -0,0 +1,196 @@
+package planfile
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// The fixtures are a real saved plan and its `terraform show -json`
+// rendering; testdata/generate.sh recreates them
+
+// loadFixture returns the decoded changes.tfplan and changes.json
+func loadFixture(t *testing.T) (*Plan, map[string]interface{}) {
+	t.Helper()
+	plan, err := Open("testdata/changes.tfplan")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	data, err := os.ReadFile("testdata/changes.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rendered map[string]interface{}
+	if err := json.Unmarshal(data, &rendered); err != nil {
+		t.Fatal(err)
+	}
+	return plan, rendered
+}
+
+// comparable normalizes a JSON value for comparison: attributes holding
+// null are dropped, as `terraform show -json` omits some of them
+func comparable(v interface{}) interface{} {
+	v = normalize(v)
+	var drop func(v interface{}) interface{}
+	drop = func(v interface{}) interface{} {
+		switch val := v.(type) {
+		case map[string]interface{}:
+			for k, item := range val {
+				if item == nil {
+					delete(val, k)
+					continue
+				}
+				val[k] = drop(item)
+			}
+		case []interface{}:
+			for i, item := range val {
+				val[i] = drop(item)
+			}
+		}
+		return v
+	}
+	return drop(v)
+}
+
+// byAddress indexes resource_changes by address
+func byAddress(t *testing.T, doc map[string]interface{}) map[string]interface{} {
+	t.Helper()
+	changes, _ := doc["resource_changes"].([]interface{})
+	out := map[string]interface{}{}
+	for _, rc := range changes {
+		address, _ := rc.(map[string]interface{})["address"].(string)
+		out[address] = comparable(rc)
+	}
+	return out
+}
+
+func TestReadSavedPlan(t *testing.T) {
+	plan, rendered := loadFixture(t)
+
+	if plan.Backend == nil || plan.Backend.Type != "local" || plan.Backend.Workspace != "staging" {
+		t.Errorf("Backend = %+v, want the local backend's staging workspace", plan.Backend)
+	}
+	if plan.TerraformVersion != rendered["terraform_version"] {
+		t.Errorf("TerraformVersion = %q, want %v", plan.TerraformVersion, rendered["terraform_version"])
+	}
+	if plan.PriorState == nil || len(plan.PriorState.Resources) != 3 {
+		t.Errorf("PriorState = %+v, want the three applied resources", plan.PriorState)
+	}
+	if plan.Config == nil || len(plan.Config.Modules) != 1 || plan.Config.Modules[0].Files["main.tf"] == "" {
+		t.Errorf("Config = %+v, want the root module's main.tf", plan.Config)
+	}
+
+	doc, err := plan.JSON()
+	if err != nil {
+		t.Fatalf("JSON: %v", err)
+	}
+
+	// Every resource change renders as terraform renders it
+	got, want := byAddress(t, doc), byAddress(t, rendered)
+	if len(got) != len(want) {
+		t.Errorf("%d resource changes, want %d", len(got), len(want))
+	}
+	for address, change := range want {
+		if !reflect.DeepEqual(got[address], change) {
+			gotJSON, _ := json.Marshal(got[address])
+			wantJSON, _ := json.Marshal(change)
+			t.Errorf("%s:\n got %s\nwant %s", address, gotJSON, wantJSON)
+		}
+	}
+
+	// Spot checks of what the fixture is for
+	checks := []struct {
+		address, path string
+		want          interface{}
+	}{
+		{"terraform_data.updated", "change.actions", []interface{}{"update"}},
+		{"terraform_data.replaced", "change.actions", []interface{}{"delete", "create"}},
+		{"terraform_data.replaced", "change.replace_paths", []interface{}{[]interface{}{"triggers_replace"}}},
+		{"terraform_data.deleted", "action_reason", "delete_because_no_resource_config"},
+		{"terraform_data.created", "change.after_sensitive.input.secret", true},
+		{"terraform_data.unknown", "change.after_unknown.input", true},
+		{"terraform_data.counted[1]", "index", float64(1)},
+		{`terraform_data.each["b"]`, "index", "b"},
+		{"data.terraform_remote_state.deferred", "mode", "data"},
+		{"data.terraform_remote_state.deferred", "change.actions", []interface{}{"read"}},
+	}
+	for _, c := range checks {
+		v := got[c.address]
+		for _, key := range strings.Split(c.path, ".") {
+			m, _ := v.(map[string]interface{})
+			v = m[key]
+		}
+		if !reflect.DeepEqual(v, c.want) {
+			t.Errorf("%s %s = %#v, want %#v", c.address, c.path, v, c.want)
+		}
+	}
+
+	gotOutputs, wantOutputs := comparable(doc["output_changes"]), comparable(rendered["output_changes"])
+	if !reflect.DeepEqual(gotOutputs, wantOutputs) {
+		gotJSON, _ := json.Marshal(gotOutputs)
+		wantJSON, _ := json.Marshal(wantOutputs)
+		t.Errorf("output_changes:\n got %s\nwant %s", gotJSON, wantJSON)
+	}
+
+	// Variables left at their default are not in the plan; the rest are
+	variables, _ := doc["variables"].(map[string]interface{})
+	if !reflect.DeepEqual(variables["region"], map[string]interface{}{"value": "eu-west-1"}) {
+		t.Errorf("variable region = %#v", variables["region"])
+	}
+}
+
+func TestReadDamagedPlan(t *testing.T) {
+	data, err := os.ReadFile("testdata/changes.tfplan")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Parse(data[:len(data)/2]); err == nil {
+		t.Error("Parse accepted a truncated archive")
+	}
+
+	// Flipping bytes inside a compressed entry fails its checksum
+	corrupt := bytes.Clone(data)
+	for i := 100; i < 110; i++ {
+		corrupt[i] ^= 0xff
+	}
+	if _, err := Parse(corrupt); err == nil {
+		t.Error("Parse accepted an archive with a corrupt entry")
+	}
+
+	// A well-formed archive whose tfplan entry is cut short
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range zr.File {
+		entry, err := readEntry(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.Name == planEntry {
+			entry = entry[:len(entry)-7]
+		}
+		w, err := zw.Create(f.Name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(entry)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Parse(buf.Bytes()); err == nil || !strings.Contains(err.Error(), "failed to decode plan") {
+		t.Errorf("Parse of a truncated tfplan entry = %v, want a decode error", err)
+	}
+
+	if _, err := Parse([]byte(`{"format_version":"1.2"}`)); err == nil {
+		t.Error("Parse accepted plan JSON as a saved plan")
+	}
+}
@@ -0,0 +1,613 @@
+package planfile
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Field numbers from Terraform's internal/plans/planproto/planfile.proto.
+// Only the fields TerraSign reads are listed; the rest are skipped.
+const (
+	planFieldVersion          = 1
+	planFieldVariables        = 2
+	planFieldResourceChanges  = 3
+	planFieldOutputChanges    = 4
+	planFieldBackend          = 13
+	planFieldTerraformVersion = 14
+	planFieldResourceDrift    = 18
+	planFieldErrored          = 20
+	planFieldApplyable        = 25
+	planFieldComplete         = 26
+
+	backendFieldType      = 1
+	backendFieldWorkspace = 3
+
+	rcFieldDeposedKey      = 7
+	rcFieldProvider        = 8
+	rcFieldChange          = 9
+	rcFieldRequiredReplace = 11
+	rcFieldActionReason    = 12
+	rcFieldAddr            = 13
+	rcFieldPrevRunAddr     = 14
+
+	changeFieldAction               = 1
+	changeFieldValues               = 2
+	changeFieldBeforeSensitivePaths = 3
+	changeFieldAfterSensitivePaths  = 4
+	changeFieldImporting            = 5
+
+	outputFieldName      = 1
+	outputFieldChange    = 2
+	outputFieldSensitive = 3
+
+	importingFieldID = 1
+
+	dynamicValueFieldMsgpack = 1
+
+	pathFieldSteps         = 1
+	stepFieldAttributeName = 1
+	stepFieldElementKey    = 2
+
+	mapEntryFieldKey   = 1
+	mapEntryFieldValue = 2
+)
+
+// protoActions maps planproto.Action to `terraform show -json` actions
+var protoActions = map[uint64][]string{
+	0: {"no-op"},
+	1: {"create"},
+	2: {"read"},
+	3: {"update"},
+	5: {"delete"},
+	6: {"delete", "create"},
+	7: {"create", "delete"},
+	8: {"forget"},
+	9: {"create", "forget"},
+}
+
+// protoActionReasons maps planproto.ResourceInstanceActionReason to the
+// action_reason of `terraform show -json`
+var protoActionReasons = map[uint64]string{
+	1:  "replace_because_tainted",
+	2:  "replace_by_request",
+	3:  "replace_because_cannot_update",
+	4:  "delete_because_no_resource_config",
+	5:  "delete_because_wrong_repetition",
+	6:  "delete_because_count_index",
+	7:  "delete_because_each_key",
+	8:  "delete_because_no_module",
+	9:  "replace_by_triggers",
+	10: "read_because_config_unknown",
+	11: "read_because_dependency_pending",
+	12: "delete_because_no_move_target",
+	13: "read_because_check_nested",
+}
+
+// rawChange is a planproto.Change before its values are decoded
+type rawChange struct {
+	action          uint64
+	values          [][]byte
+	beforeSensitive [][]interface{}
+	afterSensitive  [][]interface{}
+	importID        *string
+}
+
+// fields calls fn for each top-level field of a protobuf message, with the
+// payload of length-delimited fields or the value of varints
+func fields(msg []byte, fn func(num protowire.Number, raw []byte, v uint64) error) error {
+	for len(msg) > 0 {
+		num, typ, n := protowire.ConsumeTag(msg)
+		if n < 0 {
+			return fmt.Errorf("invalid protobuf tag: %w", protowire.ParseError(n))
+		}
+		msg = msg[n:]
+
+		var raw []byte
+		var v uint64
+		switch typ {
+		case protowire.VarintType:
+			v, n = protowire.ConsumeVarint(msg)
+		case protowire.BytesType:
+			raw, n = protowire.ConsumeBytes(msg)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, msg)
+		}
+		if n < 0 {
+			return fmt.Errorf("invalid protobuf field %d: %w", num, protowire.ParseError(n))
+		}
+		msg = msg[n:]
+
+		if err := fn(num, raw, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parsePlanProto decodes the tfplan protobuf message
+func parsePlanProto(data []byte) (*Plan, error) {
+	plan := &Plan{Variables: map[string]interface{}{}, ResourceChanges: []ResourceChange{}}
+
+	err := fields(data, func(num protowire.Number, raw []byte, v uint64) error {
+		switch num {
+		case planFieldVersion:
+			plan.FormatVersion = v
+		case planFieldErrored:
+			plan.Errored = v != 0
+		case planFieldApplyable:
+			plan.Applyable = v != 0
+		case planFieldComplete:
+			plan.Complete = v != 0
+		case planFieldTerraformVersion:
+			plan.TerraformVersion = string(raw)
+		case planFieldVariables:
+			name, value, err := parseVariable(raw)
+			if err != nil {
+				return err
+			}
+			plan.Variables[name] = value
+		case planFieldResourceChanges, planFieldResourceDrift:
+			rc, err := parseResourceChange(raw)
+			if err != nil {
+				return err
+			}
+			if num == planFieldResourceChanges {
+				plan.ResourceChanges = append(plan.ResourceChanges, *rc)
+			} else {
+				plan.ResourceDrift = append(plan.ResourceDrift, *rc)
+			}
+		case planFieldBackend:
+			backend, err := parseBackend(raw)
+			if err != nil {
+				return err
+			}
+			plan.Backend = backend
+		case planFieldOutputChanges:
+			oc, err := parseOutputChange(raw)
+			if err != nil {
+				return err
+			}
+			plan.OutputChanges = append(plan.OutputChanges, *oc)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return plan, nil
+}
+
+// parseBackend decodes the backend the plan was created with; its config
+// is not needed
+func parseBackend(data []byte) (*Backend, error) {
+	backend := &Backend{}
+	err := fields(data, func(num protowire.Number, raw []byte, _ uint64) error {
+		switch num {
+		case backendFieldType:
+			backend.Type = string(raw)
+		case backendFieldWorkspace:
+			backend.Workspace = string(raw)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return backend, nil
+}
+
+// parseVariable decodes a map<string, DynamicValue> entry
+func parseVariable(data []byte) (string, interface{}, error) {
+	var name string
+	var value interface{}
+	err := fields(data, func(num protowire.Number, raw []byte, _ uint64) error {
+		switch num {
+		case mapEntryFieldKey:
+			name = string(raw)
+		case mapEntryFieldValue:
+			v, err := parseDynamicValue(raw)
+			if err != nil {
+				return fmt.Errorf("variable %q: %w", name, err)
+			}
+			value = v
+		}
+		return nil
+	})
+	return name, value, err
+}
+
+// parseResourceChange decodes a planproto.ResourceInstanceChange
+func parseResourceChange(data []byte) (*ResourceChange, error) {
+	rc := &ResourceChange{}
+	var change *rawChange
+	var replace [][]interface{}
+
+	err := fields(data, func(num protowire.Number, raw []byte, v uint64) error {
+		switch num {
+		case rcFieldAddr:
+			rc.Address = string(raw)
+		case rcFieldPrevRunAddr:
+			rc.PreviousAddress = string(raw)
+		case rcFieldDeposedKey:
+			rc.Deposed = string(raw)
+		case rcFieldProvider:
+			rc.ProviderName = providerName(string(raw))
+		case rcFieldActionReason:
+			rc.ActionReason = protoActionReasons[v]
+		case rcFieldRequiredReplace:
+			path, err := parsePath(raw)
+			if err != nil {
+				return err
+			}
+			replace = append(replace, path)
+		case rcFieldChange:
+			c, err := parseChange(raw)
+			if err != nil {
+				return err
+			}
+			change = c
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if rc.PreviousAddress == rc.Address {
+		rc.PreviousAddress = ""
+	}
+
+	rc.fillAddress()
+	if change != nil {
+		decoded, err := change.decode()
+		if err != nil {
+			return nil, fmt.Errorf("resource %s: %w", rc.Address, err)
+		}
+		rc.Change = *decoded
+	}
+	rc.Change.ReplacePaths = replace
+
+	return rc, nil
+}
+
+// parseOutputChange decodes a planproto.OutputChange
+func parseOutputChange(data []byte) (*OutputChange, error) {
+	oc := &OutputChange{}
+	var change *rawChange
+
+	err := fields(data, func(num protowire.Number, raw []byte, v uint64) error {
+		switch num {
+		case outputFieldName:
+			oc.Name = string(raw)
+		case outputFieldSensitive:
+			oc.Sensitive = v != 0
+		case outputFieldChange:
+			c, err := parseChange(raw)
+			if err != nil {
+				return err
+			}
+			change = c
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if change != nil {
+		decoded, err := change.decode()
+		if err != nil {
+			return nil, fmt.Errorf("output %s: %w", oc.Name, err)
+		}
+		oc.Change = *decoded
+	}
+	// Outputs only record whether they are sensitive, and a wholly known
+	// output is not unknown anywhere
+	oc.Change.BeforeSensitive, oc.Change.AfterSensitive = oc.Sensitive, oc.Sensitive
+	if shape, ok := oc.Change.AfterUnknown.(map[string]interface{}); ok && len(shape) == 0 {
+		oc.Change.AfterUnknown = false
+	}
+
+	return oc, nil
+}
+
+// parseChange decodes a planproto.Change, leaving its values encoded
+func parseChange(data []byte) (*rawChange, error) {
+	c := &rawChange{}
+	err := fields(data, func(num protowire.Number, raw []byte, v uint64) error {
+		switch num {
+		case changeFieldAction:
+			c.action = v
+		case changeFieldValues:
+			msgpack, err := dynamicValueBytes(raw)
+			if err != nil {
+				return err
+			}
+			c.values = append(c.values, msgpack)
+		case changeFieldBeforeSensitivePaths, changeFieldAfterSensitivePaths:
+			path, err := parsePath(raw)
+			if err != nil {
+				return err
+			}
+			if num == changeFieldBeforeSensitivePaths {
+				c.beforeSensitive = append(c.beforeSensitive, path)
+			} else {
+				c.afterSensitive = append(c.afterSensitive, path)
+			}
+		case changeFieldImporting:
+			var id string
+			err := fields(raw, func(num protowire.Number, raw []byte, _ uint64) error {
+				if num == importingFieldID {
+					id = string(raw)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			c.importID = &id
+		}
+		return nil
+	})
+	return c, err
+}
+
+// decode turns the raw values into before and after values. Terraform
+// stores [after] for create, [before] for no-op, delete and forget, and
+// [before, after] for everything else.
+func (c *rawChange) decode() (*Change, error) {
+	actions, ok := protoActions[c.action]
+	if !ok {
+		return nil, fmt.Errorf("unknown plan action %d", c.action)
+	}
+
+	values := make([]interface{}, len(c.values))
+	for i, raw := range c.values {
+		v, err := decodeMsgpack(raw)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+
+	change := &Change{Actions: append([]string(nil), actions...)}
+	switch {
+	case len(values) == 1 && actions[0] == "create":
+		change.After = values[0]
+	case len(values) == 1 && actions[0] == "no-op":
+		change.Before = values[0]
+		change.After = values[0]
+	case len(values) == 1:
+		change.Before = values[0]
+	case len(values) >= 2:
+		change.Before = values[0]
+		change.After = values[1]
+	}
+
+	// Like `terraform show -json`, after_unknown is {} for a wholly known
+	// value, null included
+	change.AfterUnknown = map[string]interface{}{}
+	if !whollyKnown(change.After) {
+		change.AfterUnknown = unknownShape(change.After)
+	}
+	change.BeforeSensitive = sensitiveShape(change.Before, c.beforeSensitive)
+	change.AfterSensitive = sensitiveShape(change.After, c.afterSensitive)
+	change.Before = stripUnknown(change.Before)
+	change.After = stripUnknown(change.After)
+	if c.importID != nil {
+		change.Importing = &Importing{ID: *c.importID}
+	}
+
+	return change, nil
+}
+
+// parsePath decodes a planproto.Path into attribute names (strings) and
+// element keys (strings for maps, numbers for lists)
+func parsePath(data []byte) ([]interface{}, error) {
+	steps := []interface{}{}
+	err := fields(data, func(num protowire.Number, raw []byte, _ uint64) error {
+		if num != pathFieldSteps {
+			return nil
+		}
+		return fields(raw, func(num protowire.Number, raw []byte, _ uint64) error {
+			switch num {
+			case stepFieldAttributeName:
+				steps = append(steps, string(raw))
+			case stepFieldElementKey:
+				key, err := parseDynamicValue(raw)
+				if err != nil {
+					return err
+				}
+				steps = append(steps, key)
+			}
+			return nil
+		})
+	})
+	return steps, err
+}
+
+// dynamicValueBytes extracts the msgpack payload of a planproto.DynamicValue
+func dynamicValueBytes(data []byte) ([]byte, error) {
+	var msgpack []byte
+	err := fields(data, func(num protowire.Number, raw []byte, _ uint64) error {
+		if num == dynamicValueFieldMsgpack {
+			msgpack = raw
+		}
+		return nil
+	})
+	return msgpack, err
+}
+
+// parseDynamicValue decodes a planproto.DynamicValue
+func parseDynamicValue(data []byte) (interface{}, error) {
+	raw, err := dynamicValueBytes(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	v, err := decodeMsgpack(raw)
+	if err != nil {
+		return nil, err
+	}
+	return stripUnknown(v), nil
+}
+
+// stripUnknown removes unknown values as `terraform show -json` does in
+// before and after: unknown attributes are omitted, unknown list elements
+// become nil so positions still line up with after_unknown
+func stripUnknown(v interface{}) interface{} {
+	switch val := v.(type) {
+	case unknownValue:
+		return nil
+	case map[string]interface{}:
+		for k, item := range val {
+			if _, unknown := item.(unknownValue); unknown {
+				delete(val, k)
+				continue
+			}
+			val[k] = stripUnknown(item)
+		}
+	case []interface{}:
+		for i, item := range val {
+			val[i] = stripUnknown(item)
+		}
+	}
+	return v
+}
+
+// unknownShape builds the after_unknown value of `terraform show -json`:
+// true for an unknown value, objects holding only the attributes with
+// unknowns, and lists with false for known elements
+func unknownShape(v interface{}) interface{} {
+	switch val := v.(type) {
+	case unknownValue:
+		return true
+	case map[string]interface{}:
+		out := map[string]interface{}{}
+		for k, item := range val {
+			if shape := unknownShape(item); shape != false {
+				out[k] = shape
+			}
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			out[i] = unknownShape(item)
+		}
+		return out
+	}
+	return false
+}
+
+// whollyKnown reports whether a value holds no unknown values
+func whollyKnown(v interface{}) bool {
+	switch val := v.(type) {
+	case unknownValue:
+		return false
+	case map[string]interface{}:
+		for _, item := range val {
+			if !whollyKnown(item) {
+				return false
+			}
+		}
+	case []interface{}:
+		for _, item := range val {
+			if !whollyKnown(item) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// sensitiveShape builds the before_sensitive/after_sensitive value of
+// `terraform show -json` from sensitive paths: true at each sensitive path,
+// objects holding the attributes that are sensitive or collections, lists
+// with an entry per element, and false elsewhere. An unknown collection is
+// empty; one whose type the plan does not record is false.
+func sensitiveShape(v interface{}, paths [][]interface{}) interface{} {
+	for _, path := range paths {
+		if len(path) == 0 {
+			return true
+		}
+	}
+
+	switch val := v.(type) {
+	case unknownValue:
+		switch val.kind {
+		case "object":
+			return map[string]interface{}{}
+		case "list":
+			return []interface{}{}
+		}
+	case map[string]interface{}:
+		out := map[string]interface{}{}
+		for k, item := range val {
+			if shape := sensitiveShape(item, pathsUnder(paths, k)); shape != false {
+				out[k] = shape
+			}
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			out[i] = sensitiveShape(item, pathsUnder(paths, i))
+		}
+		return out
+	}
+	return false
+}
+
+// pathsUnder returns the rest of the paths whose first step is an attribute
+// name or map key (string) or list index (int) equal to step
+func pathsUnder(paths [][]interface{}, step interface{}) [][]interface{} {
+	var under [][]interface{}
+	for _, path := range paths {
+		if len(path) > 0 && sameStep(path[0], step) {
+			under = append(under, path[1:])
+		}
+	}
+	return under
+}
+
+// sameStep compares a decoded path step with a map key or list index
+func sameStep(pathStep, step interface{}) bool {
+	if i, ok := step.(int); ok {
+		index, ok := listIndex(pathStep)
+		return ok && index == i
+	}
+	return fmt.Sprint(pathStep) == step
+}
+
+// listIndex converts a path step to a list index
+func listIndex(step interface{}) (int, bool) {
+	switch s := step.(type) {
+	case float64:
+		return int(s), s >= 0
+	case string:
+		i, err := strconv.Atoi(s)
+		return i, err == nil && i >= 0
+	}
+	return 0, false
+}
+
+// providerName turns a provider config address such as
+// module.net.provider["registry.terraform.io/hashicorp/aws"].east into the
+// provider source address reported by `terraform show -json`
+func providerName(addr string) string {
+	_, rest, ok := strings.Cut(addr, `provider["`)
+	if !ok {
+		return addr
+	}
+	name, _, ok := strings.Cut(rest, `"]`)
+	if !ok {
+		return addr
+	}
+	return name
+}
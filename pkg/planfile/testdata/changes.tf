@@ -0,0 +1,68 @@
+variable "region" {
+  type = string
+}
+
+variable "secret" {
+  type      = string
+  default   = "hunter2"
+  sensitive = true
+}
+
+resource "terraform_data" "updated" {
+  input = "v2-${var.region}"
+}
+
+resource "terraform_data" "replaced" {
+  input            = "same"
+  triggers_replace = ["r2"]
+}
+
+resource "terraform_data" "created" {
+  input = {
+    name   = "new"
+    secret = var.secret
+    tags   = { Owner = "ops" }
+    ports  = [80, 443]
+  }
+}
+
+resource "terraform_data" "unknown" {
+  input = terraform_data.created.id
+}
+
+resource "terraform_data" "counted" {
+  count = 2
+  input = count.index
+}
+
+resource "terraform_data" "each" {
+  for_each = toset(["a", "b"])
+  input    = each.key
+}
+
+data "terraform_remote_state" "deferred" {
+  backend = "local"
+  config = {
+    path = "${path.module}/other.tfstate"
+  }
+  defaults = {
+    created = terraform_data.created.id
+  }
+}
+
+output "kept" {
+  value = terraform_data.updated.output
+}
+
+output "created_id" {
+  value = terraform_data.created.id
+}
+
+output "secret" {
+  value     = var.secret
+  sensitive = true
+}
+
+output "remote" {
+  value = data.terraform_remote_state.deferred.outputs
+}
@@ -0,0 +1,43 @@
+#!/bin/sh
+# Regenerates changes.tfplan and changes.json, a saved plan and its
+# `terraform show -json` rendering. Only the built-in terraform provider is
+# used, so no provider download is needed. The plan is made in the staging
+# workspace against state holding three resources, and creates, updates,
+# replaces, deletes and reads resources with sensitive and unknown values.
+set -eu
+
+here=$(cd "$(dirname "$0")" && pwd)
+work=$(mktemp -d)
+trap 'rm -rf "$work"' EXIT
+cd "$work"
+
+export TF_IN_AUTOMATION=1 CHECKPOINT_DISABLE=1
+
+cat > main.tf <<'TF'
+resource "terraform_data" "updated" {
+  input = "v1"
+}
+
+resource "terraform_data" "replaced" {
+  input            = "same"
+  triggers_replace = ["r1"]
+}
+
+resource "terraform_data" "deleted" {
+  input = "bye"
+}
+
+output "kept" {
+  value = terraform_data.updated.output
+}
+TF
+terraform init -input=false >/dev/null
+terraform workspace new staging >/dev/null
+terraform apply -auto-approve -input=false >/dev/null
+cp terraform.tfstate.d/staging/terraform.tfstate other.tfstate
+
+cp "$here/changes.tf" main.tf
+terraform plan -input=false -var region=eu-west-1 -out=changes.tfplan >/dev/null
+terraform show -json changes.tfplan > changes.json
+
+cp changes.tfplan changes.json "$here/"
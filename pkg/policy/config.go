@@ -0,0 +1,71 @@
+package policy
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configFileName is the engine configuration file inside the policy directory
+const configFileName = "config.yaml"
+
+// Config is the policy engine configuration read from <policyDir>/config.yaml
+type Config struct {
+	// EnforcementThreshold is the lowest severity that blocks signing.
+	// Findings below it are recorded in the attestation as warnings.
+	EnforcementThreshold Severity `yaml:"enforcement_threshold"`
+
+	// RequiredTags configures the required-tags built-in policy.
+	// When omitted, every taggable resource needs Environment and Owner;
+	// an empty list disables the policy.
+	RequiredTags []TagRequirement `yaml:"required_tags"`
+
+	// ProtectedResources configures the destructive-change protection
+	// policy. When omitted, databases and encryption keys are protected.
+	ProtectedResources *ProtectionConfig `yaml:"protected_resources"`
+}
+
+// loadConfig reads the engine configuration from the policy directory.
+// A missing file yields the defaults.
+func loadConfig(policyDir string) (*Config, error) {
+	config := &Config{}
+	source := "default policy config"
+
+	if policyDir != "" {
+		path := filepath.Join(policyDir, configFileName)
+		data, err := os.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read policy config: %w", err)
+		}
+		if err == nil {
+			if err := yaml.Unmarshal(data, config); err != nil {
+				return nil, fmt.Errorf("failed to parse policy config %s: %w", path, err)
+			}
+			source = path
+		}
+	}
+
+	if config.EnforcementThreshold == "" {
+		config.EnforcementThreshold = SeverityError
+	}
+	if _, err := ParseSeverity(string(config.EnforcementThreshold)); err != nil {
+		return nil, fmt.Errorf("invalid enforcement_threshold in %s: %w", source, err)
+	}
+
+	if config.ProtectedResources == nil {
+		config.ProtectedResources = defaultProtection()
+	}
+
+	if config.RequiredTags == nil {
+		config.RequiredTags = defaultTagRequirements()
+	}
+	for i := range config.RequiredTags {
+		if err := config.RequiredTags[i].validate(); err != nil {
+			return nil, fmt.Errorf("invalid required_tags rule %d in %s: %w", i+1, source, err)
+		}
+	}
+
+	return config, nil
+}
@@ -3,14 +3,23 @@ package policy
 import (
 	"encoding/json"
 	"fmt"
-	"os"
-	"os/exec"
+	"path/filepath"
 	"strings"
+	"time"
+
+	"github.com/secure-systems-lab/go-securesystemslib/dsse"
+	"github.com/sigstore/sigstore/pkg/signature"
+	"github.com/sulakshanakarunarathne/terrasign/pkg/attestation"
+	"github.com/sulakshanakarunarathne/terrasign/pkg/planfile"
 )
 
-// PolicyEngine evaluates policies against Terraform plans
+// PolicyEngine evaluates policies against Terraform plans.
+// Built-in policies always run; any .rego modules found in policyDir are
+// evaluated as well (see rego.go for the deny/warn conventions).
 type PolicyEngine struct {
 	policyDir string
+	threshold Severity // overrides enforcement_threshold from config.yaml when set
+	ack       *Acknowledgement
 }
 
 // NewPolicyEngine creates a new policy engine
@@ -20,62 +29,157 @@ func NewPolicyEngine(policyDir string) *PolicyEngine {
 	}
 }
 
+// SetThreshold sets the lowest severity that blocks signing, overriding
+// the enforcement_threshold from the policy directory's config.yaml
+func (p *PolicyEngine) SetThreshold(threshold Severity) {
+	p.threshold = threshold
+}
+
+// SetAcknowledgement lets the plan destroy or replace the protected
+// resources the acknowledgement lists
+func (p *PolicyEngine) SetAcknowledgement(ack *Acknowledgement) {
+	p.ack = ack
+}
+
 // PolicyViolation represents a policy violation
 type PolicyViolation struct {
-	Policy  string `json:"policy"`
-	Message string `json:"message"`
+	Policy      string   `json:"policy"`
+	Message     string   `json:"message"`
+	Severity    Severity `json:"severity,omitempty"`
+	Address     string   `json:"address,omitempty"`
+	Remediation string   `json:"remediation,omitempty"`
 }
 
 // EvaluateResult contains the result of policy evaluation
 type EvaluateResult struct {
 	Passed     bool              `json:"passed"`
-	Violations []PolicyViolation `json:"violations"`
+	Threshold  Severity          `json:"threshold,omitempty"` // Lowest severity that blocks signing
+	Violations []PolicyViolation `json:"violations"`         // Findings at or above the threshold
+	Warnings   []PolicyViolation `json:"warnings,omitempty"` // Findings below the threshold (recorded, not blocking)
+	Waived     []WaivedViolation `json:"waived,omitempty"`   // Findings suppressed by waivers.yaml, with the waiver used
+
+	// Acknowledged are destroys and replacements of protected resources the
+	// reviewer explicitly approved in Acknowledgement
+	Acknowledged    []PolicyViolation `json:"acknowledged,omitempty"`
+	Acknowledgement *Acknowledgement  `json:"acknowledgement,omitempty"`
+
+	Scope string `json:"scope,omitempty"` // Empty when every policy ran; ProtectionScope when only protection did
 }
 
-// Evaluate evaluates a Terraform plan against all policies
+// Evaluate evaluates a Terraform plan against all policies. The plan is a
+// saved binary plan or `terraform show -json` output.
 func (p *PolicyEngine) Evaluate(planPath string) (*EvaluateResult, error) {
-	// Convert plan to JSON
-	planJSON, err := p.convertPlanToJSON(planPath)
+	in, err := planfile.NewInput(planPath, "")
 	if err != nil {
-		return nil, fmt.Errorf("failed to convert plan to JSON: %w", err)
+		return nil, err
 	}
+	return p.EvaluateInput(in)
+}
 
-	// For now, implement simple policy checks without OPA library
-	// In production, you would use github.com/open-policy-agent/opa/rego
-	violations := p.evaluateBuiltInPolicies(planJSON)
+// EvaluateInput evaluates a plan given as a binary plan, plan JSON or both.
+// Paired JSON must be the rendering of the binary plan; policies then see
+// the binary plan's rendering, so nothing the check cannot cover reaches them.
+func (p *PolicyEngine) EvaluateInput(in planfile.Input) (*EvaluateResult, error) {
+	plan, planJSON, err := in.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plan: %w", err)
+	}
+
+	config, err := loadConfig(p.policyDir)
+	if err != nil {
+		return nil, err
+	}
+	threshold := config.EnforcementThreshold
+	if p.threshold != "" {
+		threshold = p.threshold
+	}
+
+	findings := p.evaluateBuiltInPolicies(planJSON, config, planWorkspace(plan))
+
+	// Custom Rego policies from the policy directory
+	denied, warned, err := p.evaluateRegoPolicies(planJSON)
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate Rego policies: %w", err)
+	}
+	findings = append(findings, denied...)
+	findings = append(findings, warned...)
+
+	// Time-limited exceptions from the policy directory
+	waivers, err := loadWaivers(p.policyDir)
+	if err != nil {
+		return nil, err
+	}
+	findings, waived := applyWaivers(findings, waivers, time.Now())
 
 	result := &EvaluateResult{
-		Passed:     len(violations) == 0,
-		Violations: violations,
+		Threshold:  threshold,
+		Violations: []PolicyViolation{},
+		Waived:     waived,
 	}
+	for _, f := range findings {
+		if f.Severity.AtLeast(threshold) {
+			result.Violations = append(result.Violations, f)
+		} else {
+			result.Warnings = append(result.Warnings, f)
+		}
+	}
+	if err := p.applyProtection(planJSON, config, result); err != nil {
+		return nil, err
+	}
+	result.Passed = len(result.Violations) == 0
 
 	return result, nil
 }
 
-// convertPlanToJSON converts a Terraform plan to JSON format
-func (p *PolicyEngine) convertPlanToJSON(planPath string) (map[string]interface{}, error) {
-	// Run terraform show -json
-	cmd := exec.Command("terraform", "show", "-json", planPath)
-	// Do not set cmd.Dir to plan directory, as we need to run in a directory
-	// where terraform is initialized (has .terraform providers)
-	// cmd.Dir = filepath.Dir(planPath) 
-	
-	output, err := cmd.Output()
+// EvaluateProtection runs only the destructive-change protection policy,
+// for signing when the other policies were evaluated earlier. Protected
+// resources are never skipped: destroying one needs an acknowledgement.
+func (p *PolicyEngine) EvaluateProtection(in planfile.Input) (*EvaluateResult, error) {
+	_, planJSON, err := in.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plan: %w", err)
+	}
+
+	config, err := loadConfig(p.policyDir)
 	if err != nil {
-		return nil, fmt.Errorf("terraform show failed: %w", err)
+		return nil, err
 	}
 
-	var planData map[string]interface{}
-	if err := json.Unmarshal(output, &planData); err != nil {
-		return nil, fmt.Errorf("failed to parse plan JSON: %w", err)
+	result := &EvaluateResult{
+		Violations: []PolicyViolation{},
+		Scope:      ProtectionScope,
+	}
+	if err := p.applyProtection(planJSON, config, result); err != nil {
+		return nil, err
 	}
+	result.Passed = len(result.Violations) == 0
 
-	return planData, nil
+	return result, nil
 }
 
-// evaluateBuiltInPolicies evaluates built-in security policies
-func (p *PolicyEngine) evaluateBuiltInPolicies(planData map[string]interface{}) []PolicyViolation {
+// applyProtection adds destroys and replacements of protected resources to
+// the result. They block signing at any threshold and cannot be waived;
+// only the acknowledgement moves them to Acknowledged.
+func (p *PolicyEngine) applyProtection(planJSON map[string]interface{}, config *Config, result *EvaluateResult) error {
+	findings := checkProtected(planJSON, config.ProtectedResources)
+	remaining, acknowledged, err := applyAcknowledgement(findings, p.ack)
+	if err != nil {
+		return err
+	}
+
+	result.Violations = append(result.Violations, remaining...)
+	result.Acknowledged = acknowledged
+	if p.ack != nil {
+		result.Acknowledgement = p.ack
+	}
+	return nil
+}
+
+// evaluateBuiltInPolicies evaluates built-in security policies for a plan
+// that applies to workspace
+func (p *PolicyEngine) evaluateBuiltInPolicies(planData map[string]interface{}, config *Config, workspace string) []PolicyViolation {
 	var violations []PolicyViolation
+	defaultTags := providerDefaultTags(planData)
 
 	// Extract resource changes
 	resourceChanges, ok := planData["resource_changes"].([]interface{})
@@ -101,6 +205,11 @@ func (p *PolicyEngine) evaluateBuiltInPolicies(planData map[string]interface{})
 			continue
 		}
 
+		// Data sources are read, not managed; nothing to enforce
+		if mode, _ := resource["mode"].(string); mode == "data" {
+			continue
+		}
+
 		resourceType, _ := resource["type"].(string)
 		after, _ := change["after"].(map[string]interface{})
 		address, _ := resource["address"].(string)
@@ -110,8 +219,11 @@ func (p *PolicyEngine) evaluateBuiltInPolicies(planData map[string]interface{})
 			if acl, ok := after["acl"].(string); ok {
 				if strings.Contains(acl, "public") {
 					violations = append(violations, PolicyViolation{
-						Policy:  "no-public-s3",
-						Message: fmt.Sprintf("S3 bucket '%s' has public ACL: %s", address, acl),
+						Policy:      "no-public-s3",
+						Message:     fmt.Sprintf("S3 bucket '%s' has public ACL: %s", address, acl),
+						Severity:    SeverityCritical,
+						Address:     address,
+						Remediation: "Set acl to \"private\" and grant access through bucket policies or aws_s3_bucket_public_access_block",
 					})
 				}
 			}
@@ -122,8 +234,11 @@ func (p *PolicyEngine) evaluateBuiltInPolicies(planData map[string]interface{})
 			if policyDoc, ok := after["policy"].(string); ok {
 				if strings.Contains(policyDoc, "\"*\"") && strings.Contains(policyDoc, "\"Action\"") {
 					violations = append(violations, PolicyViolation{
-						Policy:  "no-wildcard-iam",
-						Message: fmt.Sprintf("IAM policy '%s' contains wildcard actions", address),
+						Policy:      "no-wildcard-iam",
+						Message:     fmt.Sprintf("IAM policy '%s' contains wildcard actions", address),
+						Severity:    SeverityCritical,
+						Address:     address,
+						Remediation: "Grant only the specific actions and resources the workload needs",
 					})
 				}
 			}
@@ -140,8 +255,11 @@ func (p *PolicyEngine) evaluateBuiltInPolicies(planData map[string]interface{})
 					for _, cidr := range cidrBlocks {
 						if cidr == "0.0.0.0/0" && (fromPort == 22 || fromPort == 3389) {
 							violations = append(violations, PolicyViolation{
-								Policy:  "no-public-ssh-rdp",
-								Message: fmt.Sprintf("Security group '%s' allows public access to port %.0f", address, fromPort),
+								Policy:      "no-public-ssh-rdp",
+								Message:     fmt.Sprintf("Security group '%s' allows public access to port %.0f", address, fromPort),
+								Severity:    SeverityCritical,
+								Address:     address,
+								Remediation: "Restrict cidr_blocks to trusted ranges or use a bastion host / SSM Session Manager",
 							})
 						}
 					}
@@ -149,58 +267,93 @@ func (p *PolicyEngine) evaluateBuiltInPolicies(planData map[string]interface{})
 			}
 		}
 
-		// Policy 4: Resources must have required tags
-		requiredTags := []string{"Environment", "Owner"}
-		if tags, ok := after["tags"].(map[string]interface{}); ok {
-			for _, reqTag := range requiredTags {
-				if _, exists := tags[reqTag]; !exists {
-					violations = append(violations, PolicyViolation{
-						Policy:  "required-tags",
-						Message: fmt.Sprintf("Resource '%s' missing required tag: %s", address, reqTag),
-					})
-				}
-			}
-		} else if resourceType != "null_resource" {
-			// Only check tags for resources that support them
-			violations = append(violations, PolicyViolation{
-				Policy:  "required-tags",
-				Message: fmt.Sprintf("Resource '%s' has no tags defined", address),
-			})
+		// Policy 4: Taggable resources must carry the configured tags
+		if tags, taggable := effectiveTags(resource, change, defaultTags); taggable {
+			violations = append(violations, checkRequiredTags(config.RequiredTags, address, resourceType, workspace, tags)...)
 		}
 	}
 
 	return violations
 }
 
-// SaveAttestation saves a policy attestation to disk
-func (p *PolicyEngine) SaveAttestation(planPath string, result *EvaluateResult) error {
-	attestationPath := planPath + ".policy"
-	
-	data, err := json.MarshalIndent(result, "", "  ")
+// PredicateType identifies TerraSign policy attestations
+const PredicateType = "https://terrasign.dev/attestations/policy/v1"
+
+// SaveAttestation saves a policy attestation to disk as a DSSE envelope.
+// The in-toto subjects are the SHA-256 of the plan and of its JSON when
+// paired, so the attestation cannot be moved to another plan; a nil signer
+// writes an unsigned envelope.
+func (p *PolicyEngine) SaveAttestation(in planfile.Input, result *EvaluateResult, signer signature.Signer) error {
+	attestationPath := in.Path() + ".policy"
+
+	subjects, err := in.Subjects()
+	if err != nil {
+		return fmt.Errorf("failed to hash plan: %w", err)
+	}
+
+	stmt, err := attestation.NewStatement(filepath.Base(subjects[0].Path), subjects[0].Digest, PredicateType, result)
 	if err != nil {
-		return fmt.Errorf("failed to marshal attestation: %w", err)
+		return err
+	}
+	for _, subject := range subjects[1:] {
+		stmt.AddSubject(filepath.Base(subject.Path), subject.Digest)
+	}
+
+	env, err := attestation.Seal(stmt, signer)
+	if err != nil {
+		return err
 	}
 
-	if err := os.WriteFile(attestationPath, data, 0644); err != nil {
+	if err := attestation.Write(attestationPath, env); err != nil {
 		return fmt.Errorf("failed to write attestation: %w", err)
 	}
 
 	return nil
 }
 
-// LoadAttestation loads a policy attestation from disk
-func LoadAttestation(planPath string) (*EvaluateResult, error) {
-	attestationPath := planPath + ".policy"
-	
-	data, err := os.ReadFile(attestationPath)
+// LoadAttestation loads a policy attestation from disk and checks that it is
+// signed by verifier and bound to the plan (and its JSON when paired). If the
+// envelope is unsigned, the decoded result is returned together with an
+// error wrapping attestation.ErrUnsigned.
+func LoadAttestation(in planfile.Input, verifier signature.Verifier) (*EvaluateResult, error) {
+	attestationPath := in.Path() + ".policy"
+
+	subjects, err := in.Subjects()
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash plan: %w", err)
+	}
+	digests := make([]string, len(subjects))
+	for i, subject := range subjects {
+		digests[i] = subject.Digest
+	}
+
+	env, err := attestation.Read(attestationPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read attestation: %w", err)
+		return nil, err
+	}
+	return OpenAttestation(env, verifier, digests...)
+}
+
+// OpenAttestation decodes a policy attestation envelope, checking it like
+// LoadAttestation against the plan digests (hex SHA-256)
+func OpenAttestation(env *dsse.Envelope, verifier signature.Verifier, digests ...string) (*EvaluateResult, error) {
+	payload, openErr := attestation.Open(env, verifier, digests...)
+	if payload == nil {
+		return nil, openErr
+	}
+
+	var stmt attestation.Statement
+	if err := json.Unmarshal(payload, &stmt); err != nil {
+		return nil, fmt.Errorf("failed to parse attestation: %w", err)
+	}
+	if stmt.PredicateType != PredicateType {
+		return nil, fmt.Errorf("unexpected predicate type %q", stmt.PredicateType)
 	}
 
 	var result EvaluateResult
-	if err := json.Unmarshal(data, &result); err != nil {
+	if err := json.Unmarshal(stmt.Predicate, &result); err != nil {
 		return nil, fmt.Errorf("failed to parse attestation: %w", err)
 	}
 
-	return &result, nil
+	return &result, openErr
 }
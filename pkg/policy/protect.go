@@ -0,0 +1,188 @@
+package policy
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ProtectPolicy is the ID of the destructive-change protection policy
+const ProtectPolicy = "protect-destructive"
+
+// ProtectionScope marks a result that covers only the destructive-change
+// protection policy, evaluated when the other policies were skipped
+const ProtectionScope = "protected-resources"
+
+// ProtectionConfig lists the resources that may only be destroyed or
+// replaced with an explicit acknowledgement, under protected_resources in
+// config.yaml
+type ProtectionConfig struct {
+	Types     []string `yaml:"types"`     // Resource type globs, e.g. aws_db_*
+	Addresses []string `yaml:"addresses"` // Resource address globs, e.g. aws_s3_bucket.tfstate or module.db.*
+}
+
+// defaultProtection is used when config.yaml has no protected_resources
+// section: databases and encryption keys. State buckets have no type of
+// their own; list them under addresses.
+func defaultProtection() *ProtectionConfig {
+	return &ProtectionConfig{
+		Types: []string{
+			"aws_db_instance", "aws_rds_cluster", "aws_dynamodb_table", "aws_docdb_cluster",
+			"aws_neptune_cluster", "aws_redshift_cluster", "aws_kms_key",
+			"google_sql_database_instance", "google_spanner_database", "google_kms_crypto_key",
+			"azurerm_mssql_database", "azurerm_postgresql_flexible_server", "azurerm_mysql_flexible_server",
+			"azurerm_cosmosdb_account", "azurerm_key_vault",
+		},
+	}
+}
+
+// LoadProtection reads the protected resources configuration from the
+// policy directory's config.yaml, or returns the defaults
+func LoadProtection(policyDir string) (*ProtectionConfig, error) {
+	config, err := loadConfig(policyDir)
+	if err != nil {
+		return nil, err
+	}
+	return config.ProtectedResources, nil
+}
+
+// Unacknowledged returns the destroys and replacements of protected
+// resources in a plan (as `terraform show -json` renders it) that ack does
+// not cover. Like signing, it fails if ack lists an address the plan does
+// not destroy or replace.
+func (c *ProtectionConfig) Unacknowledged(planJSON map[string]interface{}, ack *Acknowledgement) ([]PolicyViolation, error) {
+	remaining, _, err := applyAcknowledgement(checkProtected(planJSON, c), ack)
+	return remaining, err
+}
+
+// Addresses returns the resource addresses of violations
+func Addresses(violations []PolicyViolation) []string {
+	addresses := make([]string, len(violations))
+	for i, v := range violations {
+		addresses[i] = v.Address
+	}
+	return addresses
+}
+
+// protects reports whether a resource is protected
+func (c *ProtectionConfig) protects(resourceType, address string) bool {
+	for _, glob := range c.Types {
+		if ok, _ := path.Match(glob, resourceType); ok {
+			return true
+		}
+	}
+	for _, glob := range c.Addresses {
+		if globToRegexp(glob).MatchString(address) {
+			return true
+		}
+	}
+	return false
+}
+
+// Acknowledgement is a reviewer's explicit approval to destroy or replace
+// protected resources. It lists each affected address and is recorded in
+// the policy attestation.
+type Acknowledgement struct {
+	Addresses []string  `json:"addresses"`
+	By        string    `json:"by,omitempty"`
+	At        time.Time `json:"at"`
+}
+
+// NewAcknowledgement acknowledges destroying or replacing the given
+// protected addresses
+func NewAcknowledgement(addresses []string, by string) *Acknowledgement {
+	sorted := append([]string(nil), addresses...)
+	sort.Strings(sorted)
+	return &Acknowledgement{Addresses: sorted, By: by, At: time.Now().UTC()}
+}
+
+// checkProtected flags deletes and replacements of protected resources.
+// Unlike the other built-in policies it looks at pure deletions.
+func checkProtected(planData map[string]interface{}, config *ProtectionConfig) []PolicyViolation {
+	var violations []PolicyViolation
+
+	resourceChanges, _ := planData["resource_changes"].([]interface{})
+	for _, rc := range resourceChanges {
+		resource, ok := rc.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if mode, _ := resource["mode"].(string); mode == "data" {
+			continue
+		}
+		// Removing a deposed object cleans up after an earlier replacement
+		if deposed, _ := resource["deposed"].(string); deposed != "" {
+			continue
+		}
+
+		change, _ := resource["change"].(map[string]interface{})
+		actions, _ := change["actions"].([]interface{})
+		names := make([]string, len(actions))
+		for i, a := range actions {
+			names[i], _ = a.(string)
+		}
+		var action string
+		switch strings.Join(names, ",") {
+		case "delete":
+			action = "destroys"
+		case "delete,create", "create,delete":
+			action = "replaces"
+		default:
+			continue
+		}
+
+		resourceType, _ := resource["type"].(string)
+		address, _ := resource["address"].(string)
+		if !config.protects(resourceType, address) {
+			continue
+		}
+
+		violations = append(violations, PolicyViolation{
+			Policy:      ProtectPolicy,
+			Message:     fmt.Sprintf("Plan %s protected resource '%s'", action, address),
+			Severity:    SeverityCritical,
+			Address:     address,
+			Remediation: fmt.Sprintf("If intended, sign with --acknowledge-destroy %s; otherwise keep the resource (lifecycle { prevent_destroy = true })", address),
+		})
+	}
+
+	return violations
+}
+
+// applyAcknowledgement moves the protection findings the acknowledgement
+// lists out of findings. Every acknowledged address must be a protected
+// resource the plan destroys or replaces, so an acknowledgement cannot be
+// reused for another plan.
+func applyAcknowledgement(findings []PolicyViolation, ack *Acknowledgement) (remaining, acknowledged []PolicyViolation, err error) {
+	if ack == nil {
+		return findings, nil, nil
+	}
+
+	listed := make(map[string]bool, len(ack.Addresses))
+	for _, address := range ack.Addresses {
+		listed[address] = true
+	}
+
+	for _, f := range findings {
+		if f.Policy == ProtectPolicy && listed[f.Address] {
+			acknowledged = append(acknowledged, f)
+			delete(listed, f.Address)
+			continue
+		}
+		remaining = append(remaining, f)
+	}
+
+	if len(listed) > 0 {
+		var extra []string
+		for address := range listed {
+			extra = append(extra, address)
+		}
+		sort.Strings(extra)
+		return nil, nil, fmt.Errorf("acknowledgement lists %s, which the plan does not destroy or replace as a protected resource",
+			strings.Join(extra, ", "))
+	}
+
+	return remaining, acknowledged, nil
+}
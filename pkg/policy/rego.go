@@ -0,0 +1,197 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/open-policy-agent/opa/v1/ast"
+	"github.com/open-policy-agent/opa/v1/rego"
+)
+
+// Rego rule names evaluated in every policy package.
+// deny results default to error severity and warn results to warn, so with
+// the default threshold deny blocks signing and warn is recorded only.
+const (
+	regoDenyRule = "deny"
+	regoWarnRule = "warn"
+)
+
+// regoModule is a parsed .rego file from the policy directory
+type regoModule struct {
+	path    string
+	source  string
+	pkgPath string // e.g. "terrasign.s3" for `package terrasign.s3`
+}
+
+// loadRegoModules reads every .rego file under the policy directory.
+// A missing directory is not an error; it simply means no custom policies.
+func (p *PolicyEngine) loadRegoModules() ([]regoModule, error) {
+	if p.policyDir == "" {
+		return nil, nil
+	}
+	if _, err := os.Stat(p.policyDir); os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	var modules []regoModule
+	err := filepath.WalkDir(p.policyDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || filepath.Ext(path) != ".rego" || strings.HasSuffix(path, "_test.rego") {
+			return nil
+		}
+
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read policy %s: %w", path, err)
+		}
+
+		parsed, err := ast.ParseModule(path, string(src))
+		if err != nil {
+			return fmt.Errorf("failed to parse policy %s: %w", path, err)
+		}
+
+		modules = append(modules, regoModule{
+			path:    path,
+			source:  string(src),
+			pkgPath: strings.TrimPrefix(parsed.Package.Path.String(), "data."),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return modules, nil
+}
+
+// evaluateRegoPolicies evaluates the deny and warn rules of every Rego package
+// in the policy directory against the `terraform show -json` document
+func (p *PolicyEngine) evaluateRegoPolicies(planData map[string]interface{}) (deny, warn []PolicyViolation, err error) {
+	modules, err := p.loadRegoModules()
+	if err != nil || len(modules) == 0 {
+		return nil, nil, err
+	}
+
+	sources := make(map[string]string, len(modules))
+	packages := map[string]bool{}
+	for _, m := range modules {
+		sources[m.path] = m.source
+		packages[m.pkgPath] = true
+	}
+
+	compiler, err := ast.CompileModules(sources)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to compile policies: %w", err)
+	}
+
+	pkgNames := make([]string, 0, len(packages))
+	for pkg := range packages {
+		pkgNames = append(pkgNames, pkg)
+	}
+	sort.Strings(pkgNames)
+
+	ctx := context.Background()
+	for _, pkg := range pkgNames {
+		d, err := evalRegoRule(ctx, compiler, planData, pkg, regoDenyRule, SeverityError)
+		if err != nil {
+			return nil, nil, err
+		}
+		deny = append(deny, d...)
+
+		w, err := evalRegoRule(ctx, compiler, planData, pkg, regoWarnRule, SeverityWarn)
+		if err != nil {
+			return nil, nil, err
+		}
+		warn = append(warn, w...)
+	}
+
+	return deny, warn, nil
+}
+
+// evalRegoRule evaluates data.<pkg>.<rule> and converts its results to violations
+func evalRegoRule(ctx context.Context, compiler *ast.Compiler, input map[string]interface{}, pkg, rule string, severity Severity) ([]PolicyViolation, error) {
+	query := fmt.Sprintf("data.%s.%s", pkg, rule)
+	r := rego.New(
+		rego.Query(query),
+		rego.Compiler(compiler),
+		rego.Input(input),
+	)
+
+	rs, err := r.Eval(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate %s: %w", query, err)
+	}
+
+	// Fail closed: a rule result that cannot be read as findings is an error,
+	// never a pass
+	var violations []PolicyViolation
+	for _, result := range rs {
+		for _, expr := range result.Expressions {
+			switch v := expr.Value.(type) {
+			case []interface{}: // deny contains msg if { ... }
+				for _, value := range v {
+					violations = append(violations, regoViolation(pkg, value, severity))
+				}
+			case bool: // deny if { ... }
+				if v {
+					violations = append(violations, PolicyViolation{
+						Policy:   pkg,
+						Message:  fmt.Sprintf("%s rule of %s is true", rule, pkg),
+						Severity: severity,
+					})
+				}
+			case string: // deny := msg if { ... }
+				violations = append(violations, regoViolation(pkg, v, severity))
+			default:
+				return nil, fmt.Errorf("unsupported result of %s: %T (use a set of messages or objects, or a boolean)", query, v)
+			}
+		}
+	}
+
+	return violations, nil
+}
+
+// regoViolation maps a deny/warn result to a PolicyViolation.
+// Results may be plain message strings or objects with "msg" and optional
+// "policy", "severity", "address" (or "resource") and "remediation" fields.
+// The package path is the default policy ID; an unknown severity keeps the
+// rule's default.
+func regoViolation(pkg string, value interface{}, severity Severity) PolicyViolation {
+	violation := PolicyViolation{Policy: pkg, Severity: severity}
+
+	switch v := value.(type) {
+	case string:
+		violation.Message = v
+	case map[string]interface{}:
+		if msg, ok := v["msg"].(string); ok {
+			violation.Message = msg
+		}
+		if id, ok := v["policy"].(string); ok && id != "" {
+			violation.Policy = id
+		}
+		if s, ok := v["severity"].(string); ok {
+			if parsed, err := ParseSeverity(s); err == nil {
+				violation.Severity = parsed
+			}
+		}
+		if addr, ok := v["address"].(string); ok {
+			violation.Address = addr
+		} else if addr, ok := v["resource"].(string); ok {
+			violation.Address = addr
+		}
+		if hint, ok := v["remediation"].(string); ok {
+			violation.Remediation = hint
+		}
+	default:
+		violation.Message = fmt.Sprintf("%v", v)
+	}
+
+	return violation
+}
@@ -0,0 +1,49 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Severity ranks how serious a policy violation is
+type Severity string
+
+const (
+	SeverityInfo     Severity = "info"
+	SeverityWarn     Severity = "warn"
+	SeverityError    Severity = "error"
+	SeverityCritical Severity = "critical"
+)
+
+// severityRank orders severities from least to most serious
+var severityRank = map[Severity]int{
+	SeverityInfo:     0,
+	SeverityWarn:     1,
+	SeverityError:    2,
+	SeverityCritical: 3,
+}
+
+// ParseSeverity parses a severity name (case-insensitive)
+func ParseSeverity(s string) (Severity, error) {
+	severity := Severity(strings.ToLower(strings.TrimSpace(s)))
+	if severity == "warning" {
+		severity = SeverityWarn
+	}
+	if _, ok := severityRank[severity]; !ok {
+		return "", fmt.Errorf("unknown severity %q (use info, warn, error or critical)", s)
+	}
+	return severity, nil
+}
+
+// AtLeast reports whether s is as serious as threshold or more.
+// Unknown or empty severities (e.g. from older attestations) count as error.
+func (s Severity) AtLeast(threshold Severity) bool {
+	return s.rank() >= threshold.rank()
+}
+
+func (s Severity) rank() int {
+	if r, ok := severityRank[s]; ok {
+		return r
+	}
+	return severityRank[SeverityError]
+}
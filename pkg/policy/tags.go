@@ -0,0 +1,207 @@
+package policy
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/sulakshanakarunarathne/terrasign/pkg/planfile"
+)
+
+// TagRequirement is one rule of the required-tags policy, configured under
+// required_tags in config.yaml
+type TagRequirement struct {
+	Tag           string   `yaml:"tag"`
+	Allowed       []string `yaml:"allowed,omitempty"`        // Exact allowed values; empty means any value
+	Pattern       string   `yaml:"pattern,omitempty"`        // Regular expression the value must match
+	ResourceTypes []string `yaml:"resource_types,omitempty"` // Resource type globs; empty means every taggable type
+	Workspaces    []string `yaml:"workspaces,omitempty"`     // Workspace globs; empty means every workspace
+	Severity      Severity `yaml:"severity,omitempty"`       // Defaults to error
+
+	pattern *regexp.Regexp
+}
+
+// defaultTagRequirements is used when config.yaml has no required_tags section
+func defaultTagRequirements() []TagRequirement {
+	return []TagRequirement{
+		{Tag: "Environment"},
+		{Tag: "Owner"},
+	}
+}
+
+// validate checks the rule and compiles its value pattern
+func (r *TagRequirement) validate() error {
+	if r.Tag == "" {
+		return fmt.Errorf("tag is required")
+	}
+	if r.Severity == "" {
+		r.Severity = SeverityError
+	}
+	if _, err := ParseSeverity(string(r.Severity)); err != nil {
+		return err
+	}
+	if r.Pattern != "" {
+		re, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid pattern for tag %s: %w", r.Tag, err)
+		}
+		r.pattern = re
+	}
+	return nil
+}
+
+// appliesTo reports whether the rule covers a resource type in a workspace
+func (r *TagRequirement) appliesTo(resourceType, workspace string) bool {
+	return matchesAny(r.ResourceTypes, resourceType) && matchesAny(r.Workspaces, workspace)
+}
+
+// check validates a tag value, returning a description of the problem or ""
+func (r *TagRequirement) check(value string) string {
+	if len(r.Allowed) > 0 {
+		allowed := false
+		for _, a := range r.Allowed {
+			if value == a {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Sprintf("allowed values: %s", strings.Join(r.Allowed, ", "))
+		}
+	}
+	if r.pattern != nil && !r.pattern.MatchString(value) {
+		return fmt.Sprintf("must match %s", r.Pattern)
+	}
+	return ""
+}
+
+// matchesAny reports whether name matches one of the globs (an empty list matches everything)
+func matchesAny(globs []string, name string) bool {
+	if len(globs) == 0 {
+		return true
+	}
+	for _, g := range globs {
+		if ok, _ := path.Match(g, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// planWorkspace returns the Terraform workspace the plan applies to: the
+// one a saved plan records, else (for plan JSON alone, which does not record
+// it) TF_WORKSPACE, else .terraform/environment, else "default"
+func planWorkspace(plan *planfile.Plan) string {
+	if plan != nil && plan.Backend != nil && plan.Backend.Workspace != "" {
+		return plan.Backend.Workspace
+	}
+	if ws := os.Getenv("TF_WORKSPACE"); ws != "" {
+		return ws
+	}
+	if data, err := os.ReadFile(".terraform/environment"); err == nil {
+		if ws := strings.TrimSpace(string(data)); ws != "" {
+			return ws
+		}
+	}
+	return "default"
+}
+
+// providerDefaultTags collects default_tags from provider blocks in the plan
+// configuration, keyed by provider name (e.g. "aws"). They are a fallback for
+// when tags_all is not yet known at plan time.
+func providerDefaultTags(planData map[string]interface{}) map[string]map[string]string {
+	defaults := map[string]map[string]string{}
+
+	config, _ := planData["configuration"].(map[string]interface{})
+	providers, _ := config["provider_config"].(map[string]interface{})
+	for _, pc := range providers {
+		provider, _ := pc.(map[string]interface{})
+		name, _ := provider["name"].(string)
+		expressions, _ := provider["expressions"].(map[string]interface{})
+		blocks, _ := expressions["default_tags"].([]interface{})
+		for _, b := range blocks {
+			block, _ := b.(map[string]interface{})
+			tagsExpr, _ := block["tags"].(map[string]interface{})
+			constant, _ := tagsExpr["constant_value"].(map[string]interface{})
+			for k, v := range constant {
+				if defaults[name] == nil {
+					defaults[name] = map[string]string{}
+				}
+				defaults[name][k] = fmt.Sprint(v)
+			}
+		}
+	}
+
+	return defaults
+}
+
+// effectiveTags returns the tags a resource will end up with, and false if
+// the resource type has no tags attribute at all. tags_all already includes
+// provider default_tags; when it is unknown the configured defaults are merged in.
+func effectiveTags(resource, change map[string]interface{}, defaults map[string]map[string]string) (map[string]string, bool) {
+	after, _ := change["after"].(map[string]interface{})
+	afterUnknown, _ := change["after_unknown"].(map[string]interface{})
+
+	_, hasTags := after["tags"]
+	_, hasTagsAll := after["tags_all"]
+	if !hasTags && !hasTagsAll && afterUnknown["tags"] == nil && afterUnknown["tags_all"] == nil {
+		return nil, false
+	}
+
+	tags := map[string]string{}
+	if tagsAll, ok := after["tags_all"].(map[string]interface{}); ok {
+		for k, v := range tagsAll {
+			tags[k] = fmt.Sprint(v)
+		}
+	} else {
+		providerName, _ := resource["provider_name"].(string)
+		for k, v := range defaults[providerName[strings.LastIndex(providerName, "/")+1:]] {
+			tags[k] = v
+		}
+	}
+	if own, ok := after["tags"].(map[string]interface{}); ok {
+		for k, v := range own {
+			tags[k] = fmt.Sprint(v)
+		}
+	}
+
+	return tags, true
+}
+
+// checkRequiredTags evaluates the required-tags rules for one resource
+func checkRequiredTags(rules []TagRequirement, address, resourceType, workspace string, tags map[string]string) []PolicyViolation {
+	var violations []PolicyViolation
+
+	for i := range rules {
+		rule := &rules[i]
+		if !rule.appliesTo(resourceType, workspace) {
+			continue
+		}
+
+		value, exists := tags[rule.Tag]
+		if !exists {
+			violations = append(violations, PolicyViolation{
+				Policy:      "required-tags",
+				Message:     fmt.Sprintf("Resource '%s' missing required tag: %s", address, rule.Tag),
+				Severity:    rule.Severity,
+				Address:     address,
+				Remediation: fmt.Sprintf("Add a %s tag to the resource or to the provider's default_tags", rule.Tag),
+			})
+			continue
+		}
+
+		if problem := rule.check(value); problem != "" {
+			violations = append(violations, PolicyViolation{
+				Policy:      "required-tags",
+				Message:     fmt.Sprintf("Resource '%s' tag %s=%q is not allowed (%s)", address, rule.Tag, value, problem),
+				Severity:    rule.Severity,
+				Address:     address,
+				Remediation: fmt.Sprintf("Set the %s tag to a permitted value", rule.Tag),
+			})
+		}
+	}
+
+	return violations
+}
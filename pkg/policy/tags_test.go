@@ -0,0 +1,62 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/sulakshanakarunarathne/terrasign/pkg/planfile"
+)
+
+func TestPlanWorkspace(t *testing.T) {
+	t.Chdir(t.TempDir()) // No .terraform/environment
+	t.Setenv("TF_WORKSPACE", "dev")
+
+	// The workspace a saved plan records wins over the environment
+	plan := &planfile.Plan{Backend: &planfile.Backend{Type: "local", Workspace: "prod"}}
+	if got := planWorkspace(plan); got != "prod" {
+		t.Errorf("planWorkspace of a plan for prod = %q", got)
+	}
+
+	// Plan JSON alone records none
+	if got := planWorkspace(&planfile.Plan{}); got != "dev" {
+		t.Errorf("planWorkspace without a recorded workspace = %q, want TF_WORKSPACE", got)
+	}
+	t.Setenv("TF_WORKSPACE", "")
+	if got := planWorkspace(nil); got != "default" {
+		t.Errorf("planWorkspace with nothing set = %q, want default", got)
+	}
+}
+
+func TestRequiredTagsFollowPlanWorkspace(t *testing.T) {
+	t.Chdir(t.TempDir())
+	t.Setenv("TF_WORKSPACE", "dev")
+
+	config := &Config{
+		RequiredTags:       []TagRequirement{{Tag: "CostCenter", Workspaces: []string{"prod"}}},
+		ProtectedResources: &ProtectionConfig{},
+	}
+	for i := range config.RequiredTags {
+		if err := config.RequiredTags[i].validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	planJSON := map[string]interface{}{
+		"resource_changes": []interface{}{map[string]interface{}{
+			"address": "aws_instance.web",
+			"type":    "aws_instance",
+			"change": map[string]interface{}{
+				"actions": []interface{}{"create"},
+				"after":   map[string]interface{}{"tags": map[string]interface{}{"Owner": "ops"}},
+			},
+		}},
+	}
+
+	engine := NewPolicyEngine(t.TempDir())
+	prod := &planfile.Plan{Backend: &planfile.Backend{Workspace: "prod"}}
+	if findings := engine.evaluateBuiltInPolicies(planJSON, config, planWorkspace(prod)); len(findings) != 1 {
+		t.Errorf("plan for prod verified with TF_WORKSPACE=dev: %d findings, want the CostCenter one", len(findings))
+	}
+	dev := &planfile.Plan{Backend: &planfile.Backend{Workspace: "dev"}}
+	if findings := engine.evaluateBuiltInPolicies(planJSON, config, planWorkspace(dev)); len(findings) != 0 {
+		t.Errorf("plan for dev: %d findings, want none", len(findings))
+	}
+}
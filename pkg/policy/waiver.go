@@ -0,0 +1,174 @@
+package policy
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// waiverFileName is the waiver file inside the policy directory
+const waiverFileName = "waivers.yaml"
+
+// Waiver is a time-limited exception for one policy on matching resources
+type Waiver struct {
+	Policy        string `yaml:"policy" json:"policy"`
+	Resource      string `yaml:"resource" json:"resource"` // Resource address glob, e.g. aws_s3_bucket.site*
+	Justification string `yaml:"justification" json:"justification"`
+	Approver      string `yaml:"approver" json:"approver"`
+	Expires       string `yaml:"expires" json:"expires"` // YYYY-MM-DD (valid through that day, UTC) or RFC 3339
+
+	expiresAt time.Time
+	pattern   *regexp.Regexp
+}
+
+// waiverFile is the on-disk format of waivers.yaml
+type waiverFile struct {
+	Waivers []Waiver `yaml:"waivers"`
+}
+
+// WaivedViolation is a finding suppressed by a waiver, kept in the attestation
+type WaivedViolation struct {
+	PolicyViolation
+	Waiver Waiver `json:"waiver"`
+}
+
+// loadWaivers reads and validates <policyDir>/waivers.yaml.
+// A missing file means no waivers; a malformed waiver is an error so a typo
+// cannot silently widen or drop an exception.
+func loadWaivers(policyDir string) ([]Waiver, error) {
+	if policyDir == "" {
+		return nil, nil
+	}
+
+	path := filepath.Join(policyDir, waiverFileName)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read waivers: %w", err)
+	}
+
+	var file waiverFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse waivers %s: %w", path, err)
+	}
+
+	for i := range file.Waivers {
+		if err := file.Waivers[i].validate(); err != nil {
+			return nil, fmt.Errorf("invalid waiver %d in %s: %w", i+1, path, err)
+		}
+	}
+
+	return file.Waivers, nil
+}
+
+// validate checks required fields and parses the expiry and resource glob
+func (w *Waiver) validate() error {
+	switch {
+	case w.Policy == "":
+		return fmt.Errorf("policy is required")
+	case w.Resource == "":
+		return fmt.Errorf("resource is required")
+	case strings.TrimSpace(w.Justification) == "":
+		return fmt.Errorf("justification is required")
+	case w.Approver == "":
+		return fmt.Errorf("approver is required")
+	case w.Expires == "":
+		return fmt.Errorf("expires is required")
+	}
+
+	expiresAt, err := parseExpiry(w.Expires)
+	if err != nil {
+		return err
+	}
+	w.expiresAt = expiresAt
+	w.pattern = globToRegexp(w.Resource)
+
+	return nil
+}
+
+// parseExpiry accepts a date (valid through the end of that day, UTC) or an RFC 3339 time
+func parseExpiry(s string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t.Add(24 * time.Hour), nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid expires %q (use YYYY-MM-DD or RFC 3339)", s)
+}
+
+// globToRegexp compiles a resource address glob. Only * and ? are special,
+// since addresses contain brackets and quotes (aws_s3_bucket.b["x"]).
+func globToRegexp(glob string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("^")
+	for _, r := range glob {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
+
+// Expired reports whether the waiver no longer applies at now
+func (w *Waiver) Expired(now time.Time) bool {
+	return !now.Before(w.expiresAt)
+}
+
+// Matches reports whether the waiver covers a finding
+func (w *Waiver) Matches(v PolicyViolation) bool {
+	return w.Policy == v.Policy && v.Address != "" && w.pattern.MatchString(v.Address)
+}
+
+// applyWaivers splits findings into those still in force and those waived.
+// Expired waivers that would have matched are reported as warn findings so
+// the team notices before renewing or fixing.
+func applyWaivers(findings []PolicyViolation, waivers []Waiver, now time.Time) (remaining []PolicyViolation, waived []WaivedViolation) {
+	expiredNoticed := map[int]bool{}
+
+	for _, f := range findings {
+		var applied *Waiver
+		for i := range waivers {
+			w := &waivers[i]
+			if !w.Matches(f) {
+				continue
+			}
+			if w.Expired(now) {
+				if !expiredNoticed[i] {
+					expiredNoticed[i] = true
+					remaining = append(remaining, PolicyViolation{
+						Policy:      "waiver-expired",
+						Message:     fmt.Sprintf("Waiver for %s on '%s' (approved by %s) expired %s", w.Policy, w.Resource, w.Approver, w.Expires),
+						Severity:    SeverityWarn,
+						Address:     f.Address,
+						Remediation: "Fix the underlying violation or renew the waiver with a new expiry",
+					})
+				}
+				continue
+			}
+			applied = w
+			break
+		}
+
+		if applied != nil {
+			waived = append(waived, WaivedViolation{PolicyViolation: f, Waiver: *applied})
+		} else {
+			remaining = append(remaining, f)
+		}
+	}
+
+	return remaining, waived
+}
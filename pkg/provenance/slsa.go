@@ -3,9 +3,12 @@ package provenance
 import (
 	"encoding/json"
 	"fmt"
-	"os"
 	"os/exec"
 	"time"
+
+	"github.com/sigstore/sigstore/pkg/signature"
+	"github.com/sulakshanakarunarathne/terrasign/pkg/attestation"
+	"github.com/sulakshanakarunarathne/terrasign/pkg/planfile"
 )
 
 // SLSAProvenance represents SLSA provenance attestation
@@ -83,13 +86,18 @@ func NewProvenanceGenerator(builderID string) *ProvenanceGenerator {
 	}
 }
 
-// Generate generates SLSA provenance for a Terraform plan
-func (g *ProvenanceGenerator) Generate(planPath string, buildStartTime time.Time) (*SLSAProvenance, error) {
-	// Calculate plan hash
-	planHash, err := calculateSHA256(planPath)
+// Generate generates SLSA provenance for a Terraform plan. Its subjects are
+// the binary plan and the plan JSON, whichever the input has.
+func (g *ProvenanceGenerator) Generate(in planfile.Input, buildStartTime time.Time) (*SLSAProvenance, error) {
+	// Calculate plan hashes
+	planSubjects, err := in.Subjects()
 	if err != nil {
 		return nil, fmt.Errorf("failed to calculate plan hash: %w", err)
 	}
+	subjects := make([]Subject, len(planSubjects))
+	for i, subject := range planSubjects {
+		subjects[i] = Subject{Name: subject.Path, Digest: map[string]string{"sha256": subject.Digest}}
+	}
 
 	// Get git information
 	gitURI, gitCommit, err := getGitInfo()
@@ -102,14 +110,7 @@ func (g *ProvenanceGenerator) Generate(planPath string, buildStartTime time.Time
 	provenance := &SLSAProvenance{
 		Type:          "https://in-toto.io/Statement/v0.1",
 		PredicateType: "https://slsa.dev/provenance/v0.2",
-		Subject: []Subject{
-			{
-				Name: planPath,
-				Digest: map[string]string{
-					"sha256": planHash,
-				},
-			},
-		},
+		Subject:       subjects,
 		Predicate: Predicate{
 			Builder: Builder{
 				ID: g.builderID,
@@ -151,54 +152,55 @@ func (g *ProvenanceGenerator) Generate(planPath string, buildStartTime time.Time
 	return provenance, nil
 }
 
-// Save saves provenance to disk
-func (g *ProvenanceGenerator) Save(provenance *SLSAProvenance, planPath string) error {
-	provenancePath := planPath + ".provenance"
-	
-	data, err := json.MarshalIndent(provenance, "", "  ")
+// Save saves provenance to disk as a DSSE envelope signed by signer.
+// A nil signer writes an unsigned envelope.
+func (g *ProvenanceGenerator) Save(provenance *SLSAProvenance, in planfile.Input, signer signature.Signer) error {
+	provenancePath := in.Path() + ".provenance"
+
+	env, err := attestation.Seal(provenance, signer)
 	if err != nil {
-		return fmt.Errorf("failed to marshal provenance: %w", err)
+		return fmt.Errorf("failed to seal provenance: %w", err)
 	}
 
-	if err := os.WriteFile(provenancePath, data, 0644); err != nil {
+	if err := attestation.Write(provenancePath, env); err != nil {
 		return fmt.Errorf("failed to write provenance: %w", err)
 	}
 
 	return nil
 }
 
-// LoadProvenance loads provenance from disk
-func LoadProvenance(planPath string) (*SLSAProvenance, error) {
-	provenancePath := planPath + ".provenance"
-	
-	data, err := os.ReadFile(provenancePath)
+// LoadProvenance loads provenance from disk and checks that it is signed by
+// verifier and that its subjects are the plan (and its JSON when paired). If
+// the envelope is unsigned, the decoded provenance is returned together with
+// an error wrapping attestation.ErrUnsigned.
+func LoadProvenance(in planfile.Input, verifier signature.Verifier) (*SLSAProvenance, error) {
+	provenancePath := in.Path() + ".provenance"
+
+	subjects, err := in.Subjects()
 	if err != nil {
-		return nil, fmt.Errorf("failed to read provenance: %w", err)
+		return nil, fmt.Errorf("failed to hash plan: %w", err)
 	}
-
-	var provenance SLSAProvenance
-	if err := json.Unmarshal(data, &provenance); err != nil {
-		return nil, fmt.Errorf("failed to parse provenance: %w", err)
+	digests := make([]string, len(subjects))
+	for i, subject := range subjects {
+		digests[i] = subject.Digest
 	}
 
-	return &provenance, nil
-}
-
-// calculateSHA256 calculates SHA256 hash of a file
-func calculateSHA256(filePath string) (string, error) {
-	cmd := exec.Command("shasum", "-a", "256", filePath)
-	output, err := cmd.Output()
+	env, err := attestation.Read(provenancePath)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	// Output format: "hash  filename"
-	parts := string(output)
-	if len(parts) > 0 {
-		return parts[:64], nil // SHA256 is 64 hex chars
+	payload, openErr := attestation.Open(env, verifier, digests...)
+	if payload == nil {
+		return nil, openErr
+	}
+
+	var provenance SLSAProvenance
+	if err := json.Unmarshal(payload, &provenance); err != nil {
+		return nil, fmt.Errorf("failed to parse provenance: %w", err)
 	}
 
-	return "", fmt.Errorf("failed to parse hash output")
+	return &provenance, openErr
 }
 
 // getGitInfo gets current git repository information
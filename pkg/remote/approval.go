@@ -0,0 +1,257 @@
+package remote
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	protorekor "github.com/sigstore/protobuf-specs/gen/pb-go/rekor/v1"
+	"github.com/sulakshanakarunarathne/terrasign/pkg/signer"
+	"github.com/sulakshanakarunarathne/terrasign/pkg/verifier"
+)
+
+// Approval is one reviewer's accepted signature on a submission
+type Approval struct {
+	Reviewer  string    `json:"reviewer"` // Identity of the trusted key
+	KeyID     string    `json:"key_id"`
+	Uploader  string    `json:"uploader,omitempty"` // Authenticated caller that uploaded the signature
+	Signature string    `json:"signature"`          // base64
+	SignedAt  time.Time `json:"signed_at"`
+	LogIndex  *int64    `json:"log_index,omitempty"` // Transparency log entry of the signature
+	Timestamp []byte    `json:"timestamp,omitempty"` // RFC 3161 timestamp response over the signature
+}
+
+// QuorumConfig sets how many distinct reviewer keys must sign a submission
+type QuorumConfig struct {
+	Default      int            // Applies to environments without an entry; 0 means 1
+	Environments map[string]int // Per environment (or workspace) overrides
+}
+
+// Required returns the number of approvals needed for an environment
+func (q QuorumConfig) Required(environment string) int {
+	if n, ok := q.Environments[environment]; ok && n > 0 {
+		return n
+	}
+	if q.Default > 0 {
+		return q.Default
+	}
+	return 1
+}
+
+// Strictest returns the largest quorum of any environment, used when a
+// submission's environment cannot be established
+func (q QuorumConfig) Strictest() int {
+	n := q.Required("")
+	for _, m := range q.Environments {
+		if m > n {
+			n = m
+		}
+	}
+	return n
+}
+
+// ParseQuorum parses a quorum spec: "2" for every environment, or
+// "default=1,prod=2,staging=1" for per-environment quorums
+func ParseQuorum(spec string) (QuorumConfig, error) {
+	q := QuorumConfig{Environments: map[string]int{}}
+	if spec == "" {
+		return q, nil
+	}
+
+	for _, part := range strings.Split(spec, ",") {
+		name, value, hasName := strings.Cut(strings.TrimSpace(part), "=")
+		if !hasName {
+			name, value = "default", name
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return q, fmt.Errorf("invalid quorum %q: need a positive number of approvals", part)
+		}
+		if name == "default" {
+			q.Default = n
+		} else {
+			q.Environments[name] = n
+		}
+	}
+
+	return q, nil
+}
+
+// checkApprover enforces separation of duties: the submitter cannot approve
+// their own plan, and each key and each caller counts once. The submitter
+// is only an identity with authentication enabled: the caller that uploads
+// the signature is then compared with the principal that submitted the
+// plan. Without authentication both names are self-declared, and only the
+// key name (the reviewer key's file name, matched against the submitter)
+// is checked, which a submitter can sidestep.
+func checkApprover(submission *PlanSubmission, key *verifier.TrustedKey, uploader string) error {
+	if submission.Submitter != "" && (key.Name == submission.Submitter || uploader == submission.Submitter) {
+		return fmt.Errorf("%s submitted this plan and cannot approve it", submission.Submitter)
+	}
+
+	for _, a := range submission.Approvals {
+		if a.KeyID == key.KeyID {
+			return fmt.Errorf("key %s already approved this plan", key.Identity())
+		}
+		if uploader != "" && a.Uploader == uploader {
+			return fmt.Errorf("%s already approved this plan", uploader)
+		}
+	}
+
+	return nil
+}
+
+// RecordApproval adds a verified signature to a submission and marks it
+// approved once the quorum is met, updating submission to the stored
+// result. With a transparency log, the signature is logged first and the
+// approval refused if that fails. The approval is applied atomically: if
+// another approval, rejection or expiry landed since the submission was
+// read, the checks run again on the current metadata and a refusal wraps
+// ErrConflict. The first approval's signature is also kept as tfplan.sig.
+func (s *SigningService) RecordApproval(submission *PlanSubmission, key *verifier.TrustedKey, uploader string, sig []byte) error {
+	now := time.Now()
+	raw := verifier.DecodeSignature(sig)
+
+	var logIndex *int64
+	if s.tlog != nil {
+		digest, err := s.planDigest(submission)
+		if err != nil {
+			return err
+		}
+		entry, err := s.logSignature(digest, raw, key)
+		if err != nil {
+			return err
+		}
+		logIndex = &entry.LogIndex
+	}
+
+	var timestamp []byte
+	if s.timestamper != nil {
+		var err error
+		if timestamp, err = s.timestamper.Timestamp(raw); err != nil {
+			return fmt.Errorf("failed to timestamp signature: %w", err)
+		}
+	}
+
+	approval := Approval{
+		Reviewer:  key.Identity(),
+		KeyID:     key.KeyID,
+		Uploader:  uploader,
+		Signature: base64.StdEncoding.EncodeToString(raw),
+		SignedAt:  now,
+		LogIndex:  logIndex,
+		Timestamp: timestamp,
+	}
+
+	updated, err := s.storage.ModifySubmission(submission.ID, func(current *PlanSubmission) error {
+		if current.PlanHash != submission.PlanHash {
+			return fmt.Errorf("%w: the plan was replaced", ErrConflict)
+		}
+		if current.Status != "pending" {
+			return fmt.Errorf("%w: submission is now %s", ErrConflict, current.Status)
+		}
+		if current.ExpiresAt != nil && !now.Before(*current.ExpiresAt) {
+			return fmt.Errorf("%w: submission expired at %s", ErrConflict, current.ExpiresAt.UTC().Format(time.RFC3339))
+		}
+		// The quorum is written after the plan is stored; until then
+		// there is no count an approval could be measured against
+		if current.RequiredApprovals < 1 {
+			return fmt.Errorf("%w: submission has no approval quorum yet", ErrConflict)
+		}
+		if err := checkApprover(current, key, uploader); err != nil {
+			return fmt.Errorf("%w: %v", ErrConflict, err)
+		}
+
+		if len(current.Approvals) == 0 {
+			current.SignerKeyID = key.KeyID
+		}
+		current.Approvals = append(current.Approvals, approval)
+
+		reviewers := make([]string, len(current.Approvals))
+		for i, a := range current.Approvals {
+			reviewers[i] = a.Reviewer
+		}
+		current.ReviewedBy = strings.Join(reviewers, ", ")
+		current.ReviewedAt = &now
+
+		if len(current.Approvals) >= current.RequiredApprovals {
+			current.Status = "approved"
+			current.SignedAt = &now
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	*submission = *updated
+
+	// Downloads serve the first approval from the metadata, so the file is
+	// only written by whichever upload became the first approval
+	if updated.Approvals[0].KeyID == key.KeyID {
+		if err := s.storage.SaveSignature(submission.ID, []byte(approval.Signature)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// signatureSet converts the approvals into the <plan>.sigs format read by
+// `terrasign verify --trusted-keys`
+func signatureSet(submission *PlanSubmission) verifier.SignatureSet {
+	set := verifier.SignatureSet{Signatures: []verifier.SignatureEntry{}}
+	for _, a := range submission.Approvals {
+		set.Signatures = append(set.Signatures, verifier.SignatureEntry{
+			KeyID:     a.KeyID,
+			Reviewer:  a.Reviewer,
+			Signature: a.Signature,
+			SignedAt:  a.SignedAt,
+		})
+	}
+	return set
+}
+
+// approvalBundle builds a Sigstore bundle for the first approval (the
+// signature served as tfplan.sig) with its timestamp and its transparency
+// log entry with a fresh inclusion proof. ErrNotFound means the approval
+// was neither logged nor timestamped.
+func (s *SigningService) approvalBundle(submission *PlanSubmission) ([]byte, error) {
+	if len(submission.Approvals) == 0 {
+		return nil, ErrNotFound
+	}
+	approval := submission.Approvals[0]
+	logged := approval.LogIndex != nil && s.tlog != nil
+	if !logged && approval.Timestamp == nil {
+		return nil, ErrNotFound
+	}
+
+	var key *verifier.TrustedKey
+	for i := range s.trustedKeys {
+		if s.trustedKeys[i].KeyID == approval.KeyID {
+			key = &s.trustedKeys[i]
+		}
+	}
+	if key == nil {
+		return nil, fmt.Errorf("key %s of %s is no longer trusted", approval.KeyID, approval.Reviewer)
+	}
+
+	var entry *protorekor.TransparencyLogEntry
+	if logged {
+		var err error
+		if entry, err = s.tlog.entry(uint64(*approval.LogIndex)); err != nil {
+			return nil, err
+		}
+	}
+	digest, err := s.planDigest(submission)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := base64.StdEncoding.DecodeString(approval.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("invalid stored signature: %w", err)
+	}
+
+	return signer.MarshalBundle([sha256.Size]byte(digest), sig, key.PublicKey(), entry, approval.Timestamp)
+}
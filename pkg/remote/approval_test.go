@@ -0,0 +1,47 @@
+package remote
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/sulakshanakarunarathne/terrasign/pkg/verifier"
+)
+
+func TestRecordApprovalNeedsQuorum(t *testing.T) {
+	store, err := NewStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewStorage: %v", err)
+	}
+	indexed, err := NewIndexedStore(store, 0)
+	if err != nil {
+		t.Fatalf("NewIndexedStore: %v", err)
+	}
+	s := &SigningService{storage: indexed}
+	key := &verifier.TrustedKey{Name: "alice", KeyID: "alice-key"}
+
+	// A submission is stored before its quorum is known
+	submission, err := indexed.StorePlan(strings.NewReader("plan"), DefaultMaxPlanSize, "ci")
+	if err != nil {
+		t.Fatalf("StorePlan: %v", err)
+	}
+	if err := s.RecordApproval(submission, key, "alice", []byte("sig")); !errors.Is(err, ErrConflict) {
+		t.Fatalf("RecordApproval without a quorum = %v, want a conflict", err)
+	}
+	if got, _ := indexed.GetSubmission(submission.ID); got.Status != "pending" || len(got.Approvals) != 0 {
+		t.Fatalf("submission after a refused approval = %s with %d approvals", got.Status, len(got.Approvals))
+	}
+
+	if _, err := indexed.ModifySubmission(submission.ID, func(current *PlanSubmission) error {
+		current.RequiredApprovals = 2
+		return nil
+	}); err != nil {
+		t.Fatalf("ModifySubmission: %v", err)
+	}
+	if err := s.RecordApproval(submission, key, "alice", []byte("sig")); err != nil {
+		t.Fatalf("RecordApproval: %v", err)
+	}
+	if submission.Status != "pending" || len(submission.Approvals) != 1 {
+		t.Errorf("one of two approvals: %s with %d approvals", submission.Status, len(submission.Approvals))
+	}
+}
@@ -0,0 +1,419 @@
+package remote
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sigstore/sigstore/pkg/signature"
+	"github.com/sulakshanakarunarathne/terrasign/pkg/verifier"
+)
+
+// Audit actions
+const (
+	AuditSubmit         = "submit"
+	AuditDownload       = "download"
+	AuditSign           = "sign"
+	AuditSignRefused    = "sign.refused"
+	AuditAttest         = "attest"
+	AuditReject         = "reject"
+	AuditExpire         = "expire"
+	AuditLockdownOn     = "lockdown.on"
+	AuditLockdownOff    = "lockdown.off"
+	AuditReleaseRefused = "lockdown.release-refused"
+	AuditTlogAppend     = "tlog.append"
+)
+
+// auditLogName is the append-only log holding audit entries
+const auditLogName = "audit"
+
+// genesisHash is the PrevHash of the first entry
+var genesisHash = strings.Repeat("0", 64)
+
+// maxAuditRetries bounds how often an append races other service instances
+const maxAuditRetries = 5
+
+// AuditEntry is one record of the audit log. Hash covers every other field,
+// including PrevHash, so changing, removing or reordering an entry breaks the
+// chain; Signature (by the service audit key) covers Hash.
+type AuditEntry struct {
+	Seq        uint64            `json:"seq"`
+	Time       time.Time         `json:"time"`
+	Action     string            `json:"action"`
+	Actor      string            `json:"actor"`
+	Submission string            `json:"submission_id,omitempty"`
+	Details    map[string]string `json:"details,omitempty"`
+	PrevHash   string            `json:"prev_hash"`
+	Hash       string            `json:"hash"`
+	Signature  string            `json:"signature,omitempty"`
+}
+
+// ComputeHash returns the entry's chain hash: SHA-256 over its JSON encoding
+// without Hash and Signature
+func (e AuditEntry) ComputeHash() (string, error) {
+	e.Hash, e.Signature = "", ""
+	data, err := json.Marshal(e)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode audit entry: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// AuditHead identifies the newest entry of the log
+type AuditHead struct {
+	Seq  uint64 `json:"seq"`
+	Hash string `json:"hash"`
+}
+
+// VerifyAuditChain checks that entries continue the chain after prev (Seq 0
+// and genesisHash for the start of the log): consecutive numbers, each
+// PrevHash matching the previous Hash and each Hash matching its entry. With
+// keys, every entry must also carry a signature by one of them. It returns
+// the new head.
+func VerifyAuditChain(prev AuditHead, entries []AuditEntry, keys []verifier.TrustedKey) (AuditHead, error) {
+	if prev.Seq == 0 {
+		prev.Hash = genesisHash
+	}
+	for _, e := range entries {
+		if e.Seq != prev.Seq+1 {
+			return prev, fmt.Errorf("entry %d follows entry %d: entries missing or reordered", e.Seq, prev.Seq)
+		}
+		if e.PrevHash != prev.Hash {
+			return prev, fmt.Errorf("entry %d does not chain to entry %d: the log was edited", e.Seq, prev.Seq)
+		}
+		hash, err := e.ComputeHash()
+		if err != nil {
+			return prev, err
+		}
+		if hash != e.Hash {
+			return prev, fmt.Errorf("entry %d hash mismatch: the entry was edited", e.Seq)
+		}
+		if len(keys) > 0 {
+			if err := verifyAuditSignature(e, keys); err != nil {
+				return prev, fmt.Errorf("entry %d: %w", e.Seq, err)
+			}
+		}
+		prev = AuditHead{Seq: e.Seq, Hash: e.Hash}
+	}
+	return prev, nil
+}
+
+// verifyAuditSignature checks an entry's signature against the audit keys
+func verifyAuditSignature(e AuditEntry, keys []verifier.TrustedKey) error {
+	if e.Signature == "" {
+		return fmt.Errorf("entry is not signed")
+	}
+	sig, err := base64.StdEncoding.DecodeString(e.Signature)
+	if err != nil {
+		return fmt.Errorf("invalid signature encoding: %w", err)
+	}
+	for _, key := range keys {
+		if key.Verify(sig, []byte(e.Hash)) == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("signature does not verify against the audit key")
+}
+
+// auditLog appends entries to the backend's audit log. It caches the head;
+// when another instance appended first, the append fails with ErrExists and
+// the head is re-read from the backend.
+type auditLog struct {
+	mu     sync.Mutex
+	store  Store
+	signer signature.Signer // nil leaves entries unsigned
+	head   AuditHead
+	loaded bool
+}
+
+// catchUp advances the cached head over entries appended by other
+// instances, checking that they continue the chain
+func (a *auditLog) catchUp() error {
+	if !a.loaded {
+		a.head = AuditHead{Hash: genesisHash}
+	}
+	for {
+		records, err := a.store.ReadRecords(auditLogName, a.head.Seq, 1000)
+		if err != nil {
+			return err
+		}
+		if len(records) == 0 {
+			a.loaded = true
+			return nil
+		}
+		entries, err := decodeAuditEntries(records)
+		if err != nil {
+			return err
+		}
+		head, err := VerifyAuditChain(a.head, entries, nil)
+		if err != nil {
+			return fmt.Errorf("audit log is broken: %w", err)
+		}
+		a.head = head
+	}
+}
+
+// append adds an entry after the current head
+func (a *auditLog) append(e AuditEntry) (*AuditEntry, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for attempt := 0; attempt < maxAuditRetries; attempt++ {
+		if err := a.catchUp(); err != nil {
+			return nil, err
+		}
+
+		e.Seq = a.head.Seq + 1
+		e.PrevHash = a.head.Hash
+		e.Signature = ""
+		hash, err := e.ComputeHash()
+		if err != nil {
+			return nil, err
+		}
+		e.Hash = hash
+		if a.signer != nil {
+			sig, err := a.signer.SignMessage(bytes.NewReader([]byte(e.Hash)))
+			if err != nil {
+				return nil, fmt.Errorf("failed to sign audit entry: %w", err)
+			}
+			e.Signature = base64.StdEncoding.EncodeToString(sig)
+		}
+
+		data, err := json.Marshal(e)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode audit entry: %w", err)
+		}
+		err = a.store.AppendRecord(auditLogName, e.Seq, data)
+		if errors.Is(err, ErrExists) {
+			continue // Another instance took this seq; catch up and retry
+		}
+		if err != nil {
+			return nil, err
+		}
+		a.head = AuditHead{Seq: e.Seq, Hash: e.Hash}
+		return &e, nil
+	}
+	return nil, fmt.Errorf("failed to append audit entry: gave up after %d conflicting writes", maxAuditRetries)
+}
+
+// currentHead returns the newest entry known to this instance
+func (a *auditLog) currentHead() (AuditHead, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := a.catchUp(); err != nil {
+		return AuditHead{}, err
+	}
+	return a.head, nil
+}
+
+// decodeAuditEntries parses stored audit records
+func decodeAuditEntries(records [][]byte) ([]AuditEntry, error) {
+	entries := make([]AuditEntry, 0, len(records))
+	for _, record := range records {
+		var e AuditEntry
+		if err := json.Unmarshal(record, &e); err != nil {
+			return nil, fmt.Errorf("failed to parse audit entry: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// audit records an action taken through r. The actor is the authenticated
+// caller, or actor as declared by the request when authentication is off.
+// A failed append is reported but does not undo the action.
+func (s *SigningService) audit(r *http.Request, action, actor, submissionID string, details map[string]string) {
+	if details == nil {
+		details = map[string]string{}
+	}
+	details["remote"] = r.RemoteAddr
+	if actor == "" {
+		actor = "anonymous"
+	}
+
+	e := AuditEntry{
+		Time:       time.Now().UTC(),
+		Action:     action,
+		Actor:      callerName(r, actor),
+		Submission: submissionID,
+		Details:    details,
+	}
+	if _, err := s.auditLog.append(e); err != nil {
+		fmt.Printf("[ERROR] Audit log: %v (action %s by %s)\n", err, e.Action, e.Actor)
+	}
+}
+
+// AuditQuery selects audit entries; the zero value returns the first page
+// of the whole log
+type AuditQuery struct {
+	After      uint64 // Only entries with a higher seq
+	Action     string
+	Actor      string
+	Submission string
+	Limit      int
+}
+
+// AuditPage is one page of /audit results. Head is the newest entry, so a
+// reader can tell whether it has seen the whole log.
+type AuditPage struct {
+	Entries   []AuditEntry `json:"entries"`
+	Head      AuditHead    `json:"head"`
+	NextAfter uint64       `json:"next_after,omitempty"` // Pass as After for the next page; 0 on the last page
+}
+
+// defaultAuditLimit and maxAuditLimit bound the page size
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// filtered reports whether the query selects a subset of entries
+func (q AuditQuery) filtered() bool {
+	return q.Action != "" || q.Actor != "" || q.Submission != ""
+}
+
+// matches reports whether an entry passes the query's filters
+func (q AuditQuery) matches(e AuditEntry) bool {
+	return (q.Action == "" || e.Action == q.Action) &&
+		(q.Actor == "" || e.Actor == q.Actor) &&
+		(q.Submission == "" || e.Submission == q.Submission)
+}
+
+// QueryAudit returns a page of audit entries in seq order
+func (s *SigningService) QueryAudit(q AuditQuery) (*AuditPage, error) {
+	if q.Limit <= 0 {
+		q.Limit = defaultAuditLimit
+	}
+	if q.Limit > maxAuditLimit {
+		q.Limit = maxAuditLimit
+	}
+
+	head, err := s.auditLog.currentHead()
+	if err != nil {
+		return nil, err
+	}
+	page := &AuditPage{Entries: []AuditEntry{}, Head: head}
+
+	after := q.After
+	for len(page.Entries) < q.Limit {
+		records, err := s.storage.ReadRecords(auditLogName, after, q.Limit)
+		if err != nil {
+			return nil, err
+		}
+		entries, err := decodeAuditEntries(records)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			after = e.Seq
+			if q.matches(e) {
+				page.Entries = append(page.Entries, e)
+				if len(page.Entries) == q.Limit {
+					break
+				}
+			}
+		}
+		if len(records) < q.Limit || !q.filtered() {
+			break
+		}
+	}
+
+	if after < head.Seq && len(page.Entries) == q.Limit {
+		page.NextAfter = after
+	}
+	return page, nil
+}
+
+// handleAudit serves GET /audit?after=&action=&actor=&submission=&limit=
+func (s *SigningService) handleAudit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	params := r.URL.Query()
+	q := AuditQuery{
+		Action:     params.Get("action"),
+		Actor:      params.Get("actor"),
+		Submission: params.Get("submission"),
+	}
+	var err error
+	if v := params.Get("after"); v != "" {
+		if q.After, err = strconv.ParseUint(v, 10, 64); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid after %q", v), http.StatusBadRequest)
+			return
+		}
+	}
+	if v := params.Get("limit"); v != "" {
+		if q.Limit, err = strconv.Atoi(v); err != nil || q.Limit < 1 {
+			http.Error(w, fmt.Sprintf("Invalid limit %q", v), http.StatusBadRequest)
+			return
+		}
+	}
+
+	page, err := s.QueryAudit(q)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to read audit log: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+// Values encodes the query as /audit parameters
+func (q AuditQuery) Values() url.Values {
+	v := url.Values{}
+	if q.After > 0 {
+		v.Set("after", strconv.FormatUint(q.After, 10))
+	}
+	if q.Action != "" {
+		v.Set("action", q.Action)
+	}
+	if q.Actor != "" {
+		v.Set("actor", q.Actor)
+	}
+	if q.Submission != "" {
+		v.Set("submission", q.Submission)
+	}
+	if q.Limit > 0 {
+		v.Set("limit", strconv.Itoa(q.Limit))
+	}
+	return v
+}
+
+// ListAudit fetches one page of audit entries
+func (c *Client) ListAudit(q AuditQuery) (*AuditPage, error) {
+	resp, err := c.client.Get(c.baseURL + "/audit?" + q.Values().Encode())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if err := authError(resp); err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("server error: %s", strings.TrimSpace(string(body)))
+	}
+
+	var page AuditPage
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return &page, nil
+}
@@ -0,0 +1,367 @@
+package remote
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"gopkg.in/yaml.v3"
+)
+
+// Role is a permission level on the signing service
+type Role string
+
+const (
+	RoleSubmitter Role = "submitter" // CI: submit plans, poll status, fetch signatures
+	RoleReviewer  Role = "reviewer"  // Humans: list, download, sign and reject plans
+	RoleAdmin     Role = "admin"     // Everything, including lockdown
+	RoleAuditor   Role = "auditor"   // Read-only access to submissions and status
+)
+
+// Principal is an authenticated caller
+type Principal struct {
+	Subject string `json:"subject"`
+	Roles   []Role `json:"roles"`
+	Method  string `json:"method"` // token, mtls or oidc
+
+	// Environments the principal may submit plans for; empty means any
+	Environments []string `json:"environments,omitempty"`
+}
+
+// Has reports whether the principal holds role. Admins hold every role.
+func (p *Principal) Has(role Role) bool {
+	for _, r := range p.Roles {
+		if r == role || r == RoleAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrUnauthenticated means the request carried no acceptable credentials
+var ErrUnauthenticated = errors.New("authentication required")
+
+// Authenticator identifies the caller of a request. It returns (nil, nil)
+// when the request carries no credentials of its kind, so several
+// authenticators can be tried in turn.
+type Authenticator interface {
+	Authenticate(r *http.Request) (*Principal, error)
+}
+
+// AuthConfig is the signing service authentication configuration (YAML)
+type AuthConfig struct {
+	Tokens []TokenEntry `yaml:"tokens"`
+	MTLS   *MTLSConfig  `yaml:"mtls"`
+	OIDC   *OIDCConfig  `yaml:"oidc"`
+}
+
+// TokenEntry is a static bearer token. Only the SHA-256 of the token is
+// stored so the config file does not leak credentials.
+type TokenEntry struct {
+	SHA256  string `yaml:"sha256"`
+	Subject string `yaml:"subject"`
+	Roles   []Role `yaml:"roles"`
+
+	Environments []string `yaml:"environments"` // Environments the token may submit plans for; empty means any
+}
+
+// MTLSConfig configures client certificate authentication. The server
+// presents CertFile/KeyFile and trusts client certificates issued by ClientCA.
+type MTLSConfig struct {
+	CertFile string         `yaml:"cert_file"`
+	KeyFile  string         `yaml:"key_file"`
+	ClientCA string         `yaml:"client_ca"`
+	Subjects []SubjectRoles `yaml:"subjects"` // Matched against the certificate CN, email and DNS SANs
+}
+
+// OIDCConfig configures OIDC JWT authentication against a JWKS
+type OIDCConfig struct {
+	Issuer     string         `yaml:"issuer"`
+	JWKSURL    string         `yaml:"jwks_url"`
+	Audience   string         `yaml:"audience"`
+	RolesClaim string         `yaml:"roles_claim"` // Claim listing role names; default "roles"
+	Subjects   []SubjectRoles `yaml:"subjects"`    // Extra roles granted to specific subjects
+}
+
+// SubjectRoles grants roles to a named identity
+type SubjectRoles struct {
+	Subject string `yaml:"subject"`
+	Roles   []Role `yaml:"roles"`
+
+	Environments []string `yaml:"environments"` // Environments the identity may submit plans for; empty means any
+}
+
+// LoadAuthConfig reads an authentication config file
+func LoadAuthConfig(path string) (*AuthConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read auth config: %w", err)
+	}
+
+	var config AuthConfig
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse auth config: %w", err)
+	}
+
+	return &config, nil
+}
+
+// HashToken returns the hex SHA-256 of a bearer token, as stored in the auth config
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// bearerToken extracts the token from an Authorization: Bearer header
+func bearerToken(r *http.Request) string {
+	auth := r.Header.Get("Authorization")
+	if len(auth) > 7 && strings.EqualFold(auth[:7], "bearer ") {
+		return strings.TrimSpace(auth[7:])
+	}
+	return ""
+}
+
+// TokenAuthenticator authenticates static bearer tokens
+type TokenAuthenticator struct {
+	entries []TokenEntry
+}
+
+// NewTokenAuthenticator creates a token authenticator
+func NewTokenAuthenticator(entries []TokenEntry) *TokenAuthenticator {
+	return &TokenAuthenticator{entries: entries}
+}
+
+// Authenticate implements Authenticator
+func (a *TokenAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	token := bearerToken(r)
+	if token == "" {
+		return nil, nil
+	}
+
+	hash := HashToken(token)
+	for _, e := range a.entries {
+		if subtle.ConstantTimeCompare([]byte(hash), []byte(strings.ToLower(e.SHA256))) == 1 {
+			return &Principal{Subject: e.Subject, Roles: e.Roles, Method: "token", Environments: e.Environments}, nil
+		}
+	}
+
+	// A JWT may be meant for the OIDC authenticator
+	if strings.Count(token, ".") == 2 {
+		return nil, nil
+	}
+	return nil, fmt.Errorf("invalid bearer token")
+}
+
+// MTLSAuthenticator authenticates verified TLS client certificates
+type MTLSAuthenticator struct {
+	subjects []SubjectRoles
+}
+
+// NewMTLSAuthenticator creates a client certificate authenticator
+func NewMTLSAuthenticator(subjects []SubjectRoles) *MTLSAuthenticator {
+	return &MTLSAuthenticator{subjects: subjects}
+}
+
+// Authenticate implements Authenticator
+func (a *MTLSAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+		return nil, nil
+	}
+
+	cert := r.TLS.VerifiedChains[0][0]
+	names := append([]string{cert.Subject.CommonName}, cert.EmailAddresses...)
+	names = append(names, cert.DNSNames...)
+
+	for _, name := range names {
+		for _, s := range a.subjects {
+			if name != "" && s.Subject == name {
+				return &Principal{Subject: name, Roles: s.Roles, Method: "mtls", Environments: s.Environments}, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("client certificate %q is not authorized", cert.Subject.CommonName)
+}
+
+// OIDCAuthenticator authenticates OIDC JWTs signed by keys from a JWKS
+type OIDCAuthenticator struct {
+	verifier   *oidc.IDTokenVerifier
+	rolesClaim string
+	subjects   []SubjectRoles
+}
+
+// NewOIDCAuthenticator creates an OIDC authenticator. Keys are fetched from
+// JWKSURL on demand and cached.
+func NewOIDCAuthenticator(config OIDCConfig) (*OIDCAuthenticator, error) {
+	if config.Issuer == "" || config.JWKSURL == "" || config.Audience == "" {
+		return nil, fmt.Errorf("oidc requires issuer, jwks_url and audience")
+	}
+
+	keySet := oidc.NewRemoteKeySet(context.Background(), config.JWKSURL)
+	rolesClaim := config.RolesClaim
+	if rolesClaim == "" {
+		rolesClaim = "roles"
+	}
+
+	return &OIDCAuthenticator{
+		verifier: oidc.NewVerifier(config.Issuer, keySet, &oidc.Config{
+			ClientID:             config.Audience,
+			SupportedSigningAlgs: []string{oidc.RS256, oidc.ES256, oidc.ES384, oidc.PS256, oidc.EdDSA},
+		}),
+		rolesClaim: rolesClaim,
+		subjects:   config.Subjects,
+	}, nil
+}
+
+// Authenticate implements Authenticator
+func (a *OIDCAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	token := bearerToken(r)
+	if token == "" || strings.Count(token, ".") != 2 {
+		return nil, nil
+	}
+
+	idToken, err := a.verifier.Verify(r.Context(), token)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OIDC token: %w", err)
+	}
+
+	var claims map[string]interface{}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("invalid OIDC claims: %w", err)
+	}
+
+	principal := &Principal{Subject: idToken.Subject, Method: "oidc"}
+	switch roles := claims[a.rolesClaim].(type) {
+	case []interface{}:
+		for _, role := range roles {
+			if s, ok := role.(string); ok {
+				principal.Roles = append(principal.Roles, Role(s))
+			}
+		}
+	case string:
+		for _, s := range strings.Fields(roles) {
+			principal.Roles = append(principal.Roles, Role(s))
+		}
+	}
+	for _, s := range a.subjects {
+		if s.Subject == idToken.Subject {
+			principal.Roles = append(principal.Roles, s.Roles...)
+			principal.Environments = append(principal.Environments, s.Environments...)
+		}
+	}
+
+	return principal, nil
+}
+
+// buildAuthenticators creates the authenticators enabled in the config
+func buildAuthenticators(config *AuthConfig) ([]Authenticator, error) {
+	var authenticators []Authenticator
+	if len(config.Tokens) > 0 {
+		authenticators = append(authenticators, NewTokenAuthenticator(config.Tokens))
+	}
+	if config.MTLS != nil {
+		authenticators = append(authenticators, NewMTLSAuthenticator(config.MTLS.Subjects))
+	}
+	if config.OIDC != nil {
+		oidcAuth, err := NewOIDCAuthenticator(*config.OIDC)
+		if err != nil {
+			return nil, err
+		}
+		authenticators = append(authenticators, oidcAuth)
+	}
+	if len(authenticators) == 0 {
+		return nil, fmt.Errorf("auth config enables no authentication method")
+	}
+	return authenticators, nil
+}
+
+// serverTLSConfig builds the TLS config that requests client certificates
+func serverTLSConfig(config *MTLSConfig) (*tls.Config, error) {
+	caData, err := os.ReadFile(config.ClientCA)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caData) {
+		return nil, fmt.Errorf("no certificates found in %s", config.ClientCA)
+	}
+
+	return &tls.Config{
+		ClientCAs:  pool,
+		ClientAuth: tls.VerifyClientCertIfGiven, // Token and OIDC callers need no certificate
+		MinVersion: tls.VersionTLS12,
+	}, nil
+}
+
+type principalKey struct{}
+
+// PrincipalFromContext returns the authenticated caller, or nil when
+// authentication is disabled
+func PrincipalFromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalKey{}).(*Principal)
+	return p
+}
+
+// authenticate runs the configured authenticators in order
+func (s *SigningService) authenticate(r *http.Request) (*Principal, error) {
+	for _, a := range s.authenticators {
+		principal, err := a.Authenticate(r)
+		if err != nil {
+			return nil, err
+		}
+		if principal != nil {
+			return principal, nil
+		}
+	}
+	return nil, ErrUnauthenticated
+}
+
+// requireRole middleware authenticates the caller and checks it holds one of
+// roles. With authentication disabled every request is let through.
+func (s *SigningService) requireRole(next http.HandlerFunc, roles ...Role) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.authenticators == nil {
+			next(w, r)
+			return
+		}
+
+		principal, err := s.authenticate(r)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="terrasign"`)
+			http.Error(w, fmt.Sprintf("Unauthorized: %v", err), http.StatusUnauthorized)
+			return
+		}
+
+		allowed := false
+		for _, role := range roles {
+			if principal.Has(role) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			http.Error(w, fmt.Sprintf("Forbidden: %s lacks role %v", principal.Subject, roles), http.StatusForbidden)
+			return
+		}
+
+		next(w, r.WithContext(context.WithValue(r.Context(), principalKey{}, principal)))
+	}
+}
+
+// callerName returns the authenticated subject, or fallback when
+// authentication is disabled
+func callerName(r *http.Request, fallback string) string {
+	if p := PrincipalFromContext(r.Context()); p != nil {
+		return p.Subject
+	}
+	return fallback
+}
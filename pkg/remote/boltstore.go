@@ -0,0 +1,392 @@
+package remote
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	submissionsBucket = []byte("submissions") // id -> metadata JSON
+	artifactsBucket   = []byte("artifacts")   // id/name -> plan, signature and attestation bytes
+	stateBucket       = []byte("state")       // name.json -> service-wide state document
+)
+
+// logBucket is the bucket of an append-only log: big-endian seq -> record
+func logBucket(log string) []byte {
+	return []byte("log/" + log)
+}
+
+// BoltStore is a Store in a single embedded bbolt database file
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// NewBoltStore opens (or creates) the database at path
+func NewBoltStore(path string) (*BoltStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database %s: %w", path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{submissionsBucket, artifactsBucket, stateBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialise database: %w", err)
+	}
+
+	return &BoltStore{db: db}, nil
+}
+
+// artifactKey is the artifacts bucket key for one of a submission's files
+func artifactKey(id, name string) []byte {
+	return []byte(id + "/" + name)
+}
+
+// StorePlan saves a plan and its pending submission in one transaction
+func (b *BoltStore) StorePlan(planData io.Reader, maxSize int64, submitter string) (*PlanSubmission, error) {
+	data, planHash, err := readPlan(planData, maxSize)
+	if err != nil {
+		return nil, err
+	}
+
+	submission := newSubmission(uuid.New().String(), planHash, submitter)
+	metadata, err := json.Marshal(submission)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+
+	err = b.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(artifactsBucket).Put(artifactKey(submission.ID, planName), data); err != nil {
+			return err
+		}
+		return tx.Bucket(submissionsBucket).Put([]byte(submission.ID), metadata)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to write plan: %w", err)
+	}
+
+	return submission, nil
+}
+
+// GetSubmission retrieves a submission by ID
+func (b *BoltStore) GetSubmission(id string) (*PlanSubmission, error) {
+	if err := checkID(id); err != nil {
+		return nil, err
+	}
+
+	var submission *PlanSubmission
+	err := b.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(submissionsBucket).Get([]byte(id))
+		if data == nil {
+			return fmt.Errorf("submission %s %w", id, ErrNotFound)
+		}
+		submission = &PlanSubmission{}
+		if err := json.Unmarshal(data, submission); err != nil {
+			return fmt.Errorf("failed to parse metadata: %w", err)
+		}
+		return nil
+	})
+	return submission, err
+}
+
+// UpdateSubmission updates submission metadata
+func (b *BoltStore) UpdateSubmission(submission *PlanSubmission) error {
+	data, err := json.Marshal(submission)
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(submissionsBucket)
+		if bucket.Get([]byte(submission.ID)) == nil {
+			return fmt.Errorf("submission %s %w", submission.ID, ErrNotFound)
+		}
+		return bucket.Put([]byte(submission.ID), data)
+	})
+}
+
+// ModifySubmission applies modify to a submission's metadata within one
+// read-write transaction, which bbolt runs one at a time
+func (b *BoltStore) ModifySubmission(id string, modify func(*PlanSubmission) error) (*PlanSubmission, error) {
+	if err := checkID(id); err != nil {
+		return nil, err
+	}
+
+	submission := &PlanSubmission{}
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(submissionsBucket)
+		data := bucket.Get([]byte(id))
+		if data == nil {
+			return fmt.Errorf("submission %s %w", id, ErrNotFound)
+		}
+		if err := json.Unmarshal(data, submission); err != nil {
+			return fmt.Errorf("failed to parse metadata: %w", err)
+		}
+		if err := modify(submission); err != nil {
+			return err
+		}
+		updated, err := json.Marshal(submission)
+		if err != nil {
+			return fmt.Errorf("failed to marshal metadata: %w", err)
+		}
+		return bucket.Put([]byte(id), updated)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return submission, nil
+}
+
+// DeleteSubmission removes a submission and its artifacts
+func (b *BoltStore) DeleteSubmission(id string) error {
+	if err := checkID(id); err != nil {
+		return err
+	}
+
+	return b.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(submissionsBucket).Delete([]byte(id)); err != nil {
+			return err
+		}
+		c := tx.Bucket(artifactsBucket).Cursor()
+		prefix := []byte(id + "/")
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Seek(prefix) {
+			if err := c.Delete(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ListSubmissions returns every submission
+func (b *BoltStore) ListSubmissions() ([]*PlanSubmission, error) {
+	var submissions []*PlanSubmission
+	err := b.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(submissionsBucket).ForEach(func(_, data []byte) error {
+			var submission PlanSubmission
+			if err := json.Unmarshal(data, &submission); err != nil {
+				return nil // Skip unreadable records like the filesystem store does
+			}
+			submissions = append(submissions, &submission)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list submissions: %w", err)
+	}
+	return submissions, nil
+}
+
+// ListPending returns all pending submissions
+func (b *BoltStore) ListPending() ([]*PlanSubmission, error) {
+	submissions, err := b.ListSubmissions()
+	if err != nil {
+		return nil, err
+	}
+	return filterPending(submissions), nil
+}
+
+// OpenPlan opens the stored plan
+func (b *BoltStore) OpenPlan(id string) (io.ReadCloser, error) {
+	return b.open(id, planName)
+}
+
+// SavePlanJSON stores the JSON rendering submitted with the plan
+func (b *BoltStore) SavePlanJSON(id string, data []byte) error {
+	return b.put(id, planJSONName, data)
+}
+
+// OpenPlanJSON opens the stored plan JSON
+func (b *BoltStore) OpenPlanJSON(id string) (io.ReadCloser, error) {
+	return b.open(id, planJSONName)
+}
+
+// SaveSignature stores the plan signature for a submission
+func (b *BoltStore) SaveSignature(id string, sig []byte) error {
+	return b.put(id, signatureName, sig)
+}
+
+// OpenSignature opens the stored signature
+func (b *BoltStore) OpenSignature(id string) (io.ReadCloser, error) {
+	return b.open(id, signatureName)
+}
+
+// SaveAttestation stores an attestation for a submission
+func (b *BoltStore) SaveAttestation(id, kind string, data []byte) error {
+	name, err := attestationName(kind)
+	if err != nil {
+		return err
+	}
+	return b.put(id, name, data)
+}
+
+// OpenAttestation opens a stored attestation
+func (b *BoltStore) OpenAttestation(id, kind string) (io.ReadCloser, error) {
+	name, err := attestationName(kind)
+	if err != nil {
+		return nil, err
+	}
+	return b.open(id, name)
+}
+
+// LoadState reads a service-wide state document
+func (b *BoltStore) LoadState(name string) ([]byte, error) {
+	file, err := stateFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var data []byte
+	err = b.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(stateBucket).Get([]byte(file))
+		if value == nil {
+			return fmt.Errorf("%s %w", file, ErrNotFound)
+		}
+		data = bytes.Clone(value)
+		return nil
+	})
+	return data, err
+}
+
+// SaveState writes a service-wide state document
+func (b *BoltStore) SaveState(name string, data []byte) error {
+	file, err := stateFile(name)
+	if err != nil {
+		return err
+	}
+
+	err = b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(stateBucket).Put([]byte(file), data)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", file, err)
+	}
+	return nil
+}
+
+// ModifyState applies modify to a state document within one read-write
+// transaction
+func (b *BoltStore) ModifyState(name string, modify func(data []byte) ([]byte, error)) error {
+	file, err := stateFile(name)
+	if err != nil {
+		return err
+	}
+
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(stateBucket)
+		updated, err := modify(bytes.Clone(bucket.Get([]byte(file))))
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(file), updated)
+	})
+}
+
+// AppendRecord writes a log record
+func (b *BoltStore) AppendRecord(log string, seq uint64, data []byte) error {
+	if err := checkLog(log); err != nil {
+		return err
+	}
+
+	key := binary.BigEndian.AppendUint64(nil, seq)
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(logBucket(log))
+		if err != nil {
+			return err
+		}
+		if bucket.Get(key) != nil {
+			return fmt.Errorf("%s record %d %w", log, seq, ErrExists)
+		}
+		return bucket.Put(key, data)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write %s record: %w", log, err)
+	}
+	return nil
+}
+
+// ReadRecords reads log records after seq in order
+func (b *BoltStore) ReadRecords(log string, after uint64, limit int) ([][]byte, error) {
+	if err := checkLog(log); err != nil {
+		return nil, err
+	}
+
+	var records [][]byte
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(logBucket(log))
+		if bucket == nil {
+			return nil
+		}
+		c := bucket.Cursor()
+		for k, v := c.Seek(binary.BigEndian.AppendUint64(nil, after+1)); k != nil; k, v = c.Next() {
+			if limit >= 0 && len(records) >= limit {
+				break
+			}
+			records = append(records, bytes.Clone(v))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s records: %w", log, err)
+	}
+	return records, nil
+}
+
+// Close closes the database
+func (b *BoltStore) Close() error {
+	return b.db.Close()
+}
+
+// put stores an artifact of an existing submission
+func (b *BoltStore) put(id, name string, data []byte) error {
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket(submissionsBucket).Get([]byte(id)) == nil {
+			return fmt.Errorf("submission %s %w", id, ErrNotFound)
+		}
+		return tx.Bucket(artifactsBucket).Put(artifactKey(id, name), data)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
+
+// open returns a copy of an artifact; bbolt values are only valid inside
+// their transaction
+func (b *BoltStore) open(id, name string) (io.ReadCloser, error) {
+	var data []byte
+	err := b.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(artifactsBucket).Get(artifactKey(id, name))
+		if value == nil {
+			return fmt.Errorf("%s for %s %w", name, id, ErrNotFound)
+		}
+		data = bytes.Clone(value)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+var _ Store = (*BoltStore)(nil)
@@ -0,0 +1,114 @@
+package remote
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+)
+
+// sharePrefix starts every encoded break-glass share
+const sharePrefix = "terrasign-share-v1"
+
+// BreakGlassShare is one holder's share of the break-glass release key
+type BreakGlassShare struct {
+	SetID     string // Fingerprint of the break-glass public key; shares of one set match
+	Threshold int    // Shares needed to rebuild the key
+	Data      []byte // Shamir share: index byte followed by the share bytes
+}
+
+// String encodes the share as terrasign-share-v1:<set>:<threshold>:<hex>
+func (s BreakGlassShare) String() string {
+	return fmt.Sprintf("%s:%s:%d:%s", sharePrefix, s.SetID, s.Threshold, hex.EncodeToString(s.Data))
+}
+
+// ParseBreakGlassShare decodes a share produced by GenerateBreakGlass
+func ParseBreakGlassShare(text string) (BreakGlassShare, error) {
+	parts := strings.Split(strings.TrimSpace(text), ":")
+	if len(parts) != 4 || parts[0] != sharePrefix {
+		return BreakGlassShare{}, fmt.Errorf("not a terrasign break-glass share")
+	}
+	threshold, err := strconv.Atoi(parts[2])
+	if err != nil || threshold < 2 {
+		return BreakGlassShare{}, fmt.Errorf("invalid share threshold %q", parts[2])
+	}
+	data, err := hex.DecodeString(parts[3])
+	if err != nil || len(data) != ed25519.SeedSize+1 {
+		return BreakGlassShare{}, fmt.Errorf("invalid share data")
+	}
+	if data[0] == 0 {
+		return BreakGlassShare{}, fmt.Errorf("invalid share index 0")
+	}
+	return BreakGlassShare{SetID: parts[1], Threshold: threshold, Data: data}, nil
+}
+
+// breakGlassSetID fingerprints a break-glass public key
+func breakGlassSetID(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return hex.EncodeToString(sum[:4])
+}
+
+// GenerateBreakGlass creates an Ed25519 release key and splits its seed
+// into n shares, any threshold of which rebuild it. Only the public key
+// (PEM) is given to the server; the private key exists only in the shares.
+func GenerateBreakGlass(n, threshold int) ([]byte, []BreakGlassShare, error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate break-glass key: %w", err)
+	}
+
+	parts, err := splitSecret(priv.Seed(), n, threshold)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	setID := breakGlassSetID(pub)
+	shares := make([]BreakGlassShare, len(parts))
+	for i, part := range parts {
+		shares[i] = BreakGlassShare{SetID: setID, Threshold: threshold, Data: part}
+	}
+
+	pubPEM, err := cryptoutils.MarshalPublicKeyToPEM(pub)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode break-glass public key: %w", err)
+	}
+
+	return pubPEM, shares, nil
+}
+
+// RebuildBreakGlassKey combines at least threshold shares of one set into
+// the release key. Too few shares, or shares from different sets, fail
+// here rather than at the server.
+func RebuildBreakGlassKey(shares []BreakGlassShare) (ed25519.PrivateKey, error) {
+	if len(shares) == 0 {
+		return nil, fmt.Errorf("no break-glass shares given")
+	}
+
+	first := shares[0]
+	data := make([][]byte, len(shares))
+	for i, share := range shares {
+		if share.SetID != first.SetID || share.Threshold != first.Threshold {
+			return nil, fmt.Errorf("shares come from different break-glass sets")
+		}
+		data[i] = share.Data
+	}
+	if len(shares) < first.Threshold {
+		return nil, fmt.Errorf("%d of %d required break-glass shares given", len(shares), first.Threshold)
+	}
+
+	seed, err := combineShares(data)
+	if err != nil {
+		return nil, err
+	}
+
+	key := ed25519.NewKeyFromSeed(seed)
+	if breakGlassSetID(key.Public().(ed25519.PublicKey)) != first.SetID {
+		return nil, fmt.Errorf("shares do not rebuild the break-glass key (corrupted or duplicated share?)")
+	}
+	return key, nil
+}
@@ -2,12 +2,19 @@ package remote
 
 import (
 	"bytes"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
+	"strings"
 	"time"
+
+	"github.com/sulakshanakarunarathne/terrasign/pkg/attestation"
+	"github.com/sulakshanakarunarathne/terrasign/pkg/planfile"
 )
 
 // Client is a client for the signing service
@@ -24,18 +31,115 @@ func NewClient(baseURL string) *Client {
 	}
 }
 
-// SubmitPlan submits a plan for review
-func (c *Client) SubmitPlan(planPath, submitter string) (string, error) {
-	file, err := os.Open(planPath)
+// ClientOptions holds credentials for an authenticated signing service
+type ClientOptions struct {
+	Token    string // Bearer token: a static service token or an OIDC JWT
+	CertFile string // Client certificate for mTLS
+	KeyFile  string // Client certificate key for mTLS
+	CAFile   string // CA bundle for the server certificate; system roots when empty
+}
+
+// NewClientWithOptions creates a signing service client that authenticates
+// with a bearer token and/or a TLS client certificate
+func NewClientWithOptions(baseURL string, opts ClientOptions) (*Client, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if opts.CAFile != "" {
+		caData, err := os.ReadFile(opts.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caData) {
+			return nil, fmt.Errorf("no certificates found in %s", opts.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if opts.CertFile != "" || opts.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	var rt http.RoundTripper = transport
+	if opts.Token != "" {
+		rt = &bearerTransport{token: opts.Token, base: transport}
+	}
+
+	return &Client{
+		baseURL: baseURL,
+		client:  &http.Client{Timeout: 30 * time.Second, Transport: rt},
+	}, nil
+}
+
+// authError turns 401/403 responses into errors carrying the server's reason
+func authError(resp *http.Response) error {
+	if resp.StatusCode != http.StatusUnauthorized && resp.StatusCode != http.StatusForbidden {
+		return nil
+	}
+	body, _ := io.ReadAll(resp.Body)
+	return fmt.Errorf("access denied: %s", strings.TrimSpace(string(body)))
+}
+
+// bearerTransport adds an Authorization header to every request
+type bearerTransport struct {
+	token string
+	base  http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *bearerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+t.token)
+	return t.base.RoundTrip(req)
+}
+
+// SubmitPlan submits a plan for review. The plan's SHA-256 is sent along
+// and must match the digest the service records. A plan paired with its
+// `terraform show -json` rendering is submitted with the JSON, hashed
+// alongside it. The environment selects the approval quorum and may be empty.
+func (c *Client) SubmitPlan(in planfile.Input, submitter, environment string) (string, error) {
+	digest, err := attestation.DigestFile(in.Path())
 	if err != nil {
-		return "", fmt.Errorf("failed to open plan file: %w", err)
+		return "", fmt.Errorf("failed to hash plan: %w", err)
 	}
-	defer file.Close()
 
-	req, err := http.NewRequest("POST", c.baseURL+"/submit?submitter="+submitter, file)
+	// A plan with its JSON is sent as a multipart form, the plan part first
+	var body io.ReadCloser
+	var jsonDigest string
+	contentType := "application/octet-stream"
+	if in.Paired() {
+		if jsonDigest, err = attestation.DigestFile(in.JSON); err != nil {
+			return "", fmt.Errorf("failed to hash plan JSON: %w", err)
+		}
+		body, contentType = submitBody(in.Plan, in.JSON)
+	} else {
+		file, err := os.Open(in.Path())
+		if err != nil {
+			return "", fmt.Errorf("failed to open plan file: %w", err)
+		}
+		body = file
+	}
+	defer body.Close()
+
+	req, err := http.NewRequest("POST", c.baseURL+"/submit?submitter="+submitter, body)
 	if err != nil {
 		return "", fmt.Errorf("failed to create request: %w", err)
 	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set(PlanHashHeader, digest)
+	if jsonDigest != "" {
+		req.Header.Set(PlanJSONHashHeader, jsonDigest)
+	}
+	if environment != "" {
+		req.Header.Set(EnvironmentHeader, environment)
+	}
 
 	resp, err := c.client.Do(req)
 	if err != nil {
@@ -52,6 +156,12 @@ func (c *Client) SubmitPlan(planPath, submitter string) (string, error) {
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
 		return "", fmt.Errorf("failed to parse response: %w", err)
 	}
+	if err := matchPlanHash(result["plan_hash"], digest); err != nil {
+		return "", err
+	}
+	if jsonDigest != "" && !strings.EqualFold(result["plan_json_hash"], jsonDigest) {
+		return "", fmt.Errorf("plan JSON hash mismatch: service recorded %q, local plan JSON is %s", result["plan_json_hash"], jsonDigest)
+	}
 
 	return result["id"], nil
 }
@@ -64,6 +174,9 @@ func (c *Client) GetStatus(id string) (*PlanSubmission, error) {
 	}
 	defer resp.Body.Close()
 
+	if err := authError(resp); err != nil {
+		return nil, err
+	}
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("submission not found")
 	}
@@ -76,68 +189,159 @@ func (c *Client) GetStatus(id string) (*PlanSubmission, error) {
 	return &submission, nil
 }
 
-// WaitForSignature polls until the plan is signed or timeout
+// WaitForSignature polls until the plan is signed or timeout.
+// A rejected plan fails immediately with the reviewer's reason.
 func (c *Client) WaitForSignature(id string, timeout time.Duration) error {
-	deadline := time.Now().Add(timeout)
+	deadline := time.After(timeout)
 	ticker := time.NewTicker(5 * time.Second)
 	defer ticker.Stop()
 
 	for {
-		select {
-		case <-ticker.C:
-			submission, err := c.GetStatus(id)
-			if err != nil {
-				return err
-			}
-
-			if submission.Status == "approved" {
-				return nil
-			}
+		submission, err := c.GetStatus(id)
+		if err != nil {
+			return err
+		}
 
-			if submission.Status == "rejected" {
-				return fmt.Errorf("plan was rejected by admin")
-			}
+		switch submission.Status {
+		case "approved":
+			return nil
+		case "rejected":
+			return fmt.Errorf("plan was rejected by %s: %s", submission.ReviewedBy, submission.RejectionReason)
+		case "expired":
+			return fmt.Errorf("plan expired at %s before it was approved (%d/%d approvals)",
+				submission.ExpiresAt.Format(time.RFC3339), len(submission.Approvals), submission.RequiredApprovals)
+		}
 
-		case <-time.After(time.Until(deadline)):
+		select {
+		case <-ticker.C:
+		case <-deadline:
 			return fmt.Errorf("timeout waiting for signature")
 		}
 	}
 }
 
-// DownloadPlan downloads the plan file
+// DownloadPlan downloads the plan file and checks it against the hash
+// recorded at submission
 func (c *Client) DownloadPlan(id, outputPath string) error {
-	return c.downloadFile(id, "plan", outputPath)
+	submission, err := c.GetStatus(id)
+	if err != nil {
+		return err
+	}
+
+	if _, err := c.downloadFile(id, "plan", outputPath); err != nil {
+		return err
+	}
+
+	digest, err := attestation.DigestFile(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to hash downloaded plan: %w", err)
+	}
+	if err := matchPlanHash(submission.PlanHash, digest); err != nil {
+		os.Remove(outputPath)
+		return fmt.Errorf("downloaded plan rejected: %w", err)
+	}
+
+	return nil
 }
 
-// DownloadSignature downloads the signature file
+// DownloadSignature downloads the signature file. The service reports the
+// hash of the plan the signature belongs to, which must match the hash
+// recorded at submission.
 func (c *Client) DownloadSignature(id, outputPath string) error {
-	return c.downloadFile(id, "signature", outputPath)
+	submission, err := c.GetStatus(id)
+	if err != nil {
+		return err
+	}
+
+	planHash, err := c.downloadFile(id, "signature", outputPath)
+	if err != nil {
+		return err
+	}
+
+	if err := matchPlanHash(submission.PlanHash, planHash); err != nil {
+		os.Remove(outputPath)
+		return fmt.Errorf("downloaded signature rejected: %w", err)
+	}
+
+	return nil
+}
+
+// DownloadSignatures downloads every approval signature as a signature set
+// (<plan>.sigs) for `terrasign verify --trusted-keys --threshold`
+func (c *Client) DownloadSignatures(id, outputPath string) error {
+	submission, err := c.GetStatus(id)
+	if err != nil {
+		return err
+	}
+
+	planHash, err := c.downloadFile(id, "signatures", outputPath)
+	if err != nil {
+		return err
+	}
+
+	if err := matchPlanHash(submission.PlanHash, planHash); err != nil {
+		os.Remove(outputPath)
+		return fmt.Errorf("downloaded signatures rejected: %w", err)
+	}
+
+	return nil
 }
 
-// downloadFile downloads a file from the service
-func (c *Client) downloadFile(id, fileType, outputPath string) error {
+// DownloadBundle downloads a Sigstore bundle for the first approval
+// signature, with its transparency log entry and inclusion proof, for
+// `terrasign verify --tlog-key`. It fails with ErrNotFound when the service
+// has no transparency log.
+func (c *Client) DownloadBundle(id, outputPath string) error {
+	submission, err := c.GetStatus(id)
+	if err != nil {
+		return err
+	}
+
+	planHash, err := c.downloadFile(id, "bundle", outputPath)
+	if err != nil {
+		return err
+	}
+
+	if err := matchPlanHash(submission.PlanHash, planHash); err != nil {
+		os.Remove(outputPath)
+		return fmt.Errorf("downloaded bundle rejected: %w", err)
+	}
+
+	return nil
+}
+
+// downloadFile downloads a file from the service and returns the plan hash
+// the service reported for it
+func (c *Client) downloadFile(id, fileType, outputPath string) (string, error) {
 	url := fmt.Sprintf("%s/download/%s/%s", c.baseURL, id, fileType)
 	resp, err := c.client.Get(url)
 	if err != nil {
-		return fmt.Errorf("failed to download: %w", err)
+		return "", fmt.Errorf("failed to download: %w", err)
 	}
 	defer resp.Body.Close()
 
+	if err := authError(resp); err != nil {
+		return "", err
+	}
+	if resp.StatusCode == http.StatusConflict {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("server refused download: %s", strings.TrimSpace(string(body)))
+	}
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("file not found")
+		return "", fmt.Errorf("file %w", ErrNotFound)
 	}
 
 	out, err := os.Create(outputPath)
 	if err != nil {
-		return fmt.Errorf("failed to create output file: %w", err)
+		return "", fmt.Errorf("failed to create output file: %w", err)
 	}
 	defer out.Close()
 
 	if _, err := io.Copy(out, resp.Body); err != nil {
-		return fmt.Errorf("failed to write file: %w", err)
+		return "", fmt.Errorf("failed to write file: %w", err)
 	}
 
-	return nil
+	return resp.Header.Get(PlanHashHeader), nil
 }
 
 // ListPending lists all pending submissions
@@ -148,6 +352,11 @@ func (c *Client) ListPending() ([]*PlanSubmission, error) {
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("server error: %s", string(body))
+	}
+
 	var submissions []*PlanSubmission
 	if err := json.NewDecoder(resp.Body).Decode(&submissions); err != nil {
 		return nil, fmt.Errorf("failed to parse response: %w", err)
@@ -189,18 +398,95 @@ func (c *Client) UploadSignature(id, signaturePath string) error {
 	return nil
 }
 
-// SetLockdown enables or disables emergency lockdown
-func (c *Client) SetLockdown(enable bool) error {
-	status := "off"
-	if enable {
-		status = "on"
+// UploadAttestation uploads a policy or provenance attestation produced
+// while signing, so CI can fetch it with the signature
+func (c *Client) UploadAttestation(id, kind, attestationPath string) error {
+	data, err := os.ReadFile(attestationPath)
+	if err != nil {
+		return fmt.Errorf("failed to read attestation: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/upload-attestation/%s/%s", c.baseURL, id, kind)
+	resp, err := c.client.Post(url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to upload attestation: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if err := authError(resp); err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("server error: %s", strings.TrimSpace(string(body)))
+	}
+
+	return nil
+}
+
+// DownloadAttestation downloads a policy or provenance attestation. A
+// submission without one returns an error wrapping ErrNotFound.
+func (c *Client) DownloadAttestation(id, kind, outputPath string) error {
+	submission, err := c.GetStatus(id)
+	if err != nil {
+		return err
 	}
-	
-	resp, err := http.Post(
-		fmt.Sprintf("%s/lockdown?mode=%s", c.baseURL, status),
-		"application/json",
-		nil,
-	)
+
+	planHash, err := c.downloadFile(id, kind, outputPath)
+	if err != nil {
+		return err
+	}
+
+	if err := matchPlanHash(submission.PlanHash, planHash); err != nil {
+		os.Remove(outputPath)
+		return fmt.Errorf("downloaded attestation rejected: %w", err)
+	}
+
+	return nil
+}
+
+// RejectPlan rejects a pending submission with a reason
+func (c *Client) RejectPlan(id, reviewer, reason string) error {
+	body, err := json.Marshal(RejectRequest{Reviewer: reviewer, Reason: reason})
+	if err != nil {
+		return fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/reject/%s", c.baseURL, id)
+	resp, err := c.client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to reject plan: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("server error: %s", string(respBody))
+	}
+
+	return nil
+}
+
+// SetLockdown enables or lifts an emergency lockdown
+func (c *Client) SetLockdown(req LockdownRequest) error {
+	params := url.Values{"mode": {req.Mode}}
+	if req.Scope != "" {
+		params.Set("scope", req.Scope)
+	}
+	if req.Target != "" {
+		params.Set("target", req.Target)
+	}
+	if req.Reason != "" {
+		params.Set("reason", req.Reason)
+	}
+	if req.Duration > 0 {
+		params.Set("duration", req.Duration.String())
+	}
+	if req.By != "" {
+		params.Set("by", req.By)
+	}
+
+	resp, err := c.client.Post(c.baseURL+"/lockdown?"+params.Encode(), "application/json", nil)
 	if err != nil {
 		return err
 	}
@@ -208,8 +494,31 @@ func (c *Client) SetLockdown(enable bool) error {
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("server returned error: %s", string(body))
+		return fmt.Errorf("server returned error: %s", strings.TrimSpace(string(body)))
 	}
 
 	return nil
 }
+
+// ListLockdowns returns the active lockdowns
+func (c *Client) ListLockdowns() ([]Lockdown, error) {
+	resp, err := c.client.Get(c.baseURL + "/lockdown")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get lockdown status: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if err := authError(resp); err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("server returned error: %s", strings.TrimSpace(string(body)))
+	}
+
+	var lockdowns []Lockdown
+	if err := json.NewDecoder(resp.Body).Decode(&lockdowns); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	return lockdowns, nil
+}
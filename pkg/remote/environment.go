@@ -0,0 +1,84 @@
+package remote
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/sulakshanakarunarathne/terrasign/pkg/planfile"
+)
+
+// EnvironmentHeader carries the environment a submitter declares for a plan.
+// It is only a claim: the service checks it against the plan's workspace and
+// the caller's credential.
+const EnvironmentHeader = "X-Environment"
+
+// Where the environment of a submission was established
+const (
+	EnvironmentFromWorkspace  = "workspace"  // The workspace recorded in the saved plan, which it applies to
+	EnvironmentFromCredential = "credential" // The caller's credential is bound to this environment
+	EnvironmentUnverified     = "unverified" // Neither applies; the strictest quorum and expiry are used
+)
+
+// defaultWorkspace is the workspace Terraform uses when none is selected.
+// It says nothing about the environment, which is then usually set by the
+// backend configuration instead.
+const defaultWorkspace = "default"
+
+// submissionEnvironment establishes the environment of a submitted plan from
+// what the submitter cannot choose: the workspace a saved plan records (the
+// one Terraform applies it to) and the environments the caller's credential
+// is bound to in the auth config. A declared environment must agree with
+// them. It returns "" with EnvironmentUnverified when neither is available.
+func submissionEnvironment(planData []byte, principal *Principal, declared string) (environment, source string, err error) {
+	var bound []string
+	if principal != nil {
+		bound = principal.Environments
+	}
+
+	if workspace := planWorkspace(planData); workspace != "" {
+		if declared != "" && declared != workspace {
+			return "", "", fmt.Errorf("declared environment %q does not match workspace %q recorded in the plan", declared, workspace)
+		}
+		if len(bound) > 0 && !contains(bound, workspace) {
+			return "", "", fmt.Errorf("%s may not submit plans for workspace %q (allowed: %s)", principal.Subject, workspace, strings.Join(bound, ", "))
+		}
+		return workspace, EnvironmentFromWorkspace, nil
+	}
+
+	switch {
+	case len(bound) == 0:
+		return "", EnvironmentUnverified, nil
+	case declared != "":
+		if !contains(bound, declared) {
+			return "", "", fmt.Errorf("%s may not submit plans for environment %q (allowed: %s)", principal.Subject, declared, strings.Join(bound, ", "))
+		}
+		return declared, EnvironmentFromCredential, nil
+	case len(bound) == 1:
+		return bound[0], EnvironmentFromCredential, nil
+	}
+	return "", "", fmt.Errorf("%s may submit plans for several environments (%s); declare one with --environment", principal.Subject, strings.Join(bound, ", "))
+}
+
+// planWorkspace returns the workspace a saved plan applies to, or "" for
+// plan JSON, unreadable plans and the default workspace
+func planWorkspace(planData []byte) string {
+	if bytes.HasPrefix(bytes.TrimSpace(planData), []byte("{")) {
+		return ""
+	}
+	plan, err := planfile.Parse(planData)
+	if err != nil || plan.Backend == nil || plan.Backend.Workspace == defaultWorkspace {
+		return ""
+	}
+	return plan.Backend.Workspace
+}
+
+// contains reports whether list holds s
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
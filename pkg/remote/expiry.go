@@ -0,0 +1,81 @@
+package remote
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// expirySweepInterval is how often pending submissions are checked for expiry
+const expirySweepInterval = time.Minute
+
+// expiryActor is recorded in the audit log for expiries
+const expiryActor = "terrasign"
+
+// expireIfDue marks a pending submission expired once its expiry has passed
+// and reports whether it did. Submissions stored before expiry existed
+// never expire.
+func (s *SigningService) expireIfDue(submission *PlanSubmission) bool {
+	if submission.Status != "pending" || submission.ExpiresAt == nil || time.Now().Before(*submission.ExpiresAt) {
+		return false
+	}
+
+	// An approval or rejection that landed meanwhile wins over the expiry
+	updated, err := s.storage.ModifySubmission(submission.ID, func(current *PlanSubmission) error {
+		if current.Status != "pending" {
+			return fmt.Errorf("%w: submission is now %s", ErrConflict, current.Status)
+		}
+		current.Status = "expired"
+		return nil
+	})
+	if errors.Is(err, ErrConflict) {
+		if current, err := s.storage.GetSubmission(submission.ID); err == nil {
+			*submission = *current
+		}
+		return submission.Status == "expired"
+	}
+	if err != nil {
+		fmt.Printf("[ERROR] Failed to expire submission %s: %v\n", submission.ID, err)
+		submission.Status = "expired"
+		return true
+	}
+	*submission = *updated
+
+	e := AuditEntry{
+		Time:       time.Now().UTC(),
+		Action:     AuditExpire,
+		Actor:      expiryActor,
+		Submission: submission.ID,
+		Details: map[string]string{
+			"expires_at": submission.ExpiresAt.UTC().Format(time.RFC3339),
+			"approvals":  fmt.Sprintf("%d/%d", len(submission.Approvals), submission.RequiredApprovals),
+		},
+	}
+	if _, err := s.auditLog.append(e); err != nil {
+		fmt.Printf("[ERROR] Audit log: %v (action %s)\n", err, e.Action)
+	}
+	return true
+}
+
+// expireDue expires every overdue pending submission
+func (s *SigningService) expireDue() {
+	pending, err := s.storage.ListPending()
+	if err != nil {
+		fmt.Printf("[ERROR] Failed to list pending submissions: %v\n", err)
+		return
+	}
+	for _, submission := range pending {
+		if s.expireIfDue(submission) {
+			fmt.Printf("Submission %s expired\n", submission.ID)
+		}
+	}
+}
+
+// sweepExpired expires overdue submissions in the background, so they
+// leave the pending list even when nobody asks about them
+func (s *SigningService) sweepExpired() {
+	s.expireDue()
+	for range time.Tick(expirySweepInterval) {
+		s.expireDue()
+	}
+}
@@ -0,0 +1,432 @@
+package remote
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// IndexedStore keeps an in-memory index of submission metadata in front of
+// a Store, so listing and querying never re-read every record from the
+// backend. Besides the records by ID, it keeps submissions sorted by
+// creation time, by status and by environment, so a query only visits the
+// range of its filter. Writes made through it update the index
+// immediately. When other service instances share the backend, a non-zero
+// refresh interval merges their writes into the index in the background.
+type IndexedStore struct {
+	Store
+
+	mu            sync.RWMutex
+	byID          map[string]PlanSubmission
+	byTime        sortedIndex // Creation time
+	byStatus      sortedIndex // Status, then creation time
+	byEnvironment sortedIndex // Environment, then creation time
+
+	// written records the submissions written through the index while a
+	// rebuild lists the backend; nil when no rebuild is running
+	written   map[string]bool
+	rebuildMu sync.Mutex
+
+	stop       chan struct{}
+	closeOnce  sync.Once
+	refreshing sync.WaitGroup
+}
+
+// NewIndexedStore wraps store and builds the index from its contents. With
+// a refresh interval, the index is rebuilt that often until Close.
+func NewIndexedStore(store Store, refresh time.Duration) (*IndexedStore, error) {
+	idx := &IndexedStore{
+		Store: store,
+		byID:  map[string]PlanSubmission{},
+		stop:  make(chan struct{}),
+	}
+	if err := idx.Rebuild(); err != nil {
+		return nil, err
+	}
+	if refresh > 0 {
+		idx.refreshing.Add(1)
+		go idx.refreshEvery(refresh)
+	}
+	return idx, nil
+}
+
+// Close stops refreshing the index, waiting for a rebuild in progress, and
+// closes the backend
+func (idx *IndexedStore) Close() error {
+	idx.closeOnce.Do(func() { close(idx.stop) })
+	idx.refreshing.Wait()
+	return idx.Store.Close()
+}
+
+// refreshEvery rebuilds the index at each interval until Close; a failed
+// rebuild keeps serving the current index
+func (idx *IndexedStore) refreshEvery(interval time.Duration) {
+	defer idx.refreshing.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-idx.stop:
+			return
+		case <-ticker.C:
+			if err := idx.Rebuild(); err != nil {
+				fmt.Printf("[WARN] %v\n", err)
+			}
+		}
+	}
+}
+
+// Rebuild merges the backend's submissions into the index, picking up
+// submissions other instances created, changed or deleted. Listing the
+// backend takes a while, so a submission written or deleted through the
+// index in the meantime keeps its indexed state rather than the listed one.
+func (idx *IndexedStore) Rebuild() error {
+	idx.rebuildMu.Lock()
+	defer idx.rebuildMu.Unlock()
+
+	idx.mu.Lock()
+	idx.written = map[string]bool{}
+	idx.mu.Unlock()
+
+	submissions, err := idx.Store.ListSubmissions()
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	written := idx.written
+	idx.written = nil
+	if err != nil {
+		return fmt.Errorf("failed to build submission index: %w", err)
+	}
+
+	if len(idx.byID) == 0 {
+		// Sorting once is cheaper than inserting every entry in order
+		for _, submission := range submissions {
+			if !written[submission.ID] {
+				idx.byID[submission.ID] = *submission
+			}
+		}
+		idx.sortAll()
+		return nil
+	}
+
+	listed := make(map[string]bool, len(submissions))
+	for _, submission := range submissions {
+		listed[submission.ID] = true
+		if !written[submission.ID] {
+			idx.set(*submission)
+		}
+	}
+	for id := range idx.byID {
+		if !listed[id] && !written[id] {
+			idx.drop(id)
+		}
+	}
+	return nil
+}
+
+// indexEntry is one submission's position in a sorted index
+type indexEntry struct {
+	key string
+	id  string
+}
+
+// sortedIndex holds entries ordered by key, then ID
+type sortedIndex []indexEntry
+
+// search returns the position of the entry, or where it would be inserted
+func (s sortedIndex) search(key, id string) int {
+	return sort.Search(len(s), func(i int) bool {
+		return s[i].key > key || (s[i].key == key && s[i].id >= id)
+	})
+}
+
+// insert adds an entry in order
+func (s *sortedIndex) insert(key, id string) {
+	i := s.search(key, id)
+	*s = append(*s, indexEntry{})
+	copy((*s)[i+1:], (*s)[i:])
+	(*s)[i] = indexEntry{key, id}
+}
+
+// remove deletes an entry if present
+func (s *sortedIndex) remove(key, id string) {
+	if i := s.search(key, id); i < len(*s) && (*s)[i] == (indexEntry{key, id}) {
+		*s = append((*s)[:i], (*s)[i+1:]...)
+	}
+}
+
+// between returns the entries with lo <= key < hi; an empty hi is unbounded
+func (s sortedIndex) between(lo, hi string) sortedIndex {
+	start := sort.Search(len(s), func(i int) bool { return s[i].key >= lo })
+	end := len(s)
+	if hi != "" {
+		end = sort.Search(len(s), func(i int) bool { return s[i].key >= hi })
+	}
+	if end < start {
+		end = start
+	}
+	return s[start:end]
+}
+
+// timeKey orders creation times as strings
+func timeKey(t time.Time) string {
+	return fmt.Sprintf("%020d", t.UnixNano())
+}
+
+// indexKeys returns a submission's keys in byTime, byStatus and byEnvironment
+func indexKeys(submission *PlanSubmission) (byTime, byStatus, byEnvironment string) {
+	created := timeKey(submission.CreatedAt)
+	return created, submission.Status + "\x00" + created, submission.Environment + "\x00" + created
+}
+
+// set records a submission in the index; the caller holds mu
+func (idx *IndexedStore) set(submission PlanSubmission) {
+	timeKey, statusKey, environmentKey := indexKeys(&submission)
+	if old, ok := idx.byID[submission.ID]; ok {
+		oldTime, oldStatus, oldEnvironment := indexKeys(&old)
+		if oldTime == timeKey && oldStatus == statusKey && oldEnvironment == environmentKey {
+			idx.byID[submission.ID] = submission
+			return
+		}
+		idx.drop(submission.ID)
+	}
+	idx.byID[submission.ID] = submission
+	idx.byTime.insert(timeKey, submission.ID)
+	idx.byStatus.insert(statusKey, submission.ID)
+	idx.byEnvironment.insert(environmentKey, submission.ID)
+}
+
+// drop removes a submission from the index; the caller holds mu
+func (idx *IndexedStore) drop(id string) {
+	old, ok := idx.byID[id]
+	if !ok {
+		return
+	}
+	timeKey, statusKey, environmentKey := indexKeys(&old)
+	idx.byTime.remove(timeKey, id)
+	idx.byStatus.remove(statusKey, id)
+	idx.byEnvironment.remove(environmentKey, id)
+	delete(idx.byID, id)
+}
+
+// sortAll rebuilds the sorted indexes from byID; the caller holds mu
+func (idx *IndexedStore) sortAll() {
+	idx.byTime, idx.byStatus, idx.byEnvironment = nil, nil, nil
+	for id, submission := range idx.byID {
+		timeKey, statusKey, environmentKey := indexKeys(&submission)
+		idx.byTime = append(idx.byTime, indexEntry{timeKey, id})
+		idx.byStatus = append(idx.byStatus, indexEntry{statusKey, id})
+		idx.byEnvironment = append(idx.byEnvironment, indexEntry{environmentKey, id})
+	}
+	for _, s := range []sortedIndex{idx.byTime, idx.byStatus, idx.byEnvironment} {
+		sort.Slice(s, func(i, j int) bool {
+			return s[i].key < s[j].key || (s[i].key == s[j].key && s[i].id < s[j].id)
+		})
+	}
+}
+
+// put records a submission written through the index
+func (idx *IndexedStore) put(submission *PlanSubmission) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if idx.written != nil {
+		idx.written[submission.ID] = true
+	}
+	idx.set(*submission)
+}
+
+// StorePlan implements Store and indexes the new submission
+func (idx *IndexedStore) StorePlan(planData io.Reader, maxSize int64, submitter string) (*PlanSubmission, error) {
+	submission, err := idx.Store.StorePlan(planData, maxSize, submitter)
+	if err != nil {
+		return nil, err
+	}
+	idx.put(submission)
+	return submission, nil
+}
+
+// UpdateSubmission implements Store and updates the index
+func (idx *IndexedStore) UpdateSubmission(submission *PlanSubmission) error {
+	if err := idx.Store.UpdateSubmission(submission); err != nil {
+		return err
+	}
+	idx.put(submission)
+	return nil
+}
+
+// ModifySubmission implements Store and updates the index
+func (idx *IndexedStore) ModifySubmission(id string, modify func(*PlanSubmission) error) (*PlanSubmission, error) {
+	submission, err := idx.Store.ModifySubmission(id, modify)
+	if err != nil {
+		return nil, err
+	}
+	idx.put(submission)
+	return submission, nil
+}
+
+// DeleteSubmission implements Store and drops the submission from the index
+func (idx *IndexedStore) DeleteSubmission(id string) error {
+	if err := idx.Store.DeleteSubmission(id); err != nil {
+		return err
+	}
+	idx.mu.Lock()
+	if idx.written != nil {
+		idx.written[id] = true
+	}
+	idx.drop(id)
+	idx.mu.Unlock()
+	return nil
+}
+
+// ListSubmissions returns every submission from the index
+func (idx *IndexedStore) ListSubmissions() ([]*PlanSubmission, error) {
+	page, err := idx.Query(SubmissionQuery{Sort: "created_at", Limit: -1})
+	if err != nil {
+		return nil, err
+	}
+	return page.Submissions, nil
+}
+
+// ListPending returns pending submissions from the index, oldest first
+func (idx *IndexedStore) ListPending() ([]*PlanSubmission, error) {
+	page, err := idx.Query(SubmissionQuery{Status: "pending", Sort: "created_at", Limit: -1})
+	if err != nil {
+		return nil, err
+	}
+	return page.Submissions, nil
+}
+
+// sortKey returns a submission's value for a sort field, as a string that
+// orders correctly
+func sortKey(submission *PlanSubmission, field string) string {
+	switch field {
+	case "submitter":
+		return submission.Submitter
+	case "status":
+		return submission.Status
+	case "environment":
+		return submission.Environment
+	case "risk":
+		// Equal scores order newest first, so -risk lists the oldest first
+		score := -1
+		if submission.Risk != nil {
+			score = submission.Risk.Score
+		}
+		return fmt.Sprintf("%04d%020d", score+1, math.MaxInt64-submission.CreatedAt.UnixNano())
+	default:
+		return timeKey(submission.CreatedAt)
+	}
+}
+
+// matches reports whether a submission passes the query's filters
+func (q SubmissionQuery) matches(submission *PlanSubmission) bool {
+	switch {
+	case q.Status != "" && submission.Status != q.Status,
+		q.Submitter != "" && submission.Submitter != q.Submitter,
+		q.Environment != "" && submission.Environment != q.Environment,
+		!q.Since.IsZero() && submission.CreatedAt.Before(q.Since),
+		!q.Until.IsZero() && !submission.CreatedAt.Before(q.Until):
+		return false
+	}
+	return true
+}
+
+// Query filters, sorts and pages the index. A negative Limit returns every
+// match.
+func (idx *IndexedStore) Query(q SubmissionQuery) (*SubmissionPage, error) {
+	field, desc := q.sortField()
+	if !sortFields[field] {
+		return nil, fmt.Errorf("invalid sort %q", q.Sort)
+	}
+
+	// Only the time range of the status or environment filtered on is
+	// visited; it comes ordered by creation time, then ID
+	idx.mu.RLock()
+	candidates, prefix := idx.byTime, ""
+	switch {
+	case q.Status != "":
+		candidates, prefix = idx.byStatus, q.Status+"\x00"
+	case q.Environment != "":
+		candidates, prefix = idx.byEnvironment, q.Environment+"\x00"
+	}
+	lo, hi := prefix, ""
+	if !q.Since.IsZero() {
+		lo += timeKey(q.Since)
+	}
+	if !q.Until.IsZero() {
+		hi = prefix + timeKey(q.Until)
+	} else if prefix != "" {
+		hi = strings.TrimSuffix(prefix, "\x00") + "\x01"
+	}
+
+	// Entries are (sort key, ID) pairs; the ID breaks ties so the order is total
+	type entry struct {
+		key        string
+		submission *PlanSubmission
+	}
+	var entries []entry
+	for _, e := range candidates.between(lo, hi) {
+		submission := idx.byID[e.id]
+		if q.matches(&submission) {
+			entries = append(entries, entry{sortKey(&submission, field), &submission})
+		}
+	}
+	idx.mu.RUnlock()
+
+	before := func(aKey, aID, bKey, bID string) bool {
+		if aKey != bKey {
+			return (aKey < bKey) != desc
+		}
+		return aID != bID && (aID < bID) != desc
+	}
+	if field == "created_at" {
+		if desc {
+			for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+				entries[i], entries[j] = entries[j], entries[i]
+			}
+		}
+	} else {
+		sort.Slice(entries, func(i, j int) bool {
+			return before(entries[i].key, entries[i].submission.ID, entries[j].key, entries[j].submission.ID)
+		})
+	}
+
+	page := &SubmissionPage{Submissions: []*PlanSubmission{}, Total: len(entries)}
+
+	start := 0
+	if q.Cursor != "" {
+		cursor, err := decodeCursor(q.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		if cursor.Sort != q.Sort {
+			return nil, fmt.Errorf("cursor belongs to a query with a different sort order")
+		}
+		start = sort.Search(len(entries), func(i int) bool {
+			return before(cursor.Key, cursor.ID, entries[i].key, entries[i].submission.ID)
+		})
+	}
+
+	limit := q.Limit
+	if limit == 0 {
+		limit = defaultQueryLimit
+	}
+	end := len(entries)
+	if limit > 0 && start+limit < end {
+		end = start + limit
+	}
+
+	for _, e := range entries[start:end] {
+		page.Submissions = append(page.Submissions, e.submission)
+	}
+	if end < len(entries) {
+		last := entries[end-1]
+		page.NextCursor = encodeCursor(queryCursor{Sort: q.Sort, Key: last.key, ID: last.submission.ID})
+	}
+
+	return page, nil
+}
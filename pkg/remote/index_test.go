@@ -0,0 +1,212 @@
+package remote
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// seedSubmissions stores submissions spread over statuses, environments and
+// creation times, some created at the same instant
+func seedSubmissions(t *testing.T, store Store, n int) {
+	t.Helper()
+	statuses := []string{"pending", "approved", "rejected", "expired"}
+	environments := []string{"prod", "staging", ""}
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < n; i++ {
+		submission, err := store.StorePlan(strings.NewReader(fmt.Sprintf("plan %d", i)), DefaultMaxPlanSize, fmt.Sprintf("ci-%d", i%3))
+		if err != nil {
+			t.Fatalf("StorePlan: %v", err)
+		}
+		if _, err := store.ModifySubmission(submission.ID, func(current *PlanSubmission) error {
+			current.Status = statuses[i%len(statuses)]
+			current.Environment = environments[i%len(environments)]
+			current.CreatedAt = base.Add(time.Duration(i/2) * time.Hour)
+			current.Risk = &RiskAssessment{Score: i % 5}
+			return nil
+		}); err != nil {
+			t.Fatalf("ModifySubmission: %v", err)
+		}
+	}
+}
+
+// queryIDs returns the IDs of every page of a query, in order
+func queryIDs(t *testing.T, idx *IndexedStore, q SubmissionQuery) ([]string, int) {
+	t.Helper()
+	var ids []string
+	total := -1
+	for {
+		page, err := idx.Query(q)
+		if err != nil {
+			t.Fatalf("Query(%+v): %v", q, err)
+		}
+		if total >= 0 && page.Total != total {
+			t.Fatalf("Query(%+v): total changed from %d to %d between pages", q, total, page.Total)
+		}
+		total = page.Total
+		for _, submission := range page.Submissions {
+			ids = append(ids, submission.ID)
+		}
+		if page.NextCursor == "" {
+			return ids, total
+		}
+		q.Cursor = page.NextCursor
+	}
+}
+
+// scanIDs answers a query by filtering and sorting every stored submission
+func scanIDs(t *testing.T, store Store, q SubmissionQuery) []string {
+	t.Helper()
+	all, err := store.ListSubmissions()
+	if err != nil {
+		t.Fatalf("ListSubmissions: %v", err)
+	}
+	field, desc := q.sortField()
+	var matched []*PlanSubmission
+	for _, submission := range all {
+		if q.matches(submission) {
+			matched = append(matched, submission)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		a, b := sortKey(matched[i], field), sortKey(matched[j], field)
+		if a != b {
+			return (a < b) != desc
+		}
+		return (matched[i].ID < matched[j].ID) != desc
+	})
+	ids := []string{}
+	for _, submission := range matched {
+		ids = append(ids, submission.ID)
+	}
+	return ids
+}
+
+func TestIndexedStoreQuery(t *testing.T) {
+	store, err := NewStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewStorage: %v", err)
+	}
+	seedSubmissions(t, store, 24)
+	idx, err := NewIndexedStore(store, 0)
+	if err != nil {
+		t.Fatalf("NewIndexedStore: %v", err)
+	}
+	// Writes through the index keep the sorted indexes in step
+	seedSubmissions(t, idx, 6)
+
+	since := time.Date(2026, 1, 1, 3, 0, 0, 0, time.UTC)
+	until := time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)
+	queries := []SubmissionQuery{
+		{},
+		{Sort: "created_at", Limit: 4},
+		{Status: "pending", Limit: 2},
+		{Status: "approved", Sort: "created_at", Since: since, Until: until},
+		{Environment: "prod", Sort: "-risk", Limit: 3},
+		{Environment: "", Since: since, Sort: "submitter", Limit: 5},
+		{Until: until, Sort: "-status", Limit: 7},
+		{Status: "rejected", Environment: "staging", Sort: "environment"},
+		{Submitter: "ci-1", Sort: "-created_at", Limit: 1},
+		{Status: "expired", Since: until, Until: since},
+	}
+	for _, q := range queries {
+		got, total := queryIDs(t, idx, q)
+		want := scanIDs(t, idx.Store, q)
+		if len(got) == 0 {
+			got = []string{}
+		}
+		if !reflect.DeepEqual(got, want) || total != len(want) {
+			t.Errorf("Query(%+v) = %d of %d submissions, want %d:\n got %v\nwant %v", q, len(got), total, len(want), got, want)
+		}
+	}
+}
+
+// listHook runs a hook after the backend is listed, before the listing is
+// returned, as if other writes landed while a rebuild was in progress
+type listHook struct {
+	Store
+	hook func()
+}
+
+func (l *listHook) ListSubmissions() ([]*PlanSubmission, error) {
+	submissions, err := l.Store.ListSubmissions()
+	if l.hook != nil {
+		l.hook()
+	}
+	return submissions, err
+}
+
+func TestIndexedStoreRebuildMerges(t *testing.T) {
+	backend, err := NewStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewStorage: %v", err)
+	}
+	store := &listHook{Store: backend}
+	idx, err := NewIndexedStore(store, 0)
+	if err != nil {
+		t.Fatalf("NewIndexedStore: %v", err)
+	}
+
+	kept, _ := idx.StorePlan(strings.NewReader("kept"), DefaultMaxPlanSize, "ci")
+	deleted, _ := idx.StorePlan(strings.NewReader("deleted"), DefaultMaxPlanSize, "ci")
+	changed, _ := idx.StorePlan(strings.NewReader("changed"), DefaultMaxPlanSize, "ci")
+
+	// Another instance's writes reach the backend only
+	remote, _ := backend.StorePlan(strings.NewReader("remote"), DefaultMaxPlanSize, "ci")
+	remoteGone, _ := idx.StorePlan(strings.NewReader("remote gone"), DefaultMaxPlanSize, "ci")
+	backend.DeleteSubmission(remoteGone.ID)
+
+	// Writes through the index while the backend is listed
+	var added *PlanSubmission
+	store.hook = func() {
+		store.hook = nil
+		added, _ = idx.StorePlan(strings.NewReader("added"), DefaultMaxPlanSize, "ci")
+		idx.DeleteSubmission(deleted.ID)
+		idx.ModifySubmission(changed.ID, func(current *PlanSubmission) error {
+			current.Status = "rejected"
+			return nil
+		})
+	}
+	if err := idx.Rebuild(); err != nil {
+		t.Fatalf("Rebuild: %v", err)
+	}
+
+	pending, _ := queryIDs(t, idx, SubmissionQuery{Status: "pending", Sort: "created_at", Limit: -1})
+	sort.Strings(pending)
+	want := []string{kept.ID, remote.ID, added.ID}
+	sort.Strings(want)
+	if !reflect.DeepEqual(pending, want) {
+		t.Errorf("pending after rebuild = %v, want %v", pending, want)
+	}
+	if rejected, _ := queryIDs(t, idx, SubmissionQuery{Status: "rejected"}); !reflect.DeepEqual(rejected, []string{changed.ID}) {
+		t.Errorf("rejected after rebuild = %v, want %v", rejected, []string{changed.ID})
+	}
+	if all, _ := idx.ListSubmissions(); len(all) != 4 {
+		t.Errorf("%d submissions after rebuild, want 4", len(all))
+	}
+}
+
+func TestIndexedStoreRefreshesInBackground(t *testing.T) {
+	backend, err := NewStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewStorage: %v", err)
+	}
+	idx, err := NewIndexedStore(backend, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("NewIndexedStore: %v", err)
+	}
+	defer idx.Close()
+
+	remote, _ := backend.StorePlan(strings.NewReader("remote"), DefaultMaxPlanSize, "ci")
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if pending, _ := idx.ListPending(); len(pending) == 1 && pending[0].ID == remote.ID {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("another instance's submission never reached the index")
+		}
+	}
+}
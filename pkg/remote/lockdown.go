@@ -1,48 +1,456 @@
 package remote
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
+	"strings"
+	"sync"
+	"time"
 )
 
-// handleLockdown toggles lockdown mode
+// Lockdown scopes
+const (
+	ScopeGlobal    = "global"    // Every request
+	ScopeWorkspace = "workspace" // Submissions for one environment or workspace
+	ScopeSubmitter = "submitter" // Submissions from one submitter
+)
+
+// legacyLockdownFile is the marker file older versions wrote in the
+// server's working directory
+const legacyLockdownFile = "LOCKDOWN_MODE"
+
+// lockdownCacheTTL is how long the lockdown list is cached when the storage
+// backend is shared, so replicas see a new lockdown within seconds
+const lockdownCacheTTL = 2 * time.Second
+
+// Lockdown is an active emergency lockdown
+type Lockdown struct {
+	Scope     string     `json:"scope"`            // global, workspace or submitter
+	Target    string     `json:"target,omitempty"` // Workspace or submitter name; empty for global
+	Reason    string     `json:"reason"`
+	EnabledBy string     `json:"enabled_by"`
+	EnabledAt time.Time  `json:"enabled_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"` // Lifted automatically after this time
+}
+
+// String describes the lockdown for error messages and logs
+func (l Lockdown) String() string {
+	scope := l.Scope
+	if l.Target != "" {
+		scope += " " + l.Target
+	}
+	desc := fmt.Sprintf("%s lockdown by %s at %s", scope, l.EnabledBy, l.EnabledAt.Format(time.RFC3339))
+	if l.Reason != "" {
+		desc += ": " + l.Reason
+	}
+	if l.ExpiresAt != nil {
+		desc += fmt.Sprintf(" (expires %s)", l.ExpiresAt.Format(time.RFC3339))
+	}
+	return desc
+}
+
+// active reports whether the lockdown has not expired
+func (l Lockdown) active(now time.Time) bool {
+	return l.ExpiresAt == nil || now.Before(*l.ExpiresAt)
+}
+
+// sameScope reports whether two lockdowns cover the same scope and target
+func (l Lockdown) sameScope(other Lockdown) bool {
+	return l.Scope == other.Scope && l.Target == other.Target
+}
+
+// LockdownRequest enables or lifts a lockdown (POST /lockdown)
+type LockdownRequest struct {
+	Mode     string        // on or off
+	Scope    string        // Default global
+	Target   string        // Required for workspace and submitter scopes
+	Reason   string        // Why the lockdown was enabled
+	Duration time.Duration // Auto-expiry; 0 lasts until lifted
+	By       string        // Who is acting; replaced by the caller's identity when authenticated
+}
+
+// lockdownState caches the lockdown list held in the storage backend
+type lockdownState struct {
+	mu        sync.Mutex
+	lockdowns []Lockdown
+	loaded    time.Time
+	ttl       time.Duration // 0 = cache until this instance changes it
+}
+
+// lockdowns returns the active lockdowns, reloading a stale cache
+func (s *SigningService) lockdowns() ([]Lockdown, error) {
+	st := &s.lockdown
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if st.loaded.IsZero() || (st.ttl > 0 && time.Since(st.loaded) > st.ttl) {
+		lockdowns, err := s.loadLockdowns()
+		if err != nil {
+			return nil, err
+		}
+		st.lockdowns = lockdowns
+		st.loaded = time.Now()
+	}
+
+	now := time.Now()
+	var active []Lockdown
+	for _, l := range st.lockdowns {
+		if l.active(now) {
+			active = append(active, l)
+		}
+	}
+	return active, nil
+}
+
+// loadLockdowns reads the lockdown list from the storage backend
+func (s *SigningService) loadLockdowns() ([]Lockdown, error) {
+	data, err := s.storage.LoadState("lockdown")
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load lockdown state: %w", err)
+	}
+
+	var lockdowns []Lockdown
+	if err := json.Unmarshal(data, &lockdowns); err != nil {
+		return nil, fmt.Errorf("failed to parse lockdown state: %w", err)
+	}
+	return lockdowns, nil
+}
+
+// updateLockdowns applies change to the stored lockdown list, dropping
+// expired entries, and saves it. The update is atomic in the backend, so a
+// concurrent change from another replica is not lost.
+func (s *SigningService) updateLockdowns(change func([]Lockdown) []Lockdown) error {
+	st := &s.lockdown
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	var updated []Lockdown
+	err := s.storage.ModifyState("lockdown", func(data []byte) ([]byte, error) {
+		var current []Lockdown
+		if data != nil {
+			if err := json.Unmarshal(data, &current); err != nil {
+				return nil, fmt.Errorf("failed to parse lockdown state: %w", err)
+			}
+		}
+
+		now := time.Now()
+		var live []Lockdown
+		for _, l := range current {
+			if l.active(now) {
+				live = append(live, l)
+			}
+		}
+		updated = change(live)
+
+		data, err := json.MarshalIndent(updated, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal lockdown state: %w", err)
+		}
+		return data, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	st.lockdowns = updated
+	st.loaded = time.Now()
+	return nil
+}
+
+// EnableLockdown adds (or replaces) a lockdown for a scope
+func (s *SigningService) EnableLockdown(l Lockdown) error {
+	return s.updateLockdowns(func(lockdowns []Lockdown) []Lockdown {
+		var kept []Lockdown
+		for _, existing := range lockdowns {
+			if !existing.sameScope(l) {
+				kept = append(kept, existing)
+			}
+		}
+		return append(kept, l)
+	})
+}
+
+// LiftLockdown removes the lockdown for a scope. It reports whether one was active.
+func (s *SigningService) LiftLockdown(scope, target string) (bool, error) {
+	lifted := false
+	err := s.updateLockdowns(func(lockdowns []Lockdown) []Lockdown {
+		lifted = false
+		var kept []Lockdown
+		for _, existing := range lockdowns {
+			if existing.sameScope(Lockdown{Scope: scope, Target: target}) {
+				lifted = true
+				continue
+			}
+			kept = append(kept, existing)
+		}
+		return kept
+	})
+	return lifted, err
+}
+
+// importLegacyLockdown turns a LOCKDOWN_MODE file left in the working
+// directory by an older version into a stored global lockdown
+func (s *SigningService) importLegacyLockdown() error {
+	if _, err := os.Stat(legacyLockdownFile); err != nil {
+		return nil
+	}
+
+	err := s.EnableLockdown(Lockdown{
+		Scope:     ScopeGlobal,
+		Reason:    "imported from " + legacyLockdownFile + " file",
+		EnabledBy: "unknown",
+		EnabledAt: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("[WARN] Imported %s as a global lockdown and removed the file\n", legacyLockdownFile)
+	return os.Remove(legacyLockdownFile)
+}
+
+// parseLockdownRequest reads a lockdown request from query parameters
+func parseLockdownRequest(r *http.Request) (LockdownRequest, error) {
+	q := r.URL.Query()
+	req := LockdownRequest{
+		Mode:   q.Get("mode"),
+		Scope:  q.Get("scope"),
+		Target: q.Get("target"),
+		Reason: q.Get("reason"),
+		By:     q.Get("by"),
+	}
+
+	if req.Mode != "on" && req.Mode != "off" {
+		return req, fmt.Errorf("invalid mode (use 'on' or 'off')")
+	}
+
+	if req.Scope == "" {
+		req.Scope = ScopeGlobal
+	}
+	switch req.Scope {
+	case ScopeGlobal:
+		if req.Target != "" {
+			return req, fmt.Errorf("a global lockdown takes no target")
+		}
+	case ScopeWorkspace, ScopeSubmitter:
+		if req.Target == "" {
+			return req, fmt.Errorf("a %s lockdown requires a target", req.Scope)
+		}
+	default:
+		return req, fmt.Errorf("invalid scope %q (use global, workspace or submitter)", req.Scope)
+	}
+
+	if d := q.Get("duration"); d != "" {
+		duration, err := time.ParseDuration(d)
+		if err != nil || duration <= 0 {
+			return req, fmt.Errorf("invalid duration %q (e.g. 30m, 4h)", d)
+		}
+		req.Duration = duration
+	}
+
+	if req.By == "" {
+		req.By = "admin"
+	}
+	req.By = callerName(r, req.By)
+
+	return req, nil
+}
+
+// handleLockdown lists (GET) or enables and lifts (POST) lockdowns
 func (s *SigningService) handleLockdown(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		if p := PrincipalFromContext(r.Context()); p != nil && !p.Has(RoleAdmin) && !p.Has(RoleAuditor) {
+			http.Error(w, fmt.Sprintf("Forbidden: %s lacks role admin or auditor", p.Subject), http.StatusForbidden)
+			return
+		}
+		lockdowns, err := s.lockdowns()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if lockdowns == nil {
+			lockdowns = []Lockdown{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(lockdowns)
+		return
+	}
+
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	mode := r.URL.Query().Get("mode")
-	if mode != "on" && mode != "off" {
-		http.Error(w, "Invalid mode (use 'on' or 'off')", http.StatusBadRequest)
+	req, err := parseLockdownRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	lockdownFile := "LOCKDOWN_MODE"
-	if mode == "on" {
-		// Create lockdown file
-		if err := os.WriteFile(lockdownFile, []byte("EMERGENCY LOCKDOWN"), 0644); err != nil {
-			http.Error(w, "Failed to enable lockdown", http.StatusInternalServerError)
+	// Admins enable lockdowns. Lifting one needs a signed challenge from an
+	// admin key or the break-glass key, whoever the caller is.
+	p := PrincipalFromContext(r.Context())
+	if req.Mode == "on" && p != nil && !p.Has(RoleAdmin) {
+		http.Error(w, fmt.Sprintf("Forbidden: %s lacks role admin", p.Subject), http.StatusForbidden)
+		return
+	}
+	caller := req.By
+	releasedWith := ""
+	if req.Mode == "off" {
+		var release LockdownRelease
+		if err := json.NewDecoder(io.LimitReader(r.Body, 64<<10)).Decode(&release); err != nil {
+			http.Error(w, "Lifting a lockdown requires a signed release challenge (POST /lockdown/challenge)", http.StatusForbidden)
+			return
+		}
+		signer, err := s.verifyRelease(release, req.Scope, req.Target)
+		if err != nil {
+			s.audit(r, AuditReleaseRefused, req.By, "", map[string]string{"scope": req.Scope, "target": req.Target, "method": release.Method, "reason": err.Error()})
+			http.Error(w, fmt.Sprintf("Lockdown release refused: %v", err), http.StatusForbidden)
 			return
 		}
-		fmt.Println("[EMERGENCY LOCKDOWN ENABLED]")
+		releasedWith = signer
+		req.By = fmt.Sprintf("%s (%s)", req.By, signer)
+	}
+
+	if req.Mode == "on" {
+		l := Lockdown{
+			Scope:     req.Scope,
+			Target:    req.Target,
+			Reason:    req.Reason,
+			EnabledBy: req.By,
+			EnabledAt: time.Now(),
+		}
+		if req.Duration > 0 {
+			expires := l.EnabledAt.Add(req.Duration)
+			l.ExpiresAt = &expires
+		}
+		if err := s.EnableLockdown(l); err != nil {
+			http.Error(w, fmt.Sprintf("Failed to enable lockdown: %v", err), http.StatusInternalServerError)
+			return
+		}
+		fmt.Printf("[EMERGENCY LOCKDOWN ENABLED] %s\n", l)
+		details := map[string]string{"scope": l.Scope, "target": l.Target, "reason": l.Reason}
+		if l.ExpiresAt != nil {
+			details["expires_at"] = l.ExpiresAt.UTC().Format(time.RFC3339)
+		}
+		s.audit(r, AuditLockdownOn, req.By, "", details)
 	} else {
-		// Remove lockdown file
-		if err := os.Remove(lockdownFile); err != nil && !os.IsNotExist(err) {
-			http.Error(w, "Failed to disable lockdown", http.StatusInternalServerError)
+		lifted, err := s.LiftLockdown(req.Scope, req.Target)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to disable lockdown: %v", err), http.StatusInternalServerError)
 			return
 		}
-		fmt.Println("[LOCKDOWN DISABLED]")
+		if !lifted {
+			http.Error(w, fmt.Sprintf("No active %s lockdown", strings.TrimSpace(req.Scope+" "+req.Target)), http.StatusNotFound)
+			return
+		}
+		fmt.Printf("[LOCKDOWN DISABLED] %s by %s\n", strings.TrimSpace(req.Scope+" "+req.Target), req.By)
+		s.audit(r, AuditLockdownOff, caller, "", map[string]string{"scope": req.Scope, "target": req.Target, "released_with": releasedWith})
 	}
 
 	w.WriteHeader(http.StatusOK)
 }
 
-// isLockdown checks if lockdown is active
-func (s *SigningService) isLockdown() bool {
-	_, err := os.Stat("LOCKDOWN_MODE")
-	return err == nil
+// blockingLockdown returns the lockdown that applies to a request, if any.
+// Requests naming a submission are checked against its environment and
+// submitter; new submissions against the submitter here, and against their
+// environment by handleSubmit once the service has established it from
+// the plan (see workspaceLockdown).
+func (s *SigningService) blockingLockdown(r *http.Request) (*Lockdown, error) {
+	lockdowns, err := s.lockdowns()
+	if err != nil || len(lockdowns) == 0 {
+		return nil, err
+	}
+
+	for _, l := range lockdowns {
+		if l.Scope == ScopeGlobal {
+			return &l, nil
+		}
+	}
+
+	if r.URL.Path == "/submit" {
+		submitter := r.Header.Get("X-Submitter")
+		if submitter == "" {
+			submitter = r.URL.Query().Get("submitter")
+		}
+		if submitter == "" {
+			submitter = "unknown"
+		}
+		return submitterLockdown(lockdowns, callerName(r, submitter)), nil
+	}
+
+	id := submissionIDFromPath(r.URL.Path)
+	if id == "" {
+		return nil, nil // Listings are only stopped by a global lockdown
+	}
+	submission, err := s.storage.GetSubmission(id)
+	if err != nil {
+		return nil, nil // The handler reports the missing submission
+	}
+	if l := submitterLockdown(lockdowns, submission.Submitter); l != nil {
+		return l, nil
+	}
+	return environmentLockdown(lockdowns, verifiedEnvironment(submission)), nil
+}
+
+// workspaceLockdown returns the workspace lockdown covering a new
+// submission's environment ("" when it could not be established), if any
+func (s *SigningService) workspaceLockdown(environment string) (*Lockdown, error) {
+	lockdowns, err := s.lockdowns()
+	if err != nil {
+		return nil, err
+	}
+	return environmentLockdown(lockdowns, environment), nil
+}
+
+// submitterLockdown returns the lockdown of a submitter, if any
+func submitterLockdown(lockdowns []Lockdown, submitter string) *Lockdown {
+	for _, l := range lockdowns {
+		if l.Scope == ScopeSubmitter && l.Target == submitter {
+			return &l
+		}
+	}
+	return nil
+}
+
+// environmentLockdown returns the workspace lockdown of an environment, if
+// any. It fails closed: an environment the service could not establish
+// ("") might be the locked one, so every workspace lockdown covers it.
+func environmentLockdown(lockdowns []Lockdown, environment string) *Lockdown {
+	for _, l := range lockdowns {
+		if l.Scope == ScopeWorkspace && (environment == "" || l.Target == environment) {
+			return &l
+		}
+	}
+	return nil
+}
+
+// verifiedEnvironment returns the environment of a submission if the
+// service established it, or "" for an unverified one (including those
+// stored before environments were verified, which took the submitter's
+// declaration)
+func verifiedEnvironment(submission *PlanSubmission) string {
+	switch submission.EnvironmentSource {
+	case EnvironmentFromWorkspace, EnvironmentFromCredential:
+		return submission.Environment
+	}
+	return ""
+}
+
+// submissionIDFromPath returns the submission ID from /<route>/<id>[/...]
+func submissionIDFromPath(path string) string {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) < 2 {
+		return ""
+	}
+	return parts[1]
 }
 
 // checkLockdown middleware to reject requests during lockdown
@@ -54,8 +462,14 @@ func (s *SigningService) checkLockdown(next http.HandlerFunc) http.HandlerFunc {
 			return
 		}
 
-		if s.isLockdown() {
-			http.Error(w, "EMERGENCY LOCKDOWN ACTIVE - REJECTING ALL REQUESTS", http.StatusServiceUnavailable)
+		l, err := s.blockingLockdown(r)
+		if err != nil {
+			// Fail closed: an unreadable lockdown state must not let requests through
+			http.Error(w, fmt.Sprintf("Lockdown state unavailable: %v", err), http.StatusServiceUnavailable)
+			return
+		}
+		if l != nil {
+			http.Error(w, fmt.Sprintf("EMERGENCY LOCKDOWN ACTIVE - REJECTING REQUEST (%s)", l), http.StatusServiceUnavailable)
 			return
 		}
 
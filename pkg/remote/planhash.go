@@ -0,0 +1,130 @@
+package remote
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/sulakshanakarunarathne/terrasign/pkg/attestation"
+	"github.com/sulakshanakarunarathne/terrasign/pkg/planfile"
+)
+
+// PlanHashHeader carries the hex SHA-256 of a plan on submissions and downloads
+const PlanHashHeader = "X-Plan-Sha256"
+
+// checkStoredPlan re-hashes the stored plan, and its JSON when one was
+// submitted, and compares them with the digests recorded at submission.
+// Submissions from before plan hashes were recorded have no digest and are
+// not checked.
+func (s *SigningService) checkStoredPlan(submission *PlanSubmission) error {
+	if submission.PlanHash == "" {
+		return nil
+	}
+
+	planData, err := s.loadPlan(submission.ID)
+	if err != nil {
+		return fmt.Errorf("failed to hash stored plan: %w", err)
+	}
+	sum := sha256.Sum256(planData)
+	digest := hex.EncodeToString(sum[:])
+	if !strings.EqualFold(digest, submission.PlanHash) {
+		return fmt.Errorf("stored plan for %s does not match its recorded hash (recorded %s, found %s)",
+			submission.ID, submission.PlanHash, digest)
+	}
+
+	if submission.PlanJSONHash != "" {
+		jsonData, err := s.loadPlanJSON(submission.ID)
+		if err != nil {
+			return fmt.Errorf("failed to hash stored plan JSON: %w", err)
+		}
+		sum := sha256.Sum256(jsonData)
+		digest := hex.EncodeToString(sum[:])
+		if !strings.EqualFold(digest, submission.PlanJSONHash) {
+			return fmt.Errorf("stored plan JSON for %s does not match its recorded hash (recorded %s, found %s)",
+				submission.ID, submission.PlanJSONHash, digest)
+		}
+	}
+
+	return nil
+}
+
+// signedMessage returns what reviewers sign for a submission: the stored
+// plan, or the binding of the plan and JSON digests when the plan was
+// submitted with its JSON. The stored files must match the recorded hashes.
+func (s *SigningService) signedMessage(submission *PlanSubmission) ([]byte, error) {
+	if submission.PlanJSONHash != "" {
+		if err := s.checkStoredPlan(submission); err != nil {
+			return nil, fmt.Errorf("stored plan changed since submission: %w", err)
+		}
+		return planfile.Binding(submission.PlanHash, submission.PlanJSONHash), nil
+	}
+
+	planData, err := s.loadPlan(submission.ID)
+	if err != nil {
+		return nil, err
+	}
+	if submission.PlanHash != "" {
+		sum := sha256.Sum256(planData)
+		if err := matchPlanHash(submission.PlanHash, hex.EncodeToString(sum[:])); err != nil {
+			return nil, fmt.Errorf("stored plan changed since submission: %w", err)
+		}
+	}
+	return planData, nil
+}
+
+// loadPlan reads a stored plan into memory
+func (s *SigningService) loadPlan(id string) ([]byte, error) {
+	plan, err := s.storage.OpenPlan(id)
+	if err != nil {
+		return nil, err
+	}
+	defer plan.Close()
+
+	data, err := io.ReadAll(plan)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stored plan: %w", err)
+	}
+	return data, nil
+}
+
+// CheckPlanHash compares a local plan, and its JSON when paired, with the
+// hashes the service recorded for the submission
+func (c *Client) CheckPlanHash(id string, in planfile.Input) error {
+	submission, err := c.GetStatus(id)
+	if err != nil {
+		return err
+	}
+
+	digest, err := attestation.DigestFile(in.Path())
+	if err != nil {
+		return fmt.Errorf("failed to hash plan: %w", err)
+	}
+	if err := matchPlanHash(submission.PlanHash, digest); err != nil {
+		return err
+	}
+	if !in.Paired() {
+		return nil
+	}
+
+	jsonDigest, err := attestation.DigestFile(in.JSON)
+	if err != nil {
+		return fmt.Errorf("failed to hash plan JSON: %w", err)
+	}
+	if !strings.EqualFold(submission.PlanJSONHash, jsonDigest) {
+		return fmt.Errorf("plan JSON hash mismatch: service recorded %q, local plan JSON is %s", submission.PlanJSONHash, jsonDigest)
+	}
+	return nil
+}
+
+// matchPlanHash compares a recorded plan hash with one computed locally
+func matchPlanHash(recorded, actual string) error {
+	if recorded == "" {
+		return fmt.Errorf("service recorded no plan hash for this submission")
+	}
+	if !strings.EqualFold(recorded, actual) {
+		return fmt.Errorf("plan hash mismatch: service recorded %s, local plan is %s", recorded, actual)
+	}
+	return nil
+}
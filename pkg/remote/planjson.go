@@ -0,0 +1,155 @@
+package remote
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"os"
+	"strings"
+
+	"github.com/sulakshanakarunarathne/terrasign/pkg/attestation"
+	"github.com/sulakshanakarunarathne/terrasign/pkg/planfile"
+)
+
+// PlanJSONHashHeader carries the hex SHA-256 of the plan JSON submitted with
+// a plan
+const PlanJSONHashHeader = "X-Plan-Json-Sha256"
+
+// maxPlanJSONSize bounds the plan JSON read into memory on submission
+const maxPlanJSONSize = 256 << 20
+
+// Multipart form names of a plan submitted with its JSON
+const (
+	planPart     = "plan"
+	planJSONPart = "plan_json"
+)
+
+// storePlanJSON reads the plan JSON part that follows the plan in a
+// multipart submission, checks that it is the rendering of the stored plan
+// and stores it, recording its hash on the submission
+func (s *SigningService) storePlanJSON(submission *PlanSubmission, mr *multipart.Reader) error {
+	part, err := mr.NextPart()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read submission: %w", err)
+	}
+	defer part.Close()
+	if part.FormName() != planJSONPart {
+		return fmt.Errorf("unexpected part %q after the plan (want %q)", part.FormName(), planJSONPart)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(part, maxPlanJSONSize+1))
+	if err != nil {
+		return fmt.Errorf("failed to read plan JSON: %w", err)
+	}
+	if len(data) > maxPlanJSONSize {
+		return fmt.Errorf("plan JSON exceeds %d bytes", maxPlanJSONSize)
+	}
+
+	planData, err := s.loadPlan(submission.ID)
+	if err != nil {
+		return err
+	}
+	plan, err := planfile.Parse(planData)
+	if err != nil {
+		return err
+	}
+	rendered, _, err := planfile.ReadJSON(data)
+	if err != nil {
+		return err
+	}
+	if err := plan.CheckJSON(rendered); err != nil {
+		return err
+	}
+
+	if err := s.storage.SavePlanJSON(submission.ID, data); err != nil {
+		return err
+	}
+	sum := sha256.Sum256(data)
+	submission.PlanJSONHash = hex.EncodeToString(sum[:])
+	return nil
+}
+
+// loadPlanJSON reads the stored plan JSON into memory
+func (s *SigningService) loadPlanJSON(id string) ([]byte, error) {
+	file, err := s.storage.OpenPlanJSON(id)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stored plan JSON: %w", err)
+	}
+	return data, nil
+}
+
+// submitBody streams a plan, and its JSON when jsonPath is set, as a
+// multipart submission body. It returns the body and its content type.
+func submitBody(planPath, jsonPath string) (io.ReadCloser, string) {
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+
+	go func() {
+		err := writePart(mw, planPart, planPath)
+		if err == nil {
+			err = writePart(mw, planJSONPart, jsonPath)
+		}
+		if err == nil {
+			err = mw.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+
+	return pr, mw.FormDataContentType()
+}
+
+// writePart copies a file into a multipart form field
+func writePart(mw *multipart.Writer, name, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	part, err := mw.CreateFormFile(name, name)
+	if err != nil {
+		return fmt.Errorf("failed to write submission: %w", err)
+	}
+	if _, err := io.Copy(part, file); err != nil {
+		return fmt.Errorf("failed to write submission: %w", err)
+	}
+	return nil
+}
+
+// DownloadPlanJSON downloads the plan JSON submitted with a plan and checks
+// it against the hash recorded at submission
+func (c *Client) DownloadPlanJSON(id, outputPath string) error {
+	submission, err := c.GetStatus(id)
+	if err != nil {
+		return err
+	}
+	if submission.PlanJSONHash == "" {
+		return fmt.Errorf("submission %s has no plan JSON: %w", id, ErrNotFound)
+	}
+
+	if _, err := c.downloadFile(id, "plan-json", outputPath); err != nil {
+		return err
+	}
+
+	digest, err := attestation.DigestFile(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to hash downloaded plan JSON: %w", err)
+	}
+	if !strings.EqualFold(digest, submission.PlanJSONHash) {
+		os.Remove(outputPath)
+		return fmt.Errorf("downloaded plan JSON rejected: service recorded %s, received %s", submission.PlanJSONHash, digest)
+	}
+
+	return nil
+}
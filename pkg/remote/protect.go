@@ -0,0 +1,82 @@
+package remote
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/sulakshanakarunarathne/terrasign/pkg/attestation"
+	"github.com/sulakshanakarunarathne/terrasign/pkg/planfile"
+	"github.com/sulakshanakarunarathne/terrasign/pkg/policy"
+	"github.com/sulakshanakarunarathne/terrasign/pkg/verifier"
+)
+
+// checkAcknowledgement refuses to count an approval of a plan that destroys
+// or replaces protected resources, unless the submission's policy
+// attestation acknowledges each of them, is signed by the approving key and
+// is bound to the stored plan (`terrasign admin sign --acknowledge-destroy`
+// uploads it before the signature). The CLI enforces the same when signing;
+// this keeps a signature made without it from approving the plan.
+func (s *SigningService) checkAcknowledgement(submission *PlanSubmission, key *verifier.TrustedKey) error {
+	doc, err := s.planDocument(submission)
+	if err != nil {
+		return fmt.Errorf("cannot check protected resources: %w", err)
+	}
+	findings, err := s.protection.Unacknowledged(doc, nil)
+	if err != nil || len(findings) == 0 {
+		return err
+	}
+	destroyed := strings.Join(policy.Addresses(findings), ", ")
+
+	file, err := s.storage.OpenAttestation(submission.ID, "policy")
+	if err != nil {
+		return fmt.Errorf("plan destroys or replaces protected resources (%s) and no acknowledgement was uploaded (sign with --acknowledge-destroy)", destroyed)
+	}
+	data, err := io.ReadAll(io.LimitReader(file, maxAttestationSize))
+	file.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read policy attestation: %w", err)
+	}
+
+	digests := []string{submission.PlanHash}
+	if submission.PlanJSONHash != "" {
+		digests = append(digests, submission.PlanJSONHash)
+	}
+	env, err := attestation.Parse(data)
+	if err != nil {
+		return fmt.Errorf("plan destroys or replaces protected resources (%s); the policy attestation is invalid: %w", destroyed, err)
+	}
+	result, err := policy.OpenAttestation(env, key.Verifier(), digests...)
+	if err != nil {
+		return fmt.Errorf("plan destroys or replaces protected resources (%s); the policy attestation does not verify against key %s: %w", destroyed, key.Identity(), err)
+	}
+
+	remaining, err := s.protection.Unacknowledged(doc, result.Acknowledgement)
+	if err != nil {
+		return fmt.Errorf("invalid acknowledgement: %w", err)
+	}
+	if len(remaining) > 0 {
+		return fmt.Errorf("plan destroys or replaces protected resources without acknowledgement: %s (sign with --acknowledge-destroy)", strings.Join(policy.Addresses(remaining), ", "))
+	}
+	return nil
+}
+
+// planDocument returns a submission's plan as `terraform show -json`
+// renders it. A binary plan is rendered natively even when plan JSON was
+// submitted with it, as that is the plan Terraform applies.
+func (s *SigningService) planDocument(submission *PlanSubmission) (map[string]interface{}, error) {
+	data, err := s.loadPlan(submission.ID)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		_, doc, err := planfile.ReadJSON(data)
+		return doc, err
+	}
+	plan, err := planfile.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	return plan.JSON()
+}
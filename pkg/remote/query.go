@@ -0,0 +1,218 @@
+package remote
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Page size limits for /submissions
+const (
+	defaultQueryLimit = 50
+	maxQueryLimit     = 500
+)
+
+// SubmissionQuery filters, sorts and pages submissions. Zero values mean
+// "no filter".
+type SubmissionQuery struct {
+	Status      string // pending, approved, rejected or expired
+	Submitter   string
+	Environment string    // Environment or workspace the plan was submitted for
+	Since       time.Time // Created at or after
+	Until       time.Time // Created before
+	Sort        string    // created_at, submitter, status, environment or risk; prefix "-" for descending. Default -created_at
+	Limit       int       // Page size; default 50, at most 500
+	Cursor      string    // NextCursor from the previous page
+}
+
+// SubmissionPage is one page of query results
+type SubmissionPage struct {
+	Submissions []*PlanSubmission `json:"submissions"`
+	Total       int               `json:"total"`                 // Matches across all pages
+	NextCursor  string            `json:"next_cursor,omitempty"` // Empty on the last page
+}
+
+// sortFields are the fields a query can sort by
+var sortFields = map[string]bool{"created_at": true, "submitter": true, "status": true, "environment": true, "risk": true}
+
+// sortField splits Sort into the field name and direction
+func (q SubmissionQuery) sortField() (field string, desc bool) {
+	if q.Sort == "" {
+		return "created_at", true
+	}
+	return strings.TrimPrefix(q.Sort, "-"), strings.HasPrefix(q.Sort, "-")
+}
+
+// Values encodes the query as URL parameters
+func (q SubmissionQuery) Values() url.Values {
+	v := url.Values{}
+	set := func(name, value string) {
+		if value != "" {
+			v.Set(name, value)
+		}
+	}
+	set("status", q.Status)
+	set("submitter", q.Submitter)
+	set("environment", q.Environment)
+	if !q.Since.IsZero() {
+		v.Set("since", q.Since.Format(time.RFC3339Nano))
+	}
+	if !q.Until.IsZero() {
+		v.Set("until", q.Until.Format(time.RFC3339Nano))
+	}
+	set("sort", q.Sort)
+	if q.Limit > 0 {
+		v.Set("limit", strconv.Itoa(q.Limit))
+	}
+	set("cursor", q.Cursor)
+	return v
+}
+
+// ParseSubmissionQuery parses and validates /submissions URL parameters.
+// Dates are RFC3339 or YYYY-MM-DD; a date-only "until" includes that day.
+func ParseSubmissionQuery(v url.Values) (SubmissionQuery, error) {
+	q := SubmissionQuery{
+		Status:      v.Get("status"),
+		Submitter:   v.Get("submitter"),
+		Environment: v.Get("environment"),
+		Sort:        v.Get("sort"),
+		Cursor:      v.Get("cursor"),
+	}
+	if q.Environment == "" {
+		q.Environment = v.Get("workspace")
+	}
+
+	switch q.Status {
+	case "", "pending", "approved", "rejected", "expired":
+	default:
+		return q, fmt.Errorf("invalid status %q (use pending, approved, rejected or expired)", q.Status)
+	}
+
+	var err error
+	if s := v.Get("since"); s != "" {
+		if q.Since, err = ParseQueryTime(s, false); err != nil {
+			return q, err
+		}
+	}
+	if s := v.Get("until"); s != "" {
+		if q.Until, err = ParseQueryTime(s, true); err != nil {
+			return q, err
+		}
+	}
+
+	if field, _ := q.sortField(); !sortFields[field] {
+		return q, fmt.Errorf("invalid sort %q (use created_at, submitter, status, environment or risk, prefixed with - for descending)", q.Sort)
+	}
+
+	if s := v.Get("limit"); s != "" {
+		if q.Limit, err = strconv.Atoi(s); err != nil || q.Limit < 1 || q.Limit > maxQueryLimit {
+			return q, fmt.Errorf("invalid limit %q (1-%d)", s, maxQueryLimit)
+		}
+	}
+
+	if q.Cursor != "" {
+		if _, err := decodeCursor(q.Cursor); err != nil {
+			return q, err
+		}
+	}
+
+	return q, nil
+}
+
+// ParseQueryTime parses an RFC3339 time or a YYYY-MM-DD date. With endOfDay
+// a date means the start of the following day, so the day is included in an
+// exclusive upper bound.
+func ParseQueryTime(s string, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q (use YYYY-MM-DD or RFC3339)", s)
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+// queryCursor marks the last item of a page: its sort key and ID. The next
+// page starts after that position, even if the item has since changed.
+type queryCursor struct {
+	Sort string `json:"s"`
+	Key  string `json:"k"`
+	ID   string `json:"i"`
+}
+
+// encodeCursor encodes a cursor as opaque URL-safe text
+func encodeCursor(c queryCursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor decodes a cursor from encodeCursor
+func decodeCursor(s string) (queryCursor, error) {
+	var c queryCursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err == nil {
+		err = json.Unmarshal(data, &c)
+	}
+	if err != nil {
+		return c, fmt.Errorf("invalid cursor")
+	}
+	return c, nil
+}
+
+// handleSubmissions answers GET /submissions from the submission index
+func (s *SigningService) handleSubmissions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q, err := ParseSubmissionQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page, err := s.storage.Query(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+// ListSubmissions queries submissions on the service. Follow NextCursor
+// for further pages.
+func (c *Client) ListSubmissions(q SubmissionQuery) (*SubmissionPage, error) {
+	resp, err := c.client.Get(c.baseURL + "/submissions?" + q.Values().Encode())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list submissions: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if err := authError(resp); err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("server error: %s", strings.TrimSpace(string(body)))
+	}
+
+	var page SubmissionPage
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return &page, nil
+}
@@ -0,0 +1,87 @@
+package remote
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// RejectRequest is the body of POST /reject/{id}
+type RejectRequest struct {
+	Reviewer string `json:"reviewer"`
+	Reason   string `json:"reason"`
+}
+
+// handleReject handles plan rejection from admin
+func (s *SigningService) handleReject(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Extract ID from path: /reject/{id}
+	id := r.URL.Path[len("/reject/"):]
+	if id == "" {
+		http.Error(w, "Missing submission ID", http.StatusBadRequest)
+		return
+	}
+
+	var req RejectRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Reason) == "" {
+		http.Error(w, "A rejection reason is required", http.StatusBadRequest)
+		return
+	}
+	if req.Reviewer == "" {
+		req.Reviewer = "admin"
+	}
+	req.Reviewer = callerName(r, req.Reviewer)
+
+	submission, err := s.storage.GetSubmission(id)
+	if err != nil {
+		http.Error(w, "Submission not found", http.StatusNotFound)
+		return
+	}
+	s.expireIfDue(submission)
+	if submission.Status != "pending" {
+		http.Error(w, fmt.Sprintf("Submission %s is already %s", id, submission.Status), http.StatusConflict)
+		return
+	}
+
+	if err := s.MarkRejected(id, req.Reviewer, req.Reason); err != nil {
+		if errors.Is(err, ErrConflict) {
+			http.Error(w, fmt.Sprintf("Submission %s: %v", id, err), http.StatusConflict)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to update status: %v", err), http.StatusInternalServerError)
+		return
+	}
+	s.audit(r, AuditReject, req.Reviewer, id, map[string]string{"reason": req.Reason})
+
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "Submission %s rejected\n", id)
+}
+
+// MarkRejected marks a pending submission as rejected with the reviewer's
+// reason. It fails with ErrConflict if the submission was approved or
+// expired meanwhile.
+func (s *SigningService) MarkRejected(id, reviewer, reason string) error {
+	now := time.Now()
+	_, err := s.storage.ModifySubmission(id, func(submission *PlanSubmission) error {
+		if submission.Status != "pending" {
+			return fmt.Errorf("%w: submission is now %s", ErrConflict, submission.Status)
+		}
+		submission.Status = "rejected"
+		submission.ReviewedBy = reviewer
+		submission.ReviewedAt = &now
+		submission.RejectionReason = reason
+		return nil
+	})
+	return err
+}
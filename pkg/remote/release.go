@@ -0,0 +1,319 @@
+package remote
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/sulakshanakarunarathne/terrasign/pkg/verifier"
+)
+
+// challengeTTL is how long a lockdown release challenge can be answered
+const challengeTTL = 5 * time.Minute
+
+// maxChallenges bounds the outstanding challenges so unanswered requests
+// cannot grow the state document without limit
+const maxChallenges = 100
+
+// maxChallengesPerCaller bounds one caller's outstanding challenges, so a
+// single caller cannot use up maxChallenges and block everyone's release
+const maxChallengesPerCaller = 5
+
+// Release methods
+const (
+	ReleaseAdminKey   = "admin-key"   // Signed with an admin private key
+	ReleaseBreakGlass = "break-glass" // Signed with the key rebuilt from M-of-N break-glass shares
+)
+
+// releaseKey is a public key accepted for lifting lockdowns
+type releaseKey struct {
+	method string // ReleaseAdminKey or ReleaseBreakGlass
+	key    verifier.TrustedKey
+}
+
+// loadReleaseKeys loads the admin keys and the break-glass key
+func loadReleaseKeys(adminKey, breakGlassKey string) ([]releaseKey, error) {
+	var keys []releaseKey
+	for _, k := range []struct{ method, path string }{{ReleaseAdminKey, adminKey}, {ReleaseBreakGlass, breakGlassKey}} {
+		if k.path == "" {
+			continue
+		}
+		loaded, err := verifier.LoadTrustedKeys([]string{k.path})
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range loaded {
+			keys = append(keys, releaseKey{method: k.method, key: key})
+		}
+	}
+	return keys, nil
+}
+
+// LockdownChallenge is a single-use nonce that must be signed to lift a
+// lockdown. Message is the exact text to sign; it binds the nonce to one
+// scope and target.
+type LockdownChallenge struct {
+	Nonce     string    `json:"nonce"`
+	Scope     string    `json:"scope"`
+	Target    string    `json:"target,omitempty"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Message   string    `json:"message"`
+
+	IssuedTo string `json:"issued_to,omitempty"` // Caller that requested it, for the per-caller limit
+}
+
+// LockdownRelease answers a challenge (body of POST /lockdown?mode=off)
+type LockdownRelease struct {
+	Nonce     string `json:"nonce"`
+	Method    string `json:"method"`    // admin-key or break-glass
+	Signature string `json:"signature"` // base64 signature over the challenge message
+}
+
+// challengeMessage is the text signed to release a lockdown
+func challengeMessage(nonce, scope, target string, expires time.Time) string {
+	return fmt.Sprintf("terrasign-lockdown-release\nscope=%s\ntarget=%s\nnonce=%s\nexpires=%s\n",
+		scope, target, nonce, expires.UTC().Format(time.RFC3339))
+}
+
+// modifyChallenges applies change to the outstanding challenges, dropping
+// expired ones. They live in the storage backend so a replica other than the
+// one that issued a challenge can accept its answer, and are updated
+// atomically so two replicas cannot both consume one challenge.
+func (s *SigningService) modifyChallenges(change func([]LockdownChallenge) ([]LockdownChallenge, error)) error {
+	return s.storage.ModifyState("lockdown-challenges", func(data []byte) ([]byte, error) {
+		var challenges []LockdownChallenge
+		if data != nil {
+			if err := json.Unmarshal(data, &challenges); err != nil {
+				return nil, fmt.Errorf("failed to parse lockdown challenges: %w", err)
+			}
+		}
+
+		challenges, err := change(challenges)
+		if err != nil {
+			return nil, err
+		}
+
+		now := time.Now()
+		live := []LockdownChallenge{}
+		for _, c := range challenges {
+			if now.Before(c.ExpiresAt) {
+				live = append(live, c)
+			}
+		}
+		data, err = json.MarshalIndent(live, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal lockdown challenges: %w", err)
+		}
+		return data, nil
+	})
+}
+
+// IssueChallenge creates a release challenge for a scope on behalf of a
+// caller (the authenticated subject, else the client address)
+func (s *SigningService) IssueChallenge(scope, target, caller string) (*LockdownChallenge, error) {
+	nonce := make([]byte, 32)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate challenge: %w", err)
+	}
+
+	c := LockdownChallenge{
+		Nonce:     hex.EncodeToString(nonce),
+		Scope:     scope,
+		Target:    target,
+		ExpiresAt: time.Now().Add(challengeTTL).UTC().Truncate(time.Second),
+		IssuedTo:  caller,
+	}
+	c.Message = challengeMessage(c.Nonce, c.Scope, c.Target, c.ExpiresAt)
+
+	err := s.modifyChallenges(func(challenges []LockdownChallenge) ([]LockdownChallenge, error) {
+		now := time.Now()
+		live, mine := 0, 0
+		for _, existing := range challenges {
+			if !now.Before(existing.ExpiresAt) {
+				continue
+			}
+			live++
+			if existing.IssuedTo == caller {
+				mine++
+			}
+		}
+		if mine >= maxChallengesPerCaller {
+			return nil, fmt.Errorf("%w: %s has %d outstanding challenges; answer or let them expire", errTooManyChallenges, caller, mine)
+		}
+		if live >= maxChallenges {
+			return nil, fmt.Errorf("%w; retry in a few minutes", errTooManyChallenges)
+		}
+		return append(challenges, c), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// errTooManyChallenges refuses a challenge over the outstanding limits
+var errTooManyChallenges = errors.New("too many outstanding challenges")
+
+// consumeChallenge removes a challenge so it can be answered only once
+func (s *SigningService) consumeChallenge(nonce string) (*LockdownChallenge, error) {
+	var found *LockdownChallenge
+	err := s.modifyChallenges(func(challenges []LockdownChallenge) ([]LockdownChallenge, error) {
+		found = nil
+		var rest []LockdownChallenge
+		for _, c := range challenges {
+			if c.Nonce == nonce {
+				found = &c
+				continue
+			}
+			rest = append(rest, c)
+		}
+		if found == nil {
+			return nil, fmt.Errorf("unknown or already used challenge")
+		}
+		return rest, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !time.Now().Before(found.ExpiresAt) {
+		return nil, fmt.Errorf("challenge expired at %s", found.ExpiresAt.Format(time.RFC3339))
+	}
+	return found, nil
+}
+
+// verifyRelease checks a signed challenge for lifting the lockdown on
+// scope/target and returns who signed it
+func (s *SigningService) verifyRelease(release LockdownRelease, scope, target string) (string, error) {
+	if len(s.releaseKeys) == 0 {
+		return "", fmt.Errorf("no lockdown release keys configured (start the server with --admin-key or --break-glass-key)")
+	}
+
+	c, err := s.consumeChallenge(release.Nonce)
+	if err != nil {
+		return "", err
+	}
+	if c.Scope != scope || c.Target != target {
+		return "", fmt.Errorf("challenge was issued for %s lockdown %q, not %s %q", c.Scope, c.Target, scope, target)
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(release.Signature)
+	if err != nil {
+		return "", fmt.Errorf("invalid signature encoding: %w", err)
+	}
+
+	for _, rk := range s.releaseKeys {
+		// A break-glass answer only counts against the break-glass key and vice versa
+		if rk.method != release.Method {
+			continue
+		}
+		if rk.key.Verify(sig, []byte(c.Message)) == nil {
+			return fmt.Sprintf("%s %s", rk.method, rk.key.Identity()), nil
+		}
+	}
+	return "", fmt.Errorf("signature does not verify against any %s release key", release.Method)
+}
+
+// handleLockdownChallenge issues a release challenge: POST /lockdown/challenge?scope=&target=
+func (s *SigningService) handleLockdownChallenge(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	scope, target := r.URL.Query().Get("scope"), r.URL.Query().Get("target")
+	if scope == "" {
+		scope = ScopeGlobal
+	}
+
+	caller := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		caller = host
+	}
+	caller = callerName(r, caller)
+
+	c, err := s.IssueChallenge(scope, target, caller)
+	if errors.Is(err, errTooManyChallenges) {
+		http.Error(w, fmt.Sprintf("Challenge refused: %v", err), http.StatusTooManyRequests)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to issue challenge: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(c)
+}
+
+// ReleaseLockdown lifts a lockdown: it fetches a challenge, signs its
+// message with sign and submits the signature
+func (c *Client) ReleaseLockdown(req LockdownRequest, method string, sign func(message []byte) ([]byte, error)) error {
+	if req.Scope == "" {
+		req.Scope = ScopeGlobal
+	}
+	params := url.Values{"scope": {req.Scope}, "target": {req.Target}}
+	resp, err := c.client.Post(c.baseURL+"/lockdown/challenge?"+params.Encode(), "application/json", nil)
+	if err != nil {
+		return fmt.Errorf("failed to request challenge: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if err := authError(resp); err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("server returned error: %s", strings.TrimSpace(string(body)))
+	}
+
+	var challenge LockdownChallenge
+	if err := json.NewDecoder(resp.Body).Decode(&challenge); err != nil {
+		return fmt.Errorf("failed to parse challenge: %w", err)
+	}
+
+	// Only sign what we asked for
+	if challenge.Message != challengeMessage(challenge.Nonce, req.Scope, req.Target, challenge.ExpiresAt) {
+		return fmt.Errorf("server sent a challenge for a different lockdown")
+	}
+
+	sig, err := sign([]byte(challenge.Message))
+	if err != nil {
+		return fmt.Errorf("failed to sign challenge: %w", err)
+	}
+
+	body, err := json.Marshal(LockdownRelease{
+		Nonce:     challenge.Nonce,
+		Method:    method,
+		Signature: base64.StdEncoding.EncodeToString(sig),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode release: %w", err)
+	}
+
+	params.Set("mode", "off")
+	if req.By != "" {
+		params.Set("by", req.By)
+	}
+	resp2, err := c.client.Post(c.baseURL+"/lockdown?"+params.Encode(), "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to release lockdown: %w", err)
+	}
+	defer resp2.Body.Close()
+
+	if resp2.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp2.Body)
+		return fmt.Errorf("server returned error: %s", strings.TrimSpace(string(body)))
+	}
+
+	return nil
+}
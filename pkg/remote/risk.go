@@ -0,0 +1,286 @@
+package remote
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sulakshanakarunarathne/terrasign/pkg/planfile"
+)
+
+// Risk levels, lowest first
+var riskLevels = []string{"low", "medium", "high", "critical"}
+
+// Lowest score of each risk level above low
+const (
+	riskMedium   = 20
+	riskHigh     = 50
+	riskCritical = 80
+	maxRiskScore = 100
+)
+
+// Points each change adds to a plan's risk score
+const (
+	deletePoints         = 10 // Destroying an object
+	replacePoints        = 8  // Destroying and recreating an object
+	iamPoints            = 6  // Any change to IAM resources
+	networkPoints        = 4  // Any change to networking resources
+	statefulDeletePoints = 45 // Extra for destroying or replacing an object that holds data; alone makes a plan high risk
+	statefulUpdatePoints = 3  // Extra for updating an object that holds data
+	sizePointsPer        = 10 // One point for every this many resources touched
+)
+
+// maxFactorResources is how many addresses a risk factor lists
+const maxFactorResources = 10
+
+// statefulTypes are resource type globs of objects that hold data, which a
+// destroy or replacement loses
+var statefulTypes = []string{
+	"aws_db_instance", "aws_rds_cluster*", "aws_dynamodb_table", "aws_s3_bucket",
+	"aws_ebs_volume", "aws_efs_file_system", "aws_elasticache_*", "aws_redshift_cluster",
+	"aws_docdb_cluster*", "aws_neptune_cluster*", "aws_opensearch_domain", "aws_elasticsearch_domain",
+	"aws_kinesis_stream", "aws_msk_cluster", "aws_kms_key", "aws_secretsmanager_secret",
+	"google_sql_database*", "google_storage_bucket", "google_bigtable_*", "google_spanner_*",
+	"google_bigquery_dataset", "google_bigquery_table", "google_compute_disk", "google_kms_crypto_key",
+	"azurerm_mssql_*", "azurerm_postgresql_*", "azurerm_mysql_*", "azurerm_cosmosdb_*",
+	"azurerm_storage_account", "azurerm_managed_disk", "azurerm_key_vault",
+}
+
+// iamTypes are resource type globs of identity and access resources
+var iamTypes = []string{
+	"*_iam_*", "aws_kms_key_policy", "aws_s3_bucket_policy", "aws_organizations_policy*",
+	"azurerm_role_*", "azuread_*",
+}
+
+// networkTypes are resource type globs of networking resources
+var networkTypes = []string{
+	"aws_security_group*", "aws_vpc*", "aws_subnet", "aws_route*", "aws_network_acl*",
+	"aws_internet_gateway", "aws_nat_gateway", "aws_eip", "aws_lb*", "aws_alb*", "aws_ec2_transit_gateway*",
+	"google_compute_firewall*", "google_compute_network*", "google_compute_subnetwork",
+	"google_compute_router*", "google_compute_route",
+	"azurerm_network_*", "azurerm_virtual_network*", "azurerm_subnet*", "azurerm_firewall*", "azurerm_route*",
+}
+
+// RiskAssessment is the blast radius of a submitted plan: a score from 0 to
+// 100, its level and the factors that contributed to it
+type RiskAssessment struct {
+	Score   int          `json:"score"`
+	Level   string       `json:"level"` // low, medium, high or critical
+	Factors []RiskFactor `json:"factors,omitempty"`
+}
+
+// RiskFactor is one kind of change that raised a plan's risk score
+type RiskFactor struct {
+	Factor    string   `json:"factor"` // delete, replace, iam, network, stateful, size or unreadable
+	Points    int      `json:"points"`
+	Count     int      `json:"count"`
+	Resources []string `json:"resources,omitempty"` // First addresses involved
+	Detail    string   `json:"detail,omitempty"`
+}
+
+// String summarizes an assessment for listings, e.g. "72 (high)"
+func (r *RiskAssessment) String() string {
+	if r == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%d (%s)", r.Score, r.Level)
+}
+
+// Summary describes the contributing factors, e.g. "2 delete, 1 iam"
+func (r *RiskAssessment) Summary() string {
+	if r == nil {
+		return "-"
+	}
+	if len(r.Factors) == 0 {
+		return "no risky changes"
+	}
+	var parts []string
+	for _, f := range r.Factors {
+		if f.Detail != "" {
+			parts = append(parts, f.Factor+": "+f.Detail)
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%d %s", f.Count, f.Factor))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// AssessPlan scores a saved plan or its `terraform show -json` output. A
+// plan that cannot be read is scored as critical, since reviewers cannot
+// see what it changes either.
+func AssessPlan(data []byte) *RiskAssessment {
+	var plan *planfile.Plan
+	var err error
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		plan, _, err = planfile.ReadJSON(data)
+	} else {
+		plan, err = planfile.Parse(data)
+	}
+	if err != nil {
+		return &RiskAssessment{
+			Score: maxRiskScore,
+			Level: riskLevel(maxRiskScore),
+			Factors: []RiskFactor{{
+				Factor: "unreadable",
+				Points: maxRiskScore,
+				Detail: err.Error(),
+			}},
+		}
+	}
+	return assessChanges(plan.ResourceChanges)
+}
+
+// assessChanges scores the resource changes of a plan
+func assessChanges(changes []planfile.ResourceChange) *RiskAssessment {
+	factors := make(map[string]*RiskFactor)
+	add := func(name, address string, points int) {
+		f, ok := factors[name]
+		if !ok {
+			f = &RiskFactor{Factor: name}
+			factors[name] = f
+		}
+		f.Points += points
+		f.Count++
+		if len(f.Resources) < maxFactorResources {
+			f.Resources = append(f.Resources, address)
+		}
+	}
+
+	touched := 0
+	for _, rc := range changes {
+		if rc.Mode == "data" {
+			continue
+		}
+		var destroys bool
+		switch strings.Join(rc.Change.Actions, ",") {
+		case "delete":
+			add("delete", rc.Address, deletePoints)
+			destroys = true
+		case "delete,create", "create,delete":
+			add("replace", rc.Address, replacePoints)
+			destroys = true
+		case "create", "update", "forget":
+		default:
+			continue // no-op and read
+		}
+		touched++
+
+		if matchesType(rc.Type, iamTypes) {
+			add("iam", rc.Address, iamPoints)
+		}
+		if matchesType(rc.Type, networkTypes) {
+			add("network", rc.Address, networkPoints)
+		}
+		if matchesType(rc.Type, statefulTypes) {
+			switch {
+			case destroys:
+				add("stateful", rc.Address, statefulDeletePoints)
+			case strings.Join(rc.Change.Actions, ",") == "update":
+				add("stateful", rc.Address, statefulUpdatePoints)
+			}
+		}
+	}
+	if points := touched / sizePointsPer; points > 0 {
+		factors["size"] = &RiskFactor{Factor: "size", Points: points, Count: touched}
+	}
+
+	assessment := &RiskAssessment{}
+	for _, f := range factors {
+		assessment.Score += f.Points
+		assessment.Factors = append(assessment.Factors, *f)
+	}
+	if assessment.Score > maxRiskScore {
+		assessment.Score = maxRiskScore
+	}
+	assessment.Level = riskLevel(assessment.Score)
+
+	// Largest contributions first
+	sort.Slice(assessment.Factors, func(i, j int) bool {
+		a, b := assessment.Factors[i], assessment.Factors[j]
+		if a.Points != b.Points {
+			return a.Points > b.Points
+		}
+		return a.Factor < b.Factor
+	})
+	return assessment
+}
+
+// riskLevel returns the level of a score
+func riskLevel(score int) string {
+	switch {
+	case score >= riskCritical:
+		return "critical"
+	case score >= riskHigh:
+		return "high"
+	case score >= riskMedium:
+		return "medium"
+	}
+	return "low"
+}
+
+// matchesType reports whether a resource type matches any of the globs
+func matchesType(resourceType string, globs []string) bool {
+	for _, glob := range globs {
+		if ok, _ := path.Match(glob, resourceType); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// RiskQuorumConfig raises the approval quorum for risky plans: the number
+// of distinct reviewer keys a plan at or above each level needs
+type RiskQuorumConfig map[string]int
+
+// Required returns the approvals a plan with this assessment needs at least;
+// 0 when no configured level applies
+func (q RiskQuorumConfig) Required(risk *RiskAssessment) int {
+	if risk == nil {
+		return 0
+	}
+	required := 0
+	for _, level := range riskLevels {
+		if n := q[level]; n > required {
+			required = n
+		}
+		if level == risk.Level {
+			break
+		}
+	}
+	return required
+}
+
+// ParseRiskQuorum parses a risk quorum spec like "high=2,critical=3"
+func ParseRiskQuorum(spec string) (RiskQuorumConfig, error) {
+	q := RiskQuorumConfig{}
+	if spec == "" {
+		return q, nil
+	}
+
+	for _, part := range strings.Split(spec, ",") {
+		level, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok || !validRiskLevel(level) {
+			return q, fmt.Errorf("invalid risk quorum %q: use <level>=<approvals> with level low, medium, high or critical", part)
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return q, fmt.Errorf("invalid risk quorum %q: need a positive number of approvals", part)
+		}
+		q[level] = n
+	}
+
+	return q, nil
+}
+
+// validRiskLevel reports whether level names a risk level
+func validRiskLevel(level string) bool {
+	for _, l := range riskLevels {
+		if l == level {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,693 @@
+package remote
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// S3Config configures the S3-compatible object store backend
+type S3Config struct {
+	Endpoint  string // e.g. https://s3.us-east-1.amazonaws.com or http://localhost:9000
+	Region    string
+	Bucket    string
+	Prefix    string // Key prefix, without leading or trailing slash
+	AccessKey string
+	SecretKey string
+
+	SessionToken string // Temporary credentials (STS, instance or task roles)
+}
+
+// S3ConfigFromEnv reads credentials, region and endpoint from the standard
+// AWS environment variables
+func S3ConfigFromEnv() S3Config {
+	config := S3Config{
+		Endpoint:  os.Getenv("AWS_ENDPOINT_URL_S3"),
+		Region:    os.Getenv("AWS_REGION"),
+		AccessKey: os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+
+		SessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if config.Endpoint == "" {
+		config.Endpoint = os.Getenv("AWS_ENDPOINT_URL")
+	}
+	if config.Region == "" {
+		config.Region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	return config
+}
+
+// S3Store is a Store in an S3-compatible bucket (AWS S3, MinIO, ...), with
+// the same <id>/<file> layout as the filesystem store. Requests use
+// path-style addressing and AWS Signature Version 4.
+type S3Store struct {
+	config S3Config
+	client *http.Client
+}
+
+// NewS3Store creates an S3 store
+func NewS3Store(config S3Config) (*S3Store, error) {
+	if config.Bucket == "" {
+		return nil, fmt.Errorf("s3 storage requires a bucket")
+	}
+	if config.AccessKey == "" || config.SecretKey == "" {
+		return nil, fmt.Errorf("s3 storage requires AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+	if config.Region == "" {
+		config.Region = "us-east-1"
+	}
+	if config.Endpoint == "" {
+		config.Endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", config.Region)
+	}
+	config.Endpoint = strings.TrimSuffix(config.Endpoint, "/")
+	config.Prefix = strings.Trim(config.Prefix, "/")
+
+	return &S3Store{config: config, client: &http.Client{Timeout: 60 * time.Second}}, nil
+}
+
+// key returns the object key for one of a submission's files
+func (s *S3Store) key(id, name string) string {
+	if s.config.Prefix == "" {
+		return id + "/" + name
+	}
+	return s.config.Prefix + "/" + id + "/" + name
+}
+
+// StorePlan saves a plan file and creates a submission record
+func (s *S3Store) StorePlan(planData io.Reader, maxSize int64, submitter string) (*PlanSubmission, error) {
+	data, planHash, err := readPlan(planData, maxSize)
+	if err != nil {
+		return nil, err
+	}
+
+	submission := newSubmission(uuid.New().String(), planHash, submitter)
+	if err := s.putObject(s.key(submission.ID, planName), data); err != nil {
+		return nil, fmt.Errorf("failed to write plan: %w", err)
+	}
+	if err := s.UpdateSubmission(submission); err != nil {
+		return nil, err
+	}
+
+	return submission, nil
+}
+
+// GetSubmission retrieves a submission by ID
+func (s *S3Store) GetSubmission(id string) (*PlanSubmission, error) {
+	if err := checkID(id); err != nil {
+		return nil, err
+	}
+
+	data, err := s.getObject(s.key(id, metadataName))
+	if err != nil {
+		return nil, fmt.Errorf("submission %s: %w", id, err)
+	}
+
+	var submission PlanSubmission
+	if err := json.Unmarshal(data, &submission); err != nil {
+		return nil, fmt.Errorf("failed to parse metadata: %w", err)
+	}
+
+	return &submission, nil
+}
+
+// UpdateSubmission updates submission metadata
+func (s *S3Store) UpdateSubmission(submission *PlanSubmission) error {
+	data, err := json.MarshalIndent(submission, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+	if err := s.putObject(s.key(submission.ID, metadataName), data); err != nil {
+		return fmt.Errorf("failed to write metadata: %w", err)
+	}
+	return nil
+}
+
+// ModifySubmission applies modify to a submission's metadata and writes it
+// back with If-Match on the ETag it read, so an update by another instance
+// in between fails the write (412) and modify runs again on the new version
+func (s *S3Store) ModifySubmission(id string, modify func(*PlanSubmission) error) (*PlanSubmission, error) {
+	if err := checkID(id); err != nil {
+		return nil, err
+	}
+	key := s.key(id, metadataName)
+
+	for attempt := 0; attempt < maxModifyAttempts; attempt++ {
+		data, etag, err := s.getObjectVersion(key)
+		if err != nil {
+			return nil, fmt.Errorf("submission %s: %w", id, err)
+		}
+		if etag == "" {
+			return nil, fmt.Errorf("s3 returned no ETag for %s", key)
+		}
+
+		var submission PlanSubmission
+		if err := json.Unmarshal(data, &submission); err != nil {
+			return nil, fmt.Errorf("failed to parse metadata: %w", err)
+		}
+		if err := modify(&submission); err != nil {
+			return nil, err
+		}
+
+		updated, err := json.MarshalIndent(&submission, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal metadata: %w", err)
+		}
+		resp, err := s.doWithHeaders(http.MethodPut, key, nil, updated, map[string]string{"If-Match": etag})
+		if err != nil {
+			return nil, fmt.Errorf("failed to write metadata: %w", err)
+		}
+		err = s3Error(resp)
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusPreconditionFailed || resp.StatusCode == http.StatusConflict {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to write metadata: %w", err)
+		}
+		return &submission, nil
+	}
+
+	return nil, fmt.Errorf("submission %s: %w (gave up after %d attempts)", id, ErrConflict, maxModifyAttempts)
+}
+
+// DeleteSubmission removes a submission and its objects
+func (s *S3Store) DeleteSubmission(id string) error {
+	if err := checkID(id); err != nil {
+		return err
+	}
+
+	keys, err := s.listKeys(s.key(id, ""))
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := s.deleteObject(key); err != nil {
+			return fmt.Errorf("failed to delete %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// ListSubmissions returns every submission
+func (s *S3Store) ListSubmissions() ([]*PlanSubmission, error) {
+	prefix := ""
+	if s.config.Prefix != "" {
+		prefix = s.config.Prefix + "/"
+	}
+	keys, err := s.listKeys(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var submissions []*PlanSubmission
+	for _, key := range keys {
+		if !strings.HasSuffix(key, "/"+metadataName) {
+			continue
+		}
+		parts := strings.Split(key, "/")
+		submission, err := s.GetSubmission(parts[len(parts)-2])
+		if err != nil {
+			continue
+		}
+		submissions = append(submissions, submission)
+	}
+
+	return submissions, nil
+}
+
+// ListPending returns all pending submissions
+func (s *S3Store) ListPending() ([]*PlanSubmission, error) {
+	submissions, err := s.ListSubmissions()
+	if err != nil {
+		return nil, err
+	}
+	return filterPending(submissions), nil
+}
+
+// OpenPlan opens the stored plan
+func (s *S3Store) OpenPlan(id string) (io.ReadCloser, error) {
+	return s.open(id, planName)
+}
+
+// SavePlanJSON stores the JSON rendering submitted with the plan
+func (s *S3Store) SavePlanJSON(id string, data []byte) error {
+	if err := checkID(id); err != nil {
+		return err
+	}
+	if err := s.putObject(s.key(id, planJSONName), data); err != nil {
+		return fmt.Errorf("failed to write plan JSON: %w", err)
+	}
+	return nil
+}
+
+// OpenPlanJSON opens the stored plan JSON
+func (s *S3Store) OpenPlanJSON(id string) (io.ReadCloser, error) {
+	return s.open(id, planJSONName)
+}
+
+// SaveSignature stores the plan signature for a submission
+func (s *S3Store) SaveSignature(id string, sig []byte) error {
+	if err := checkID(id); err != nil {
+		return err
+	}
+	if err := s.putObject(s.key(id, signatureName), sig); err != nil {
+		return fmt.Errorf("failed to write signature: %w", err)
+	}
+	return nil
+}
+
+// OpenSignature opens the stored signature
+func (s *S3Store) OpenSignature(id string) (io.ReadCloser, error) {
+	return s.open(id, signatureName)
+}
+
+// SaveAttestation stores an attestation for a submission
+func (s *S3Store) SaveAttestation(id, kind string, data []byte) error {
+	name, err := attestationName(kind)
+	if err != nil {
+		return err
+	}
+	if err := checkID(id); err != nil {
+		return err
+	}
+	if err := s.putObject(s.key(id, name), data); err != nil {
+		return fmt.Errorf("failed to write attestation: %w", err)
+	}
+	return nil
+}
+
+// OpenAttestation opens a stored attestation
+func (s *S3Store) OpenAttestation(id, kind string) (io.ReadCloser, error) {
+	name, err := attestationName(kind)
+	if err != nil {
+		return nil, err
+	}
+	return s.open(id, name)
+}
+
+// stateKey returns the object key of a state document
+func (s *S3Store) stateKey(name string) (string, error) {
+	file, err := stateFile(name)
+	if err != nil {
+		return "", err
+	}
+	if s.config.Prefix == "" {
+		return file, nil
+	}
+	return s.config.Prefix + "/" + file, nil
+}
+
+// LoadState reads a service-wide state document
+func (s *S3Store) LoadState(name string) ([]byte, error) {
+	key, err := s.stateKey(name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := s.getObject(key)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", key, err)
+	}
+	return data, nil
+}
+
+// SaveState writes a service-wide state document
+func (s *S3Store) SaveState(name string, data []byte) error {
+	key, err := s.stateKey(name)
+	if err != nil {
+		return err
+	}
+	if err := s.putObject(key, data); err != nil {
+		return fmt.Errorf("failed to write %s: %w", key, err)
+	}
+	return nil
+}
+
+// ModifyState applies modify to a state document and writes it back
+// conditionally: If-Match on the ETag read, or If-None-Match when the
+// document did not exist, so a concurrent writer makes modify run again
+func (s *S3Store) ModifyState(name string, modify func(data []byte) ([]byte, error)) error {
+	key, err := s.stateKey(name)
+	if err != nil {
+		return err
+	}
+
+	for attempt := 0; attempt < maxModifyAttempts; attempt++ {
+		data, etag, err := s.getObjectVersion(key)
+		condition := map[string]string{"If-Match": etag}
+		if errors.Is(err, ErrNotFound) {
+			data, condition = nil, map[string]string{"If-None-Match": "*"}
+		} else if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		} else if etag == "" {
+			return fmt.Errorf("s3 returned no ETag for %s", key)
+		}
+
+		updated, err := modify(data)
+		if err != nil {
+			return err
+		}
+		resp, err := s.doWithHeaders(http.MethodPut, key, nil, updated, condition)
+		if err != nil {
+			return fmt.Errorf("failed to write %s: %w", key, err)
+		}
+		err = s3Error(resp)
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusPreconditionFailed || resp.StatusCode == http.StatusConflict {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to write %s: %w", key, err)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("%s changed concurrently (gave up after %d attempts)", key, maxModifyAttempts)
+}
+
+// logKey returns the object key of a log record, or of the log's prefix
+// when name is empty
+func (s *S3Store) logKey(log, name string) string {
+	if s.config.Prefix == "" {
+		return log + "/" + name
+	}
+	return s.config.Prefix + "/" + log + "/" + name
+}
+
+// AppendRecord writes a log record. The conditional put (If-None-Match: *)
+// makes the append fail if another instance already wrote the seq.
+func (s *S3Store) AppendRecord(log string, seq uint64, data []byte) error {
+	if err := checkLog(log); err != nil {
+		return err
+	}
+
+	resp, err := s.doWithHeaders(http.MethodPut, s.logKey(log, recordName(seq)), nil, data, map[string]string{"If-None-Match": "*"})
+	if err != nil {
+		return fmt.Errorf("failed to write %s record: %w", log, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusPreconditionFailed || resp.StatusCode == http.StatusConflict {
+		return fmt.Errorf("%s record %d %w", log, seq, ErrExists)
+	}
+	if err := s3Error(resp); err != nil {
+		return fmt.Errorf("failed to write %s record: %w", log, err)
+	}
+	return nil
+}
+
+// ReadRecords reads log records after seq in order
+func (s *S3Store) ReadRecords(log string, after uint64, limit int) ([][]byte, error) {
+	if err := checkLog(log); err != nil {
+		return nil, err
+	}
+
+	keys, err := s.listKeysAfter(s.logKey(log, ""), s.logKey(log, recordName(after)), limit)
+	if err != nil {
+		return nil, err
+	}
+
+	var records [][]byte
+	for _, key := range keys {
+		data, err := s.getObject(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", key, err)
+		}
+		records = append(records, data)
+	}
+	return records, nil
+}
+
+// Close implements Store
+func (s *S3Store) Close() error {
+	return nil
+}
+
+// open fetches one of a submission's objects
+func (s *S3Store) open(id, name string) (io.ReadCloser, error) {
+	if err := checkID(id); err != nil {
+		return nil, err
+	}
+	data, err := s.getObject(s.key(id, name))
+	if err != nil {
+		return nil, fmt.Errorf("%s for %s: %w", name, id, err)
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+// putObject uploads an object
+func (s *S3Store) putObject(key string, data []byte) error {
+	resp, err := s.do(http.MethodPut, key, nil, data)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return s3Error(resp)
+}
+
+// getObject downloads an object; a missing object wraps ErrNotFound
+func (s *S3Store) getObject(key string) ([]byte, error) {
+	data, _, err := s.getObjectVersion(key)
+	return data, err
+}
+
+// getObjectVersion downloads an object along with its ETag
+func (s *S3Store) getObjectVersion(key string) ([]byte, string, error) {
+	resp, err := s.do(http.MethodGet, key, nil, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if err := s3Error(resp); err != nil {
+		return nil, "", err
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, resp.Header.Get("ETag"), nil
+}
+
+// deleteObject removes an object
+func (s *S3Store) deleteObject(key string) error {
+	resp, err := s.do(http.MethodDelete, key, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return s3Error(resp)
+}
+
+// listBucketResult is the ListObjectsV2 response
+type listBucketResult struct {
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// listKeys lists every object key under prefix
+func (s *S3Store) listKeys(prefix string) ([]string, error) {
+	return s.listKeysAfter(prefix, "", -1)
+}
+
+// listKeysAfter lists up to limit object keys under prefix that sort after
+// startAfter (limit < 0 for all)
+func (s *S3Store) listKeysAfter(prefix, startAfter string, limit int) ([]string, error) {
+	var keys []string
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if startAfter != "" {
+			query.Set("start-after", startAfter)
+		}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		resp, err := s.do(http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+		var result listBucketResult
+		err = s3Error(resp)
+		if err == nil {
+			err = xml.NewDecoder(resp.Body).Decode(&result)
+		}
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list objects: %w", err)
+		}
+
+		for _, c := range result.Contents {
+			if limit >= 0 && len(keys) >= limit {
+				return keys, nil
+			}
+			keys = append(keys, c.Key)
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return keys, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// s3Error converts an unsuccessful response into an error
+func s3Error(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	return fmt.Errorf("s3 returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+}
+
+// do sends a signed request for key (or the bucket itself when key is empty)
+func (s *S3Store) do(method, key string, query url.Values, body []byte) (*http.Response, error) {
+	return s.doWithHeaders(method, key, query, body, nil)
+}
+
+// doWithHeaders is do with extra (unsigned) request headers
+func (s *S3Store) doWithHeaders(method, key string, query url.Values, body []byte, headers map[string]string) (*http.Response, error) {
+	path := "/" + s.config.Bucket
+	if key != "" {
+		path += "/" + key
+	}
+
+	u, err := url.Parse(s.config.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid s3 endpoint: %w", err)
+	}
+	u.Path = path
+	u.RawPath = s3EscapePath(path)
+	u.RawQuery = s3CanonicalQuery(query)
+
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	s.sign(req, body, time.Now().UTC())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("s3 request failed: %w", err)
+	}
+	return resp, nil
+}
+
+// sign adds AWS Signature Version 4 headers to req
+func (s *S3Store) sign(req *http.Request, body []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalHeaders := "host:" + req.URL.Host + "\n" +
+		"x-amz-content-sha256:" + payloadHash + "\n" +
+		"x-amz-date:" + amzDate + "\n"
+	if s.config.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.config.SessionToken)
+		signedHeaders += ";x-amz-security-token"
+		canonicalHeaders += "x-amz-security-token:" + s.config.SessionToken + "\n"
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.config.Region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.config.SecretKey), date)
+	key = hmacSHA256(key, s.config.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.config.AccessKey, scope, signedHeaders, signature))
+}
+
+// s3EscapePath URI-encodes each path segment as SigV4 requires
+func s3EscapePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = s3Escape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// s3CanonicalQuery encodes query parameters sorted by name, as SigV4 requires
+func s3CanonicalQuery(query url.Values) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var parts []string
+	for _, name := range names {
+		for _, value := range query[name] {
+			parts = append(parts, s3Escape(name)+"="+s3Escape(value))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// s3Escape percent-encodes everything except unreserved characters
+func s3Escape(s string) string {
+	var b strings.Builder
+	for _, c := range []byte(s) {
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+var _ Store = (*S3Store)(nil)
@@ -0,0 +1,231 @@
+package remote
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeS3 is an in-memory S3 endpoint with the subset of the API S3Store
+// uses: object GET/PUT/DELETE with If-Match and If-None-Match, and
+// ListObjectsV2 with prefix, start-after and continuation tokens
+type fakeS3 struct {
+	t *testing.T
+
+	mu       sync.Mutex
+	objects  map[string]fakeObject
+	versions int
+	pageSize int    // Keys per list page, small to exercise pagination
+	token    string // Session token every request must carry, if set
+}
+
+type fakeObject struct {
+	data []byte
+	etag string
+}
+
+// newFakeS3 starts a fake S3 server and returns an S3Store using it
+func newFakeS3(t *testing.T, sessionToken string) (*fakeS3, *S3Store) {
+	fake := &fakeS3{t: t, objects: map[string]fakeObject{}, pageSize: 2, token: sessionToken}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	store, err := NewS3Store(S3Config{
+		Endpoint:     server.URL,
+		Bucket:       "plans",
+		Prefix:       "terrasign",
+		AccessKey:    "AKIDEXAMPLE",
+		SecretKey:    "secret",
+		SessionToken: sessionToken,
+	})
+	if err != nil {
+		t.Fatalf("NewS3Store: %v", err)
+	}
+	return fake, store
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := f.checkAuth(r); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != "plans" {
+		http.Error(w, "NoSuchBucket", http.StatusNotFound)
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if key == "" {
+		if r.Method != http.MethodGet || r.URL.Query().Get("list-type") != "2" {
+			http.Error(w, "unsupported bucket request", http.StatusBadRequest)
+			return
+		}
+		f.list(w, r)
+		return
+	}
+
+	object, exists := f.objects[key]
+	switch r.Method {
+	case http.MethodGet:
+		if !exists {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("ETag", object.etag)
+		w.Write(object.data)
+	case http.MethodPut:
+		if r.Header.Get("If-None-Match") == "*" && exists {
+			http.Error(w, "PreconditionFailed", http.StatusPreconditionFailed)
+			return
+		}
+		if match := r.Header.Get("If-Match"); match != "" && (!exists || match != object.etag) {
+			http.Error(w, "PreconditionFailed", http.StatusPreconditionFailed)
+			return
+		}
+		f.versions++
+		f.objects[key] = fakeObject{data: body, etag: fmt.Sprintf("%q", fmt.Sprintf("v%d", f.versions))}
+		w.WriteHeader(http.StatusOK)
+	case http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "unsupported method", http.StatusMethodNotAllowed)
+	}
+}
+
+// checkAuth requires a SigV4 authorization header that signs the dated
+// payload hash and, with a session token, the token
+func (f *fakeS3) checkAuth(r *http.Request) error {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/") {
+		return fmt.Errorf("missing or malformed authorization %q", auth)
+	}
+	if r.Header.Get("X-Amz-Date") == "" || r.Header.Get("X-Amz-Content-Sha256") == "" {
+		return fmt.Errorf("missing signed date or payload hash")
+	}
+	if f.token == "" {
+		return nil
+	}
+	if r.Header.Get("X-Amz-Security-Token") != f.token {
+		return fmt.Errorf("missing session token")
+	}
+	if !strings.Contains(auth, "SignedHeaders=host;x-amz-content-sha256;x-amz-date;x-amz-security-token,") {
+		return fmt.Errorf("session token is not signed: %s", auth)
+	}
+	return nil
+}
+
+// list serves ListObjectsV2; the continuation token is the last key returned
+func (f *fakeS3) list(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	after := query.Get("start-after")
+	if token := query.Get("continuation-token"); token != "" {
+		after = token
+	}
+
+	var keys []string
+	for key := range f.objects {
+		if strings.HasPrefix(key, query.Get("prefix")) && key > after {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var result listBucketResult
+	if len(keys) > f.pageSize {
+		keys = keys[:f.pageSize]
+		result.IsTruncated = true
+		result.NextContinuationToken = keys[len(keys)-1]
+	}
+	for _, key := range keys {
+		result.Contents = append(result.Contents, struct {
+			Key string `xml:"Key"`
+		}{key})
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	xml.NewEncoder(w).Encode(struct {
+		XMLName xml.Name `xml:"ListBucketResult"`
+		listBucketResult
+	}{listBucketResult: result})
+}
+
+func TestS3StoreModifyRetriesOnConflictingWrite(t *testing.T) {
+	_, store := newFakeS3(t, "")
+	submission, err := store.StorePlan(strings.NewReader("plan"), DefaultMaxPlanSize, "ci")
+	if err != nil {
+		t.Fatalf("StorePlan: %v", err)
+	}
+
+	// Another instance rejects the submission between the read and the write
+	other := *store
+	calls := 0
+	_, err = store.ModifySubmission(submission.ID, func(current *PlanSubmission) error {
+		calls++
+		if calls == 1 {
+			rejected := *current
+			rejected.Status = "rejected"
+			if err := other.UpdateSubmission(&rejected); err != nil {
+				t.Fatalf("UpdateSubmission: %v", err)
+			}
+		}
+		if current.Status != "pending" {
+			return fmt.Errorf("%w: submission is now %s", ErrConflict, current.Status)
+		}
+		current.Status = "approved"
+		return nil
+	})
+	if calls != 2 {
+		t.Errorf("modify ran %d times, want 2", calls)
+	}
+	if err == nil || !strings.Contains(err.Error(), "rejected") {
+		t.Fatalf("ModifySubmission = %v, want a conflict with the rejection", err)
+	}
+
+	stored, err := store.GetSubmission(submission.ID)
+	if err != nil {
+		t.Fatalf("GetSubmission: %v", err)
+	}
+	if stored.Status != "rejected" {
+		t.Errorf("status = %s, want the concurrent rejection kept", stored.Status)
+	}
+}
+
+func TestS3StoreSessionToken(t *testing.T) {
+	_, store := newFakeS3(t, "session-token")
+	if _, err := store.StorePlan(strings.NewReader("plan"), DefaultMaxPlanSize, "ci"); err != nil {
+		t.Fatalf("StorePlan with a session token: %v", err)
+	}
+
+	store.config.SessionToken = ""
+	if _, err := store.ListSubmissions(); err == nil {
+		t.Fatal("request without the session token was accepted")
+	}
+}
+
+func TestS3ConfigFromEnv(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_SESSION_TOKEN", "session-token")
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "eu-west-1")
+
+	config := S3ConfigFromEnv()
+	if config.SessionToken != "session-token" {
+		t.Errorf("SessionToken = %q", config.SessionToken)
+	}
+	if config.Region != "eu-west-1" {
+		t.Errorf("Region = %q, want the AWS_DEFAULT_REGION fallback", config.Region)
+	}
+}
@@ -2,44 +2,203 @@ package remote
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"mime/multipart"
 	"net/http"
-	"os"
+	"strconv"
 	"strings"
 	"time"
+
+	"github.com/sulakshanakarunarathne/terrasign/pkg/policy"
+	"github.com/sulakshanakarunarathne/terrasign/pkg/signer"
+	"github.com/sulakshanakarunarathne/terrasign/pkg/tsa"
+	"github.com/sulakshanakarunarathne/terrasign/pkg/verifier"
 )
 
+// sharedIndexRefresh is how often the submission index is rebuilt when the
+// storage backend is shared between service instances
+const sharedIndexRefresh = 15 * time.Second
+
 // SigningService is the HTTP service for remote plan signing
 type SigningService struct {
-	storage *Storage
-	config  SigningServiceConfig
+	storage        *IndexedStore
+	config         SigningServiceConfig
+	authenticators []Authenticator       // nil when authentication is disabled
+	trustedKeys    []verifier.TrustedKey // Reviewer keys accepted for uploaded signatures
+	lockdown       lockdownState         // Cached lockdown list; the backend holds the state
+	releaseKeys    []releaseKey          // Keys whose signed challenges lift lockdowns
+	auditLog       *auditLog             // Hash-chained record of every action
+	tlog           *transparencyLog      // Merkle log of approval signatures; nil when disabled
+	timestamper    tsa.Timestamper       // Counter-signs approvals; nil when no TSA is configured
+	authority      *tsa.Authority        // Built-in TSA served at /tsa; nil unless configured
+
+	protection *policy.ProtectionConfig // Resources an approval may only destroy with an acknowledgement
 }
 
 // NewSigningService creates a new signing service
 func NewSigningService(config SigningServiceConfig) (*SigningService, error) {
-	storage, err := NewStorage(config.StorageDir)
+	backend, err := OpenStore(config.StorageDir)
+	if err != nil {
+		return nil, err
+	}
+
+	// Other instances may write to a shared bucket; pick up their changes
+	var indexRefresh, lockdownTTL time.Duration
+	if _, shared := backend.(*S3Store); shared {
+		indexRefresh = sharedIndexRefresh
+		lockdownTTL = lockdownCacheTTL
+	}
+	storage, err := NewIndexedStore(backend, indexRefresh)
 	if err != nil {
 		return nil, err
 	}
 
-	return &SigningService{
-		storage: storage,
-		config:  config,
-	}, nil
+	service := &SigningService{
+		storage:  storage,
+		config:   config,
+		lockdown: lockdownState{ttl: lockdownTTL},
+	}
+
+	service.auditLog = &auditLog{store: backend}
+	if config.AuditKey != "" {
+		service.auditLog.signer, err = signer.LoadSigner(config.AuditKey, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load audit key: %w", err)
+		}
+	}
+	if _, err := service.auditLog.currentHead(); err != nil {
+		return nil, fmt.Errorf("%w (inspect it with `terrasign audit verify --storage`; move the damaged log aside to start a new one)", err)
+	}
+
+	if config.TlogKey != "" {
+		tlogSigner, err := signer.LoadSigner(config.TlogKey, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load transparency log key: %w", err)
+		}
+		if service.tlog, err = newTransparencyLog(backend, tlogSigner); err != nil {
+			return nil, err
+		}
+	}
+
+	switch {
+	case config.TSAURL != "" && (config.TSACert != "" || config.TSAKey != ""):
+		return nil, fmt.Errorf("use either an external timestamp authority (--tsa-url) or the built-in one (--tsa-cert and --tsa-key)")
+	case config.TSAURL != "":
+		service.timestamper = tsa.NewClient(config.TSAURL)
+	case config.TSACert != "" || config.TSAKey != "":
+		if config.TSACert == "" || config.TSAKey == "" {
+			return nil, fmt.Errorf("the built-in timestamp authority needs both --tsa-cert and --tsa-key")
+		}
+		if service.authority, err = tsa.LoadAuthority(config.TSACert, config.TSAKey); err != nil {
+			return nil, err
+		}
+		service.timestamper = service.authority
+	}
+
+	if err := service.importLegacyLockdown(); err != nil {
+		return nil, err
+	}
+
+	keyPaths := config.TrustedKeys
+	if config.AdminKey != "" {
+		keyPaths = append([]string{config.AdminKey}, keyPaths...)
+	}
+	service.trustedKeys, err = verifier.LoadTrustedKeys(keyPaths)
+	if err != nil {
+		return nil, err
+	}
+
+	service.releaseKeys, err = loadReleaseKeys(config.AdminKey, config.BreakGlassKey)
+	if err != nil {
+		return nil, err
+	}
+
+	service.protection, err = policy.LoadProtection(config.PolicyDir)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.Auth != nil {
+		service.authenticators, err = buildAuthenticators(config.Auth)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return service, nil
 }
 
 // Start starts the HTTP server
 func (s *SigningService) Start() error {
-	http.HandleFunc("/submit", s.checkLockdown(s.handleSubmit))
-	http.HandleFunc("/status/", s.checkLockdown(s.handleStatus))
-	http.HandleFunc("/download/", s.checkLockdown(s.handleDownload))
-	http.HandleFunc("/list-pending", s.checkLockdown(s.handleListPending))
-	http.HandleFunc("/upload-signature/", s.checkLockdown(s.handleUploadSignature))
-	http.HandleFunc("/lockdown", s.handleLockdown) // No middleware for lockdown handler
+	http.HandleFunc("/submit", s.requireRole(s.checkLockdown(s.handleSubmit), RoleSubmitter))
+	http.HandleFunc("/status/", s.requireRole(s.checkLockdown(s.handleStatus), RoleSubmitter, RoleReviewer, RoleAuditor))
+	http.HandleFunc("/download/", s.requireRole(s.checkLockdown(s.handleDownload), RoleSubmitter, RoleReviewer))
+	http.HandleFunc("/list-pending", s.requireRole(s.checkLockdown(s.handleListPending), RoleReviewer, RoleAuditor))
+	http.HandleFunc("/submissions", s.requireRole(s.checkLockdown(s.handleSubmissions), RoleReviewer, RoleAuditor))
+	http.HandleFunc("/upload-signature/", s.requireRole(s.checkLockdown(s.handleUploadSignature), RoleReviewer))
+	http.HandleFunc("/upload-attestation/", s.requireRole(s.checkLockdown(s.handleUploadAttestation), RoleReviewer))
+	http.HandleFunc("/reject/", s.requireRole(s.checkLockdown(s.handleReject), RoleReviewer))
+	http.HandleFunc("/audit", s.requireRole(s.handleAudit, RoleAuditor, RoleAdmin))
+	http.HandleFunc("/tlog/checkpoint", s.requireRole(s.handleTlogCheckpoint, RoleSubmitter, RoleReviewer, RoleAuditor, RoleAdmin))
+	http.HandleFunc("/tlog/entries/", s.requireRole(s.handleTlogEntry, RoleSubmitter, RoleReviewer, RoleAuditor, RoleAdmin))
+	http.HandleFunc("/tlog/entries", s.requireRole(s.checkLockdown(s.handleTlogAppend), RoleSubmitter, RoleReviewer, RoleAdmin))
+	// RFC 3161 clients do not authenticate; the TSA only signs hashes
+	http.HandleFunc("/tsa", s.handleTimestamp)
+	// No lockdown middleware for the lockdown handlers. Anyone authenticated may
+	// answer a release challenge: the signature is what authorizes the release.
+	http.HandleFunc("/lockdown", s.requireRole(s.handleLockdown, RoleAdmin, RoleAuditor, RoleReviewer, RoleSubmitter))
+	http.HandleFunc("/lockdown/challenge", s.requireRole(s.handleLockdownChallenge, RoleAdmin, RoleAuditor, RoleReviewer, RoleSubmitter))
 
 	addr := fmt.Sprintf(":%d", s.config.Port)
 	fmt.Printf("Starting signing service on %s\n", addr)
-	fmt.Printf("Storage directory: %s\n", s.config.StorageDir)
+	fmt.Printf("Storage: %s\n", s.config.StorageDir)
+
+	if len(s.trustedKeys) == 0 {
+		fmt.Println("[WARN] No trusted reviewer keys configured - signature uploads will be refused (use --admin-key or --trusted-keys)")
+	}
+	for _, key := range s.trustedKeys {
+		fmt.Printf("Trusted reviewer key: %s\n", key.Identity())
+	}
+
+	lockdowns, err := s.lockdowns()
+	if err != nil {
+		return err
+	}
+	for _, l := range lockdowns {
+		fmt.Printf("[WARN] Active %s\n", l)
+	}
+	if len(s.releaseKeys) == 0 {
+		fmt.Println("[WARN] No lockdown release keys configured - lockdowns can only expire (use --admin-key or --break-glass-key)")
+	}
+	if s.tlog != nil {
+		fmt.Printf("Transparency log: %s (%d entries)\n", s.tlog.origin, s.tlog.tree.Size())
+	}
+	switch {
+	case s.authority != nil:
+		fmt.Printf("Timestamp authority: built-in at /tsa (%s)\n", s.authority.Subject())
+	case s.timestamper != nil:
+		fmt.Printf("Timestamp authority: %s\n", s.config.TSAURL)
+	}
+
+	fmt.Printf("Submissions expire after: %s\n", s.config.MaxAge)
+	go s.sweepExpired()
+
+	if s.authenticators == nil {
+		fmt.Println("[WARN] Authentication disabled - any caller can download plans and upload signatures (use --auth-config)")
+	}
+
+	if s.config.Auth != nil && s.config.Auth.MTLS != nil {
+		tlsConfig, err := serverTLSConfig(s.config.Auth.MTLS)
+		if err != nil {
+			return err
+		}
+		server := &http.Server{Addr: addr, TLSConfig: tlsConfig}
+		fmt.Println("TLS enabled (client certificates accepted)")
+		return server.ListenAndServeTLS(s.config.Auth.MTLS.CertFile, s.config.Auth.MTLS.KeyFile)
+	}
+
 	return http.ListenAndServe(addr, nil)
 }
 
@@ -58,19 +217,165 @@ func (s *SigningService) handleSubmit(w http.ResponseWriter, r *http.Request) {
 	if submitter == "" {
 		submitter = "unknown"
 	}
+	// An authenticated caller cannot claim to be someone else
+	submitter = callerName(r, submitter)
+
+	// The declared environment is checked against the plan once it is stored
+	declared := r.Header.Get(EnvironmentHeader)
+	if declared == "" {
+		declared = r.URL.Query().Get("environment")
+	}
+
+	// The body is the plan, or a multipart form that also carries the plan
+	// JSON; each is bounded again as it is read
+	maxSize := s.config.MaxPlanSize
+	if maxSize <= 0 {
+		maxSize = DefaultMaxPlanSize
+	}
+	multipartBody := strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/")
+	maxBody := maxSize
+	if multipartBody {
+		maxBody += maxPlanJSONSize + 1<<20
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxBody)
+
+	// A plan with its JSON arrives as a multipart form, the plan part first
+	body := io.Reader(r.Body)
+	var mr *multipart.Reader
+	if multipartBody {
+		var err error
+		if mr, err = r.MultipartReader(); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid submission: %v", err), http.StatusBadRequest)
+			return
+		}
+		part, err := mr.NextPart()
+		if err != nil || part.FormName() != planPart {
+			http.Error(w, fmt.Sprintf("Invalid submission: the first part must be %q", planPart), http.StatusBadRequest)
+			return
+		}
+		body = part
+	}
 
 	// Store the plan
-	submission, err := s.storage.StorePlan(r.Body, submitter)
+	submission, err := s.storage.StorePlan(body, maxSize, submitter)
+	var bodyTooLarge *http.MaxBytesError
+	if errors.Is(err, ErrPlanTooLarge) || errors.As(err, &bodyTooLarge) {
+		http.Error(w, fmt.Sprintf("Plan too large: the maximum plan size is %d bytes", maxSize), http.StatusRequestEntityTooLarge)
+		return
+	}
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Failed to store plan: %v", err), http.StatusInternalServerError)
 		return
 	}
 
+	// The client may send the digest it computed; a mismatch means the plan
+	// was altered in transit
+	if want := r.Header.Get(PlanHashHeader); want != "" && !strings.EqualFold(want, submission.PlanHash) {
+		s.storage.DeleteSubmission(submission.ID)
+		http.Error(w, fmt.Sprintf("Plan hash mismatch: client sent %s, server received %s", want, submission.PlanHash), http.StatusBadRequest)
+		return
+	}
+
+	// Plan JSON is only accepted as the rendering of the submitted plan
+	if mr != nil {
+		if err := s.storePlanJSON(submission, mr); err != nil {
+			s.storage.DeleteSubmission(submission.ID)
+			http.Error(w, fmt.Sprintf("Plan JSON rejected: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+	if want := r.Header.Get(PlanJSONHashHeader); want != "" && !strings.EqualFold(want, submission.PlanJSONHash) {
+		s.storage.DeleteSubmission(submission.ID)
+		http.Error(w, fmt.Sprintf("Plan JSON hash mismatch: client sent %s, server received %q", want, submission.PlanJSONHash), http.StatusBadRequest)
+		return
+	}
+
+	// Score the blast radius; risky plans may need more reviewers
+	planData, err := s.loadPlan(submission.ID)
+	if err != nil {
+		s.storage.DeleteSubmission(submission.ID)
+		http.Error(w, fmt.Sprintf("Failed to store plan: %v", err), http.StatusInternalServerError)
+		return
+	}
+	submission.Risk = AssessPlan(planData)
+
+	// The environment selects the approval quorum and expiry. A submitter
+	// could declare any environment, so it comes from the plan's workspace
+	// or the caller's credential; when neither establishes it, the strictest
+	// quorum and shortest expiry apply.
+	environment, source, err := submissionEnvironment(planData, PrincipalFromContext(r.Context()), declared)
+	if err != nil {
+		s.storage.DeleteSubmission(submission.ID)
+		http.Error(w, fmt.Sprintf("Environment rejected: %v", err), http.StatusForbidden)
+		return
+	}
+	submission.Environment = environment
+	submission.EnvironmentSource = source
+
+	// Workspace lockdowns apply to the established environment; while one
+	// is active, a plan whose environment is unknown is refused too
+	if l, err := s.workspaceLockdown(environment); err != nil || l != nil {
+		s.storage.DeleteSubmission(submission.ID)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Lockdown state unavailable: %v", err), http.StatusServiceUnavailable)
+			return
+		}
+		http.Error(w, fmt.Sprintf("EMERGENCY LOCKDOWN ACTIVE - REJECTING REQUEST (%s)", l), http.StatusServiceUnavailable)
+		return
+	}
+	maxAge := s.config.MaxAge.For(environment)
+	required := s.config.Quorum.Required(environment)
+	if source == EnvironmentUnverified {
+		maxAge = s.config.MaxAge.Shortest()
+		required = s.config.Quorum.Strictest()
+	}
+	if n := s.config.RiskQuorum.Required(submission.Risk); n > required {
+		required = n
+	}
+	expiresAt := submission.CreatedAt.Add(maxAge)
+
+	// Until the quorum is written the submission refuses approvals, so
+	// only these fields are set; a write that fails leaves no submission
+	// behind that could never be approved
+	updated, err := s.storage.ModifySubmission(submission.ID, func(current *PlanSubmission) error {
+		current.Risk = submission.Risk
+		current.Environment = environment
+		current.EnvironmentSource = source
+		current.RequiredApprovals = required
+		current.ExpiresAt = &expiresAt
+		return nil
+	})
+	if err != nil {
+		s.storage.DeleteSubmission(submission.ID)
+		http.Error(w, fmt.Sprintf("Failed to store plan: %v", err), http.StatusInternalServerError)
+		return
+	}
+	submission = updated
+	details := map[string]string{
+		"plan_hash":   submission.PlanHash,
+		"environment": environment,
+		"env_source":  source,
+		"expires_at":  expiresAt.UTC().Format(time.RFC3339),
+		"risk":        submission.Risk.String(),
+		"approvals":   strconv.Itoa(submission.RequiredApprovals),
+	}
+	if submission.PlanJSONHash != "" {
+		details["plan_json_hash"] = submission.PlanJSONHash
+	}
+	if declared != "" && declared != environment {
+		details["declared_environment"] = declared
+	}
+	s.audit(r, AuditSubmit, submitter, submission.ID, details)
+
 	// Return submission ID
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{
-		"id":     submission.ID,
-		"status": submission.Status,
+		"id":             submission.ID,
+		"status":         submission.Status,
+		"plan_hash":      submission.PlanHash,
+		"plan_json_hash": submission.PlanJSONHash,
+		"expires_at":     expiresAt.UTC().Format(time.RFC3339),
+		"risk":           submission.Risk.String(),
 	})
 }
 
@@ -87,6 +392,7 @@ func (s *SigningService) handleStatus(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Submission not found", http.StatusNotFound)
 		return
 	}
+	s.expireIfDue(submission)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(submission)
@@ -112,50 +418,100 @@ func (s *SigningService) handleDownload(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	var filePath string
+	// Plans can contain secrets; submitters may only fetch signatures
+	if p := PrincipalFromContext(r.Context()); p != nil && (fileType == "plan" || fileType == "plan-json") && !p.Has(RoleReviewer) {
+		http.Error(w, "Forbidden: downloading plans requires the reviewer role", http.StatusForbidden)
+		return
+	}
+
 	switch fileType {
-	case "plan":
-		filePath = s.storage.GetPlanPath(id)
-	case "signature":
-		filePath = s.storage.GetSignaturePath(id)
+	case "plan", "plan-json", "signature", "signatures", "bundle":
 	default:
-		http.Error(w, "Invalid file type", http.StatusBadRequest)
+		if _, err := attestationName(fileType); err != nil {
+			http.Error(w, "Invalid file type", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Refuse to serve anything for a plan that changed in storage since submission
+	submission, err := s.storage.GetSubmission(id)
+	if err != nil {
+		http.Error(w, "Submission not found", http.StatusNotFound)
+		return
+	}
+	if err := s.checkStoredPlan(submission); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
 		return
 	}
+	w.Header().Set(PlanHashHeader, submission.PlanHash)
 
-	// Check if file exists
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
+	var file io.ReadCloser
+	switch fileType {
+	case "plan":
+		file, err = s.storage.OpenPlan(id)
+	case "plan-json":
+		file, err = s.storage.OpenPlanJSON(id)
+	case "signature":
+		// The first approval, as recorded atomically with the metadata
+		if len(submission.Approvals) > 0 {
+			s.audit(r, AuditDownload, "", id, map[string]string{"file": fileType})
+			w.Header().Set("Content-Type", "application/octet-stream")
+			io.WriteString(w, submission.Approvals[0].Signature)
+			return
+		}
+		file, err = s.storage.OpenSignature(id)
+	case "signatures":
+		// Generated from the recorded approvals
+		if len(submission.Approvals) == 0 {
+			http.Error(w, "File not found", http.StatusNotFound)
+			return
+		}
+		s.audit(r, AuditDownload, "", id, map[string]string{"file": fileType})
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(signatureSet(submission))
+		return
+	case "bundle":
+		// Generated from the first approval and its transparency log entry
+		bundle, err := s.approvalBundle(submission)
+		if errors.Is(err, ErrNotFound) {
+			http.Error(w, "File not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to build bundle: %v", err), http.StatusInternalServerError)
+			return
+		}
+		s.audit(r, AuditDownload, "", id, map[string]string{"file": fileType})
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(bundle)
+		return
+	default:
+		file, err = s.storage.OpenAttestation(id, fileType)
+	}
+	if errors.Is(err, ErrNotFound) {
 		http.Error(w, "File not found", http.StatusNotFound)
 		return
 	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to read %s: %v", fileType, err), http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+	s.audit(r, AuditDownload, "", id, map[string]string{"file": fileType})
 
-	http.ServeFile(w, r, filePath)
+	w.Header().Set("Content-Type", "application/octet-stream")
+	io.Copy(w, file)
 }
 
-// handleListPending returns all pending submissions
+// handleListPending returns all pending submissions, riskiest first
 func (s *SigningService) handleListPending(w http.ResponseWriter, r *http.Request) {
-	pending, err := s.storage.ListPending()
+	s.expireDue()
+	page, err := s.storage.Query(SubmissionQuery{Status: "pending", Sort: "-risk", Limit: -1})
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Failed to list pending: %v", err), http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(pending)
-}
-
-// MarkSigned marks a submission as signed (called after admin signs)
-func (s *SigningService) MarkSigned(id, reviewer string) error {
-	submission, err := s.storage.GetSubmission(id)
-	if err != nil {
-		return err
-	}
-
-	now := time.Now()
-	submission.Status = "approved"
-	submission.ReviewedBy = reviewer
-	submission.ReviewedAt = &now
-	submission.SignedAt = &now
-
-	return s.storage.UpdateSubmission(submission)
+	json.NewEncoder(w).Encode(page.Submissions)
 }
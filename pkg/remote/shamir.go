@@ -0,0 +1,114 @@
+package remote
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+// Shamir secret sharing over GF(2^8), used for break-glass lockdown release.
+// Each share is the x coordinate (1..255) followed by one y byte per secret byte.
+
+// splitSecret splits secret into n shares, any k of which recover it
+func splitSecret(secret []byte, n, k int) ([][]byte, error) {
+	if k < 2 || n < k || n > 255 {
+		return nil, fmt.Errorf("invalid share parameters: need 2 <= threshold <= shares <= 255")
+	}
+	if len(secret) == 0 {
+		return nil, errors.New("secret must not be empty")
+	}
+
+	shares := make([][]byte, n)
+	for i := range shares {
+		shares[i] = make([]byte, len(secret)+1)
+		shares[i][0] = byte(i + 1)
+	}
+
+	coeffs := make([]byte, k)
+	for b, s := range secret {
+		coeffs[0] = s
+		if _, err := rand.Read(coeffs[1:]); err != nil {
+			return nil, fmt.Errorf("failed to generate shares: %w", err)
+		}
+		for i := range shares {
+			shares[i][b+1] = evalPolynomial(coeffs, shares[i][0])
+		}
+	}
+
+	return shares, nil
+}
+
+// combineShares recovers the secret from k or more shares
+func combineShares(shares [][]byte) ([]byte, error) {
+	if len(shares) < 2 {
+		return nil, errors.New("at least two shares are required")
+	}
+
+	size := len(shares[0])
+	seen := map[byte]bool{}
+	for _, s := range shares {
+		if len(s) != size || size < 2 {
+			return nil, errors.New("shares have inconsistent lengths")
+		}
+		if s[0] == 0 || seen[s[0]] {
+			return nil, errors.New("shares must have distinct non-zero indexes")
+		}
+		seen[s[0]] = true
+	}
+
+	secret := make([]byte, size-1)
+	for b := range secret {
+		// Lagrange interpolation at x = 0
+		var value byte
+		for i, si := range shares {
+			num, den := byte(1), byte(1)
+			for j, sj := range shares {
+				if i == j {
+					continue
+				}
+				num = gfMul(num, sj[0])
+				den = gfMul(den, si[0]^sj[0])
+			}
+			value ^= gfMul(si[b+1], gfDiv(num, den))
+		}
+		secret[b] = value
+	}
+
+	return secret, nil
+}
+
+// evalPolynomial evaluates the polynomial with the given coefficients at x
+func evalPolynomial(coeffs []byte, x byte) byte {
+	var y byte
+	for i := len(coeffs) - 1; i >= 0; i-- {
+		y = gfMul(y, x) ^ coeffs[i]
+	}
+	return y
+}
+
+// gfMul multiplies in GF(2^8) with the AES polynomial
+func gfMul(a, b byte) byte {
+	var p byte
+	for b > 0 {
+		if b&1 != 0 {
+			p ^= a
+		}
+		carry := a & 0x80
+		a <<= 1
+		if carry != 0 {
+			a ^= 0x1b
+		}
+		b >>= 1
+	}
+	return p
+}
+
+// gfDiv divides in GF(2^8); b must be non-zero
+func gfDiv(a, b byte) byte {
+	// b^254 is the multiplicative inverse of b
+	inv := byte(1)
+	for i := 0; i < 254; i++ {
+		inv = gfMul(inv, b)
+	}
+	return gfMul(a, inv)
+}
@@ -0,0 +1,200 @@
+package remote
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// subsets returns every k-element subset of indexes 0..n-1
+func subsets(n, k int) [][]int {
+	if k == 0 {
+		return [][]int{nil}
+	}
+	var out [][]int
+	for first := 0; first <= n-k; first++ {
+		for _, rest := range subsets(n-first-1, k-1) {
+			subset := []int{first}
+			for _, i := range rest {
+				subset = append(subset, first+1+i)
+			}
+			out = append(out, subset)
+		}
+	}
+	return out
+}
+
+func TestSplitSecretThreshold(t *testing.T) {
+	secret := []byte("break-glass seed of thirty-two b")
+	const n, k = 5, 3
+	shares, err := splitSecret(secret, n, k)
+	if err != nil {
+		t.Fatalf("splitSecret: %v", err)
+	}
+
+	for _, subset := range subsets(n, k) {
+		picked := make([][]byte, len(subset))
+		for i, index := range subset {
+			picked[i] = shares[index]
+		}
+		got, err := combineShares(picked)
+		if err != nil {
+			t.Fatalf("combineShares(%v): %v", subset, err)
+		}
+		if !bytes.Equal(got, secret) {
+			t.Errorf("shares %v rebuilt %q", subset, got)
+		}
+	}
+
+	// Fewer than k shares interpolate a different polynomial
+	for _, subset := range subsets(n, k-1) {
+		got, err := combineShares([][]byte{shares[subset[0]], shares[subset[1]]})
+		if err == nil && bytes.Equal(got, secret) {
+			t.Errorf("shares %v rebuilt the secret below the threshold", subset)
+		}
+	}
+
+	for _, bad := range []struct{ n, k int }{{5, 1}, {2, 3}, {256, 3}} {
+		if _, err := splitSecret(secret, bad.n, bad.k); err == nil {
+			t.Errorf("splitSecret accepted %d shares with threshold %d", bad.n, bad.k)
+		}
+	}
+}
+
+func TestCombineSharesRejectsBadIndexes(t *testing.T) {
+	shares, err := splitSecret([]byte("secret"), 3, 2)
+	if err != nil {
+		t.Fatalf("splitSecret: %v", err)
+	}
+	zero := bytes.Clone(shares[1])
+	zero[0] = 0
+
+	for name, picked := range map[string][][]byte{
+		"duplicate": {shares[0], shares[0]},
+		"index 0":   {shares[0], zero},
+		"short":     {shares[0], shares[1][:3]},
+		"one share": {shares[0]},
+	} {
+		if _, err := combineShares(picked); err == nil {
+			t.Errorf("combineShares accepted %s", name)
+		}
+	}
+}
+
+func TestBreakGlassShares(t *testing.T) {
+	pubPEM, shares, err := GenerateBreakGlass(4, 3)
+	if err != nil {
+		t.Fatalf("GenerateBreakGlass: %v", err)
+	}
+
+	// Shares survive their text encoding
+	parsed := make([]BreakGlassShare, len(shares))
+	for i, share := range shares {
+		if parsed[i], err = ParseBreakGlassShare(share.String() + "\n"); err != nil {
+			t.Fatalf("ParseBreakGlassShare: %v", err)
+		}
+		if parsed[i].SetID != share.SetID || parsed[i].Threshold != share.Threshold || !bytes.Equal(parsed[i].Data, share.Data) {
+			t.Errorf("share %d = %+v after encoding, want %+v", i, parsed[i], share)
+		}
+	}
+
+	key, err := RebuildBreakGlassKey(parsed[1:])
+	if err != nil {
+		t.Fatalf("RebuildBreakGlassKey: %v", err)
+	}
+	if !strings.Contains(string(pubPEM), "PUBLIC KEY") || breakGlassSetID(key.Public().(ed25519.PublicKey)) != shares[0].SetID {
+		t.Error("rebuilt key does not match the break-glass public key")
+	}
+
+	if _, err := RebuildBreakGlassKey(parsed[:2]); err == nil {
+		t.Error("RebuildBreakGlassKey accepted fewer shares than the threshold")
+	}
+	if _, err := RebuildBreakGlassKey([]BreakGlassShare{parsed[0], parsed[0], parsed[1]}); err == nil {
+		t.Error("RebuildBreakGlassKey accepted a duplicated share")
+	}
+	moved := parsed[2]
+	moved.Data = bytes.Clone(moved.Data)
+	moved.Data[0] = 200
+	if _, err := RebuildBreakGlassKey([]BreakGlassShare{parsed[0], parsed[1], moved}); err == nil {
+		t.Error("RebuildBreakGlassKey accepted a share with a foreign index")
+	}
+	_, other, _ := GenerateBreakGlass(4, 3)
+	if _, err := RebuildBreakGlassKey([]BreakGlassShare{parsed[0], parsed[1], other[2]}); err == nil {
+		t.Error("RebuildBreakGlassKey accepted shares from two sets")
+	}
+
+	zero := shares[0]
+	zero.Data = bytes.Clone(zero.Data)
+	zero.Data[0] = 0
+	for _, text := range []string{zero.String(), "terrasign-share-v1:abcd:3:zz", "terrasign-share-v1:abcd:1:" + strings.Repeat("01", 33), "not a share"} {
+		if _, err := ParseBreakGlassShare(text); err == nil {
+			t.Errorf("ParseBreakGlassShare accepted %q", text)
+		}
+	}
+}
+
+func TestLockdownChallenges(t *testing.T) {
+	store, err := NewStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewStorage: %v", err)
+	}
+	indexed, err := NewIndexedStore(store, 0)
+	if err != nil {
+		t.Fatalf("NewIndexedStore: %v", err)
+	}
+	s := &SigningService{storage: indexed}
+
+	// A challenge is answered once
+	c, err := s.IssueChallenge("global", "", "alice")
+	if err != nil {
+		t.Fatalf("IssueChallenge: %v", err)
+	}
+	if !strings.Contains(c.Message, "nonce="+c.Nonce) || !strings.Contains(c.Message, "scope=global") {
+		t.Errorf("challenge message %q does not bind the nonce and scope", c.Message)
+	}
+	if _, err := s.consumeChallenge(c.Nonce); err != nil {
+		t.Fatalf("consumeChallenge: %v", err)
+	}
+	if _, err := s.consumeChallenge(c.Nonce); err == nil {
+		t.Error("a challenge was answered twice")
+	}
+
+	// An expired challenge is refused, even when still stored
+	c, err = s.IssueChallenge("global", "", "alice")
+	if err != nil {
+		t.Fatalf("IssueChallenge: %v", err)
+	}
+	err = store.ModifyState("lockdown-challenges", func(data []byte) ([]byte, error) {
+		var challenges []LockdownChallenge
+		if err := json.Unmarshal(data, &challenges); err != nil {
+			return nil, err
+		}
+		for i := range challenges {
+			challenges[i].ExpiresAt = time.Now().Add(-time.Second)
+		}
+		return json.Marshal(challenges)
+	})
+	if err != nil {
+		t.Fatalf("ModifyState: %v", err)
+	}
+	if _, err := s.consumeChallenge(c.Nonce); err == nil || !strings.Contains(err.Error(), "expired") {
+		t.Errorf("consumeChallenge of an expired challenge = %v", err)
+	}
+
+	// One caller's outstanding challenges are capped without blocking others
+	for i := 0; i < maxChallengesPerCaller; i++ {
+		if _, err := s.IssueChallenge("global", "", "mallory"); err != nil {
+			t.Fatalf("IssueChallenge %d: %v", i, err)
+		}
+	}
+	if _, err := s.IssueChallenge("global", "", "mallory"); !errors.Is(err, errTooManyChallenges) {
+		t.Errorf("IssueChallenge over the per-caller limit = %v", err)
+	}
+	if _, err := s.IssueChallenge("global", "", "bob"); err != nil {
+		t.Errorf("another caller's IssueChallenge: %v", err)
+	}
+}
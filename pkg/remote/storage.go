@@ -1,19 +1,26 @@
 package remote
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
-	"time"
+	"strings"
+	"sync"
 
 	"github.com/google/uuid"
 )
 
-// Storage handles plan storage and retrieval
+// Storage is the local filesystem Store: one directory per submission
+// holding metadata.json, the plan and its artifacts
 type Storage struct {
 	baseDir string
+
+	mu sync.Mutex // Serializes metadata and state writes
 }
 
 // NewStorage creates a new storage instance
@@ -25,7 +32,7 @@ func NewStorage(baseDir string) (*Storage, error) {
 }
 
 // StorePlan saves a plan file and creates a submission record
-func (s *Storage) StorePlan(planData io.Reader, submitter string) (*PlanSubmission, error) {
+func (s *Storage) StorePlan(planData io.Reader, maxSize int64, submitter string) (*PlanSubmission, error) {
 	id := uuid.New().String()
 	
 	// Create directory for this submission
@@ -35,24 +42,29 @@ func (s *Storage) StorePlan(planData io.Reader, submitter string) (*PlanSubmissi
 	}
 
 	// Save plan file
-	planPath := filepath.Join(submissionDir, "tfplan")
+	planPath := filepath.Join(submissionDir, planName)
 	planFile, err := os.Create(planPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create plan file: %w", err)
 	}
 	defer planFile.Close()
 
-	if _, err := io.Copy(planFile, planData); err != nil {
-		return nil, fmt.Errorf("failed to write plan data: %w", err)
+	// Hash while streaming so the digest covers exactly the bytes on disk
+	hasher := sha256.New()
+	n, err := io.Copy(io.MultiWriter(planFile, hasher), io.LimitReader(planData, maxSize+1))
+	if err == nil && n > maxSize {
+		err = planTooLarge(maxSize)
+	} else if err != nil {
+		err = fmt.Errorf("failed to write plan data: %w", err)
+	}
+	if err != nil {
+		planFile.Close()
+		os.RemoveAll(submissionDir)
+		return nil, err
 	}
 
 	// Create submission metadata
-	submission := &PlanSubmission{
-		ID:          id,
-		Submitter:   submitter,
-		CreatedAt:   time.Now(),
-		Status:      "pending",
-	}
+	submission := newSubmission(id, hex.EncodeToString(hasher.Sum(nil)), submitter)
 
 	// Save metadata
 	if err := s.saveMetadata(submission); err != nil {
@@ -64,10 +76,14 @@ func (s *Storage) StorePlan(planData io.Reader, submitter string) (*PlanSubmissi
 
 // GetSubmission retrieves a submission by ID
 func (s *Storage) GetSubmission(id string) (*PlanSubmission, error) {
-	metadataPath := filepath.Join(s.baseDir, id, "metadata.json")
+	if err := checkID(id); err != nil {
+		return nil, err
+	}
+
+	metadataPath := filepath.Join(s.baseDir, id, metadataName)
 	data, err := os.ReadFile(metadataPath)
 	if err != nil {
-		return nil, fmt.Errorf("submission not found: %w", err)
+		return nil, fmt.Errorf("submission %s %w", id, ErrNotFound)
 	}
 
 	var submission PlanSubmission
@@ -78,14 +94,14 @@ func (s *Storage) GetSubmission(id string) (*PlanSubmission, error) {
 	return &submission, nil
 }
 
-// ListPending returns all pending submissions
-func (s *Storage) ListPending() ([]*PlanSubmission, error) {
+// ListSubmissions returns every submission
+func (s *Storage) ListSubmissions() ([]*PlanSubmission, error) {
 	entries, err := os.ReadDir(s.baseDir)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read storage directory: %w", err)
 	}
 
-	var pending []*PlanSubmission
+	var submissions []*PlanSubmission
 	for _, entry := range entries {
 		if !entry.IsDir() {
 			continue
@@ -95,41 +111,269 @@ func (s *Storage) ListPending() ([]*PlanSubmission, error) {
 		if err != nil {
 			continue
 		}
+		submissions = append(submissions, submission)
+	}
+
+	return submissions, nil
+}
+
+// ListPending returns all pending submissions
+func (s *Storage) ListPending() ([]*PlanSubmission, error) {
+	submissions, err := s.ListSubmissions()
+	if err != nil {
+		return nil, err
+	}
+	return filterPending(submissions), nil
+}
+
+// DeleteSubmission removes a submission and its files
+func (s *Storage) DeleteSubmission(id string) error {
+	if err := checkID(id); err != nil {
+		return err
+	}
+	return os.RemoveAll(filepath.Join(s.baseDir, id))
+}
+
+// OpenPlan opens the stored plan
+func (s *Storage) OpenPlan(id string) (io.ReadCloser, error) {
+	return s.open(id, planName)
+}
+
+// SavePlanJSON writes the JSON rendering submitted with the plan
+func (s *Storage) SavePlanJSON(id string, data []byte) error {
+	if err := checkID(id); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(s.baseDir, id, planJSONName), data, 0644); err != nil {
+		return fmt.Errorf("failed to write plan JSON: %w", err)
+	}
+	return nil
+}
+
+// OpenPlanJSON opens the stored plan JSON
+func (s *Storage) OpenPlanJSON(id string) (io.ReadCloser, error) {
+	return s.open(id, planJSONName)
+}
+
+// OpenSignature opens the stored signature
+func (s *Storage) OpenSignature(id string) (io.ReadCloser, error) {
+	return s.open(id, signatureName)
+}
+
+// SaveAttestation writes an attestation (tfplan.<kind>) for a submission
+func (s *Storage) SaveAttestation(id, kind string, data []byte) error {
+	name, err := attestationName(kind)
+	if err != nil {
+		return err
+	}
+	if err := checkID(id); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(s.baseDir, id, name), data, 0644); err != nil {
+		return fmt.Errorf("failed to write attestation: %w", err)
+	}
+	return nil
+}
+
+// OpenAttestation opens a stored attestation
+func (s *Storage) OpenAttestation(id, kind string) (io.ReadCloser, error) {
+	name, err := attestationName(kind)
+	if err != nil {
+		return nil, err
+	}
+	return s.open(id, name)
+}
+
+// LoadState reads a service-wide state document
+func (s *Storage) LoadState(name string) ([]byte, error) {
+	file, err := stateFile(name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filepath.Join(s.baseDir, file))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%s %w", file, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", file, err)
+	}
+	return data, nil
+}
+
+// SaveState writes a service-wide state document, replacing it atomically
+func (s *Storage) SaveState(name string, data []byte) error {
+	file, err := stateFile(name)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(s.baseDir, file)
+	if err := os.WriteFile(path+".tmp", data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", file, err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return fmt.Errorf("failed to write %s: %w", file, err)
+	}
+	return nil
+}
+
+// ModifyState applies modify to a state document under the store's lock
+func (s *Storage) ModifyState(name string, modify func(data []byte) ([]byte, error)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-		if submission.Status == "pending" {
-			pending = append(pending, submission)
+	data, err := s.LoadState(name)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	updated, err := modify(data)
+	if err != nil {
+		return err
+	}
+	return s.SaveState(name, updated)
+}
+
+// AppendRecord writes a log record; O_EXCL makes a second writer of the
+// same seq fail
+func (s *Storage) AppendRecord(log string, seq uint64, data []byte) error {
+	if err := checkLog(log); err != nil {
+		return err
+	}
+	dir := filepath.Join(s.baseDir, log)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s directory: %w", log, err)
+	}
+
+	f, err := os.OpenFile(filepath.Join(dir, recordName(seq)), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if os.IsExist(err) {
+		return fmt.Errorf("%s record %d %w", log, seq, ErrExists)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create %s record: %w", log, err)
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write %s record: %w", log, err)
+	}
+	return f.Close()
+}
+
+// ReadRecords reads log records after seq in order
+func (s *Storage) ReadRecords(log string, after uint64, limit int) ([][]byte, error) {
+	if err := checkLog(log); err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(filepath.Join(s.baseDir, log))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s directory: %w", log, err)
+	}
+
+	// ReadDir sorts by name, which is seq order
+	var records [][]byte
+	from := recordName(after)
+	for _, entry := range entries {
+		if limit >= 0 && len(records) >= limit {
+			break
+		}
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") || entry.Name() <= from {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(s.baseDir, log, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s record: %w", log, err)
 		}
+		records = append(records, data)
 	}
+	return records, nil
+}
 
-	return pending, nil
+// Close implements Store; the filesystem needs no cleanup
+func (s *Storage) Close() error {
+	return nil
+}
+
+// open opens one of a submission's files
+func (s *Storage) open(id, name string) (io.ReadCloser, error) {
+	if err := checkID(id); err != nil {
+		return nil, err
+	}
+	f, err := os.Open(filepath.Join(s.baseDir, id, name))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%s for %s %w", name, id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", name, err)
+	}
+	return f, nil
 }
 
 // GetPlanPath returns the path to the plan file
 func (s *Storage) GetPlanPath(id string) string {
-	return filepath.Join(s.baseDir, id, "tfplan")
+	return filepath.Join(s.baseDir, id, planName)
 }
 
 // GetSignaturePath returns the path to the signature file
 func (s *Storage) GetSignaturePath(id string) string {
-	return filepath.Join(s.baseDir, id, "tfplan.sig")
+	return filepath.Join(s.baseDir, id, signatureName)
+}
+
+// SaveSignature writes the plan signature file for a submission
+func (s *Storage) SaveSignature(id string, sig []byte) error {
+	if err := checkID(id); err != nil {
+		return err
+	}
+	if err := os.WriteFile(s.GetSignaturePath(id), sig, 0644); err != nil {
+		return fmt.Errorf("failed to write signature: %w", err)
+	}
+	return nil
 }
 
 // UpdateSubmission updates submission metadata
 func (s *Storage) UpdateSubmission(submission *PlanSubmission) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	return s.saveMetadata(submission)
 }
 
-// saveMetadata saves submission metadata to disk
+// ModifySubmission applies modify to a submission's metadata under the
+// store's lock. The filesystem store serves a single instance, so the lock
+// is all that orders concurrent writers.
+func (s *Storage) ModifySubmission(id string, modify func(*PlanSubmission) error) (*PlanSubmission, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	submission, err := s.GetSubmission(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := modify(submission); err != nil {
+		return nil, err
+	}
+	if err := s.saveMetadata(submission); err != nil {
+		return nil, err
+	}
+	return submission, nil
+}
+
+// saveMetadata saves submission metadata to disk, replacing the file in one
+// rename so readers never see a partial write
 func (s *Storage) saveMetadata(submission *PlanSubmission) error {
-	metadataPath := filepath.Join(s.baseDir, submission.ID, "metadata.json")
+	metadataPath := filepath.Join(s.baseDir, submission.ID, metadataName)
 	data, err := json.MarshalIndent(submission, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal metadata: %w", err)
 	}
 
-	if err := os.WriteFile(metadataPath, data, 0644); err != nil {
+	if err := os.WriteFile(metadataPath+".tmp", data, 0644); err != nil {
+		return fmt.Errorf("failed to write metadata: %w", err)
+	}
+	if err := os.Rename(metadataPath+".tmp", metadataPath); err != nil {
 		return fmt.Errorf("failed to write metadata: %w", err)
 	}
 
 	return nil
 }
+
+var _ Store = (*Storage)(nil)
@@ -0,0 +1,221 @@
+package remote
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Store persists submissions (metadata) and their artifacts: the plan, the
+// signature and attestations. Implementations must be safe for concurrent
+// use; the object store backends let several service instances share state.
+type Store interface {
+	// StorePlan fails with ErrPlanTooLarge, storing nothing, when the plan
+	// exceeds maxSize bytes
+	StorePlan(planData io.Reader, maxSize int64, submitter string) (*PlanSubmission, error)
+	GetSubmission(id string) (*PlanSubmission, error)
+	UpdateSubmission(submission *PlanSubmission) error
+	DeleteSubmission(id string) error
+	ListSubmissions() ([]*PlanSubmission, error)
+	ListPending() ([]*PlanSubmission, error)
+
+	// ModifySubmission applies modify to the current metadata of a
+	// submission and stores the result only if no other writer (in this or
+	// another instance) updated it meanwhile; on such a conflict modify runs
+	// again on the newer metadata. An error from modify leaves the
+	// submission unchanged and is returned as is.
+	ModifySubmission(id string, modify func(*PlanSubmission) error) (*PlanSubmission, error)
+
+	OpenPlan(id string) (io.ReadCloser, error)
+	SavePlanJSON(id string, data []byte) error
+	OpenPlanJSON(id string) (io.ReadCloser, error)
+	SaveSignature(id string, sig []byte) error
+	OpenSignature(id string) (io.ReadCloser, error)
+	SaveAttestation(id, kind string, data []byte) error
+	OpenAttestation(id, kind string) (io.ReadCloser, error)
+
+	// Service-wide state documents such as the lockdown list. ModifyState
+	// is the read-modify-write counterpart of ModifySubmission: modify gets
+	// the current document (nil if there is none) and returns the new one,
+	// and runs again if another writer changed the document meanwhile.
+	LoadState(name string) ([]byte, error)
+	SaveState(name string, data []byte) error
+	ModifyState(name string, modify func(data []byte) ([]byte, error)) error
+
+	// Append-only logs such as the audit log. Records are numbered from 1
+	// and never replaced: appending an existing seq fails with ErrExists.
+	// ReadRecords returns up to limit records after seq (limit < 0 for all).
+	AppendRecord(log string, seq uint64, data []byte) error
+	ReadRecords(log string, after uint64, limit int) ([][]byte, error)
+
+	Close() error
+}
+
+// ErrNotFound is returned (wrapped) for missing submissions and artifacts
+var ErrNotFound = errors.New("not found")
+
+// ErrExists is returned (wrapped) when appending a log record that another
+// writer already appended
+var ErrExists = errors.New("already exists")
+
+// ErrPlanTooLarge is returned (wrapped) when a submitted plan exceeds the
+// maximum plan size
+var ErrPlanTooLarge = errors.New("plan too large")
+
+// DefaultMaxPlanSize is the largest plan stored unless the service is
+// configured otherwise
+const DefaultMaxPlanSize = 256 << 20
+
+// planTooLarge reports a plan over maxSize bytes
+func planTooLarge(maxSize int64) error {
+	return fmt.Errorf("%w: the maximum plan size is %d bytes", ErrPlanTooLarge, maxSize)
+}
+
+// ErrConflict is returned (wrapped) when a submission kept changing under a
+// ModifySubmission, or changed so that the modification no longer applies
+var ErrConflict = errors.New("submission changed concurrently")
+
+// maxModifyAttempts bounds how often ModifySubmission retries on conflict
+const maxModifyAttempts = 10
+
+// Artifact names, shared by every backend's layout
+const (
+	metadataName  = "metadata.json"
+	planName      = "tfplan"
+	planJSONName  = "tfplan.json"
+	signatureName = "tfplan.sig"
+)
+
+// AttestationKinds are the attestations a submission can carry, stored as
+// tfplan.<kind> like the files `terrasign sign` writes next to a plan
+var AttestationKinds = []string{"policy", "provenance"}
+
+// attestationName returns the artifact name for an attestation kind
+func attestationName(kind string) (string, error) {
+	for _, k := range AttestationKinds {
+		if k == kind {
+			return planName + "." + kind, nil
+		}
+	}
+	return "", fmt.Errorf("unknown attestation kind %q", kind)
+}
+
+// stateNames are the service-wide state documents, stored as <name>.json
+// beside the submissions
+var stateNames = map[string]bool{"lockdown": true, "lockdown-challenges": true}
+
+// stateFile returns the file or key name of a state document
+func stateFile(name string) (string, error) {
+	if !stateNames[name] {
+		return "", fmt.Errorf("unknown state document %q", name)
+	}
+	return name + ".json", nil
+}
+
+// logNames are the append-only logs, stored as <name>/<seq>.json beside the
+// submissions
+var logNames = map[string]bool{"audit": true, "tlog": true}
+
+// checkLog rejects unknown log names
+func checkLog(name string) error {
+	if !logNames[name] {
+		return fmt.Errorf("unknown log %q", name)
+	}
+	return nil
+}
+
+// recordName returns the file or key name of a log record; the zero padding
+// makes lexical order match seq order
+func recordName(seq uint64) string {
+	return fmt.Sprintf("%020d.json", seq)
+}
+
+// checkID rejects IDs that are not UUIDs, so an ID can never escape its
+// directory or key prefix
+func checkID(id string) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return fmt.Errorf("invalid submission ID %q", id)
+	}
+	return nil
+}
+
+// newSubmission creates the pending record for a stored plan
+func newSubmission(id, planHash, submitter string) *PlanSubmission {
+	return &PlanSubmission{
+		ID:        id,
+		PlanHash:  planHash,
+		Submitter: submitter,
+		CreatedAt: time.Now(),
+		Status:    "pending",
+	}
+}
+
+// readPlan reads a submitted plan of at most maxSize bytes into memory and
+// hashes it
+func readPlan(planData io.Reader, maxSize int64) ([]byte, string, error) {
+	data, err := io.ReadAll(io.LimitReader(planData, maxSize+1))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read plan data: %w", err)
+	}
+	if int64(len(data)) > maxSize {
+		return nil, "", planTooLarge(maxSize)
+	}
+	sum := sha256.Sum256(data)
+	return data, hex.EncodeToString(sum[:]), nil
+}
+
+// filterPending returns the pending submissions in a list
+func filterPending(submissions []*PlanSubmission) []*PlanSubmission {
+	var pending []*PlanSubmission
+	for _, submission := range submissions {
+		if submission.Status == "pending" {
+			pending = append(pending, submission)
+		}
+	}
+	return pending
+}
+
+// OpenStore opens the backend named by spec:
+//
+//	./terrasign-storage                                   local directory
+//	bolt:///var/lib/terrasign/terrasign.db                embedded bbolt database
+//	s3://bucket/prefix?endpoint=http://minio:9000&region=us-east-1
+//
+// S3 credentials are read from AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY.
+func OpenStore(spec string) (Store, error) {
+	scheme, rest, hasScheme := strings.Cut(spec, "://")
+	if !hasScheme {
+		return NewStorage(spec)
+	}
+
+	switch scheme {
+	case "file":
+		return NewStorage(rest)
+	case "bolt", "bbolt":
+		return NewBoltStore(rest)
+	case "s3":
+		u, err := url.Parse(spec)
+		if err != nil {
+			return nil, fmt.Errorf("invalid storage URL: %w", err)
+		}
+		config := S3ConfigFromEnv()
+		config.Bucket = u.Host
+		config.Prefix = strings.Trim(u.Path, "/")
+		if endpoint := u.Query().Get("endpoint"); endpoint != "" {
+			config.Endpoint = endpoint
+		}
+		if region := u.Query().Get("region"); region != "" {
+			config.Region = region
+		}
+		return NewS3Store(config)
+	}
+
+	return nil, fmt.Errorf("unsupported storage scheme %q (use a directory, bolt:// or s3://)", scheme)
+}
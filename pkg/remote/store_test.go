@@ -0,0 +1,337 @@
+package remote
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// forEachStore runs a test against a fresh instance of every Store backend
+func forEachStore(t *testing.T, test func(t *testing.T, store Store)) {
+	backends := []struct {
+		name string
+		open func(t *testing.T) Store
+	}{
+		{"filesystem", func(t *testing.T) Store {
+			store, err := NewStorage(t.TempDir())
+			if err != nil {
+				t.Fatalf("NewStorage: %v", err)
+			}
+			return store
+		}},
+		{"bolt", func(t *testing.T) Store {
+			store, err := NewBoltStore(filepath.Join(t.TempDir(), "terrasign.db"))
+			if err != nil {
+				t.Fatalf("NewBoltStore: %v", err)
+			}
+			return store
+		}},
+		{"s3", func(t *testing.T) Store {
+			_, store := newFakeS3(t, "")
+			return store
+		}},
+	}
+
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			store := backend.open(t)
+			t.Cleanup(func() { store.Close() })
+			test(t, store)
+		})
+	}
+}
+
+// readAll reads and closes an opened artifact
+func readAll(file io.ReadCloser, err error) string {
+	if err != nil {
+		return "error: " + err.Error()
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return "error: " + err.Error()
+	}
+	return string(data)
+}
+
+func TestStoreSubmissions(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		// More submissions than a fake S3 list page, to cover pagination
+		var ids []string
+		for i := 0; i < 5; i++ {
+			submission, err := store.StorePlan(strings.NewReader(fmt.Sprintf("plan %d", i)), DefaultMaxPlanSize, "ci")
+			if err != nil {
+				t.Fatalf("StorePlan: %v", err)
+			}
+			sum := sha256.Sum256([]byte(fmt.Sprintf("plan %d", i)))
+			if submission.PlanHash != hex.EncodeToString(sum[:]) || submission.Status != "pending" {
+				t.Fatalf("StorePlan = %+v", submission)
+			}
+			ids = append(ids, submission.ID)
+		}
+
+		submission, err := store.GetSubmission(ids[1])
+		if err != nil {
+			t.Fatalf("GetSubmission: %v", err)
+		}
+		submission.Status = "rejected"
+		submission.RejectionReason = "too risky"
+		if err := store.UpdateSubmission(submission); err != nil {
+			t.Fatalf("UpdateSubmission: %v", err)
+		}
+		if got, _ := store.GetSubmission(ids[1]); got.RejectionReason != "too risky" {
+			t.Errorf("update not stored: %+v", got)
+		}
+
+		all, err := store.ListSubmissions()
+		if err != nil || len(all) != 5 {
+			t.Fatalf("ListSubmissions = %d submissions, %v; want 5", len(all), err)
+		}
+		pending, err := store.ListPending()
+		if err != nil || len(pending) != 4 {
+			t.Fatalf("ListPending = %d submissions, %v; want 4", len(pending), err)
+		}
+
+		if err := store.DeleteSubmission(ids[0]); err != nil {
+			t.Fatalf("DeleteSubmission: %v", err)
+		}
+		if _, err := store.GetSubmission(ids[0]); !errors.Is(err, ErrNotFound) {
+			t.Errorf("GetSubmission after delete = %v, want ErrNotFound", err)
+		}
+		if _, err := store.OpenPlan(ids[0]); !errors.Is(err, ErrNotFound) {
+			t.Errorf("OpenPlan after delete = %v, want ErrNotFound", err)
+		}
+		if all, _ := store.ListSubmissions(); len(all) != 4 {
+			t.Errorf("ListSubmissions after delete = %d submissions, want 4", len(all))
+		}
+
+		if _, err := store.GetSubmission("../metadata"); err == nil || errors.Is(err, ErrNotFound) {
+			t.Errorf("GetSubmission with an invalid ID = %v, want an invalid ID error", err)
+		}
+		if _, err := store.GetSubmission(uuid.New().String()); !errors.Is(err, ErrNotFound) {
+			t.Errorf("GetSubmission of an unknown ID = %v, want ErrNotFound", err)
+		}
+	})
+}
+
+func TestStoreArtifacts(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		submission, err := store.StorePlan(strings.NewReader("plan"), DefaultMaxPlanSize, "ci")
+		if err != nil {
+			t.Fatalf("StorePlan: %v", err)
+		}
+		id := submission.ID
+
+		if got := readAll(store.OpenPlan(id)); got != "plan" {
+			t.Errorf("plan = %q", got)
+		}
+		if _, err := store.OpenSignature(id); !errors.Is(err, ErrNotFound) {
+			t.Errorf("OpenSignature before upload = %v, want ErrNotFound", err)
+		}
+
+		if err := store.SavePlanJSON(id, []byte(`{"format_version":"1.2"}`)); err != nil {
+			t.Fatalf("SavePlanJSON: %v", err)
+		}
+		if err := store.SaveSignature(id, []byte("c2lnbmF0dXJl")); err != nil {
+			t.Fatalf("SaveSignature: %v", err)
+		}
+		if err := store.SaveAttestation(id, "policy", []byte("attestation")); err != nil {
+			t.Fatalf("SaveAttestation: %v", err)
+		}
+
+		if got := readAll(store.OpenPlanJSON(id)); got != `{"format_version":"1.2"}` {
+			t.Errorf("plan JSON = %q", got)
+		}
+		if got := readAll(store.OpenSignature(id)); got != "c2lnbmF0dXJl" {
+			t.Errorf("signature = %q", got)
+		}
+		if got := readAll(store.OpenAttestation(id, "policy")); got != "attestation" {
+			t.Errorf("attestation = %q", got)
+		}
+		if _, err := store.OpenAttestation(id, "provenance"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("OpenAttestation of a missing kind = %v, want ErrNotFound", err)
+		}
+		if err := store.SaveAttestation(id, "../metadata.json", nil); err == nil {
+			t.Error("SaveAttestation accepted an unknown kind")
+		}
+	})
+}
+
+func TestStorePlanTooLarge(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		if _, err := store.StorePlan(strings.NewReader("0123456789a"), 10, "ci"); !errors.Is(err, ErrPlanTooLarge) {
+			t.Fatalf("StorePlan of 11 bytes with a 10 byte maximum = %v, want ErrPlanTooLarge", err)
+		}
+		if all, _ := store.ListSubmissions(); len(all) != 0 {
+			t.Errorf("a rejected plan left %d submissions behind", len(all))
+		}
+
+		submission, err := store.StorePlan(strings.NewReader("0123456789"), 10, "ci")
+		if err != nil {
+			t.Fatalf("StorePlan of exactly the maximum: %v", err)
+		}
+		if got := readAll(store.OpenPlan(submission.ID)); got != "0123456789" {
+			t.Errorf("plan = %q", got)
+		}
+	})
+}
+
+func TestStoreState(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		if _, err := store.LoadState("lockdown"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("LoadState before save = %v, want ErrNotFound", err)
+		}
+		for _, data := range []string{`{"v":1}`, `{"v":2}`} {
+			if err := store.SaveState("lockdown", []byte(data)); err != nil {
+				t.Fatalf("SaveState: %v", err)
+			}
+		}
+		data, err := store.LoadState("lockdown")
+		if err != nil || string(data) != `{"v":2}` {
+			t.Errorf("LoadState = %q, %v", data, err)
+		}
+		if err := store.SaveState("unknown", nil); err == nil {
+			t.Error("SaveState accepted an unknown document")
+		}
+
+		// Concurrent read-modify-writes are all applied, none lost
+		const writers = 8
+		var wg sync.WaitGroup
+		for i := 0; i < writers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				err := store.ModifyState("lockdown-challenges", func(data []byte) ([]byte, error) {
+					return append(data, 'x'), nil
+				})
+				if err != nil {
+					t.Errorf("ModifyState: %v", err)
+				}
+			}()
+		}
+		wg.Wait()
+		data, err = store.LoadState("lockdown-challenges")
+		if err != nil || string(data) != strings.Repeat("x", writers) {
+			t.Errorf("LoadState after concurrent ModifyState = %q, %v; want %d updates", data, err, writers)
+		}
+
+		// An error from modify leaves the document unchanged
+		refused := errors.New("refused")
+		err = store.ModifyState("lockdown", func([]byte) ([]byte, error) { return []byte("lost"), refused })
+		if !errors.Is(err, refused) {
+			t.Errorf("ModifyState = %v, want the modify error", err)
+		}
+		if data, _ := store.LoadState("lockdown"); string(data) != `{"v":2}` {
+			t.Errorf("LoadState after a refused modification = %q", data)
+		}
+
+		// State documents are not submissions
+		if all, err := store.ListSubmissions(); err != nil || len(all) != 0 {
+			t.Errorf("ListSubmissions = %d submissions, %v; want none", len(all), err)
+		}
+	})
+}
+
+func TestStoreAppendRecord(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		if records, err := store.ReadRecords("audit", 0, -1); err != nil || len(records) != 0 {
+			t.Fatalf("ReadRecords of an empty log = %q, %v", records, err)
+		}
+
+		for seq := uint64(1); seq <= 5; seq++ {
+			if err := store.AppendRecord("audit", seq, []byte(fmt.Sprintf("record %d", seq))); err != nil {
+				t.Fatalf("AppendRecord(%d): %v", seq, err)
+			}
+		}
+
+		// A second writer of a seq loses; the record is not replaced
+		if err := store.AppendRecord("audit", 3, []byte("replaced")); !errors.Is(err, ErrExists) {
+			t.Errorf("AppendRecord of an existing seq = %v, want ErrExists", err)
+		}
+
+		records, err := store.ReadRecords("audit", 0, -1)
+		if err != nil {
+			t.Fatalf("ReadRecords: %v", err)
+		}
+		var got []string
+		for _, record := range records {
+			got = append(got, string(record))
+		}
+		want := "record 1,record 2,record 3,record 4,record 5"
+		if strings.Join(got, ",") != want {
+			t.Errorf("ReadRecords = %q, want %s", got, want)
+		}
+
+		records, err = store.ReadRecords("audit", 2, 2)
+		if err != nil || len(records) != 2 || string(records[0]) != "record 3" || string(records[1]) != "record 4" {
+			t.Errorf("ReadRecords(after 2, limit 2) = %q, %v", records, err)
+		}
+		if records, _ := store.ReadRecords("tlog", 0, -1); len(records) != 0 {
+			t.Errorf("logs are not separate: tlog has %q", records)
+		}
+		if err := store.AppendRecord("../audit", 1, nil); err == nil {
+			t.Error("AppendRecord accepted an unknown log")
+		}
+	})
+}
+
+func TestStoreModifySubmission(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		submission, err := store.StorePlan(strings.NewReader("plan"), DefaultMaxPlanSize, "ci")
+		if err != nil {
+			t.Fatalf("StorePlan: %v", err)
+		}
+
+		// Concurrent modifications are all applied, none lost
+		const writers = 8
+		var wg sync.WaitGroup
+		for i := 0; i < writers; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				_, err := store.ModifySubmission(submission.ID, func(current *PlanSubmission) error {
+					current.Approvals = append(current.Approvals, Approval{KeyID: fmt.Sprintf("key-%d", i)})
+					return nil
+				})
+				if err != nil {
+					t.Errorf("ModifySubmission: %v", err)
+				}
+			}(i)
+		}
+		wg.Wait()
+
+		stored, err := store.GetSubmission(submission.ID)
+		if err != nil {
+			t.Fatalf("GetSubmission: %v", err)
+		}
+		if len(stored.Approvals) != writers {
+			t.Errorf("%d approvals stored, want %d", len(stored.Approvals), writers)
+		}
+
+		// An error from modify leaves the submission unchanged
+		refused := errors.New("refused")
+		_, err = store.ModifySubmission(submission.ID, func(current *PlanSubmission) error {
+			current.Status = "approved"
+			return refused
+		})
+		if !errors.Is(err, refused) {
+			t.Errorf("ModifySubmission = %v, want the modify error", err)
+		}
+		if stored, _ := store.GetSubmission(submission.ID); stored.Status != "pending" {
+			t.Errorf("status = %s after a refused modification", stored.Status)
+		}
+
+		_, err = store.ModifySubmission(uuid.New().String(), func(*PlanSubmission) error { return nil })
+		if !errors.Is(err, ErrNotFound) {
+			t.Errorf("ModifySubmission of an unknown ID = %v, want ErrNotFound", err)
+		}
+	})
+}
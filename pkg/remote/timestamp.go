@@ -0,0 +1,40 @@
+package remote
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/sulakshanakarunarathne/terrasign/pkg/tsa"
+)
+
+// maxTimestampQuerySize bounds RFC 3161 requests to POST /tsa
+const maxTimestampQuerySize = 16 << 10
+
+// handleTimestamp serves POST /tsa: the built-in RFC 3161 timestamp
+// authority, for signers that cannot reach a public TSA
+func (s *SigningService) handleTimestamp(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.authority == nil {
+		http.Error(w, "Timestamp authority not enabled (start the server with --tsa-cert and --tsa-key)", http.StatusNotFound)
+		return
+	}
+
+	query, err := io.ReadAll(io.LimitReader(r.Body, maxTimestampQuerySize))
+	if err != nil {
+		http.Error(w, "Failed to read request", http.StatusBadRequest)
+		return
+	}
+
+	resp, err := s.authority.Respond(query)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid timestamp request: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", tsa.ReplyMediaType)
+	w.Write(resp)
+}
@@ -0,0 +1,447 @@
+package remote
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	protocommon "github.com/sigstore/protobuf-specs/gen/pb-go/common/v1"
+	protorekor "github.com/sigstore/protobuf-specs/gen/pb-go/rekor/v1"
+	"github.com/sigstore/sigstore/pkg/signature"
+	"github.com/sigstore/sigstore/pkg/signature/options"
+	"github.com/sulakshanakarunarathne/terrasign/pkg/planfile"
+	"github.com/sulakshanakarunarathne/terrasign/pkg/tlog"
+	"github.com/sulakshanakarunarathne/terrasign/pkg/verifier"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// tlogName is the append-only log holding transparency log entries
+const tlogName = "tlog"
+
+// tlogKeyName names the log key in checkpoint signatures
+const tlogKeyName = "terrasign"
+
+// maxTlogEntrySize bounds entries submitted to POST /tlog/entries
+const maxTlogEntrySize = 64 << 10
+
+// tlogRecord is a transparency log entry as stored in the backend. Record
+// seq N holds leaf N-1 of the Merkle tree.
+type tlogRecord struct {
+	Body           []byte `json:"body"`            // Canonical hashedrekord
+	IntegratedTime int64  `json:"integrated_time"` // Unix seconds
+}
+
+// transparencyLog is the service's local, Rekor-compatible transparency log.
+// The Merkle tree is rebuilt from the backend at startup and kept in memory;
+// like the audit log, appends racing another instance fail with ErrExists
+// and are retried after catching up.
+type transparencyLog struct {
+	mu     sync.Mutex
+	store  Store
+	signer signature.SignerVerifier // Signs checkpoints
+	logID  []byte
+	origin string
+	tree   tlog.Tree
+}
+
+// newTransparencyLog loads the log kept in a backend
+func newTransparencyLog(store Store, signer signature.SignerVerifier) (*transparencyLog, error) {
+	pub, err := signer.PublicKey()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transparency log key: %w", err)
+	}
+	logID, err := tlog.LogID(pub)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &transparencyLog{store: store, signer: signer, logID: logID, origin: tlog.Origin(logID)}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := t.catchUp(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// catchUp adds entries appended by other instances to the tree
+func (t *transparencyLog) catchUp() error {
+	for {
+		records, err := t.store.ReadRecords(tlogName, t.tree.Size(), 1000)
+		if err != nil {
+			return err
+		}
+		if len(records) == 0 {
+			return nil
+		}
+		for _, data := range records {
+			var record tlogRecord
+			if err := json.Unmarshal(data, &record); err != nil {
+				return fmt.Errorf("failed to parse transparency log entry %d: %w", t.tree.Size(), err)
+			}
+			t.tree.AppendLeaf(tlog.LeafHash(record.Body))
+		}
+	}
+}
+
+// append logs an entry body and returns the entry with its inclusion proof
+func (t *transparencyLog) append(body []byte) (*protorekor.TransparencyLogEntry, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	record := tlogRecord{Body: body, IntegratedTime: time.Now().Unix()}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode transparency log entry: %w", err)
+	}
+
+	for attempt := 0; attempt < maxAuditRetries; attempt++ {
+		if err := t.catchUp(); err != nil {
+			return nil, err
+		}
+
+		index := t.tree.Size()
+		err := t.store.AppendRecord(tlogName, index+1, data)
+		if errors.Is(err, ErrExists) {
+			continue // Another instance took this index; catch up and retry
+		}
+		if err != nil {
+			return nil, err
+		}
+		t.tree.AppendLeaf(tlog.LeafHash(body))
+		return t.buildEntry(index, record)
+	}
+	return nil, fmt.Errorf("failed to append transparency log entry: gave up after %d conflicting writes", maxAuditRetries)
+}
+
+// entry returns a logged entry with an inclusion proof for the current tree
+func (t *transparencyLog) entry(index uint64) (*protorekor.TransparencyLogEntry, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.catchUp(); err != nil {
+		return nil, err
+	}
+	if index >= t.tree.Size() {
+		return nil, fmt.Errorf("transparency log entry %d %w", index, ErrNotFound)
+	}
+
+	records, err := t.store.ReadRecords(tlogName, index, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) != 1 {
+		return nil, fmt.Errorf("transparency log entry %d %w", index, ErrNotFound)
+	}
+	var record tlogRecord
+	if err := json.Unmarshal(records[0], &record); err != nil {
+		return nil, fmt.Errorf("failed to parse transparency log entry %d: %w", index, err)
+	}
+	if !bytes.Equal(tlog.LeafHash(record.Body), t.tree.Leaf(index)) {
+		return nil, fmt.Errorf("transparency log entry %d was modified in storage", index)
+	}
+
+	return t.buildEntry(index, record)
+}
+
+// buildEntry assembles a TransparencyLogEntry with an inclusion proof and a
+// signed checkpoint for the current tree. Callers hold t.mu.
+func (t *transparencyLog) buildEntry(index uint64, record tlogRecord) (*protorekor.TransparencyLogEntry, error) {
+	size := t.tree.Size()
+	hashes, err := t.tree.InclusionProof(index, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build inclusion proof: %w", err)
+	}
+	checkpoint, root, err := t.checkpoint(size)
+	if err != nil {
+		return nil, err
+	}
+
+	return &protorekor.TransparencyLogEntry{
+		LogIndex:          int64(index),
+		LogId:             &protocommon.LogId{KeyId: t.logID},
+		KindVersion:       &protorekor.KindVersion{Kind: tlog.KindHashedRekord, Version: tlog.HashedRekordVersion},
+		IntegratedTime:    record.IntegratedTime,
+		CanonicalizedBody: record.Body,
+		InclusionProof: &protorekor.InclusionProof{
+			LogIndex:   int64(index),
+			RootHash:   root,
+			TreeSize:   int64(size),
+			Hashes:     hashes,
+			Checkpoint: &protorekor.Checkpoint{Envelope: checkpoint},
+		},
+	}, nil
+}
+
+// checkpoint signs the tree head for a size. Callers hold t.mu.
+func (t *transparencyLog) checkpoint(size uint64) (string, []byte, error) {
+	root, err := t.tree.Root(size)
+	if err != nil {
+		return "", nil, err
+	}
+	envelope, err := tlog.SignCheckpoint(tlog.Checkpoint{Origin: t.origin, Size: size, RootHash: root}, tlogKeyName, t.signer)
+	if err != nil {
+		return "", nil, err
+	}
+	return envelope, root, nil
+}
+
+// latestCheckpoint signs the current tree head
+func (t *transparencyLog) latestCheckpoint() (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := t.catchUp(); err != nil {
+		return "", err
+	}
+	envelope, _, err := t.checkpoint(t.tree.Size())
+	return envelope, err
+}
+
+// logSignature records a signature over a plan digest in the transparency
+// log. It returns nil when the log is not enabled.
+func (s *SigningService) logSignature(digest, sig []byte, key *verifier.TrustedKey) (*protorekor.TransparencyLogEntry, error) {
+	if s.tlog == nil {
+		return nil, nil
+	}
+	rekord, err := tlog.NewHashedRekord(digest, sig, key.PublicKey())
+	if err != nil {
+		return nil, err
+	}
+	body, err := rekord.Canonical()
+	if err != nil {
+		return nil, err
+	}
+	entry, err := s.tlog.append(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to log signature in the transparency log: %w", err)
+	}
+	return entry, nil
+}
+
+// planDigest returns the SHA-256 of a submission's plan
+func (s *SigningService) planDigest(submission *PlanSubmission) ([]byte, error) {
+	if submission.PlanJSONHash != "" {
+		sum := sha256.Sum256(planfile.Binding(submission.PlanHash, submission.PlanJSONHash))
+		return sum[:], nil
+	}
+	if submission.PlanHash != "" {
+		digest, err := hex.DecodeString(submission.PlanHash)
+		if err != nil || len(digest) != sha256.Size {
+			return nil, fmt.Errorf("invalid plan hash %q recorded for %s", submission.PlanHash, submission.ID)
+		}
+		return digest, nil
+	}
+	planData, err := s.loadPlan(submission.ID)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(planData)
+	return sum[:], nil
+}
+
+// handleTlogCheckpoint serves GET /tlog/checkpoint: the signed tree head
+func (s *SigningService) handleTlogCheckpoint(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.tlog == nil {
+		http.Error(w, "Transparency log not enabled (start the server with --tlog-key)", http.StatusNotFound)
+		return
+	}
+
+	checkpoint, err := s.tlog.latestCheckpoint()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to read transparency log: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	io.WriteString(w, checkpoint)
+}
+
+// handleTlogEntry serves GET /tlog/entries/{index}: a logged entry with an
+// inclusion proof against the current tree head
+func (s *SigningService) handleTlogEntry(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.tlog == nil {
+		http.Error(w, "Transparency log not enabled (start the server with --tlog-key)", http.StatusNotFound)
+		return
+	}
+
+	v := strings.TrimPrefix(r.URL.Path, "/tlog/entries/")
+	index, err := strconv.ParseUint(v, 10, 64)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid log index %q", v), http.StatusBadRequest)
+		return
+	}
+
+	entry, err := s.tlog.entry(index)
+	if errors.Is(err, ErrNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to read transparency log: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeTlogEntry(w, http.StatusOK, entry)
+}
+
+// handleTlogAppend serves POST /tlog/entries. The body is a hashedrekord
+// entry, as for Rekor; the signature must verify against the entry's public
+// key. The response is the logged entry with its inclusion proof.
+func (s *SigningService) handleTlogAppend(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.tlog == nil {
+		http.Error(w, "Transparency log not enabled (start the server with --tlog-key)", http.StatusNotFound)
+		return
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxTlogEntrySize))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to read entry: %v", err), http.StatusBadRequest)
+		return
+	}
+	rekord, err := tlog.ParseHashedRekord(data)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid entry: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err := verifyHashedRekord(rekord); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid entry: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	// Log the canonical form so every copy of an entry hashes the same
+	body, err := rekord.Canonical()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	entry, err := s.tlog.append(body)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to append entry: %v", err), http.StatusInternalServerError)
+		return
+	}
+	s.audit(r, AuditTlogAppend, "", "", map[string]string{
+		"log_index": strconv.FormatInt(entry.LogIndex, 10),
+		"digest":    rekord.Spec.Data.Hash.Value,
+	})
+
+	writeTlogEntry(w, http.StatusCreated, entry)
+}
+
+// verifyHashedRekord checks that an entry's signature verifies over its
+// digest with its public key
+func verifyHashedRekord(rekord *tlog.HashedRekord) error {
+	digest, err := rekord.Digest()
+	if err != nil {
+		return err
+	}
+	sig, err := rekord.Signature()
+	if err != nil {
+		return err
+	}
+	pub, err := rekord.PublicKey()
+	if err != nil {
+		return err
+	}
+	v, err := signature.LoadVerifier(pub, crypto.SHA256)
+	if err != nil {
+		return fmt.Errorf("unsupported public key: %w", err)
+	}
+	if err := v.VerifySignature(bytes.NewReader(sig), bytes.NewReader(nil), options.WithDigest(digest)); err != nil {
+		return fmt.Errorf("signature does not verify: %w", err)
+	}
+	return nil
+}
+
+// writeTlogEntry writes an entry in the Sigstore protobuf JSON encoding
+func writeTlogEntry(w http.ResponseWriter, status int, entry *protorekor.TransparencyLogEntry) {
+	data, err := protojson.Marshal(entry)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to encode entry: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(data)
+}
+
+// AddTlogEntry logs a hashedrekord entry body in the service's transparency
+// log and returns the entry with its inclusion proof
+func (c *Client) AddTlogEntry(body []byte) (*protorekor.TransparencyLogEntry, error) {
+	resp, err := c.client.Post(c.baseURL+"/tlog/entries", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach transparency log: %w", err)
+	}
+	defer resp.Body.Close()
+	return readTlogEntry(resp, http.StatusCreated)
+}
+
+// GetTlogEntry fetches a logged entry with an inclusion proof against the
+// current tree head
+func (c *Client) GetTlogEntry(index int64) (*protorekor.TransparencyLogEntry, error) {
+	resp, err := c.client.Get(fmt.Sprintf("%s/tlog/entries/%d", c.baseURL, index))
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach transparency log: %w", err)
+	}
+	defer resp.Body.Close()
+	return readTlogEntry(resp, http.StatusOK)
+}
+
+// GetTlogCheckpoint fetches the signed tree head
+func (c *Client) GetTlogCheckpoint() (string, error) {
+	resp, err := c.client.Get(c.baseURL + "/tlog/checkpoint")
+	if err != nil {
+		return "", fmt.Errorf("failed to reach transparency log: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if err := authError(resp); err != nil {
+		return "", err
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("server error: %s", strings.TrimSpace(string(body)))
+	}
+	return string(body), nil
+}
+
+// readTlogEntry decodes an entry response
+func readTlogEntry(resp *http.Response, want int) (*protorekor.TransparencyLogEntry, error) {
+	if err := authError(resp); err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != want {
+		return nil, fmt.Errorf("server error: %s", strings.TrimSpace(string(body)))
+	}
+
+	var entry protorekor.TransparencyLogEntry
+	if err := protojson.Unmarshal(body, &entry); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	return &entry, nil
+}
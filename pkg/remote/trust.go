@@ -0,0 +1,31 @@
+package remote
+
+import (
+	"fmt"
+
+	"github.com/sulakshanakarunarathne/terrasign/pkg/verifier"
+)
+
+// verifyPlanSignature checks sig over the stored plan (or the binding of
+// plan and JSON digests) against every trusted key and returns the key that
+// produced it. The stored files must still match the hashes recorded at
+// submission.
+func (s *SigningService) verifyPlanSignature(submission *PlanSubmission, sig []byte) (*verifier.TrustedKey, error) {
+	if len(s.trustedKeys) == 0 {
+		return nil, fmt.Errorf("no trusted reviewer keys configured (start the server with --admin-key or --trusted-keys)")
+	}
+
+	message, err := s.signedMessage(submission)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range s.trustedKeys {
+		key := &s.trustedKeys[i]
+		if err := key.Verify(sig, message); err == nil {
+			return key, nil
+		}
+	}
+
+	return nil, fmt.Errorf("signature does not verify against the stored plan with any trusted key")
+}
@@ -1,22 +1,57 @@
 package remote
 
-import "time"
+import (
+	"time"
+
+	"github.com/sulakshanakarunarathne/terrasign/pkg/verifier"
+)
 
 // PlanSubmission represents a plan submitted for review
 type PlanSubmission struct {
-	ID          string    `json:"id"`
-	PlanHash    string    `json:"plan_hash"`
-	Submitter   string    `json:"submitter"`
-	CreatedAt   time.Time `json:"created_at"`
-	Status      string    `json:"status"` // pending, approved, rejected
-	ReviewedBy  string    `json:"reviewed_by,omitempty"`
+	ID          string     `json:"id"`
+	PlanHash    string     `json:"plan_hash"`
+	Submitter   string     `json:"submitter"`
+	CreatedAt   time.Time  `json:"created_at"`
+	Status      string     `json:"status"` // pending, approved, rejected, expired
+	ReviewedBy  string     `json:"reviewed_by,omitempty"`
 	ReviewedAt  *time.Time `json:"reviewed_at,omitempty"`
 	SignedAt    *time.Time `json:"signed_at,omitempty"`
+	SignerKeyID string     `json:"signer_key_id,omitempty"` // Trusted key that produced the first accepted signature
+
+	PlanJSONHash string `json:"plan_json_hash,omitempty"` // Set when submitted with plan JSON; reviewers sign the binding of both digests
+
+	Risk *RiskAssessment `json:"risk,omitempty"` // Blast radius scored at submission; orders the pending queue and can raise the quorum
+
+	Environment       string     `json:"environment,omitempty"`
+	EnvironmentSource string     `json:"environment_source,omitempty"` // workspace, credential or unverified
+	RequiredApprovals int        `json:"required_approvals,omitempty"` // Quorum of distinct reviewer keys
+	Approvals         []Approval `json:"approvals,omitempty"`
+
+	RejectionReason string `json:"rejection_reason,omitempty"` // Set when Status is rejected
+
+	ExpiresAt *time.Time `json:"expires_at,omitempty"` // Pending submissions expire at this time
 }
 
 // SigningServiceConfig holds configuration for the signing service
 type SigningServiceConfig struct {
-	StorageDir string
-	Port       int
-	AdminKey   string // Path to admin public key for verification
+	StorageDir    string // Directory, bolt:///path/to.db or s3://bucket/prefix (see OpenStore)
+	Port          int
+	AdminKey      string                // Path to admin public key for verification; also signs lockdown releases
+	BreakGlassKey string                // Public key rebuilt from M-of-N break-glass shares to release lockdowns
+	AuditKey      string                // Private key that signs audit log entries; empty leaves them unsigned
+	TlogKey       string                // Private key that signs transparency log checkpoints; empty disables the log
+	TSAURL        string                // RFC 3161 timestamp authority that counter-signs approvals
+	TSACert       string                // Certificate chain of the built-in timestamp authority (with TSAKey; excludes TSAURL)
+	TSAKey        string                // Private key of the built-in timestamp authority
+	TrustedKeys   []string              // Additional reviewer public keys (files or directories of *.pub / *.pem)
+	Auth          *AuthConfig           // Authentication and roles; nil disables authentication
+	Quorum        QuorumConfig          // Reviewer signatures required per environment (default 1)
+	RiskQuorum    RiskQuorumConfig      // Reviewer signatures required at least per risk level
+	MaxAge        verifier.MaxAgeConfig // How long submissions stay reviewable per environment (default 24h)
+	MaxPlanSize   int64                 // Largest plan accepted, in bytes; 0 means DefaultMaxPlanSize
+
+	// PolicyDir holds the config.yaml whose protected_resources list the
+	// resources an approval may only destroy with an acknowledgement;
+	// without one, databases and encryption keys are protected
+	PolicyDir string
 }
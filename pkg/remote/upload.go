@@ -1,12 +1,21 @@
 package remote
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
-	"os"
+	"strings"
+	"time"
 )
 
+// maxSignatureSize bounds uploaded signatures
+const maxSignatureSize = 64 << 10
+
+// maxAttestationSize bounds uploaded attestations
+const maxAttestationSize = 1 << 20
+
 // handleUploadSignature handles signature upload from admin
 func (s *SigningService) handleUploadSignature(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -22,32 +31,129 @@ func (s *SigningService) handleUploadSignature(w http.ResponseWriter, r *http.Re
 	}
 
 	// Get submission to verify it exists
-	_, err := s.storage.GetSubmission(id)
+	submission, err := s.storage.GetSubmission(id)
 	if err != nil {
 		http.Error(w, "Submission not found", http.StatusNotFound)
 		return
 	}
+	if s.expireIfDue(submission) || submission.Status == "expired" {
+		s.audit(r, AuditSignRefused, "", id, map[string]string{"reason": "submission expired"})
+		http.Error(w, fmt.Sprintf("Submission %s expired at %s; submit the plan again", id, submission.ExpiresAt.UTC().Format(time.RFC3339)), http.StatusConflict)
+		return
+	}
+	if submission.Status != "pending" {
+		http.Error(w, fmt.Sprintf("Submission %s is already %s (reviewed by %s)", id, submission.Status, submission.ReviewedBy), http.StatusConflict)
+		return
+	}
 
-	// Save signature file
-	sigPath := s.storage.GetSignaturePath(id)
-	sigFile, err := os.Create(sigPath)
+	// Read the signature (base64 or raw; a few KB at most)
+	sig, err := io.ReadAll(io.LimitReader(r.Body, maxSignatureSize))
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to create signature file: %v", err), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("Failed to read signature: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	// Only a signature over the stored plan by a trusted reviewer key approves it
+	key, err := s.verifyPlanSignature(submission, sig)
+	if err != nil {
+		s.audit(r, AuditSignRefused, "", id, map[string]string{"reason": err.Error()})
+		http.Error(w, fmt.Sprintf("Signature rejected: %v", err), http.StatusForbidden)
+		return
+	}
+
+	// Separation of duties: no self-approval, one approval per key and caller
+	uploader := ""
+	if p := PrincipalFromContext(r.Context()); p != nil {
+		uploader = p.Subject
+	}
+	if err := checkApprover(submission, key, uploader); err != nil {
+		s.audit(r, AuditSignRefused, "", id, map[string]string{"key": key.Identity(), "reason": err.Error()})
+		http.Error(w, fmt.Sprintf("Approval refused: %v", err), http.StatusForbidden)
 		return
 	}
-	defer sigFile.Close()
 
-	if _, err := io.Copy(sigFile, r.Body); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to write signature: %v", err), http.StatusInternalServerError)
+	// Destroying protected resources needs the approver's signed acknowledgement
+	if err := s.checkAcknowledgement(submission, key); err != nil {
+		s.audit(r, AuditSignRefused, "", id, map[string]string{"key": key.Identity(), "reason": err.Error()})
+		http.Error(w, fmt.Sprintf("Approval refused: %v", err), http.StatusForbidden)
 		return
 	}
 
-	// Mark as signed
-	if err := s.MarkSigned(id, "admin"); err != nil {
+	if err := s.RecordApproval(submission, key, uploader, sig); err != nil {
+		if errors.Is(err, ErrConflict) {
+			s.audit(r, AuditSignRefused, "", id, map[string]string{"key": key.Identity(), "reason": err.Error()})
+			http.Error(w, fmt.Sprintf("Approval refused: %v", err), http.StatusConflict)
+			return
+		}
 		http.Error(w, fmt.Sprintf("Failed to update status: %v", err), http.StatusInternalServerError)
 		return
 	}
+	details := map[string]string{
+		"key":       key.Identity(),
+		"approvals": fmt.Sprintf("%d/%d", len(submission.Approvals), submission.RequiredApprovals),
+		"status":    submission.Status,
+	}
+	if logIndex := submission.Approvals[len(submission.Approvals)-1].LogIndex; logIndex != nil {
+		details["log_index"] = fmt.Sprintf("%d", *logIndex)
+	}
+	s.audit(r, AuditSign, "", id, details)
+
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "Signature from %s accepted for submission %s (%d/%d approvals, status %s)\n",
+		key.Name, id, len(submission.Approvals), submission.RequiredApprovals, submission.Status)
+}
+
+// handleUploadAttestation stores a policy or provenance attestation for a
+// pending submission: POST /upload-attestation/{id}/{kind}. Reviewers upload
+// attestations before the signature so they are in place once the plan is
+// approved; `terrasign verify --strict` checks them against the plan.
+func (s *SigningService) handleUploadAttestation(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, kind, ok := strings.Cut(r.URL.Path[len("/upload-attestation/"):], "/")
+	if !ok || id == "" {
+		http.Error(w, "Invalid path (use /upload-attestation/{id}/{kind})", http.StatusBadRequest)
+		return
+	}
+	if _, err := attestationName(kind); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	submission, err := s.storage.GetSubmission(id)
+	if err != nil {
+		http.Error(w, "Submission not found", http.StatusNotFound)
+		return
+	}
+	s.expireIfDue(submission)
+	if submission.Status != "pending" {
+		http.Error(w, fmt.Sprintf("Submission %s is already %s", id, submission.Status), http.StatusConflict)
+		return
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxAttestationSize+1))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to read attestation: %v", err), http.StatusBadRequest)
+		return
+	}
+	if len(data) > maxAttestationSize {
+		http.Error(w, "Attestation too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if !json.Valid(data) {
+		http.Error(w, "Attestation is not valid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.storage.SaveAttestation(id, kind, data); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to store attestation: %v", err), http.StatusInternalServerError)
+		return
+	}
+	s.audit(r, AuditAttest, "", id, map[string]string{"kind": kind})
 
 	w.WriteHeader(http.StatusOK)
-	fmt.Fprintf(w, "Signature uploaded successfully for submission %s\n", id)
+	fmt.Fprintf(w, "%s attestation stored for submission %s\n", kind, id)
 }
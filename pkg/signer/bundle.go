@@ -0,0 +1,75 @@
+package signer
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"fmt"
+	"os"
+
+	protobundle "github.com/sigstore/protobuf-specs/gen/pb-go/bundle/v1"
+	protocommon "github.com/sigstore/protobuf-specs/gen/pb-go/common/v1"
+	protorekor "github.com/sigstore/protobuf-specs/gen/pb-go/rekor/v1"
+	"github.com/sulakshanakarunarathne/terrasign/pkg/attestation"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// bundleMediaType is the media type cosign sign-blob --bundle writes
+const bundleMediaType = "application/vnd.dev.sigstore.bundle.v0.3+json"
+
+// MarshalBundle encodes a Sigstore bundle for a key-based message signature,
+// with an optional transparency log entry and RFC 3161 timestamp response.
+// Without either the layout matches what
+// `cosign sign-blob --bundle --tlog-upload=false` produces, so existing
+// tooling can keep reading it.
+func MarshalBundle(digest [sha256.Size]byte, sig []byte, pub crypto.PublicKey, entry *protorekor.TransparencyLogEntry, timestamp []byte) ([]byte, error) {
+	hint, err := attestation.KeyID(pub)
+	if err != nil {
+		return nil, err
+	}
+
+	bundle := &protobundle.Bundle{
+		MediaType: bundleMediaType,
+		VerificationMaterial: &protobundle.VerificationMaterial{
+			Content: &protobundle.VerificationMaterial_PublicKey{
+				PublicKey: &protocommon.PublicKeyIdentifier{Hint: hint},
+			},
+		},
+		Content: &protobundle.Bundle_MessageSignature{
+			MessageSignature: &protocommon.MessageSignature{
+				MessageDigest: &protocommon.HashOutput{
+					Algorithm: protocommon.HashAlgorithm_SHA2_256,
+					Digest:    digest[:],
+				},
+				Signature: sig,
+			},
+		},
+	}
+	if entry != nil {
+		bundle.VerificationMaterial.TlogEntries = []*protorekor.TransparencyLogEntry{entry}
+	}
+	if timestamp != nil {
+		bundle.VerificationMaterial.TimestampVerificationData = &protobundle.TimestampVerificationData{
+			Rfc3161Timestamps: []*protocommon.RFC3161SignedTimestamp{{SignedTimestamp: timestamp}},
+		}
+	}
+
+	data, err := protojson.Marshal(bundle)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal bundle: %w", err)
+	}
+	return data, nil
+}
+
+// writeBundle writes a Sigstore bundle for a key-based message signature
+func writeBundle(bundlePath string, digest [sha256.Size]byte, sig []byte, pub crypto.PublicKey, entry *protorekor.TransparencyLogEntry, timestamp []byte) error {
+	data, err := MarshalBundle(digest, sig, pub, entry, timestamp)
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(bundlePath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,33 @@
+package signer
+
+import (
+	"crypto"
+	"fmt"
+
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+	"github.com/sigstore/sigstore/pkg/signature"
+)
+
+// PassFunc returns the password for an encrypted private key.
+// The boolean argument reports whether the caller wants the password confirmed.
+type PassFunc = cryptoutils.PassFunc
+
+// DefaultPassFunc reads the key password the same way the cosign CLI does:
+// from COSIGN_PASSWORD if set, otherwise from the terminal or piped stdin.
+var DefaultPassFunc PassFunc = cryptoutils.GetPasswordFromStdIn
+
+// LoadSigner loads a PEM-encoded private key and returns a signer for it.
+// Plain PKCS#8/SEC 1 keys and encrypted cosign/sigstore keys are supported;
+// passFunc is only called when the key is encrypted.
+func LoadSigner(keyPath string, passFunc PassFunc) (signature.SignerVerifier, error) {
+	if passFunc == nil {
+		passFunc = DefaultPassFunc
+	}
+
+	sv, err := signature.LoadSignerVerifierFromPEMFile(keyPath, crypto.SHA256, passFunc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load private key %s: %w", keyPath, err)
+	}
+
+	return sv, nil
+}
@@ -0,0 +1,105 @@
+package signer
+
+import (
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"os"
+
+	"github.com/sigstore/sigstore-go/pkg/sign"
+	"github.com/sigstore/sigstore/pkg/oauthflow"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// Public Sigstore instance used for keyless signing by default
+const (
+	DefaultFulcioURL  = "https://fulcio.sigstore.dev"
+	DefaultRekorURL   = "https://rekor.sigstore.dev"
+	DefaultOIDCIssuer = "https://oauth2.sigstore.dev/auth"
+)
+
+// oidcClientID is the OAuth client the public Sigstore issuer accepts
+const oidcClientID = "sigstore"
+
+// signKeyless signs the plan message in-process with an ephemeral key,
+// certified by Fulcio for the signer's OIDC identity and logged in Rekor.
+// It writes the Sigstore bundle, the base64 signature and the certificate
+// next to the plan.
+func signKeyless(message []byte, sigFile, bundleFile, certFile string, opts Options) error {
+	token, err := identityToken(opts)
+	if err != nil {
+		return err
+	}
+
+	keypair, err := sign.NewEphemeralKeypair(nil)
+	if err != nil {
+		return fmt.Errorf("failed to generate ephemeral key: %w", err)
+	}
+
+	fulcioURL := opts.FulcioURL
+	if fulcioURL == "" {
+		fulcioURL = DefaultFulcioURL
+	}
+	rekorURL := opts.RekorURL
+	if rekorURL == "" {
+		rekorURL = DefaultRekorURL
+	}
+
+	bundle, err := sign.Bundle(&sign.PlainData{Data: message}, keypair, sign.BundleOptions{
+		CertificateProvider:        sign.NewFulcio(&sign.FulcioOptions{BaseURL: fulcioURL}),
+		CertificateProviderOptions: &sign.CertificateProviderOptions{IDToken: token},
+		TransparencyLogs:           []sign.Transparency{sign.NewRekor(&sign.RekorOptions{BaseURL: rekorURL})},
+	})
+	if err != nil {
+		return fmt.Errorf("keyless signing failed: %w", err)
+	}
+	// Verification requires the Rekor entry, so a bundle without one is useless
+	entries := bundle.GetVerificationMaterial().GetTlogEntries()
+	if len(entries) == 0 {
+		return fmt.Errorf("keyless signing failed: Rekor returned no transparency log entry")
+	}
+	fmt.Printf("[OK] Signature logged in Rekor at index %d\n", entries[0].GetLogIndex())
+
+	data, err := protojson.Marshal(bundle)
+	if err != nil {
+		return fmt.Errorf("failed to marshal bundle: %w", err)
+	}
+	if err := os.WriteFile(bundleFile, data, 0644); err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+
+	sig := bundle.GetMessageSignature().GetSignature()
+	if err := os.WriteFile(sigFile, []byte(base64.StdEncoding.EncodeToString(sig)), 0644); err != nil {
+		return fmt.Errorf("failed to write signature file: %w", err)
+	}
+
+	cert := pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: bundle.GetVerificationMaterial().GetCertificate().GetRawBytes(),
+	})
+	if err := os.WriteFile(certFile, cert, 0644); err != nil {
+		return fmt.Errorf("failed to write certificate file: %w", err)
+	}
+
+	return nil
+}
+
+// identityToken returns the OIDC token Fulcio certifies: the one passed in
+// (CI systems provide it, e.g. SIGSTORE_ID_TOKEN), or one obtained through
+// the issuer's browser flow
+func identityToken(opts Options) (string, error) {
+	if opts.IDToken != "" {
+		return opts.IDToken, nil
+	}
+
+	issuer := opts.OIDCIssuer
+	if issuer == "" {
+		issuer = DefaultOIDCIssuer
+	}
+	fmt.Printf("Requesting an identity token from %s...\n", issuer)
+	token, err := oauthflow.OIDConnect(issuer, oidcClientID, "", "", oauthflow.DefaultIDTokenGetter)
+	if err != nil {
+		return "", fmt.Errorf("failed to get identity token: %w", err)
+	}
+	return token.RawString, nil
+}
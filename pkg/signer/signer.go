@@ -1,30 +1,109 @@
 package signer
 
 import (
-	"encoding/json"
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
 	"fmt"
 	"os"
-	"os/exec"
 	"time"
 
+	protorekor "github.com/sigstore/protobuf-specs/gen/pb-go/rekor/v1"
+	"github.com/sigstore/sigstore/pkg/signature"
+	"github.com/sulakshanakarunarathne/terrasign/pkg/planfile"
 	"github.com/sulakshanakarunarathne/terrasign/pkg/policy"
 	"github.com/sulakshanakarunarathne/terrasign/pkg/provenance"
+	"github.com/sulakshanakarunarathne/terrasign/pkg/tlog"
+	"github.com/sulakshanakarunarathne/terrasign/pkg/tsa"
 )
 
-// Sign signs a Terraform plan using Cosign
+// Sign signs a Terraform plan using Cosign-compatible signatures
 func Sign(planPath, keyPath string) error {
 	return SignWithOptions(planPath, keyPath, false)
 }
 
+// Options controls how a plan is signed
+type Options struct {
+	KeyPath    string   // Private key path; empty means keyless (OIDC)
+	SkipPolicy bool     // Skip policy evaluation (already done during submission)
+	PassFunc   PassFunc // Password callback for encrypted keys; defaults to DefaultPassFunc
+
+	// PolicyThreshold is the lowest severity that blocks signing.
+	// Empty uses enforcement_threshold from ./policies/config.yaml (default: error).
+	PolicyThreshold policy.Severity
+
+	// TlogUpload logs the signature (a hashedrekord entry body) in a
+	// transparency log and returns the entry with its inclusion proof, which
+	// is embedded in the bundle. Nil leaves the signature unlogged.
+	TlogUpload func(body []byte) (*protorekor.TransparencyLogEntry, error)
+
+	// Timestamper has an RFC 3161 timestamp authority counter-sign the
+	// signature; the response is embedded in the bundle. Nil skips it.
+	Timestamper tsa.Timestamper
+
+	// PlanJSON is the `terraform show -json` rendering of the plan. It must
+	// match the binary plan; the signature and attestations then cover both
+	// digests. The plan itself may also be JSON, signed on its own.
+	PlanJSON string
+
+	// Keyless signing: Fulcio and Rekor URLs (default the public Sigstore
+	// instance), and the OIDC token to certify. Without IDToken the browser
+	// flow of OIDCIssuer is used.
+	FulcioURL  string
+	RekorURL   string
+	IDToken    string
+	OIDCIssuer string
+
+	// Acknowledgement approves destroying or replacing the protected
+	// resources it lists; it is recorded in the policy attestation. Without
+	// it, signing a plan that destroys a protected resource fails, even
+	// with SkipPolicy.
+	Acknowledgement *policy.Acknowledgement
+}
+
 // SignWithOptions signs a Terraform plan with additional options
 func SignWithOptions(planPath, keyPath string, skipPolicy bool) error {
+	return SignPlan(planPath, Options{
+		KeyPath:    keyPath,
+		SkipPolicy: skipPolicy,
+	})
+}
+
+// SignPlan evaluates policies, generates provenance and signs a Terraform plan
+func SignPlan(planPath string, opts Options) error {
 	fmt.Printf("Signing plan at %s\n", planPath)
 
+	in, err := planfile.NewInput(planPath, opts.PlanJSON)
+	if err != nil {
+		return err
+	}
+	if in.Paired() {
+		// Only JSON rendered from this plan may be bound to it
+		if _, _, err := in.Load(); err != nil {
+			return err
+		}
+		fmt.Printf("[OK] Plan JSON %s matches the binary plan\n", in.JSON)
+	}
+
+	// Load the key up front so attestations and the plan are signed by the
+	// same key. Keyless signing has no local key, so attestations stay unsigned.
+	var sv signature.SignerVerifier
+	if opts.KeyPath != "" {
+		sv, err = LoadSigner(opts.KeyPath, opts.PassFunc)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Step 1: Evaluate policies (skip if already done during submission)
-	if !skipPolicy {
+	policyEngine := policy.NewPolicyEngine("./policies")
+	policyEngine.SetAcknowledgement(opts.Acknowledgement)
+	if !opts.SkipPolicy {
 		fmt.Println("Evaluating security policies...")
-		policyEngine := policy.NewPolicyEngine("./policies")
-		policyResult, err := policyEngine.Evaluate(planPath)
+		if opts.PolicyThreshold != "" {
+			policyEngine.SetThreshold(opts.PolicyThreshold)
+		}
+		policyResult, err := policyEngine.EvaluateInput(in)
 		if err != nil {
 			return fmt.Errorf("policy evaluation failed: %w", err)
 		}
@@ -32,18 +111,49 @@ func SignWithOptions(planPath, keyPath string, skipPolicy bool) error {
 		if !policyResult.Passed {
 			fmt.Println("\n[ERROR] POLICY VIOLATIONS DETECTED:")
 			for _, violation := range policyResult.Violations {
-				fmt.Printf("  - [%s] %s\n", violation.Policy, violation.Message)
+				printViolation("  -", violation)
 			}
-			return fmt.Errorf("plan failed %d policy check(s) - signing aborted", len(policyResult.Violations))
+			return fmt.Errorf("plan failed %d policy check(s) at or above %s severity - signing aborted",
+				len(policyResult.Violations), policyResult.Threshold)
+		}
+		fmt.Printf("[OK] All policy checks passed (threshold: %s)\n", policyResult.Threshold)
+		for _, warning := range policyResult.Warnings {
+			printViolation("  [WARN]", warning)
+		}
+		for _, waived := range policyResult.Waived {
+			fmt.Printf("  [WAIVED] [%s] %s - %s (approved by %s, expires %s)\n",
+				waived.Policy, waived.Address, waived.Waiver.Justification, waived.Waiver.Approver, waived.Waiver.Expires)
 		}
-		fmt.Println("[OK] All policy checks passed")
+		printAcknowledged(policyResult)
 
 		// Save policy attestation
-		if err := policyEngine.SaveAttestation(planPath, policyResult); err != nil {
+		if err := policyEngine.SaveAttestation(in, policyResult, sv); err != nil {
 			return fmt.Errorf("failed to save policy attestation: %w", err)
 		}
 	} else {
 		fmt.Println("[SKIP] Policy evaluation (already done during submission)")
+
+		// Destroying protected resources always needs an acknowledgement
+		protectResult, err := policyEngine.EvaluateProtection(in)
+		if err != nil {
+			return fmt.Errorf("protected resource check failed: %w", err)
+		}
+		if !protectResult.Passed {
+			fmt.Println("\n[ERROR] PROTECTED RESOURCES WOULD BE DESTROYED:")
+			for _, violation := range protectResult.Violations {
+				printViolation("  -", violation)
+			}
+			return fmt.Errorf("plan destroys or replaces %d protected resource(s) without acknowledgement - signing aborted",
+				len(protectResult.Violations))
+		}
+		printAcknowledged(protectResult)
+
+		// Record the acknowledgement in a signed attestation
+		if protectResult.Acknowledgement != nil {
+			if err := policyEngine.SaveAttestation(in, protectResult, sv); err != nil {
+				return fmt.Errorf("failed to save policy attestation: %w", err)
+			}
+		}
 	}
 
 	// Step 2: Generate SLSA provenance
@@ -55,85 +165,121 @@ func SignWithOptions(planPath, keyPath string, skipPolicy bool) error {
 	
 	provenanceGen := provenance.NewProvenanceGenerator(builderID)
 	buildStartTime := time.Now().Add(-5 * time.Minute) // Approximate
-	slsaProvenance, err := provenanceGen.Generate(planPath, buildStartTime)
+	slsaProvenance, err := provenanceGen.Generate(in, buildStartTime)
 	if err != nil {
 		return fmt.Errorf("provenance generation failed: %w", err)
 	}
 
-	if err := provenanceGen.Save(slsaProvenance, planPath); err != nil {
+	if err := provenanceGen.Save(slsaProvenance, in, sv); err != nil {
 		return fmt.Errorf("failed to save provenance: %w", err)
 	}
 	fmt.Println("[OK] Provenance generated")
 
-	// Step 3: Sign with Cosign
+	// Step 3: Sign with Sigstore. A plan paired with its JSON is signed as the
+	// binding of both digests.
 	fmt.Println("Signing with cryptographic signature...")
-	if keyPath != "" {
-		fmt.Printf("Signing with key: %s\n", keyPath)
+	base := in.Path()
+	bundleFile := base + ".bundle"
+	sigFile := base + ".sig"
+
+	message, err := in.Message()
+	if err != nil {
+		return err
+	}
+
+	if opts.KeyPath != "" {
+		fmt.Printf("Signing with key: %s\n", opts.KeyPath)
+		if err := signWithKey(message, sv, sigFile, bundleFile, opts); err != nil {
+			return err
+		}
 	} else {
+		if opts.TlogUpload != nil || opts.Timestamper != nil {
+			return fmt.Errorf("the local transparency log and timestamp authority need key-based signing; keyless signatures are logged in Rekor")
+		}
 		fmt.Println("Signing with keyless (OIDC)")
+		if err := signKeyless(message, sigFile, bundleFile, base+".crt", opts); err != nil {
+			return err
+		}
 	}
 
-	// Construct the cosign command
-	// We must use --bundle to avoid interactive prompts or config errors.
-	// We will extract the signature from the bundle afterwards.
-	bundleFile := planPath + ".bundle"
-	sigFile := planPath + ".sig"
-
-	// Minimal args to generate bundle without Tlog upload (for key-based)
-	// Note: --tlog-upload=false might require bundle in recent versions
-	args := []string{"sign-blob", "--yes",
-		"--bundle", bundleFile,
-		"--tlog-upload=false",
+	fmt.Printf("Successfully signed plan.\nSignature: %s\nBundle: %s\n", sigFile, bundleFile)
+	if in.Paired() {
+		fmt.Printf("Plan JSON: %s (bound to the signature)\n", in.JSON)
 	}
+	fmt.Printf("Policy Attestation: %s\n", base+".policy")
+	fmt.Printf("SLSA Provenance: %s\n", base+".provenance")
+	return nil
+}
 
-	if keyPath != "" {
-		args = append(args, "--key", keyPath)
+// printViolation prints a policy finding with its severity and remediation hint
+func printViolation(prefix string, v policy.PolicyViolation) {
+	fmt.Printf("%s [%s] [%s] %s\n", prefix, v.Severity, v.Policy, v.Message)
+	if v.Remediation != "" {
+		fmt.Printf("      fix: %s\n", v.Remediation)
 	}
+}
 
-	args = append(args, planPath)
-
-	cmd := exec.Command("cosign", args...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("cosign signing failed: %w", err)
+// printAcknowledged prints the protected resources a plan destroys or
+// replaces with the reviewer's acknowledgement
+func printAcknowledged(result *policy.EvaluateResult) {
+	if result.Acknowledgement == nil {
+		return
 	}
-
-	// Extract signature from bundle
-	if err := extractSignatureFromBundle(bundleFile, sigFile); err != nil {
-		return fmt.Errorf("failed to extract signature from bundle: %w", err)
+	by := result.Acknowledgement.By
+	if by == "" {
+		by = "the signer"
+	}
+	for _, ack := range result.Acknowledged {
+		fmt.Printf("  [ACKNOWLEDGED] [%s] %s (acknowledged by %s)\n", ack.Policy, ack.Message, by)
 	}
-
-	fmt.Printf("Successfully signed plan.\nSignature: %s\nBundle: %s\n", sigFile, bundleFile)
-	fmt.Printf("Policy Attestation: %s\n", planPath+".policy")
-	fmt.Printf("SLSA Provenance: %s\n", planPath+".provenance")
-	return nil
 }
 
-// extractSignatureFromBundle parses the bundle and writes the signature to a file
-func extractSignatureFromBundle(bundlePath, sigPath string) error {
-	data, err := os.ReadFile(bundlePath)
+// signWithKey signs the plan message in-process with a local private key and
+// writes the base64 signature and a Sigstore bundle next to the plan,
+// logging and timestamping the signature first when the options ask for it
+func signWithKey(planData []byte, sv signature.SignerVerifier, sigFile, bundleFile string, opts Options) error {
+	sig, err := sv.SignMessage(bytes.NewReader(planData))
+	if err != nil {
+		return fmt.Errorf("signing failed: %w", err)
+	}
+
+	pub, err := sv.PublicKey()
 	if err != nil {
-		return fmt.Errorf("failed to read bundle: %w", err)
+		return fmt.Errorf("failed to get public key: %w", err)
 	}
+	digest := sha256.Sum256(planData)
 
-	var bundle struct {
-		MessageSignature struct {
-			Signature string `json:"signature"`
-		} `json:"messageSignature"`
+	var entry *protorekor.TransparencyLogEntry
+	if opts.TlogUpload != nil {
+		rekord, err := tlog.NewHashedRekord(digest[:], sig, pub)
+		if err != nil {
+			return err
+		}
+		body, err := rekord.Canonical()
+		if err != nil {
+			return err
+		}
+		if entry, err = opts.TlogUpload(body); err != nil {
+			return fmt.Errorf("failed to log signature in the transparency log: %w", err)
+		}
+		fmt.Printf("[OK] Signature logged in the transparency log at index %d\n", entry.LogIndex)
 	}
 
-	if err := json.Unmarshal(data, &bundle); err != nil {
-		return fmt.Errorf("failed to parse bundle JSON: %w", err)
+	var timestamp []byte
+	if opts.Timestamper != nil {
+		if timestamp, err = opts.Timestamper.Timestamp(sig); err != nil {
+			return fmt.Errorf("failed to timestamp signature: %w", err)
+		}
+		fmt.Println("[OK] Signature timestamped by the timestamp authority")
 	}
 
-	if bundle.MessageSignature.Signature == "" {
-		return fmt.Errorf("signature not found in bundle")
+	if err := writeBundle(bundleFile, digest, sig, pub, entry, timestamp); err != nil {
+		return err
 	}
 
-	// Write signature to file (it is already base64 encoded in JSON)
-	if err := os.WriteFile(sigPath, []byte(bundle.MessageSignature.Signature), 0644); err != nil {
+	// Signature file holds the base64 signature, as cosign writes it
+	encoded := base64.StdEncoding.EncodeToString(sig)
+	if err := os.WriteFile(sigFile, []byte(encoded), 0644); err != nil {
 		return fmt.Errorf("failed to write signature file: %w", err)
 	}
 
@@ -11,7 +11,7 @@ import (
 
 // Execute wraps the terraform command, intercepting "apply" to enforce verification.
 // args are the arguments intended for terraform (e.g., "apply", "tfplan").
-func Execute(args []string, keyPath, identity, issuer string) error {
+func Execute(args []string, opts verifier.Options) error {
 	if len(args) == 0 {
 		return fmt.Errorf("no arguments provided to terraform wrapper")
 	}
@@ -35,12 +35,14 @@ func Execute(args []string, keyPath, identity, issuer string) error {
 		if planFile != "" {
 			fmt.Printf("Intercepted 'apply' command. Verifying plan: %s\n", planFile)
 			
-			if keyPath == "" && identity == "" {
+			if opts.KeyPath == "" && opts.Identity == "" {
 				return fmt.Errorf("either --key or --identity must be provided for verification")
 			}
             
             // Verification logic:
-			if err := verifier.Verify(planFile, keyPath, identity, issuer); err != nil {
+			report, err := verifier.VerifyWithOptions(planFile, opts)
+			report.Print(os.Stdout)
+			if err != nil {
 				return fmt.Errorf("PLAN VERIFICATION FAILED: %v. Aborting apply.", err)
 			}
 		} else {
@@ -0,0 +1,122 @@
+package tlog
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+	"github.com/sigstore/sigstore/pkg/signature"
+)
+
+// Checkpoint is a signed tree head in the signed note format Rekor uses:
+//
+//	<origin>
+//	<tree size>
+//	<base64 root hash>
+//
+//	— <key name> <base64(4-byte key hint || signature)>
+type Checkpoint struct {
+	Origin   string
+	Size     uint64
+	RootHash []byte
+}
+
+// body returns the signed text of the checkpoint
+func (c Checkpoint) body() string {
+	return fmt.Sprintf("%s\n%d\n%s\n", c.Origin, c.Size, base64.StdEncoding.EncodeToString(c.RootHash))
+}
+
+// keyHint returns the note key hint: the first 4 bytes of the SHA-256 of the
+// DER public key
+func keyHint(pub crypto.PublicKey) ([]byte, error) {
+	der, err := cryptoutils.MarshalPublicKeyToDER(pub)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal public key: %w", err)
+	}
+	sum := sha256.Sum256(der)
+	return sum[:4], nil
+}
+
+// SignCheckpoint signs a checkpoint and returns the note envelope. The key
+// name may not contain spaces.
+func SignCheckpoint(c Checkpoint, keyName string, signer signature.SignerVerifier) (string, error) {
+	if keyName == "" || strings.ContainsAny(keyName, " \t\n+") {
+		return "", fmt.Errorf("invalid checkpoint key name %q", keyName)
+	}
+
+	pub, err := signer.PublicKey()
+	if err != nil {
+		return "", fmt.Errorf("failed to get public key: %w", err)
+	}
+	hint, err := keyHint(pub)
+	if err != nil {
+		return "", err
+	}
+
+	body := c.body()
+	sig, err := signer.SignMessage(strings.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to sign checkpoint: %w", err)
+	}
+
+	return fmt.Sprintf("%s\n— %s %s\n", body, keyName, base64.StdEncoding.EncodeToString(append(hint, sig...))), nil
+}
+
+// VerifyCheckpoint checks a note envelope's signature by the log key and
+// returns the checkpoint it signs
+func VerifyCheckpoint(envelope string, logKey signature.Verifier) (*Checkpoint, error) {
+	body, sigs, ok := strings.Cut(envelope, "\n\n")
+	if !ok {
+		return nil, fmt.Errorf("malformed checkpoint: no signature block")
+	}
+	body += "\n"
+
+	pub, err := logKey.PublicKey()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get log public key: %w", err)
+	}
+	hint, err := keyHint(pub)
+	if err != nil {
+		return nil, err
+	}
+
+	verified := false
+	for _, line := range strings.Split(strings.TrimSpace(sigs), "\n") {
+		fields := strings.Fields(strings.TrimPrefix(line, "— "))
+		if len(fields) != 2 {
+			continue
+		}
+		// The key hint, not the name, identifies the key
+		raw, err := base64.StdEncoding.DecodeString(fields[1])
+		if err != nil || len(raw) < 5 || !bytes.Equal(raw[:4], hint) {
+			continue
+		}
+		if logKey.VerifySignature(bytes.NewReader(raw[4:]), strings.NewReader(body)) == nil {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, fmt.Errorf("checkpoint is not signed by the log key")
+	}
+
+	lines := strings.Split(strings.TrimSuffix(body, "\n"), "\n")
+	if len(lines) < 3 {
+		return nil, fmt.Errorf("malformed checkpoint: expected origin, size and root hash")
+	}
+	size, err := strconv.ParseUint(lines[1], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("malformed checkpoint size %q", lines[1])
+	}
+	root, err := base64.StdEncoding.DecodeString(lines[2])
+	if err != nil {
+		return nil, fmt.Errorf("malformed checkpoint root hash: %w", err)
+	}
+
+	return &Checkpoint{Origin: lines[0], Size: size, RootHash: root}, nil
+}
@@ -0,0 +1,179 @@
+package tlog
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	protorekor "github.com/sigstore/protobuf-specs/gen/pb-go/rekor/v1"
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+	"github.com/sigstore/sigstore/pkg/signature"
+	"github.com/transparency-dev/merkle/proof"
+)
+
+// Entry kind logged for signatures, as in Rekor
+const (
+	KindHashedRekord    = "hashedrekord"
+	HashedRekordVersion = "0.0.1"
+)
+
+// HashedRekord is a Rekor hashedrekord entry: a signature over a SHA-256
+// digest and the public key that made it. Fields are declared in key order so
+// json.Marshal produces the canonical body.
+type HashedRekord struct {
+	APIVersion string           `json:"apiVersion"`
+	Kind       string           `json:"kind"`
+	Spec       HashedRekordSpec `json:"spec"`
+}
+
+// HashedRekordSpec is the spec of a hashedrekord entry
+type HashedRekordSpec struct {
+	Data struct {
+		Hash struct {
+			Algorithm string `json:"algorithm"`
+			Value     string `json:"value"` // hex
+		} `json:"hash"`
+	} `json:"data"`
+	Signature struct {
+		Content   string `json:"content"` // base64 signature
+		PublicKey struct {
+			Content string `json:"content"` // base64 PEM public key
+		} `json:"publicKey"`
+	} `json:"signature"`
+}
+
+// NewHashedRekord describes a signature over a SHA-256 digest
+func NewHashedRekord(digest, sig []byte, pub crypto.PublicKey) (*HashedRekord, error) {
+	pemKey, err := cryptoutils.MarshalPublicKeyToPEM(pub)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal public key: %w", err)
+	}
+
+	e := &HashedRekord{APIVersion: HashedRekordVersion, Kind: KindHashedRekord}
+	e.Spec.Data.Hash.Algorithm = "sha256"
+	e.Spec.Data.Hash.Value = hex.EncodeToString(digest)
+	e.Spec.Signature.Content = base64.StdEncoding.EncodeToString(sig)
+	e.Spec.Signature.PublicKey.Content = base64.StdEncoding.EncodeToString(pemKey)
+	return e, nil
+}
+
+// ParseHashedRekord parses an entry body and checks its kind
+func ParseHashedRekord(body []byte) (*HashedRekord, error) {
+	var e HashedRekord
+	if err := json.Unmarshal(body, &e); err != nil {
+		return nil, fmt.Errorf("failed to parse entry: %w", err)
+	}
+	if e.Kind != KindHashedRekord || e.APIVersion != HashedRekordVersion {
+		return nil, fmt.Errorf("unsupported entry kind %s %s", e.Kind, e.APIVersion)
+	}
+	if e.Spec.Data.Hash.Algorithm != "sha256" {
+		return nil, fmt.Errorf("unsupported hash algorithm %q", e.Spec.Data.Hash.Algorithm)
+	}
+	return &e, nil
+}
+
+// Canonical returns the body stored in the log
+func (e *HashedRekord) Canonical() ([]byte, error) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode entry: %w", err)
+	}
+	return data, nil
+}
+
+// Digest returns the signed SHA-256 digest
+func (e *HashedRekord) Digest() ([]byte, error) {
+	digest, err := hex.DecodeString(e.Spec.Data.Hash.Value)
+	if err != nil || len(digest) != sha256.Size {
+		return nil, fmt.Errorf("invalid sha256 digest %q", e.Spec.Data.Hash.Value)
+	}
+	return digest, nil
+}
+
+// Signature returns the raw signature
+func (e *HashedRekord) Signature() ([]byte, error) {
+	sig, err := base64.StdEncoding.DecodeString(e.Spec.Signature.Content)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature encoding: %w", err)
+	}
+	return sig, nil
+}
+
+// PublicKey returns the key that made the signature
+func (e *HashedRekord) PublicKey() (crypto.PublicKey, error) {
+	pemKey, err := base64.StdEncoding.DecodeString(e.Spec.Signature.PublicKey.Content)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key encoding: %w", err)
+	}
+	pub, err := cryptoutils.UnmarshalPEMToPublicKey(pemKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key: %w", err)
+	}
+	return pub, nil
+}
+
+// LogID returns the log ID for a log key: the SHA-256 of its DER encoding
+func LogID(pub crypto.PublicKey) ([]byte, error) {
+	der, err := cryptoutils.MarshalPublicKeyToDER(pub)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal log public key: %w", err)
+	}
+	sum := sha256.Sum256(der)
+	return sum[:], nil
+}
+
+// Origin returns the checkpoint origin line of a log
+func Origin(logID []byte) string {
+	return fmt.Sprintf("terrasign - %x", logID[:8])
+}
+
+// VerifyEntry checks an entry offline against the log's public key: the
+// inclusion proof must lead from the entry body to the proof's root hash, and
+// the checkpoint must be signed by the log and commit to that root. It
+// returns the logged hashedrekord.
+func VerifyEntry(entry *protorekor.TransparencyLogEntry, logKey signature.Verifier) (*HashedRekord, error) {
+	pub, err := logKey.PublicKey()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get log public key: %w", err)
+	}
+	logID, err := LogID(pub)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(entry.GetLogId().GetKeyId(), logID) {
+		return nil, fmt.Errorf("entry is from log %x, not the trusted log %x", entry.GetLogId().GetKeyId(), logID)
+	}
+
+	ip := entry.GetInclusionProof()
+	if ip == nil || ip.GetCheckpoint() == nil {
+		return nil, fmt.Errorf("entry has no inclusion proof")
+	}
+	if ip.LogIndex != entry.LogIndex || ip.LogIndex < 0 || ip.TreeSize <= ip.LogIndex {
+		return nil, fmt.Errorf("inclusion proof is for index %d of %d, not entry %d", ip.LogIndex, ip.TreeSize, entry.LogIndex)
+	}
+
+	leaf := LeafHash(entry.CanonicalizedBody)
+	if err := proof.VerifyInclusion(hasher, uint64(ip.LogIndex), uint64(ip.TreeSize), leaf, ip.Hashes, ip.RootHash); err != nil {
+		return nil, fmt.Errorf("inclusion proof does not lead from the entry to root hash %x", ip.RootHash)
+	}
+
+	cp, err := VerifyCheckpoint(ip.Checkpoint.Envelope, logKey)
+	if err != nil {
+		return nil, err
+	}
+	if cp.Origin != Origin(logID) {
+		return nil, fmt.Errorf("checkpoint is for log %q", cp.Origin)
+	}
+	if cp.Size != uint64(ip.TreeSize) || !bytes.Equal(cp.RootHash, ip.RootHash) {
+		return nil, fmt.Errorf("checkpoint (size %d) does not match the inclusion proof (size %d)", cp.Size, ip.TreeSize)
+	}
+
+	if entry.GetKindVersion().GetKind() != KindHashedRekord {
+		return nil, fmt.Errorf("unsupported entry kind %q", entry.GetKindVersion().GetKind())
+	}
+	return ParseHashedRekord(entry.CanonicalizedBody)
+}
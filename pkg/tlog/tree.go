@@ -0,0 +1,116 @@
+// Package tlog implements a local transparency log for network-isolated
+// environments: an RFC 6962 Merkle tree of Rekor hashedrekord entries with
+// signed checkpoints, and offline verification of the entries it hands out.
+// Entries use the Sigstore TransparencyLogEntry format, so they can be
+// embedded in a Sigstore bundle like entries from a public Rekor.
+package tlog
+
+import (
+	"fmt"
+
+	"github.com/transparency-dev/merkle/compact"
+	"github.com/transparency-dev/merkle/proof"
+	"github.com/transparency-dev/merkle/rfc6962"
+)
+
+// hasher is the RFC 6962 SHA-256 hasher Rekor uses
+var hasher = rfc6962.DefaultHasher
+
+// Tree is an in-memory Merkle tree that keeps the hash of every perfect
+// subtree, so roots and inclusion proofs for any size up to Size can be
+// built without rehashing the leaves
+type Tree struct {
+	levels [][][]byte // levels[l][i] is the hash of leaves [i<<l, (i+1)<<l)
+}
+
+// Size returns the number of leaves
+func (t *Tree) Size() uint64 {
+	if len(t.levels) == 0 {
+		return 0
+	}
+	return uint64(len(t.levels[0]))
+}
+
+// AppendLeaf adds a leaf given its leaf hash (see LeafHash)
+func (t *Tree) AppendLeaf(leafHash []byte) {
+	if len(t.levels) == 0 {
+		t.levels = [][][]byte{nil}
+	}
+	t.levels[0] = append(t.levels[0], leafHash)
+
+	// Complete every perfect subtree the new leaf closes
+	for level := 0; len(t.levels[level])%2 == 0; level++ {
+		nodes := t.levels[level]
+		parent := hasher.HashChildren(nodes[len(nodes)-2], nodes[len(nodes)-1])
+		if level+1 == len(t.levels) {
+			t.levels = append(t.levels, nil)
+		}
+		t.levels[level+1] = append(t.levels[level+1], parent)
+	}
+}
+
+// Leaf returns the leaf hash at index, or nil past the end of the tree
+func (t *Tree) Leaf(index uint64) []byte {
+	if index >= t.Size() {
+		return nil
+	}
+	return t.levels[0][index]
+}
+
+// node returns the hash of a perfect subtree
+func (t *Tree) node(id compact.NodeID) ([]byte, error) {
+	if int(id.Level) >= len(t.levels) || id.Index >= uint64(len(t.levels[id.Level])) {
+		return nil, fmt.Errorf("node %d/%d is not in the tree", id.Level, id.Index)
+	}
+	return t.levels[id.Level][id.Index], nil
+}
+
+// Root returns the root hash of the tree's first size leaves
+func (t *Tree) Root(size uint64) ([]byte, error) {
+	if size > t.Size() {
+		return nil, fmt.Errorf("tree size %d exceeds %d leaves", size, t.Size())
+	}
+	if size == 0 {
+		return hasher.EmptyRoot(), nil
+	}
+
+	// The perfect subtrees covering [0, size), left to right, fold into the root
+	ids := compact.RangeNodes(0, size, nil)
+	root, err := t.node(ids[len(ids)-1])
+	if err != nil {
+		return nil, err
+	}
+	for i := len(ids) - 2; i >= 0; i-- {
+		left, err := t.node(ids[i])
+		if err != nil {
+			return nil, err
+		}
+		root = hasher.HashChildren(left, root)
+	}
+	return root, nil
+}
+
+// InclusionProof returns the audit path for leaf index in the tree of the
+// first size leaves
+func (t *Tree) InclusionProof(index, size uint64) ([][]byte, error) {
+	if size > t.Size() {
+		return nil, fmt.Errorf("tree size %d exceeds %d leaves", size, t.Size())
+	}
+	nodes, err := proof.Inclusion(index, size)
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := make([][]byte, len(nodes.IDs))
+	for i, id := range nodes.IDs {
+		if hashes[i], err = t.node(id); err != nil {
+			return nil, err
+		}
+	}
+	return nodes.Rehash(hashes, hasher.HashChildren)
+}
+
+// LeafHash returns the RFC 6962 leaf hash of an entry body
+func LeafHash(body []byte) []byte {
+	return hasher.HashLeaf(body)
+}
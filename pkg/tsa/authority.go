@@ -0,0 +1,172 @@
+package tsa
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/digitorus/timestamp"
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+)
+
+// policyOID is the TSA policy stamped into local tokens. RFC 3161 requires
+// one; verifiers only check the TSA certificate, so a placeholder will do.
+var policyOID = asn1.ObjectIdentifier{1, 2, 3, 4, 1}
+
+// oidExtKeyUsage and oidTimeStamping build the critical timeStamping EKU
+// RFC 3161 requires of TSA certificates
+var (
+	oidExtKeyUsage  = asn1.ObjectIdentifier{2, 5, 29, 37}
+	oidTimeStamping = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 3, 8}
+)
+
+// Authority is a local RFC 3161 timestamp authority
+type Authority struct {
+	cert   *x509.Certificate
+	chain  []*x509.Certificate // cert first, then any intermediates
+	signer crypto.Signer
+}
+
+// LoadAuthority loads a TSA certificate chain (PEM, signing certificate
+// first) and its unencrypted PEM private key
+func LoadAuthority(certPath, keyPath string) (*Authority, error) {
+	certPEM, err := os.ReadFile(certPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read TSA certificate: %w", err)
+	}
+	chain, err := cryptoutils.UnmarshalCertificatesFromPEM(certPEM)
+	if err != nil || len(chain) == 0 {
+		return nil, fmt.Errorf("failed to parse TSA certificate %s: %v", certPath, err)
+	}
+
+	keyPEM, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read TSA key: %w", err)
+	}
+	priv, err := cryptoutils.UnmarshalPEMToPrivateKey(keyPEM, cryptoutils.SkipPassword)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse TSA key %s: %w", keyPath, err)
+	}
+	signer, ok := priv.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("TSA key %s cannot sign", keyPath)
+	}
+
+	if err := cryptoutils.EqualKeys(chain[0].PublicKey, signer.Public()); err != nil {
+		return nil, fmt.Errorf("TSA key %s does not match certificate %s", keyPath, certPath)
+	}
+	if !hasTimestampingEKU(chain[0]) {
+		return nil, fmt.Errorf("TSA certificate %s lacks the timeStamping extended key usage", certPath)
+	}
+
+	return &Authority{cert: chain[0], chain: chain, signer: signer}, nil
+}
+
+// hasTimestampingEKU reports whether a certificate may sign timestamps
+func hasTimestampingEKU(cert *x509.Certificate) bool {
+	for _, eku := range cert.ExtKeyUsage {
+		if eku == x509.ExtKeyUsageTimeStamping {
+			return true
+		}
+	}
+	return false
+}
+
+// Subject returns the TSA certificate subject, for logging
+func (a *Authority) Subject() string {
+	return a.cert.Subject.String()
+}
+
+// Respond answers a DER timestamp request with a DER timestamp response
+func (a *Authority) Respond(reqDER []byte) ([]byte, error) {
+	req, err := timestamp.ParseRequest(reqDER)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timestamp request: %w", err)
+	}
+	if req.HashAlgorithm != crypto.SHA256 && req.HashAlgorithm != crypto.SHA384 && req.HashAlgorithm != crypto.SHA512 {
+		return nil, fmt.Errorf("unsupported hash algorithm %v", req.HashAlgorithm)
+	}
+	if len(req.HashedMessage) != req.HashAlgorithm.Size() {
+		return nil, fmt.Errorf("hashed message is %d bytes, expected %d", len(req.HashedMessage), req.HashAlgorithm.Size())
+	}
+	return a.stamp(req.HashAlgorithm, req.HashedMessage, req.Nonce, req.Certificates)
+}
+
+// Timestamp counter-signs a signature in-process and returns the DER
+// timestamp response, like Client.Timestamp
+func (a *Authority) Timestamp(sig []byte) ([]byte, error) {
+	h := crypto.SHA256.New()
+	h.Write(sig)
+	return a.stamp(crypto.SHA256, h.Sum(nil), nil, true)
+}
+
+// stamp builds a signed timestamp response for the current time
+func (a *Authority) stamp(hash crypto.Hash, hashed []byte, nonce *big.Int, includeCerts bool) ([]byte, error) {
+	ts := timestamp.Timestamp{
+		HashAlgorithm:     hash,
+		HashedMessage:     hashed,
+		Time:              time.Now().UTC(),
+		Policy:            policyOID,
+		Nonce:             nonce,
+		Ordering:          false,
+		Certificates:      a.chain[1:],
+		AddTSACertificate: includeCerts,
+	}
+	resp, err := ts.CreateResponseWithOpts(a.cert, a.signer, crypto.SHA256)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign timestamp: %w", err)
+	}
+	return resp, nil
+}
+
+// GenerateAuthority creates a self-signed TSA certificate and key for the
+// local authority, valid for the given duration. Verifiers trust the
+// certificate with `terrasign verify --tsa-cert`.
+func GenerateAuthority(commonName string, validity time.Duration) (certPEM, keyPEM []byte, err error) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate TSA key: %w", err)
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 127))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate serial number: %w", err)
+	}
+
+	eku, err := asn1.Marshal([]asn1.ObjectIdentifier{oidTimeStamping})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode key usage: %w", err)
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: commonName, Organization: []string{"TerraSign"}},
+		NotBefore:             now.Add(-5 * time.Minute),
+		NotAfter:              now.Add(validity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping},
+		BasicConstraintsValid: true,
+		ExtraExtensions:       []pkix.Extension{{Id: oidExtKeyUsage, Critical: true, Value: eku}},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, priv.Public(), priv)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create TSA certificate: %w", err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal TSA key: %w", err)
+	}
+
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM, nil
+}
@@ -0,0 +1,72 @@
+package tsa
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/digitorus/pkcs7"
+	"github.com/digitorus/timestamp"
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+)
+
+// LoadRoots loads trusted TSA certificates (PEM files, each holding one or
+// more certificates) into a pool
+func LoadRoots(paths []string) (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read TSA certificate: %w", err)
+		}
+		certs, err := cryptoutils.UnmarshalCertificatesFromPEM(data)
+		if err != nil || len(certs) == 0 {
+			return nil, fmt.Errorf("failed to parse TSA certificate %s: %v", path, err)
+		}
+		for _, cert := range certs {
+			pool.AddCert(cert)
+		}
+	}
+	return pool, nil
+}
+
+// Verify checks a DER timestamp response over a signature: the token must
+// stamp the SHA-256 of sig and be signed by a certificate for timestamping
+// that chains to roots, valid at the stamped time. It returns the time.
+func Verify(response, sig []byte, roots *x509.CertPool) (time.Time, error) {
+	ts, err := timestamp.ParseResponse(response)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid timestamp: %w", err)
+	}
+	sum := sha256.Sum256(sig)
+	if ts.HashAlgorithm != crypto.SHA256 || !bytes.Equal(ts.HashedMessage, sum[:]) {
+		return time.Time{}, fmt.Errorf("timestamp is for a different signature")
+	}
+
+	p7, err := pkcs7.Parse(ts.RawToken)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid timestamp token: %w", err)
+	}
+	if len(p7.Certificates) == 0 {
+		return time.Time{}, fmt.Errorf("timestamp token does not include the TSA certificate")
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range p7.Certificates {
+		intermediates.AddCert(cert)
+	}
+	err = p7.VerifyWithOpts(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   ts.Time,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping},
+	})
+	if err != nil {
+		return time.Time{}, fmt.Errorf("timestamp is not signed by a trusted TSA: %w", err)
+	}
+
+	return ts.Time, nil
+}
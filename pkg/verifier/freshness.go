@@ -0,0 +1,109 @@
+package verifier
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultMaxAge is the maximum age of a plan before it is considered stale,
+// unless configured otherwise
+const DefaultMaxAge = 24 * time.Hour
+
+// MaxAgeConfig sets the freshness window per environment (or workspace)
+type MaxAgeConfig struct {
+	Default      time.Duration            // Applies to environments without an entry; 0 means DefaultMaxAge
+	Environments map[string]time.Duration // Per environment overrides
+}
+
+// For returns the maximum plan age for an environment
+func (m MaxAgeConfig) For(environment string) time.Duration {
+	if d, ok := m.Environments[environment]; ok && d > 0 {
+		return d
+	}
+	if m.Default > 0 {
+		return m.Default
+	}
+	return DefaultMaxAge
+}
+
+// Shortest returns the shortest window of any environment
+func (m MaxAgeConfig) Shortest() time.Duration {
+	d := m.For("")
+	for _, e := range m.Environments {
+		if e > 0 && e < d {
+			d = e
+		}
+	}
+	return d
+}
+
+// String renders the config in the form ParseMaxAge reads
+func (m MaxAgeConfig) String() string {
+	parts := []string{"default=" + formatHours(m.For(""))}
+	names := make([]string, 0, len(m.Environments))
+	for name := range m.Environments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		parts = append(parts, name+"="+formatHours(m.Environments[name]))
+	}
+	return strings.Join(parts, ",")
+}
+
+// ParseMaxAge parses a freshness spec: "12h" for every environment, or
+// "default=24h,prod=2h,dev=72h" for per-environment windows
+func ParseMaxAge(spec string) (MaxAgeConfig, error) {
+	m := MaxAgeConfig{Environments: map[string]time.Duration{}}
+	if spec == "" {
+		return m, nil
+	}
+
+	for _, part := range strings.Split(spec, ",") {
+		name, value, hasName := strings.Cut(strings.TrimSpace(part), "=")
+		if !hasName {
+			name, value = "default", name
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			return m, fmt.Errorf("invalid max age %q: need a positive duration like 2h", part)
+		}
+		if name == "default" {
+			m.Default = d
+		} else {
+			m.Environments[name] = d
+		}
+	}
+
+	return m, nil
+}
+
+// checkFreshness fails plans older than maxAge, measured from a time taken
+// from source
+func checkFreshness(report *VerificationReport, at time.Time, maxAge time.Duration, key, source string) {
+	age := time.Since(at)
+	evidence := map[string]string{
+		key:       at.UTC().Format(time.RFC3339),
+		"source":  source,
+		"max_age": maxAge.String(),
+	}
+	if age > maxAge {
+		report.add(StepFreshness, StatusFail, fmt.Sprintf("plan is stale (%.1f hours old, max %s)", age.Hours(), formatHours(maxAge)), evidence)
+	} else {
+		report.add(StepFreshness, StatusPass, fmt.Sprintf("plan is fresh (%.1f hours old, max %s)", age.Hours(), formatHours(maxAge)), evidence)
+	}
+}
+
+// formatHours renders a window like "24h" or "90m" without zero units
+func formatHours(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = s[:len(s)-2]
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = s[:len(s)-2]
+	}
+	return s
+}
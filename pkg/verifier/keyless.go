@@ -0,0 +1,103 @@
+package verifier
+
+import (
+	"bytes"
+	"fmt"
+
+	sgbundle "github.com/sigstore/sigstore-go/pkg/bundle"
+	"github.com/sigstore/sigstore-go/pkg/root"
+	"github.com/sigstore/sigstore-go/pkg/verify"
+)
+
+// verifyKeylessSignature verifies a certificate-based signature in-process
+// from the Sigstore bundle next to the plan: the certificate chains to a
+// trusted Fulcio CA, carries a valid SCT and the expected identity, and the
+// signature over the plan message is logged in Rekor while the certificate
+// was valid. Trusted material comes from trustedRootPath, or from the public
+// Sigstore instance through TUF.
+func verifyKeylessSignature(report *VerificationReport, planPath string, planData []byte, identity, issuer, trustedRootPath string) {
+	bundleFile := planPath + ".bundle"
+	evidence := map[string]string{
+		"mode":     "keyless",
+		"identity": identity,
+		"issuer":   issuer,
+		"bundle":   bundleFile,
+	}
+
+	if identity == "" || issuer == "" {
+		report.add(StepSignature, StatusFail, "keyless verification needs the expected identity and issuer", evidence)
+		return
+	}
+
+	b, err := sgbundle.LoadJSONFromPath(bundleFile)
+	if err != nil {
+		report.add(StepSignature, StatusFail, fmt.Sprintf("failed to read bundle %s (required for keyless verification): %v", bundleFile, err), evidence)
+		return
+	}
+
+	trusted, err := loadTrustedRoot(trustedRootPath)
+	if err != nil {
+		report.add(StepSignature, StatusFail, err.Error(), evidence)
+		return
+	}
+
+	result, err := verifyKeyless(b, trusted, planData, identity, issuer)
+	if err != nil {
+		report.add(StepSignature, StatusFail, err.Error(), evidence)
+		return
+	}
+
+	if result.Signature != nil && result.Signature.Certificate != nil {
+		evidence["certificate_issuer"] = result.Signature.Certificate.CertificateIssuer
+	}
+	if len(result.VerifiedTimestamps) > 0 {
+		evidence["logged_at"] = result.VerifiedTimestamps[0].Timestamp.UTC().Format("2006-01-02T15:04:05Z")
+	}
+	report.add(StepSignature, StatusPass, "signature valid", evidence)
+}
+
+// verifyKeyless checks a certificate-signed entity over the plan message
+// against the trusted material: Fulcio chain, SCT, identity and a Rekor
+// entry whose integrated time falls within the certificate's validity
+func verifyKeyless(entity verify.SignedEntity, trusted root.TrustedMaterial, planData []byte, identity, issuer string) (*verify.VerificationResult, error) {
+	v, err := verify.NewVerifier(trusted,
+		verify.WithSignedCertificateTimestamps(1),
+		verify.WithTransparencyLog(1),
+		verify.WithObserverTimestamps(1),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create verifier: %w", err)
+	}
+
+	expected, err := verify.NewShortCertificateIdentity(issuer, "", identity, "")
+	if err != nil {
+		return nil, fmt.Errorf("invalid expected identity: %w", err)
+	}
+
+	result, err := v.Verify(entity, verify.NewPolicy(
+		verify.WithArtifact(bytes.NewReader(planData)),
+		verify.WithCertificateIdentity(expected),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("keyless verification failed: %w", err)
+	}
+	return result, nil
+}
+
+// loadTrustedRoot reads a Sigstore trusted root file, or fetches the public
+// instance's trusted root through TUF when no path is given
+func loadTrustedRoot(path string) (root.TrustedMaterial, error) {
+	if path != "" {
+		trusted, err := root.NewTrustedRootFromPath(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load trusted root %s: %w", path, err)
+		}
+		return trusted, nil
+	}
+
+	trusted, err := root.FetchTrustedRoot()
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch the Sigstore trusted root: %w", err)
+	}
+	return trusted, nil
+}
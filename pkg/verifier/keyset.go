@@ -0,0 +1,226 @@
+package verifier
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sigstore/sigstore/pkg/signature"
+	"github.com/sulakshanakarunarathne/terrasign/pkg/attestation"
+)
+
+// TrustedKey is a reviewer public key whose signatures are accepted
+type TrustedKey struct {
+	Name     string // File name without extension, e.g. "alice" for alice.pub
+	KeyID    string // base64 SHA-256 of the DER public key
+	verifier signature.Verifier
+}
+
+// Identity is the reviewer identity recorded for signatures made by this key
+func (k *TrustedKey) Identity() string {
+	return fmt.Sprintf("%s (key %s)", k.Name, k.KeyID)
+}
+
+// Verifier returns the key's signature verifier, e.g. for attestations
+// signed with it
+func (k *TrustedKey) Verifier() signature.Verifier {
+	return k.verifier
+}
+
+// PublicKey returns the key itself
+func (k *TrustedKey) PublicKey() crypto.PublicKey {
+	pub, _ := k.verifier.PublicKey() // Loaded keys always have one
+	return pub
+}
+
+// Verify checks a (base64 or raw) signature over data
+func (k *TrustedKey) Verify(sig, data []byte) error {
+	return k.verifier.VerifySignature(bytes.NewReader(DecodeSignature(sig)), bytes.NewReader(data))
+}
+
+// LoadTrustedKeys loads PEM public keys. Each path may be a key file or a
+// directory, in which case every *.pub and *.pem file in it is loaded.
+func LoadTrustedKeys(paths []string) ([]TrustedKey, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read trusted key %s: %w", path, err)
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read trusted key directory: %w", err)
+		}
+		for _, e := range entries {
+			ext := filepath.Ext(e.Name())
+			if !e.IsDir() && (ext == ".pub" || ext == ".pem") {
+				files = append(files, filepath.Join(path, e.Name()))
+			}
+		}
+	}
+
+	var keys []TrustedKey
+	seen := map[string]bool{}
+	for _, file := range files {
+		v, err := LoadVerifier(file)
+		if err != nil {
+			return nil, err
+		}
+		pub, err := v.PublicKey()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read public key %s: %w", file, err)
+		}
+		keyID, err := attestation.KeyID(pub)
+		if err != nil {
+			return nil, err
+		}
+		// The same key listed twice must not count twice towards a threshold
+		if seen[keyID] {
+			continue
+		}
+		seen[keyID] = true
+
+		keys = append(keys, TrustedKey{
+			Name:     strings.TrimSuffix(filepath.Base(file), filepath.Ext(file)),
+			KeyID:    keyID,
+			verifier: v,
+		})
+	}
+
+	return keys, nil
+}
+
+// SignatureSet is the multi-party signature file written next to a plan
+// (<plan>.sigs): one entry per approving reviewer key
+type SignatureSet struct {
+	Signatures []SignatureEntry `json:"signatures"`
+}
+
+// SignatureEntry is one reviewer's signature over the plan
+type SignatureEntry struct {
+	KeyID     string    `json:"key_id"`
+	Reviewer  string    `json:"reviewer"`
+	Signature string    `json:"signature"` // base64
+	SignedAt  time.Time `json:"signed_at"`
+}
+
+// readSignatureSet collects candidate signatures from <plan>.sigs and <plan>.sig
+func readSignatureSet(planPath string) ([][]byte, error) {
+	var sigs [][]byte
+
+	data, err := os.ReadFile(planPath + ".sigs")
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read signature set: %w", err)
+	}
+	if err == nil {
+		var set SignatureSet
+		if err := json.Unmarshal(data, &set); err != nil {
+			return nil, fmt.Errorf("failed to parse signature set: %w", err)
+		}
+		for _, entry := range set.Signatures {
+			sigs = append(sigs, []byte(entry.Signature))
+		}
+	}
+
+	if sig, err := os.ReadFile(planPath + ".sig"); err == nil {
+		sigs = append(sigs, sig)
+	}
+
+	if len(sigs) == 0 {
+		return nil, fmt.Errorf("no signatures found (%s.sigs or %s.sig)", planPath, planPath)
+	}
+	return sigs, nil
+}
+
+// verifyThresholdSignatures requires valid signatures from at least threshold
+// distinct trusted keys. It returns a verifier accepting any key that signed,
+// for checking the attestations, or nil when the threshold is not met.
+func verifyThresholdSignatures(report *VerificationReport, planPath string, planData []byte, keyPaths []string, threshold int) signature.Verifier {
+	evidence := map[string]string{
+		"mode":      "threshold",
+		"threshold": fmt.Sprintf("%d", threshold),
+	}
+
+	keys, err := LoadTrustedKeys(keyPaths)
+	if err != nil {
+		report.add(StepSignature, StatusFail, err.Error(), evidence)
+		return nil
+	}
+	if len(keys) < threshold {
+		report.add(StepSignature, StatusFail, fmt.Sprintf("threshold %d exceeds the %d trusted keys", threshold, len(keys)), evidence)
+		return nil
+	}
+
+	sigs, err := readSignatureSet(planPath)
+	if err != nil {
+		report.add(StepSignature, StatusFail, err.Error(), evidence)
+		return nil
+	}
+
+	var signers []TrustedKey
+	for _, key := range keys {
+		for _, sig := range sigs {
+			if key.Verify(sig, planData) == nil {
+				signers = append(signers, key)
+				break
+			}
+		}
+	}
+
+	names := make([]string, len(signers))
+	for i, k := range signers {
+		names[i] = k.Name
+	}
+	sort.Strings(names)
+	evidence["signers"] = strings.Join(names, ",")
+	evidence["valid"] = fmt.Sprintf("%d", len(signers))
+
+	if len(signers) < threshold {
+		report.add(StepSignature, StatusFail, fmt.Sprintf("only %d of %d required trusted signatures are valid", len(signers), threshold), evidence)
+		return nil
+	}
+
+	report.add(StepSignature, StatusPass, fmt.Sprintf("%d of %d required trusted signatures valid", len(signers), threshold), evidence)
+	return anyKeyVerifier(signers)
+}
+
+// anyKeyVerifier accepts a signature from any of its keys
+type anyKeyVerifier []TrustedKey
+
+// PublicKey returns the first key
+func (v anyKeyVerifier) PublicKey(opts ...signature.PublicKeyOption) (crypto.PublicKey, error) {
+	return v[0].verifier.PublicKey(opts...)
+}
+
+// VerifySignature tries each key in turn
+func (v anyKeyVerifier) VerifySignature(sig, message io.Reader, opts ...signature.VerifyOption) error {
+	sigData, err := io.ReadAll(sig)
+	if err != nil {
+		return err
+	}
+	msg, err := io.ReadAll(message)
+	if err != nil {
+		return err
+	}
+	for _, k := range v {
+		if k.verifier.VerifySignature(bytes.NewReader(sigData), bytes.NewReader(msg), opts...) == nil {
+			return nil
+		}
+	}
+	return errors.New("signature does not match any trusted key")
+}
+
+var _ signature.Verifier = anyKeyVerifier(nil)
@@ -0,0 +1,141 @@
+package verifier
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Status is the outcome of a single verification step
+type Status string
+
+const (
+	StatusPass Status = "pass"
+	StatusFail Status = "fail"
+	StatusWarn Status = "warn"
+)
+
+// Verification steps, in the order Verify runs them
+const (
+	StepSignature    = "signature"
+	StepTransparency = "transparency"
+	StepTimestamp    = "timestamp"
+	StepPolicy       = "policy"
+	StepProvenance   = "provenance"
+	StepFreshness    = "freshness"
+)
+
+// StepResult is the outcome of one verification step
+type StepResult struct {
+	Step     string            `json:"step"`
+	Status   Status            `json:"status"`
+	Reason   string            `json:"reason"`
+	Evidence map[string]string `json:"evidence,omitempty"`
+}
+
+// VerificationReport collects the results of every verification step
+type VerificationReport struct {
+	PlanPath   string `json:"plan_path"`
+	PlanDigest string `json:"plan_digest,omitempty"`
+	// PlanJSONDigest is set when the plan was verified with its JSON rendering
+	PlanJSONDigest string       `json:"plan_json_digest,omitempty"`
+	VerifiedAt     time.Time    `json:"verified_at"`
+	Passed         bool         `json:"passed"`
+	Steps          []StepResult `json:"steps"`
+}
+
+// newReport creates an empty report for a plan
+func newReport(planPath string) *VerificationReport {
+	return &VerificationReport{
+		PlanPath:   planPath,
+		VerifiedAt: time.Now().UTC(),
+		Passed:     true,
+	}
+}
+
+// add records a step result; any failure marks the whole report as failed
+func (r *VerificationReport) add(step string, status Status, reason string, evidence map[string]string) {
+	r.Steps = append(r.Steps, StepResult{
+		Step:     step,
+		Status:   status,
+		Reason:   reason,
+		Evidence: evidence,
+	})
+	if status == StatusFail {
+		r.Passed = false
+	}
+}
+
+// Step returns the result for the named step, or nil if it did not run
+func (r *VerificationReport) Step(name string) *StepResult {
+	for i := range r.Steps {
+		if r.Steps[i].Step == name {
+			return &r.Steps[i]
+		}
+	}
+	return nil
+}
+
+// Failures returns the steps that failed
+func (r *VerificationReport) Failures() []StepResult {
+	var failed []StepResult
+	for _, s := range r.Steps {
+		if s.Status == StatusFail {
+			failed = append(failed, s)
+		}
+	}
+	return failed
+}
+
+// Err returns an error summarizing the failed steps, or nil if all passed
+func (r *VerificationReport) Err() error {
+	failed := r.Failures()
+	if len(failed) == 0 {
+		return nil
+	}
+
+	reasons := make([]string, 0, len(failed))
+	for _, s := range failed {
+		reasons = append(reasons, fmt.Sprintf("%s: %s", s.Step, s.Reason))
+	}
+	return fmt.Errorf("verification failed (%s)", strings.Join(reasons, "; "))
+}
+
+// Print writes a human-readable summary of the report
+func (r *VerificationReport) Print(w io.Writer) {
+	fmt.Fprintf(w, "Verifying plan at %s\n", r.PlanPath)
+	if r.PlanDigest != "" {
+		fmt.Fprintf(w, "Plan digest: sha256:%s\n", r.PlanDigest)
+	}
+	if r.PlanJSONDigest != "" {
+		fmt.Fprintf(w, "Plan JSON digest: sha256:%s (bound to the plan)\n", r.PlanJSONDigest)
+	}
+
+	for i, s := range r.Steps {
+		label := "[OK]"
+		switch s.Status {
+		case StatusFail:
+			label = "[FAIL]"
+		case StatusWarn:
+			label = "[WARN]"
+		}
+		fmt.Fprintf(w, "Step %d/%d: %-10s %s %s\n", i+1, len(r.Steps), s.Step, label, s.Reason)
+
+		keys := make([]string, 0, len(s.Evidence))
+		for k := range s.Evidence {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(w, "  %s: %s\n", k, s.Evidence[k])
+		}
+	}
+
+	if r.Passed {
+		fmt.Fprintln(w, "\n[SUCCESS] VERIFICATION SUCCESSFUL - All checks passed!")
+	} else {
+		fmt.Fprintln(w, "\n[ERROR] VERIFICATION FAILED")
+	}
+}
@@ -0,0 +1,71 @@
+package verifier
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sigstore/sigstore/pkg/signature"
+	"github.com/sulakshanakarunarathne/terrasign/pkg/tsa"
+)
+
+// verifyTimestamp checks the RFC 3161 timestamps in <plan>.bundle: one must
+// cover the bundle's signature, which must be a signature over the plan by a
+// key accepted in the signature step, and be signed by a trusted TSA. It
+// returns the stamped time, or nil when no timestamp is trusted.
+func verifyTimestamp(report *VerificationReport, planPath string, planData []byte, keyVerifier signature.Verifier, certPaths []string) *time.Time {
+	bundleFile := planPath + ".bundle"
+	evidence := map[string]string{
+		"bundle":   bundleFile,
+		"tsa_cert": strings.Join(certPaths, ","),
+	}
+
+	if keyVerifier == nil {
+		report.add(StepTimestamp, StatusFail, "no verified key signature to check a timestamp for", evidence)
+		return nil
+	}
+
+	roots, err := tsa.LoadRoots(certPaths)
+	if err != nil {
+		report.add(StepTimestamp, StatusFail, err.Error(), evidence)
+		return nil
+	}
+
+	bundle, err := readBundle(bundleFile)
+	if err != nil {
+		report.add(StepTimestamp, StatusFail, err.Error(), evidence)
+		return nil
+	}
+
+	timestamps := bundle.GetVerificationMaterial().GetTimestampVerificationData().GetRfc3161Timestamps()
+	if len(timestamps) == 0 {
+		report.add(StepTimestamp, StatusFail, "bundle has no RFC 3161 timestamp (was the plan signed with a timestamp authority?)", evidence)
+		return nil
+	}
+
+	sig := bundle.GetMessageSignature().GetSignature()
+	if len(sig) == 0 {
+		report.add(StepTimestamp, StatusFail, "bundle has no message signature", evidence)
+		return nil
+	}
+	if err := keyVerifier.VerifySignature(bytes.NewReader(sig), bytes.NewReader(planData)); err != nil {
+		report.add(StepTimestamp, StatusFail, "timestamped signature was not made over this plan by a verified key", evidence)
+		return nil
+	}
+
+	var lastErr error
+	for _, ts := range timestamps {
+		at, err := tsa.Verify(ts.GetSignedTimestamp(), sig, roots)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		evidence["signed_at"] = at.UTC().Format(time.RFC3339)
+		report.add(StepTimestamp, StatusPass, fmt.Sprintf("signature timestamped at %s by a trusted TSA", at.UTC().Format(time.RFC3339)), evidence)
+		return &at
+	}
+
+	report.add(StepTimestamp, StatusFail, fmt.Sprintf("timestamp rejected: %v", lastErr), evidence)
+	return nil
+}
@@ -0,0 +1,108 @@
+package verifier
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	protobundle "github.com/sigstore/protobuf-specs/gen/pb-go/bundle/v1"
+	"github.com/sigstore/sigstore/pkg/signature"
+	"github.com/sulakshanakarunarathne/terrasign/pkg/tlog"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// verifyTransparency checks offline that a signature over the plan by a key
+// accepted in the signature step is in the transparency log: some entry in
+// <plan>.bundle must carry a valid inclusion proof and a checkpoint signed by
+// the log key
+func verifyTransparency(report *VerificationReport, planPath string, planData []byte, keyVerifier signature.Verifier, tlogKeyPath string) {
+	bundleFile := planPath + ".bundle"
+	evidence := map[string]string{
+		"bundle":   bundleFile,
+		"tlog_key": tlogKeyPath,
+	}
+
+	if keyVerifier == nil {
+		report.add(StepTransparency, StatusFail, "no verified key signature to look up in the transparency log", evidence)
+		return
+	}
+
+	logKey, err := LoadVerifier(tlogKeyPath)
+	if err != nil {
+		report.add(StepTransparency, StatusFail, err.Error(), evidence)
+		return
+	}
+
+	bundle, err := readBundle(bundleFile)
+	if err != nil {
+		report.add(StepTransparency, StatusFail, err.Error(), evidence)
+		return
+	}
+
+	entries := bundle.GetVerificationMaterial().GetTlogEntries()
+	if len(entries) == 0 {
+		report.add(StepTransparency, StatusFail, "bundle has no transparency log entry (was the plan signed with a transparency log?)", evidence)
+		return
+	}
+
+	digest := sha256.Sum256(planData)
+	var lastErr error
+	for _, entry := range entries {
+		rekord, err := tlog.VerifyEntry(entry, logKey)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if err := checkLoggedSignature(rekord, digest[:], planData, keyVerifier); err != nil {
+			lastErr = err
+			continue
+		}
+
+		evidence["log_index"] = strconv.FormatInt(entry.LogIndex, 10)
+		evidence["tree_size"] = strconv.FormatInt(entry.InclusionProof.TreeSize, 10)
+		evidence["integrated_at"] = time.Unix(entry.IntegratedTime, 0).UTC().Format(time.RFC3339)
+		report.add(StepTransparency, StatusPass, fmt.Sprintf("signature logged at index %d (inclusion proof and checkpoint verified)", entry.LogIndex), evidence)
+		return
+	}
+
+	report.add(StepTransparency, StatusFail, fmt.Sprintf("transparency log entry rejected: %v", lastErr), evidence)
+}
+
+// checkLoggedSignature checks that a logged hashedrekord is a signature over
+// this plan by a verified key
+func checkLoggedSignature(rekord *tlog.HashedRekord, digest, planData []byte, keyVerifier signature.Verifier) error {
+	logged, err := rekord.Digest()
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(logged, digest) {
+		return fmt.Errorf("logged signature is for a different plan (sha256 %x)", logged)
+	}
+	sig, err := rekord.Signature()
+	if err != nil {
+		return err
+	}
+	if err := keyVerifier.VerifySignature(bytes.NewReader(sig), bytes.NewReader(planData)); err != nil {
+		return fmt.Errorf("logged signature was not made by a verified key")
+	}
+	return nil
+}
+
+// readBundle reads a Sigstore bundle written next to a plan
+func readBundle(bundleFile string) (*protobundle.Bundle, error) {
+	data, err := os.ReadFile(bundleFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("bundle not found: %s", bundleFile)
+		}
+		return nil, fmt.Errorf("failed to read bundle: %w", err)
+	}
+	var bundle protobundle.Bundle
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(data, &bundle); err != nil {
+		return nil, fmt.Errorf("failed to parse bundle: %w", err)
+	}
+	return &bundle, nil
+}
@@ -1,101 +1,346 @@
 package verifier
 
 import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"os"
-	"os/exec"
+	"strings"
 	"time"
 
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+	"github.com/sigstore/sigstore/pkg/signature"
+	"github.com/sulakshanakarunarathne/terrasign/pkg/attestation"
+	"github.com/sulakshanakarunarathne/terrasign/pkg/planfile"
 	"github.com/sulakshanakarunarathne/terrasign/pkg/policy"
 	"github.com/sulakshanakarunarathne/terrasign/pkg/provenance"
 )
 
-// Verify invokes the cosign CLI to verify the signature of the given file.
+// DefaultPolicyDir is where signing reads the policies, protected
+// resources included
+const DefaultPolicyDir = "./policies"
+
+// Options controls how a plan is verified
+type Options struct {
+	KeyPath  string // Public key for key-based verification
+	Identity string // Expected certificate identity for keyless verification
+	Issuer   string // Expected OIDC issuer for keyless verification
+	// Strict fails closed when an attestation is missing, unsigned or bound
+	// to a different plan digest, instead of reporting a warning
+	Strict bool
+	// TrustedKeys and Threshold require signatures from at least Threshold
+	// distinct keys of the set (files or directories of *.pub / *.pem),
+	// read from <plan>.sigs and <plan>.sig
+	TrustedKeys []string
+	Threshold   int
+	// TlogKey is the public key of a signing service transparency log. When
+	// set, <plan>.bundle must hold an inclusion proof, under a checkpoint
+	// signed by this key, for a signature over the plan by a verified key.
+	TlogKey string
+	// TSACerts are trusted timestamp authority certificates. When set,
+	// <plan>.bundle must hold an RFC 3161 timestamp over a verified
+	// signature, and the plan age is measured from the stamped time.
+	TSACerts []string
+	// MaxAge is the oldest a plan may be, measured from its signing time;
+	// zero means 24h. RequireSigningTime fails verification when no trusted
+	// timestamp or provenance establishes that time, instead of warning.
+	MaxAge             time.Duration
+	RequireSigningTime bool
+	// PlanJSON is the `terraform show -json` rendering the plan was signed
+	// with; signatures and attestations must then cover both digests
+	PlanJSON string
+
+	// TrustedRoot is a Sigstore trusted root file for keyless verification;
+	// empty fetches the public instance's trusted root through TUF
+	TrustedRoot string
+
+	// PolicyDir holds the config.yaml listing protected resources; a plan
+	// that destroys or replaces one needs a signed acknowledgement
+	PolicyDir string
+}
+
+// Verify checks the signature, policy attestation, provenance and freshness of a plan.
 // It requires expected identity and issuer for keyless verification, OR a key for key-based verification.
-func Verify(planPath, keyPath, identity, issuer string) error {
-	fmt.Printf("Verifying plan at %s\n", planPath)
+// The returned report is always populated; the error is non-nil if any step failed.
+func Verify(planPath, keyPath, identity, issuer string) (*VerificationReport, error) {
+	return VerifyWithOptions(planPath, Options{
+		KeyPath:  keyPath,
+		Identity: identity,
+		Issuer:   issuer,
+	})
+}
 
-	// Step 1: Verify cryptographic signature
-	fmt.Println("Step 1/4: Verifying cryptographic signature...")
-	if err := verifyCosignSignature(planPath, keyPath, identity, issuer); err != nil {
-		return fmt.Errorf("signature verification failed: %w", err)
-	}
-	fmt.Println("[OK] Signature valid")
+// VerifyWithOptions verifies a plan with additional options
+func VerifyWithOptions(planPath string, opts Options) (*VerificationReport, error) {
+	report := newReport(planPath)
 
-	// Step 2: Verify policy attestation
-	fmt.Println("Step 2/4: Verifying policy compliance...")
-	policyResult, err := policy.LoadAttestation(planPath)
+	// Signatures cover the plan, or the binding of plan and JSON digests
+	in, err := planfile.NewInput(planPath, opts.PlanJSON)
 	if err != nil {
-		fmt.Printf("⚠️  Warning: No policy attestation found (plan may predate policy checks)\n")
-	} else {
-		if !policyResult.Passed {
-			return fmt.Errorf("plan failed policy checks: %d violations", len(policyResult.Violations))
+		report.add(StepSignature, StatusFail, err.Error(), nil)
+		return report, report.Err()
+	}
+	planData, err := in.Message()
+	if err != nil {
+		report.add(StepSignature, StatusFail, err.Error(), nil)
+		return report, report.Err()
+	}
+	if in.Paired() {
+		if report.PlanDigest, report.PlanJSONDigest, err = in.Digests(); err != nil {
+			report.add(StepSignature, StatusFail, err.Error(), nil)
+			return report, report.Err()
 		}
-		fmt.Println("[OK] Policy compliance verified")
+	} else {
+		digest := sha256.Sum256(planData)
+		report.PlanDigest = hex.EncodeToString(digest[:])
 	}
+	planPath = in.Path()
 
-	// Step 3: Verify SLSA provenance
-	fmt.Println("Step 3/4: Verifying SLSA provenance...")
-	slsaProvenance, err := provenance.LoadProvenance(planPath)
-	if err != nil {
-		fmt.Printf("⚠️  Warning: No provenance found (plan may predate provenance generation)\n")
+	// Step 1: Verify cryptographic signature
+	// Attestations are signed with the same key as the plan, so the key's
+	// verifier is reused for them. Keyless plans have no attestation key.
+	var keyVerifier signature.Verifier
+	if len(opts.TrustedKeys) > 0 {
+		threshold := opts.Threshold
+		if threshold < 1 {
+			threshold = 1
+		}
+		keyVerifier = verifyThresholdSignatures(report, planPath, planData, opts.TrustedKeys, threshold)
+	} else if opts.KeyPath != "" {
+		keyVerifier = verifyKeySignature(report, planPath, planData, opts.KeyPath)
 	} else {
-		// Verify provenance is from trusted builder
-		fmt.Printf("  Builder: %s\n", slsaProvenance.Predicate.Builder.ID)
-		fmt.Printf("  Build Type: %s\n", slsaProvenance.Predicate.BuildType)
-		fmt.Println("[OK] Provenance verified")
-	}
-
-	// Step 4: Check freshness (24h limit)
-	fmt.Println("Step 4/4: Checking plan freshness...")
-	if slsaProvenance != nil {
-		age := time.Since(slsaProvenance.Predicate.Metadata.BuildFinishedOn)
-		if age > 24*time.Hour {
-			return fmt.Errorf("plan is stale (%.1f hours old, max 24h)", age.Hours())
+		verifyKeylessSignature(report, planPath, planData, opts.Identity, opts.Issuer, opts.TrustedRoot)
+	}
+
+	// Step 2: Check the signature was logged in the transparency log
+	if opts.TlogKey != "" {
+		verifyTransparency(report, planPath, planData, keyVerifier, opts.TlogKey)
+	}
+
+	// Step 3: Check the signature was timestamped by a trusted TSA
+	var signedAt *time.Time
+	if len(opts.TSACerts) > 0 {
+		signedAt = verifyTimestamp(report, planPath, planData, keyVerifier, opts.TSACerts)
+	}
+
+	// Step 4: Verify policy attestation
+	policyResult, err := policy.LoadAttestation(in, keyVerifier)
+	status, reason := attestationStatus("policy", err, opts.Strict)
+	// Only a signed attestation bound to this plan can acknowledge destroys
+	var ack *policy.Acknowledgement
+	if status == StatusPass {
+		ack = policyResult.Acknowledgement
+	}
+	unacknowledged, protectErr := unacknowledgedDestroys(in, opts.PolicyDir, ack)
+	switch {
+	case policyResult != nil && !policyResult.Passed:
+		report.add(StepPolicy, StatusFail, fmt.Sprintf("plan failed policy checks: %d violations", len(policyResult.Violations)), map[string]string{
+			"violations": fmt.Sprintf("%d", len(policyResult.Violations)),
+		})
+	case protectErr != nil:
+		report.add(StepPolicy, StatusFail, fmt.Sprintf("cannot check protected resources: %v", protectErr), nil)
+	case len(unacknowledged) > 0:
+		report.add(StepPolicy, StatusFail, "plan destroys or replaces protected resources without a signed acknowledgement", map[string]string{
+			"unacknowledged": strings.Join(unacknowledged, ", "),
+		})
+	case status == StatusPass:
+		evidence := map[string]string{
+			"subject":  "sha256:" + report.PlanDigest,
+			"warnings": fmt.Sprintf("%d", len(policyResult.Warnings)),
+			"waived":   fmt.Sprintf("%d", len(policyResult.Waived)),
+		}
+		if policyResult.Threshold != "" {
+			evidence["threshold"] = string(policyResult.Threshold)
+		}
+		if report.PlanJSONDigest != "" {
+			evidence["json_subject"] = "sha256:" + report.PlanJSONDigest
+		}
+		if ack := policyResult.Acknowledgement; ack != nil {
+			evidence["acknowledged"] = strings.Join(ack.Addresses, ", ")
+			if ack.By != "" {
+				evidence["acknowledged_by"] = ack.By
+			}
+		}
+		if policyResult.Scope == policy.ProtectionScope {
+			// The attestation vouches for the acknowledgement alone
+			evidence["scope"] = policyResult.Scope
+			status = StatusWarn
+			if opts.Strict {
+				status = StatusFail
+			}
+			report.add(StepPolicy, status, "only the protected resource acknowledgement is attested; other policies were not", evidence)
+			break
 		}
-		fmt.Printf("[OK] Plan is fresh (%.1f hours old)\n", age.Hours())
+		report.add(StepPolicy, StatusPass, "policy compliance verified", evidence)
+	default:
+		report.add(StepPolicy, status, reason, nil)
 	}
 
-	fmt.Println("\n[SUCCESS] VERIFICATION SUCCESSFUL - All checks passed!")
-	return nil
+	// Step 5: Verify SLSA provenance
+	slsaProvenance, err := provenance.LoadProvenance(in, keyVerifier)
+	status, reason = attestationStatus("provenance", err, opts.Strict)
+	if slsaProvenance != nil && status != StatusFail {
+		if status == StatusPass {
+			reason = "provenance verified"
+		}
+		report.add(StepProvenance, status, reason, map[string]string{
+			"builder":    slsaProvenance.Predicate.Builder.ID,
+			"build_type": slsaProvenance.Predicate.BuildType,
+		})
+	} else {
+		report.add(StepProvenance, status, reason, nil)
+	}
+	// Only provenance signed by a verified key can vouch for the build
+	// time; anyone can write an unsigned one
+	var builtAt *time.Time
+	if slsaProvenance != nil && status == StatusPass {
+		builtAt = &slsaProvenance.Predicate.Metadata.BuildFinishedOn
+	}
+
+	// Step 6: Check freshness. A trusted timestamp is preferred over the
+	// provenance build time, which the signer chooses.
+	maxAge := opts.MaxAge
+	if maxAge <= 0 {
+		maxAge = DefaultMaxAge
+	}
+	unknown := StatusWarn
+	if opts.RequireSigningTime {
+		unknown = StatusFail
+	}
+	switch {
+	case signedAt != nil:
+		checkFreshness(report, *signedAt, maxAge, "signed_at", "rfc3161 timestamp")
+	case len(opts.TSACerts) > 0:
+		report.add(StepFreshness, unknown, "plan age unknown (no trusted timestamp)", nil)
+	case builtAt != nil:
+		checkFreshness(report, *builtAt, maxAge, "built_at", "provenance")
+	default:
+		report.add(StepFreshness, unknown, "plan age unknown (no trusted provenance)", nil)
+	}
+
+	return report, report.Err()
 }
 
-// verifyCosignSignature verifies the cryptographic signature
-func verifyCosignSignature(planPath, keyPath, identity, issuer string) error {
+// unacknowledgedDestroys returns the protected resources the plan destroys
+// or replaces that ack does not cover, recomputed from the plan itself
+func unacknowledgedDestroys(in planfile.Input, policyDir string, ack *policy.Acknowledgement) ([]string, error) {
+	if policyDir == "" {
+		policyDir = DefaultPolicyDir
+	}
+	protection, err := policy.LoadProtection(policyDir)
+	if err != nil {
+		return nil, err
+	}
+	_, doc, err := in.Load()
+	if err != nil {
+		return nil, err
+	}
+	remaining, err := protection.Unacknowledged(doc, ack)
+	if err != nil {
+		return nil, err
+	}
+	return policy.Addresses(remaining), nil
+}
+
+// attestationStatus maps an attestation load error to a step status.
+// Missing and unsigned attestations are warnings unless strict is set;
+// bad signatures and digest mismatches always fail.
+func attestationStatus(kind string, err error, strict bool) (Status, string) {
+	soft := StatusWarn
+	if strict {
+		soft = StatusFail
+	}
+
+	switch {
+	case err == nil:
+		return StatusPass, ""
+	case errors.Is(err, attestation.ErrMissing):
+		return soft, fmt.Sprintf("no %s attestation found", kind)
+	case errors.Is(err, attestation.ErrUnsigned):
+		return soft, fmt.Sprintf("%s attestation is not signed (%v)", kind, err)
+	case errors.Is(err, attestation.ErrDigestMismatch):
+		return StatusFail, fmt.Sprintf("%s attestation is bound to a different plan", kind)
+	case errors.Is(err, attestation.ErrBadSignature):
+		return StatusFail, fmt.Sprintf("%s attestation signature is invalid", kind)
+	default:
+		return StatusFail, fmt.Sprintf("%s attestation could not be verified: %v", kind, err)
+	}
+}
+
+// verifyKeySignature verifies the plan signature in-process against a public key.
+// It returns the key's verifier when the signature is valid, nil otherwise.
+func verifyKeySignature(report *VerificationReport, planPath string, planData []byte, keyPath string) signature.Verifier {
 	sigFile := planPath + ".sig"
-	certFile := planPath + ".crt"
+	evidence := map[string]string{
+		"mode":      "key",
+		"key":       keyPath,
+		"signature": sigFile,
+	}
+
+	sig, err := readSignature(sigFile)
+	if err != nil {
+		report.add(StepSignature, StatusFail, err.Error(), evidence)
+		return nil
+	}
 
-	// Basic check if files exist
-	if _, err := os.Stat(sigFile); os.IsNotExist(err) {
-		return fmt.Errorf("signature file not found: %s", sigFile)
+	verifier, err := LoadVerifier(keyPath)
+	if err != nil {
+		report.add(StepSignature, StatusFail, err.Error(), evidence)
+		return nil
 	}
 
-	args := []string{"verify-blob",
-		"--signature", sigFile,
+	if err := verifier.VerifySignature(bytes.NewReader(sig), bytes.NewReader(planData)); err != nil {
+		report.add(StepSignature, StatusFail, fmt.Sprintf("signature does not match plan for key %s: %v", keyPath, err), evidence)
+		return nil
 	}
 
-	if keyPath != "" {
-		args = append(args, "--key", keyPath)
-		args = append(args, "--insecure-ignore-tlog=true")
-	} else {
-		// Keyless mode requires certificate, identity, and issuer
-		if _, err := os.Stat(certFile); os.IsNotExist(err) {
-			return fmt.Errorf("certificate file not found: %s (required for keyless verification)", certFile)
+	report.add(StepSignature, StatusPass, "signature valid", evidence)
+	return verifier
+}
+
+// readSignature reads a signature file written by terrasign or cosign.
+// Signatures are normally base64 encoded; raw bytes are accepted as well.
+func readSignature(sigFile string) ([]byte, error) {
+	data, err := os.ReadFile(sigFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("signature file not found: %s", sigFile)
 		}
-		args = append(args, "--certificate", certFile)
-		args = append(args, "--certificate-identity", identity)
-		args = append(args, "--certificate-oidc-issuer", issuer)
+		return nil, fmt.Errorf("failed to read signature: %w", err)
 	}
 
-	args = append(args, planPath)
+	return DecodeSignature(data), nil
+}
 
-	cmd := exec.Command("cosign", args...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+// DecodeSignature decodes a base64 signature (as written by `terrasign sign`
+// and `cosign sign-blob`), passing raw signatures through unchanged
+func DecodeSignature(data []byte) []byte {
+	if decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data))); err == nil {
+		return decoded
+	}
+	return data
+}
+
+// LoadVerifier loads a PEM-encoded public key
+func LoadVerifier(keyPath string) (signature.Verifier, error) {
+	pemData, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read public key: %w", err)
+	}
 
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("cosign verification failed: %w", err)
+	pub, err := cryptoutils.UnmarshalPEMToPublicKey(pemData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key %s: %w", keyPath, err)
+	}
+
+	verifier, err := signature.LoadVerifier(pub, crypto.SHA256)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported public key %s: %w", keyPath, err)
 	}
 
-	return nil
+	return verifier, nil
 }
@@ -0,0 +1,120 @@
+package verifier
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+	"github.com/sigstore/sigstore/pkg/signature"
+	"github.com/sulakshanakarunarathne/terrasign/pkg/planfile"
+	"github.com/sulakshanakarunarathne/terrasign/pkg/provenance"
+)
+
+// signedPlan copies the planfile fixture to a temporary directory, signs it
+// with a new key and returns the plan path, the key path and the signer
+func signedPlan(t *testing.T) (string, string, signature.SignerVerifier) {
+	t.Helper()
+	dir := t.TempDir()
+	data, err := os.ReadFile("../planfile/testdata/changes.tfplan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	planPath := filepath.Join(dir, "tfplan")
+	if err := os.WriteFile(planPath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := signature.LoadSignerVerifier(priv, crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := signer.SignMessage(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(planPath+".sig", []byte(base64.StdEncoding.EncodeToString(sig)), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	pub, err := cryptoutils.MarshalPublicKeyToPEM(priv.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPath := filepath.Join(dir, "key.pub")
+	if err := os.WriteFile(keyPath, pub, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return planPath, keyPath, signer
+}
+
+// writeProvenance writes provenance for the plan claiming it was built at
+// builtAt, signed by signer (unsigned when nil)
+func writeProvenance(t *testing.T, planPath string, builtAt time.Time, signer signature.Signer) {
+	t.Helper()
+	in, err := planfile.NewInput(planPath, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	generator := provenance.NewProvenanceGenerator("test")
+	prov, err := generator.Generate(in, builtAt)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	prov.Predicate.Metadata.BuildFinishedOn = builtAt
+	if err := generator.Save(prov, in, signer); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+}
+
+func TestFreshnessTrustsSignedProvenanceOnly(t *testing.T) {
+	planPath, keyPath, signer := signedPlan(t)
+	verify := func(requireSigningTime bool) *StepResult {
+		t.Helper()
+		report, _ := VerifyWithOptions(planPath, Options{
+			KeyPath:            keyPath,
+			MaxAge:             time.Hour,
+			RequireSigningTime: requireSigningTime,
+			PolicyDir:          t.TempDir(),
+		})
+		if step := report.Step(StepSignature); step == nil || step.Status != StatusPass {
+			t.Fatalf("signature step = %+v", step)
+		}
+		step := report.Step(StepFreshness)
+		if step == nil {
+			t.Fatal("freshness was not checked")
+		}
+		return step
+	}
+
+	// Anyone can write unsigned provenance claiming the plan is new
+	writeProvenance(t, planPath, time.Now(), nil)
+	if step := verify(false); step.Status != StatusWarn || !strings.Contains(step.Reason, "age unknown") {
+		t.Errorf("unsigned provenance: freshness = %s %q, want an unknown age warning", step.Status, step.Reason)
+	}
+	if step := verify(true); step.Status != StatusFail || !strings.Contains(step.Reason, "age unknown") {
+		t.Errorf("unsigned provenance with a signing time required: freshness = %s %q, want a failure", step.Status, step.Reason)
+	}
+
+	// Provenance signed by the plan's key dates the plan
+	writeProvenance(t, planPath, time.Now(), signer)
+	if step := verify(true); step.Status != StatusPass || step.Evidence["source"] != "provenance" {
+		t.Errorf("signed provenance: freshness = %s %q", step.Status, step.Reason)
+	}
+	writeProvenance(t, planPath, time.Now().Add(-2*time.Hour), signer)
+	if step := verify(true); step.Status != StatusFail || !strings.Contains(step.Reason, "stale") {
+		t.Errorf("signed provenance two hours old: freshness = %s %q, want stale", step.Status, step.Reason)
+	}
+}
@@ -41,7 +41,7 @@ ts-lockdown() {
     
     if [ "$mode" = "off" ]; then
         # For lockdown off, use default key path if not specified
-        if [[ ! "$@" =~ "--key" ]] && [[ ! "$@" =~ "--recovery-code" ]]; then
+        if [[ ! "$@" =~ "--key" ]] && [[ ! "$@" =~ "--share" ]]; then
             # Find project root by looking for go.mod
             local current_dir="$PWD"
             local project_root="$current_dir"